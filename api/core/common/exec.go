@@ -6,7 +6,13 @@ import (
 	"github.com/pkg/errors"
 )
 
+// ErrNoConn is returned when the statements are generated but there is no connection to run them
+var ErrNoConn = errors.New("no connection to execute the sql")
+
 func Exec(conn *sql.DB, sqls []string) error {
+	if conn == nil {
+		return ErrNoConn
+	}
 	for _, sq := range sqls {
 		if sq == "" {
 			continue
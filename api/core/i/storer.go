@@ -28,6 +28,8 @@ type StorerParams struct {
 	// storer
 	Fields string
 	TTL    int // ttl Data expiration time, unit is the day
+	// DeadLetter Whether to create the <table>_dlq table for messages that can not be parsed
+	DeadLetter bool
 
 	// switcher
 
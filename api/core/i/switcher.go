@@ -34,4 +34,9 @@ type SwitcherParams struct {
 	ParseWhere          string
 	IsRawLogFieldString bool
 	CustomTimeField     string
+	// DeadLetter Whether to route messages that can not be parsed to the <table>_dlq table
+	DeadLetter     bool
+	ParseTimeCheck string // condition that is true when the time field can be parsed
+	// SourceFormat envelope of the kafka messages, see constx.SourceFormatOTLP
+	SourceFormat string
 }
@@ -27,6 +27,7 @@ type Storer struct {
 	fields           string
 	ttl              int  // ttl Data expiration time, unit is the day
 	withAttachFields bool // withAttachFields Whether to include attachment fields, such as _key/headers
+	deadLetter       bool // deadLetter Whether to create the dead-letter table
 }
 
 func NewStorer(req i.StorerParams) *Storer {
@@ -40,6 +41,7 @@ func NewStorer(req i.StorerParams) *Storer {
 		ttl:        req.TTL,
 		conn:       req.Conn,
 		fields:     req.Fields,
+		deadLetter: req.DeadLetter,
 	}
 }
 
@@ -69,9 +71,51 @@ func (ch *Storer) createJSONAsString() (names []string, sqls []string) {
 	sqls = make([]string, 0)
 	common.AppendSQL(&names, &sqls, ch.mergeTreeTable)
 	common.AppendSQL(&names, &sqls, ch.distributedTable)
+	if ch.deadLetter {
+		common.AppendSQL(&names, &sqls, ch.deadLetterTable)
+		common.AppendSQL(&names, &sqls, ch.deadLetterDistributedTable)
+	}
 	return
 }
 
+func (ch *Storer) deadLetterTable() (name string, sql string) {
+	engine := "ENGINE = MergeTree"
+	tableName := fmt.Sprintf("`%s`.`%s_dlq`", ch.database, ch.table)
+	tableNameWithCluster := tableName
+	if ch.isReplica || ch.isShard {
+		tableName = fmt.Sprintf("`%s`.`%s_dlq_local`", ch.database, ch.table)
+		tableNameWithCluster = fmt.Sprintf("%s on cluster '%s'", tableName, ch.cluster)
+		if ch.isReplica {
+			engine = fmt.Sprintf("ENGINE = ReplicatedMergeTree('/clickhouse/tables/%s.%s_dlq_local/{shard}', '{replica}')", ch.database, ch.table)
+		}
+	}
+	return tableName, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s
+(
+  _time_second_ DateTime,
+  _topic_ String,
+  _partition_ UInt64,
+  _offset_ UInt64,
+  _error_ String,
+  _raw_message_ String CODEC(ZSTD(1))
+)
+%s
+PARTITION BY toYYYYMMDD(_time_second_)
+ORDER BY _time_second_
+TTL toDateTime(_time_second_) + INTERVAL %d DAY
+SETTINGS index_granularity = 8192;
+`, tableNameWithCluster, engine, ch.ttl)
+}
+
+func (ch *Storer) deadLetterDistributedTable() (name string, sql string) {
+	if ch.isReplica || ch.isShard {
+		ddt := fmt.Sprintf("`%s`.`%s_dlq`", ch.database, ch.table)
+		mdt := fmt.Sprintf("`%s`.`%s_dlq_local`", ch.database, ch.table)
+		return ddt, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS  %s on cluster '%s' AS %s
+ENGINE = Distributed('%s', '%s', '%s_dlq_local', rand());`, ddt, ch.cluster, mdt, ch.cluster, ch.database, ch.table)
+	}
+	return "", ""
+}
+
 func (ch *Storer) mergeTreeTable() (name string, sql string) {
 	var engine string
 	var tableNameWithCluster string
@@ -33,6 +33,9 @@ type Switcher struct {
 	withAttachFields    bool // withAttachFields Whether to include attachment fields, such as _key/headers
 	isRawLogFieldString bool // isRawLogFieldJSON Whether the raw log field is JSON
 	customTimeField     string
+	deadLetter          bool   // deadLetter Whether to route messages that can not be parsed to the dead-letter table
+	parseTimeCheck      string // parseTimeCheck Condition that is true when the time field can be parsed
+	sourceFormat        string // sourceFormat Envelope of the kafka messages, see constx.SourceFormatOTLP
 }
 
 func NewSwitcher(req i.SwitcherParams) *Switcher {
@@ -52,6 +55,9 @@ func NewSwitcher(req i.SwitcherParams) *Switcher {
 		parseWhere:          req.ParseWhere,
 		isRawLogFieldString: req.IsRawLogFieldString,
 		customTimeField:     req.CustomTimeField,
+		deadLetter:          req.DeadLetter,
+		parseTimeCheck:      req.ParseTimeCheck,
+		sourceFormat:        req.SourceFormat,
 	}
 }
 
@@ -78,9 +84,69 @@ func (ch *Switcher) createJSONAsString() (names []string, sqls []string) {
 	names = make([]string, 0)
 	sqls = make([]string, 0)
 	common.AppendSQL(&names, &sqls, ch.materializedView)
+	if ch.deadLetter {
+		common.AppendSQL(&names, &sqls, ch.deadLetterView)
+	}
 	return
 }
 
+// parsable condition that is true when the message can be stored
+func (ch *Switcher) parsable() string {
+	if ch.parseTimeCheck == "" {
+		return "isValidJSON(_log)"
+	}
+	return fmt.Sprintf("isValidJSON(_log) AND %s", ch.parseTimeCheck)
+}
+
+// source the stream table, or a subquery that unnests the envelope into one _log per log record.
+// Messages without any record are kept as they are, so they still reach the dead-letter table.
+func (ch *Switcher) source(streamName string) string {
+	if ch.sourceFormat != constx.SourceFormatOTLP {
+		return streamName
+	}
+	// resource attributes [{"key":k,"value":{"stringValue":v}}] become {"resource":{k:v}} on every record
+	return fmt.Sprintf(`(
+  SELECT
+    _topic,
+    _partition,
+    _offset,
+    arrayJoin(if(empty(_records), [_message], _records)) AS _log
+  FROM
+  (
+    SELECT
+      _topic,
+      _partition,
+      _offset,
+      _log AS _message,
+      arrayFlatten(arrayMap(r -> arrayMap(l -> concat('{"resource":', toJSONString(mapFromArrays(arrayMap(a -> JSONExtractString(a, 'key'), JSONExtractArrayRaw(r, 'resource', 'attributes')), arrayMap(a -> JSONExtractString(a, 'value', 'stringValue'), JSONExtractArrayRaw(r, 'resource', 'attributes')))), if(length(l) > 2, ',', ''), substring(l, 2)), arrayFlatten(arrayMap(s -> JSONExtractArrayRaw(s, 'logRecords'), JSONExtractArrayRaw(r, 'scopeLogs')))), JSONExtractArrayRaw(_log, 'resourceLogs'))) AS _records
+    FROM %s
+  )
+)`, streamName)
+}
+
+func (ch *Switcher) deadLetterView() (name string, sql string) {
+	dataName := fmt.Sprintf("`%s`.`%s_dlq`", ch.database, ch.table)
+	streamName := fmt.Sprintf("`%s`.`%s_stream`", ch.database, ch.table)
+	viewName := fmt.Sprintf("`%s`.`%s_dlq_view`", ch.database, ch.table)
+	viewNameWithCluster := viewName
+	if ch.isReplica || ch.isShard {
+		dataName = fmt.Sprintf("`%s`.`%s_dlq_local`", ch.database, ch.table)
+		streamName = fmt.Sprintf("`%s`.`%s_local_stream`", ch.database, ch.table)
+		viewName = fmt.Sprintf("`%s`.`%s_dlq_local_view`", ch.database, ch.table)
+		viewNameWithCluster = fmt.Sprintf("%s on cluster '%s'", viewName, ch.cluster)
+	}
+	return viewName, fmt.Sprintf(`CREATE MATERIALIZED VIEW IF NOT EXISTS %s TO %s AS
+SELECT
+now() AS _time_second_,
+_topic AS _topic_,
+_partition AS _partition_,
+_offset AS _offset_,
+multiIf(NOT isValidJSON(_log), 'invalid json', 'invalid time field') AS _error_,
+_log AS _raw_message_
+FROM %s WHERE NOT (%s);
+`, viewNameWithCluster, dataName, ch.source(streamName), ch.parsable())
+}
+
 func (ch *Switcher) materializedView() (name string, sql string) {
 	var dataName string
 	var streamName string
@@ -113,10 +179,14 @@ func (ch *Switcher) materializedView() (name string, sql string) {
     JSONLength(JSONExtractString(%s, '%s')) as len
   FROM %s 
 )
-WHERE len>0 and`, l, ch.rawLogField, streamName)
+WHERE len>0 and`, l, ch.rawLogField, ch.source(streamName))
 
 	if ch.isRawLogFieldString {
-		rawLogFieldCheck = fmt.Sprintf("FROM %s WHERE", streamName)
+		rawLogFieldCheck = fmt.Sprintf("FROM %s WHERE", ch.source(streamName))
+	}
+	parseWhere := ch.parseWhere
+	if ch.deadLetter {
+		parseWhere = fmt.Sprintf("%s AND %s", parseWhere, ch.parsable())
 	}
 
 	if ch.withAttachFields {
@@ -130,7 +200,7 @@ JSONExtractString(%s, '%s') AS _raw_log_%s
 %s %s;
 `, viewNameWithCluster, dataName, ch.parseFields, ch.parseTime,
 			"`_headers.name` AS `_headers_name`,\n`_headers.value` AS `_headers_name`",
-			l, ch.rawLogField, ch.parseIndexes, rawLogFieldCheck, ch.parseWhere)
+			l, ch.rawLogField, ch.parseIndexes, rawLogFieldCheck, parseWhere)
 	}
 	return viewName, fmt.Sprintf(`CREATE MATERIALIZED VIEW IF NOT EXISTS %s TO %s AS
 SELECT
@@ -141,7 +211,7 @@ JSONExtractString(%s, '%s') AS _raw_log_%s
 `, viewNameWithCluster, dataName, ch.parseFields, ch.parseTime,
 		l, ch.rawLogField, ch.parseIndexes,
 		rawLogFieldCheck,
-		ch.parseWhere)
+		parseWhere)
 }
 
 func (ch *Switcher) Delete() error {
@@ -153,12 +223,14 @@ func (ch *Switcher) Delete() error {
 		} else {
 			sqls = append(sqls, fmt.Sprintf("DROP TABLE IF EXISTS `%s`.`%s_local_view` ON CLUSTER %s", ch.database, ch.table, ch.cluster))
 		}
+		sqls = append(sqls, fmt.Sprintf("DROP TABLE IF EXISTS `%s`.`%s_dlq_local_view` ON CLUSTER %s", ch.database, ch.table, ch.cluster))
 	} else {
 		if ch.customTimeField != "" {
 			sqls = append(sqls, fmt.Sprintf("DROP TABLE IF EXISTS `%s`.`%s_%s_local_view` ON CLUSTER %s", ch.database, ch.table, ch.customTimeField, ch.cluster))
 		} else {
 			sqls = append(sqls, fmt.Sprintf("DROP TABLE IF EXISTS `%s`.`%s_view`", ch.database, ch.table))
 		}
+		sqls = append(sqls, fmt.Sprintf("DROP TABLE IF EXISTS `%s`.`%s_dlq_view`", ch.database, ch.table))
 	}
 	return common.Exec(ch.conn, sqls)
 }
@@ -5,7 +5,6 @@ import (
 	"strings"
 
 	"github.com/ego-component/egorm"
-	"github.com/google/uuid"
 	"github.com/gotomicro/ego/core/elog"
 	"github.com/spf13/cast"
 
@@ -15,8 +14,8 @@ import (
 	db2 "github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
 	view2 "github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
 	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/alarm/pusher"
 	"github.com/clickvisual/clickvisual/api/internal/service/event"
-	"github.com/clickvisual/clickvisual/api/internal/service/inquiry/factory"
 	"github.com/clickvisual/clickvisual/api/internal/service/permission"
 	"github.com/clickvisual/clickvisual/api/internal/service/permission/pmsplugin"
 )
@@ -30,6 +29,10 @@ func Create(c *core.Context) {
 		c.JSONE(1, "invalid parameter", err)
 		return
 	}
+	if err := pusher.TemplateValidate(req.Template); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
 	for _, f := range req.Filters {
 		tableInfo, err := db2.TableInfo(invoker.Db, f.Tid)
 		if err != nil {
@@ -49,37 +52,8 @@ func Create(c *core.Context) {
 			return
 		}
 	}
-	tx := invoker.Db.Begin()
-	tableIds := db2.Ints{}
-	for _, f := range req.Filters {
-		tableIds = append(tableIds, f.Tid)
-	}
-	obj := &db2.Alarm{
-		Uuid:             uuid.NewString(),
-		Name:             req.Name,
-		Desc:             req.Desc,
-		Interval:         req.Interval,
-		Unit:             req.Unit,
-		Tags:             req.Tags,
-		NoDataOp:         req.NoDataOp,
-		ChannelIds:       db2.Ints(req.ChannelIds),
-		Uid:              c.Uid(),
-		Level:            req.Level,
-		TableIds:         tableIds,
-		DutyOfficers:     db2.Ints(req.DutyOfficers),
-		IsDisableResolve: req.IsDisableResolve,
-	}
-	if err := db2.AlarmCreate(tx, obj); err != nil {
-		tx.Rollback()
-		c.JSONE(1, "alarm create failed 01", err)
-		return
-	}
-	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
-		c.JSONE(1, "alarm create failed 03", err)
-		return
-	}
-	if err := service.Alert.CreateOrUpdate(obj, req); err != nil {
+	obj, err := service.Alert.Create(c.Uid(), req)
+	if err != nil {
 		c.JSONE(1, err.Error(), err)
 		return
 	}
@@ -129,6 +103,9 @@ func Update(c *core.Context) {
 		// 删除告警规则
 		for _, ri := range relatedList {
 			instance := ri.Instance
+			if instance.AlertEvaluator == db2.AlertEvaluatorNative {
+				continue
+			}
 			if instance.RuleStoreType == db2.RuleStoreTypeK8sOperator {
 				clusterRuleGroup := db2.ClusterRuleGroup{}
 				if tmp, ok := clusterRuleGroups[instance.GetRuleStoreKey()]; ok {
@@ -139,7 +116,7 @@ func Update(c *core.Context) {
 					clusterRuleGroup.GroupName = alarmInfo.GetGroupName(instance.ID)
 				}
 				clusterRuleGroups[instance.GetRuleStoreKey()] = clusterRuleGroup
-			} else if instance.RuleStoreType == db2.RuleStoreTypeFile || instance.RuleStoreType == db2.RuleStoreTypeK8sConfigMap {
+			} else if instance.IsRuleStoreByRule() {
 				if err = service.Alert.DeletePrometheusRule(&ri.Instance, &alarmInfo); err != nil {
 					c.JSONE(core.CodeErr, "prometheus rule delete failed:"+err.Error(), err)
 					return
@@ -324,6 +301,11 @@ func Info(c *core.Context) {
 		c.JSONE(core.CodeErr, err.Error(), err)
 		return
 	}
+	instances := make(map[int]db2.BaseInstance, len(relatedList))
+	for _, ri := range relatedList {
+		instances[ri.Table.ID] = ri.Instance
+	}
+	ruleHealth := service.Alert.RuleHealth(&alarmInfo, filters, instances)
 	respAlarmFilters := make([]view2.RespAlarmInfoFilter, 0)
 	for _, filter := range filters {
 		conditionConds := egorm.Conds{}
@@ -337,6 +319,7 @@ func Info(c *core.Context) {
 			AlarmFilter: filter,
 			TableName:   filterTableInfo.Name,
 			Conditions:  conditions,
+			RuleHealth:  ruleHealth[filter.ID],
 		})
 	}
 	user, _ := db2.UserInfo(alarmInfo.Uid)
@@ -410,81 +393,16 @@ func Delete(c *core.Context) {
 			return
 		}
 	}
-	tx := invoker.Db.Begin()
-	if err = db2.AlarmDelete(tx, id); err != nil {
-		c.JSONE(1, err.Error(), err)
-		return
-	}
-	// filter
-	if err = db2.AlarmFilterDeleteBatch(tx, id); err != nil {
-		tx.Rollback()
-		c.JSONE(1, err.Error(), err)
+	composites, err := service.CompositesOfAlarm(id)
+	if err != nil {
+		c.JSONE(core.CodeErr, err.Error(), err)
 		return
 	}
-	// condition
-	if err = db2.AlarmConditionDeleteBatch(tx, id); err != nil {
-		tx.Rollback()
-		c.JSONE(1, err.Error(), err)
+	if len(composites) > 0 {
+		c.JSONE(1, "used by composite alarm "+composites[0].Name, nil)
 		return
 	}
-	clusterRuleGroups := map[string]db2.ClusterRuleGroup{}
-	for _, ri := range relatedList {
-		instance := ri.Instance
-		if instance.RuleStoreType == db2.RuleStoreTypeK8sOperator {
-			clusterRuleGroup := db2.ClusterRuleGroup{}
-			if tmp, ok := clusterRuleGroups[instance.GetRuleStoreKey()]; ok {
-				clusterRuleGroup = tmp
-			} else {
-				clusterRuleGroup.ClusterId = instance.K8sClusterId
-				clusterRuleGroup.Instance = instance
-				clusterRuleGroup.GroupName = alarmInfo.GetGroupName(instance.ID)
-			}
-			clusterRuleGroups[instance.GetRuleStoreKey()] = clusterRuleGroup
-		} else if instance.RuleStoreType == db2.RuleStoreTypeFile || instance.RuleStoreType == db2.RuleStoreTypeK8sConfigMap {
-			_ = service.Alert.DeletePrometheusRule(&ri.Instance, &alarmInfo)
-		}
-		var op factory.Operator
-		op, err = service.InstanceManager.Load(ri.Table.Database.Iid)
-		if err != nil {
-			tx.Rollback()
-			c.JSONE(core.CodeErr, err.Error(), err)
-			return
-		}
-		if len(alarmInfo.ViewDDLs) > 0 {
-			for iidTable := range alarmInfo.ViewDDLs {
-				table := iidTable
-				iidTableArr := strings.Split(iidTable, "|")
-				if len(iidTableArr) == 2 {
-					table = iidTableArr[1]
-					iid, _ := strconv.Atoi(iidTableArr[0])
-					op, err = service.InstanceManager.Load(iid)
-					if err != nil {
-						tx.Rollback()
-						c.JSONE(core.CodeErr, err.Error(), err)
-						return
-					}
-					if iid != ri.Table.Database.Iid {
-						continue
-					}
-				}
-				if err = op.DeleteAlertView(table, ri.Table.Database.Cluster); err != nil {
-					tx.Rollback()
-					c.JSONE(core.CodeErr, err.Error(), err)
-					return
-				}
-			}
-		} else {
-			if err = op.DeleteAlertView(alarmInfo.ViewTableName, ri.Table.Database.Cluster); err != nil {
-				tx.Rollback()
-				c.JSONE(core.CodeErr, err.Error(), err)
-				return
-			}
-		}
-	}
-	if len(clusterRuleGroups) > 0 {
-		_ = service.Alert.PrometheusRuleBatchRemove(clusterRuleGroups)
-	}
-	if err = tx.Commit().Error; err != nil {
+	if err = service.Alert.Delete(id); err != nil {
 		c.JSONE(core.CodeErr, err.Error(), err)
 		return
 	}
@@ -506,6 +424,12 @@ func HistoryList(c *core.Context) {
 	if req.AlarmId != 0 {
 		conds["alarm_id"] = req.AlarmId
 	}
+	if req.CompositeId != 0 {
+		conds["composite_id"] = req.CompositeId
+	}
+	if req.WatchdogId != 0 {
+		conds["watchdog_id"] = req.WatchdogId
+	}
 	if req.StartTime != 0 {
 		conds["ctime"] = egorm.Cond{Op: ">", Val: req.StartTime}
 	}
@@ -9,6 +9,7 @@ import (
 	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
 	db2 "github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
 	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/alarm/pusher"
 	"github.com/clickvisual/clickvisual/api/internal/service/event"
 )
 
@@ -16,21 +17,28 @@ import (
 // @Tags         ALARM
 // @Summary	     告警渠道创建
 func ChannelCreate(c *core.Context) {
-	var req db2.AlarmChannel
-	if err := c.Bind(&req); err != nil {
+	var params db2.ReqAlarmChannel
+	if err := c.Bind(&params); err != nil {
 		c.JSONE(1, "invalid parameter: "+err.Error(), err)
 		return
 	}
+	req := params.AlarmChannel
+	req.CallbackSecret = params.CallbackSecret
 	req.Uid = c.Uid()
 	if err := req.JudgmentType(); err != nil {
 		c.JSONE(1, err.Error(), err)
 		return
 	}
+	if err := pusher.TemplateValidate(req.Template); err != nil {
+		c.JSONE(1, err.Error(), err)
+		return
+	}
 	err := db2.AlarmChannelCreate(invoker.Db, &req)
 	if err != nil {
 		c.JSONE(1, "create failed: "+err.Error(), err)
 		return
 	}
+	req.RedactKey()
 	event.Event.AlarmCMDB(c.User(), db2.OpnAlarmsChannelsCreate, map[string]interface{}{"req": req})
 	c.JSONOK()
 }
@@ -44,12 +52,30 @@ func ChannelUpdate(c *core.Context) {
 		c.JSONE(1, "invalid parameter", nil)
 		return
 	}
-	var req db2.AlarmChannel
-	if err := c.Bind(&req); err != nil {
+	var params db2.ReqAlarmChannel
+	if err := c.Bind(&params); err != nil {
 		c.JSONE(1, "invalid parameter: "+err.Error(), err)
 		return
 	}
-	if err := req.JudgmentType(); err != nil {
+	cur, err := db2.AlarmChannelInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "update failed: "+err.Error(), err)
+		return
+	}
+	req := params.AlarmChannel
+	req.CallbackSecret = params.CallbackSecret
+	if req.CallbackSecret == "" {
+		req.CallbackSecret = cur.CallbackSecret
+	}
+	if err = req.UnredactKey(&cur); err != nil {
+		c.JSONE(1, err.Error(), err)
+		return
+	}
+	if err = req.JudgmentType(); err != nil {
+		c.JSONE(1, err.Error(), err)
+		return
+	}
+	if err := pusher.TemplateValidate(req.Template); err != nil {
 		c.JSONE(1, err.Error(), err)
 		return
 	}
@@ -57,11 +83,20 @@ func ChannelUpdate(c *core.Context) {
 	ups["name"] = req.Name
 	ups["typ"] = req.Typ
 	ups["key"] = req.Key
+	ups["group_by"] = req.GroupBy
+	ups["group_wait"] = req.GroupWait
+	ups["group_interval"] = req.GroupInterval
+	ups["repeat_interval"] = req.RepeatInterval
+	ups["rate_limit"] = req.RateLimit
+	ups["template"] = req.Template
+	ups["chat_ops"] = req.ChatOps
+	ups["callback_secret"] = req.CallbackSecret
 	ups["uid"] = c.Uid()
 	if err := db2.AlarmChannelUpdate(invoker.Db, id, ups); err != nil {
 		c.JSONE(1, "update failed: "+err.Error(), err)
 		return
 	}
+	req.RedactKey()
 	event.Event.AlarmCMDB(c.User(), db2.OpnAlarmsChannelsUpdate, map[string]interface{}{"req": req})
 	c.JSONOK()
 }
@@ -75,6 +110,9 @@ func ChannelList(c *core.Context) {
 		c.JSONE(core.CodeErr, err.Error(), err)
 		return
 	}
+	for _, channel := range res {
+		channel.RedactKey()
+	}
 	c.JSONOK(res)
 }
 
@@ -92,6 +130,7 @@ func ChannelDelete(c *core.Context) {
 		c.JSONE(1, "failed to delete: "+err.Error(), err)
 		return
 	}
+	alarmInfo.RedactKey()
 	event.Event.AlarmCMDB(c.User(), db2.OpnAlarmsChannelsDelete, map[string]interface{}{"alarmInfo": alarmInfo})
 	c.JSONOK()
 }
@@ -110,6 +149,7 @@ func ChannelInfo(c *core.Context) {
 		c.JSONE(core.CodeErr, err.Error(), err)
 		return
 	}
+	res.RedactKey()
 	c.JSONOK(res)
 }
 
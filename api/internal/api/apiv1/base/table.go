@@ -299,10 +299,15 @@ func TableDelete(c *core.Context) {
 		c.JSONE(core.CodeErr, "delete failed 05", err)
 		return
 	}
-	if err = tx.Commit().Error; err != nil {
+	if err = db.WatchdogDeleteByTid(tx, tableInfo.ID); err != nil {
+		tx.Rollback()
 		c.JSONE(core.CodeErr, "delete failed 06", err)
 		return
 	}
+	if err = tx.Commit().Error; err != nil {
+		c.JSONE(core.CodeErr, "delete failed 07", err)
+		return
+	}
 	if tableInfo.CreateType != constx.TableCreateTypeExist && tableInfo.CreateType != constx.TableCreateTypeBufferNullDataPipe {
 		table := tableInfo.Name
 		iid := tableInfo.Database.Iid
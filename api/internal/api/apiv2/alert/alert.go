@@ -15,6 +15,7 @@ import (
 	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
 	"github.com/clickvisual/clickvisual/api/internal/service"
 	"github.com/clickvisual/clickvisual/api/internal/service/alarm/alertcomponent"
+	"github.com/clickvisual/clickvisual/api/internal/service/alarm/rule"
 	"github.com/clickvisual/clickvisual/api/internal/service/event"
 	"github.com/clickvisual/clickvisual/api/internal/service/permission"
 	"github.com/clickvisual/clickvisual/api/internal/service/permission/pmsplugin"
@@ -50,7 +51,8 @@ func SettingUpdate(c *core.Context) {
 		c.JSONE(1, "permission verification failed", err)
 		return
 	}
-	if current.RuleStoreType != 0 && current.RuleStoreType != req.RuleStoreType {
+	isRuleStoreChanged := req.AlertEvaluator == db2.AlertEvaluatorPrometheus && current.RuleStoreType != 0 && current.RuleStoreType != req.RuleStoreType
+	if current.AlertEvaluator != req.AlertEvaluator || isRuleStoreChanged {
 		// Detect whether there is an alarm under the current condition
 		errCheck := service.Alert.IsAllClosed(iid)
 		if errCheck != nil {
@@ -58,7 +60,32 @@ func SettingUpdate(c *core.Context) {
 			return
 		}
 	}
+	// the secret is not returned by the setting info, an empty one keeps the current secret
+	webhookSecret := strings.TrimSpace(req.WebhookSecret)
+	if webhookSecret == "" && req.WebhookAuth == current.WebhookAuth {
+		webhookSecret = current.WebhookSecret
+	}
+	if err = service.WebhookSettingValidate(req.WebhookAuth, webhookSecret, req.WebhookAllowIPs); err != nil {
+		c.JSONE(1, err.Error(), err)
+		return
+	}
+	// kept out of the event
+	req.WebhookSecret = ""
 	ups := make(map[string]interface{}, 0)
+	ups["alert_evaluator"] = req.AlertEvaluator
+	ups["webhook_auth"] = req.WebhookAuth
+	ups["webhook_secret"] = webhookSecret
+	ups["webhook_allow_ips"] = req.WebhookAllowIPs
+	if req.AlertEvaluator == db2.AlertEvaluatorNative {
+		// alarms are evaluated by clickvisual itself, prometheus is not required
+		if err = db2.InstanceUpdate(invoker.Db, iid, ups); err != nil {
+			c.JSONE(1, err.Error(), err)
+			return
+		}
+		event.Event.InquiryCMDB(c.User(), db2.OpnInstancesUpdate, map[string]interface{}{"req": req})
+		c.JSONOK()
+		return
+	}
 	ups["rule_store_type"] = req.RuleStoreType
 	switch req.RuleStoreType {
 	case db2.RuleStoreTypeFile:
@@ -80,6 +107,20 @@ func SettingUpdate(c *core.Context) {
 		}
 		ups["cluster_id"] = req.ClusterId
 		ups["config_prometheus_operator"] = req.ConfigPrometheusOperator
+	case db2.RuleStoreTypeRuler:
+		rulerUrl := strings.TrimSuffix(strings.TrimSpace(req.RulerUrl), "/")
+		if err = rule.RulerCheck(&rule.Params{
+			InstanceID:     iid,
+			RulerUrl:       rulerUrl,
+			RulerNamespace: req.RulerNamespace,
+			RulerTenant:    req.RulerTenant,
+		}); err != nil {
+			c.JSONE(1, "ruler check failed: "+err.Error(), err)
+			return
+		}
+		ups["ruler_url"] = rulerUrl
+		ups["ruler_namespace"] = req.RulerNamespace
+		ups["ruler_tenant"] = req.RulerTenant
 	}
 
 	if req.RuleStoreType != 0 {
@@ -124,12 +165,21 @@ func SettingList(c *core.Context) {
 		row := db2.RespAlertSettingListItem{
 			InstanceId:         instance.ID,
 			InstanceName:       instance.Name,
+			AlertEvaluator:     instance.AlertEvaluator,
 			RuleStoreType:      instance.RuleStoreType,
 			PrometheusTarget:   instance.PrometheusTarget,
 			IsAlertManagerOK:   1,
 			IsPrometheusOK:     1,
 			IsMetricsSamplesOk: 1,
 		}
+		if instance.AlertEvaluator == db2.AlertEvaluatorNative {
+			// prometheus, alertmanager and metrics.samples are not used by the native evaluator
+			row.IsPrometheusOK = 3
+			row.IsAlertManagerOK = 3
+			row.IsMetricsSamplesOk = 3
+			res = append(res, &row)
+			continue
+		}
 		if tmp, ok := checkHistory[instance.PrometheusTarget]; ok {
 			row.IsPrometheusOK = tmp.IsPrometheusOK
 			row.CheckPrometheusResult = tmp.CheckPrometheusResult
@@ -197,6 +247,7 @@ func SettingInfo(c *core.Context) {
 	c.JSONOK(&db2.RespAlertSettingInfo{
 		InstanceId: iid,
 		ReqAlertSettingUpdate: db2.ReqAlertSettingUpdate{
+			AlertEvaluator:           res.AlertEvaluator,
 			RuleStoreType:            res.RuleStoreType,
 			PrometheusTarget:         res.PrometheusTarget,
 			FilePath:                 res.FilePath,
@@ -204,6 +255,11 @@ func SettingInfo(c *core.Context) {
 			Configmap:                res.K8sConfigmap,
 			ClusterId:                res.K8sClusterId,
 			ConfigPrometheusOperator: res.ConfigPrometheusOperator,
+			RulerUrl:                 res.RulerUrl,
+			RulerNamespace:           res.RulerNamespace,
+			RulerTenant:              res.RulerTenant,
+			WebhookAuth:              res.WebhookAuth,
+			WebhookAllowIPs:          res.WebhookAllowIPs,
 		},
 	})
 }
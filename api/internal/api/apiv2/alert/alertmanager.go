@@ -1,35 +1,54 @@
 package alert
 
 import (
+	"encoding/json"
+	"net/http"
 	"strings"
 
 	"github.com/gotomicro/cetus/l"
 
 	"github.com/gotomicro/ego/core/elog"
 
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
 	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
 	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
 	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/event"
 )
 
 // Webhook  godoc
 // @Summary      告警推送 Webhook
 // @Description {"version":"4","groupKey":"{}:{alertname=\\"e6e85281_6e22_4159_90e8_38943e75fb3f_194\\"}","status":"firing","receiver":"webhook","groupLabels":{"alertname":"e6e85281_6e22_4159_90e8_38943e75fb3f_194"},"commonLabels":{"alertname":"e6e85281_6e22_4159_90e8_38943e75fb3f_194","filterId":"194","severity":"warning","uuid":"e6e85281-6e22-4159-90e8-38943e75fb3f"},"commonAnnotations":{"description":" (当前值: 1)","summary":"告警 "},"externalURL":"http://duminxiangdeMacBook-Pro.local:9093","alerts":[{"labels":{"alertname":"e6e85281_6e22_4159_90e8_38943e75fb3f_194","filterId":"194","severity":"warning","uuid":"e6e85281-6e22-4159-90e8-38943e75fb3f"},"annotations":{"description":" (当前值: 1)","summary":"告警 "},"startsAt":"2022-11-07T09:23:17.6Z","endsAt":"0001-01-01T00:00:00Z"}]}
+// @Description  Calls are authenticated by the webhook setting of the instance of the alarm: bearer token, hmac signature of the body in X-Clickvisual-Signature: sha256=<hex>, ip allow-list of the peer or of X-Forwarded-For behind app.alertWebhookTrustedProxies
 // @Tags         ALARM
 // @Produce      json
 // @Param        req body db.Notification true "params"
 // @Success      200 {object} core.Res{}
+// @Failure      401 {object} core.Res{}
 // @Router       /api/v1/prometheus/alerts [post]
 func Webhook(c *core.Context) {
 	var notification db.Notification
-	err := c.Bind(&notification)
+	body, err := c.GetRawData()
+	if err == nil {
+		err = json.Unmarshal(body, &notification)
+	}
 	if err != nil {
 		elog.Error("Bind", elog.FieldMethod("Webhook"), elog.Any("notification", notification))
 		c.JSONE(1, "invalid parameter", err)
 		return
 	}
+	alarmUUID := strings.TrimSpace(notification.CommonLabels["uuid"])
+	clientIP := service.WebhookClientIP(c.Request.RemoteAddr, c.Request.Header)
+	instance, err := service.Alert.WebhookInstance(alarmUUID)
+	if err == nil {
+		err = service.WebhookVerify(instance, c.Request.Header, body, clientIP)
+	}
+	if err != nil {
+		webhookReject(c, instance, alarmUUID, clientIP, err)
+		return
+	}
 	elog.Info("alert", elog.FieldMethod("Webhook"), l.A("notification", notification))
-	err = service.Alert.HandlerAlertManager(strings.TrimSpace(notification.CommonLabels["uuid"]), strings.TrimSpace(notification.CommonLabels["filterId"]), notification)
+	err = service.Alert.HandlerAlertManager(alarmUUID, strings.TrimSpace(notification.CommonLabels["filterId"]), notification)
 	if err != nil {
 		elog.Error("HandlerAlertManager", elog.FieldMethod("Webhook"), elog.Any("notification", notification), l.E(err))
 		c.JSONE(1, "message send failed: "+err.Error(), err)
@@ -38,3 +57,38 @@ func Webhook(c *core.Context) {
 	elog.Debug("finish", elog.FieldMethod("Webhook"), elog.Any("notification", notification))
 	c.JSONOK()
 }
+
+// webhookReject records the rejected call of an instance, the alertmanager does not retry on 401.
+// The calls of unknown alarms are only logged, anyone can make them.
+func webhookReject(c *core.Context, instance *db.BaseInstance, alarmUUID, clientIP string, err error) {
+	elog.Warn("alert", elog.FieldMethod("Webhook"), elog.String("step", "rejected"), elog.String("ip", clientIP), elog.String("uuid", alarmUUID), elog.FieldErr(err))
+	c.Context.JSON(http.StatusUnauthorized, core.Res{Code: 1, Msg: "webhook authentication failed"})
+	if instance == nil {
+		return
+	}
+	event.Event.AlarmCMDB(&core.User{Username: "alertmanager"}, db.OpnAlarmsWebhookReject, map[string]interface{}{
+		"iid":       instance.ID,
+		"uuid":      alarmUUID,
+		"ip":        clientIP,
+		"userAgent": c.Request.UserAgent(),
+		"reason":    err.Error(),
+	})
+}
+
+// ChartImage  godoc
+// @Summary	     Chart of an alarm message
+// @Description  Png chart linked by the messages of the channels which can not upload images, the token is the secret so no login is needed.
+// @Tags         ALARM
+// @Produce      png
+// @Param        token path string true "chart token"
+// @Success      200 {file} binary
+// @Router       /api/v1/alert/charts/{token} [get]
+func ChartImage(c *core.Context) {
+	chart, err := db.AlarmChartInfoByToken(invoker.Db, c.Param("token"))
+	if err != nil {
+		c.JSONE(http.StatusNotFound, "chart not found", nil)
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.Data(http.StatusOK, "image/png", chart.Image)
+}
@@ -0,0 +1,30 @@
+package alert
+
+import (
+	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+)
+
+// Analytics  godoc
+// @Summary	     Alarm history analytics
+// @Description  Firing counts, mean time to acknowledge and resolve and flapping score per alarm and per team between st and et,
+// @Description  with the noisiest alarms. Only the alarms of the tables the user views are counted.
+// @Tags         ALARM
+// @Produce      json
+// @Param        req query view.ReqAlarmAnalytics true "params"
+// @Success      200 {object} core.Res{data=view.RespAlarmAnalytics}
+// @Router       /api/v2/alert/analytics [get]
+func Analytics(c *core.Context) {
+	var req view.ReqAlarmAnalytics
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	res, err := service.Alert.Analytics(c.Uid(), req)
+	if err != nil {
+		c.JSONE(1, "analytics failed: "+err.Error(), err)
+		return
+	}
+	c.JSONOK(res)
+}
@@ -0,0 +1,74 @@
+package alert
+
+import (
+	"strconv"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
+	db2 "github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission/pmsplugin"
+)
+
+// Backtest  godoc
+// @Summary	     Alarm backtest
+// @Description  Start evaluating the filters and conditions of an alarm at its interval between st and et in the background,
+// @Description  as the native evaluator does. Returns the job, polled until its result has the firing/resolved transitions
+// @Description  and how many notifications would have been sent, nothing is created.
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        req body view.ReqAlarmBacktest true "params"
+// @Success      200 {object} core.Res{data=view.RespAlarmBacktestJob}
+// @Router       /api/v2/alert/alarms/backtest [post]
+func Backtest(c *core.Context) {
+	var req view.ReqAlarmBacktest
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	for _, f := range req.Filters {
+		tableInfo, err := db2.TableInfo(invoker.Db, f.Tid)
+		if err != nil {
+			c.JSONE(1, "backtest failed 01: "+err.Error(), err)
+			return
+		}
+		if err = permission.Manager.CheckNormalPermission(view.ReqPermission{
+			UserId:      c.Uid(),
+			ObjectType:  pmsplugin.PrefixInstance,
+			ObjectIdx:   strconv.Itoa(tableInfo.Database.Iid),
+			SubResource: pmsplugin.Alarm,
+			Acts:        []string{pmsplugin.ActView},
+			DomainType:  pmsplugin.PrefixTable,
+			DomainId:    strconv.Itoa(tableInfo.ID),
+		}); err != nil {
+			c.JSONE(1, "permission verification failed", err)
+			return
+		}
+	}
+	res, err := service.Alert.BacktestStart(c.Uid(), req)
+	if err != nil {
+		c.JSONE(1, "backtest failed 02: "+err.Error(), err)
+		return
+	}
+	c.JSONOK(res)
+}
+
+// BacktestJob  godoc
+// @Summary	     Alarm backtest job
+// @Description  Progress of a backtest started by the user, with its result once the status is done
+// @Tags         ALARM
+// @Produce      json
+// @Param        job-id path string true "job id"
+// @Success      200 {object} core.Res{data=view.RespAlarmBacktestJob}
+// @Router       /api/v2/alert/alarms/backtest/{job-id} [get]
+func BacktestJob(c *core.Context) {
+	res, err := service.Alert.BacktestJob(c.Uid(), c.Param("job-id"))
+	if err != nil {
+		c.JSONE(1, "backtest failed: "+err.Error(), err)
+		return
+	}
+	c.JSONOK(res)
+}
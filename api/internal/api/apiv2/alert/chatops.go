@@ -0,0 +1,173 @@
+package alert
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/spf13/cast"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/alarm/pusher"
+	"github.com/clickvisual/clickvisual/api/internal/service/event"
+)
+
+// ChatOpsCallback  godoc
+// @Summary	     Chat-ops callback of feishu and slack
+// @Description  Request url of the card callbacks of the feishu app or of the interactivity of the slack app,
+// @Description  and the form posted by the confirm page of the dingding links.
+// @Description  The callbacks are verified with the callback secret of the channel, the reply is sent to the chat.
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        channel-id path int true "channel id"
+// @Success      200
+// @Router       /api/v1/alert/chatops/{channel-id} [post]
+func ChatOpsCallback(c *core.Context) {
+	channel, ok := chatOpsChannel(c)
+	if !ok {
+		return
+	}
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSONE(http.StatusBadRequest, "invalid body", err)
+		return
+	}
+	switch channel.Typ {
+	case db.ChannelFeiShu:
+		req, errParse := pusher.ParseFeiShuCallback(channel.CallbackSecret, c.Request.Header, body, time.Now())
+		if errParse != nil {
+			chatOpsUnauthorized(c, channel, errParse)
+			return
+		}
+		if req.Challenge != "" {
+			c.Context.JSON(http.StatusOK, gin.H{"challenge": req.Challenge})
+			return
+		}
+		chatOpsReply(channel, req)
+		c.Context.JSON(http.StatusOK, gin.H{})
+	case db.ChannelSlack:
+		req, errParse := pusher.ParseSlackCallback(channel.CallbackSecret, c.Request.Header, body)
+		if errParse != nil {
+			chatOpsUnauthorized(c, channel, errParse)
+			return
+		}
+		// the logs button opens its link and calls back too
+		if req.Action != "" {
+			chatOpsReply(channel, req)
+		}
+		c.Status(http.StatusOK)
+	case db.ChannelDingDing:
+		query, errParse := url.ParseQuery(string(body))
+		if errParse != nil {
+			c.JSONE(http.StatusBadRequest, "invalid body", errParse)
+			return
+		}
+		req, errParse := pusher.ParseDingDingCallback(channel.CallbackSecret, channel.ID, query, time.Now())
+		if errParse != nil {
+			chatOpsUnauthorized(c, channel, errParse)
+			return
+		}
+		c.String(http.StatusOK, chatOpsReply(channel, req))
+	default:
+		c.JSONE(http.StatusBadRequest, "chat-ops callbacks are only sent by feishu, slack and dingding", nil)
+	}
+}
+
+// ChatOpsLink  godoc
+// @Summary	     Chat-ops link of dingding
+// @Description  The buttons of dingding are links signed with the callback secret of the channel, they expire after a day.
+// @Description  The link opens a confirm page, the action is only taken by the POST of its form and each link is used once.
+// @Tags         ALARM
+// @Produce      html
+// @Param        channel-id path int true "channel id"
+// @Param        alarmId query int true "alarm id"
+// @Param        action query string true "ack or snooze"
+// @Param        exp query int true "expiry of the link"
+// @Param        sign query string true "signature of the link"
+// @Success      200 {string} string
+// @Router       /api/v1/alert/chatops/{channel-id} [get]
+func ChatOpsLink(c *core.Context) {
+	channel, ok := chatOpsChannel(c)
+	if !ok {
+		return
+	}
+	if channel.Typ != db.ChannelDingDing {
+		c.JSONE(http.StatusBadRequest, "chat-ops links are only sent to dingding", nil)
+		return
+	}
+	query := c.Request.URL.Query()
+	req, err := pusher.ParseDingDingCallback(channel.CallbackSecret, channel.ID, query, time.Now())
+	if err != nil {
+		chatOpsUnauthorized(c, channel, err)
+		return
+	}
+	title, text := "Acknowledge", fmt.Sprintf("Acknowledge the alarm #%d", req.AlarmId)
+	if req.Action == pusher.ChatOpsSnooze {
+		title, text = "Snooze", fmt.Sprintf("Snooze the alarm #%d for %s", req.AlarmId, pusher.ChatOpsSnoozeDuration)
+	}
+	renderConfirm(c, confirmPage{
+		Title:  title,
+		Text:   text,
+		Action: c.Request.URL.Path,
+		Fields: map[string]string{"alarmId": query.Get("alarmId"), "action": query.Get("action"), "exp": query.Get("exp"), "sign": query.Get("sign")},
+	})
+}
+
+// chatOpsUnauthorized the callers are not authenticated, the reason of the failure is only logged
+func chatOpsUnauthorized(c *core.Context, channel *db.AlarmChannel, err error) {
+	elog.Warn("chatOps", elog.String("step", "verify"), elog.Int("channelId", channel.ID), elog.FieldErr(err))
+	c.JSONE(http.StatusUnauthorized, "chat-ops callback verification failed", nil)
+}
+
+func chatOpsChannel(c *core.Context) (*db.AlarmChannel, bool) {
+	channel, err := db.AlarmChannelInfo(invoker.Db, cast.ToInt(c.Param("channel-id")))
+	if err != nil || !channel.IsChatOps() {
+		c.JSONE(http.StatusNotFound, "chat-ops channel not found", nil)
+		return nil, false
+	}
+	return &channel, true
+}
+
+// chatOpsReply applies the action and sends the reply to the chat, in the thread of the message for slack
+func chatOpsReply(channel *db.AlarmChannel, req pusher.ChatOpsRequest) string {
+	reply, err := service.Alert.ChatOps(channel, req)
+	if reply == "" {
+		reply = pusher.ChatOpsReply(fmt.Sprintf("#%d", req.AlarmId), req, err)
+	}
+	if err != nil {
+		elog.Error("chatOps", elog.Int("channelId", channel.ID), elog.Int("alarmId", req.AlarmId), elog.String("action", req.Action), elog.FieldErr(err))
+	} else {
+		op := db.OpnAlarmsAck
+		if req.Action == pusher.ChatOpsSnooze {
+			op = db.OpnAlarmsSilencesCreate
+		}
+		user, _ := db.UserInfo(channel.Uid)
+		event.Event.AlarmCMDB(&core.User{Uid: int64(user.ID), Nickname: user.Nickname, Username: user.Username}, op,
+			map[string]interface{}{"alarmId": req.AlarmId, "channelId": channel.ID, "chatUser": req.User})
+	}
+	if req.ResponseURL != "" {
+		err = pusher.ReplySlack(req, reply)
+	} else {
+		err = chatOpsSend(channel, reply)
+	}
+	if err != nil {
+		elog.Error("chatOps", elog.String("step", "reply"), elog.Int("channelId", channel.ID), elog.FieldErr(err))
+	}
+	return reply
+}
+
+// chatOpsSend the webhooks of feishu and dingding can not reply to a message, the reply is a new message
+func chatOpsSend(channel *db.AlarmChannel, reply string) error {
+	p, err := pusher.GetPusher(channel.Typ)
+	if err != nil {
+		return err
+	}
+	return p.Send(channel, &db.PushMsg{Title: reply, Text: reply})
+}
@@ -0,0 +1,106 @@
+package alert
+
+import (
+	"net/http"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
+	db2 "github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/event"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission"
+)
+
+// ExportAlarmCode  godoc
+// @Summary	     Alarm code export
+// @Description  Channels, alarms and the silences not ended as yaml, the keys and callback secrets of the channels are redacted.
+// @Description  Redacted values are kept as they are when the yaml is applied.
+// @Tags         ALARM
+// @Produce      application/x-yaml
+// @Success      200 {object} view.AlarmCode
+// @Router       /api/v2/alert/code [get]
+func ExportAlarmCode(c *core.Context) {
+	if err := permission.Manager.IsRootUser(c.Uid()); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	res, err := service.Alert.AlarmCodeExport()
+	if err != nil {
+		c.JSONE(1, "export failed 01: "+err.Error(), err)
+		return
+	}
+	out, err := yaml.Marshal(res)
+	if err != nil {
+		c.JSONE(1, "export failed 02: "+err.Error(), err)
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename=alarms.yaml")
+	c.Data(http.StatusOK, "application/x-yaml; charset=utf-8", out)
+}
+
+// PlanAlarmCode  godoc
+// @Summary	     Alarm code plan
+// @Description  Changes applying the yaml would make, nothing is changed.
+// @Description  Channels and alarms are matched by name, silences by all their fields, objects missing from the yaml are deleted with prune.
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        req body view.ReqAlarmCode true "params"
+// @Success      200 {object} core.Res{data=[]view.AlarmCodeChange}
+// @Router       /api/v2/alert/code/plan [post]
+func PlanAlarmCode(c *core.Context) {
+	code, prune, ok := alarmCodeReq(c)
+	if !ok {
+		return
+	}
+	res, err := service.Alert.AlarmCodePlan(code, prune)
+	if err != nil {
+		c.JSONE(1, "plan failed: "+err.Error(), err)
+		return
+	}
+	c.JSONOK(res)
+}
+
+// ApplyAlarmCode  godoc
+// @Summary	     Alarm code apply
+// @Description  Applies the changes of the plan in a transaction, the views and rules of the alarms are synced once it is committed.
+// @Description  A failed sync is resumed by applying the same yaml again.
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        req body view.ReqAlarmCode true "params"
+// @Success      200 {object} core.Res{data=[]view.AlarmCodeChange}
+// @Router       /api/v2/alert/code/apply [post]
+func ApplyAlarmCode(c *core.Context) {
+	code, prune, ok := alarmCodeReq(c)
+	if !ok {
+		return
+	}
+	res, err := service.Alert.AlarmCodeApply(c.Uid(), code, prune)
+	event.Event.AlarmCMDB(c.User(), db2.OpnAlarmsCodeApply, map[string]interface{}{"prune": prune, "changes": res})
+	if err != nil {
+		c.JSONE(1, "apply failed: "+err.Error(), res)
+		return
+	}
+	c.JSONOK(res)
+}
+
+func alarmCodeReq(c *core.Context) (code view.AlarmCode, prune bool, ok bool) {
+	if err := permission.Manager.IsRootUser(c.Uid()); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	var req view.ReqAlarmCode
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	code, err := service.ParseAlarmCode(req.Content)
+	if err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	return code, req.Prune, true
+}
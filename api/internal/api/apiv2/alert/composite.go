@@ -0,0 +1,225 @@
+package alert
+
+import (
+	"github.com/ego-component/egorm"
+	"github.com/spf13/cast"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
+	db2 "github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/event"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission/pmsplugin"
+)
+
+// ListComposite  godoc
+// @Summary	     Composite alarm list
+// @Description  The composite alarms created by the user or whose alarms the user can all view
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Success      200 {object} core.Res{data=[]db.AlarmComposite}
+// @Router       /api/v2/alert/composites [get]
+func ListComposite(c *core.Context) {
+	list, err := db2.AlarmCompositeList(invoker.Db, egorm.Conds{})
+	if err != nil {
+		c.JSONE(core.CodeErr, err.Error(), err)
+		return
+	}
+	res := make([]*db2.AlarmComposite, 0, len(list))
+	for _, composite := range list {
+		if compositeViewPermission(c.Uid(), composite) == nil {
+			res = append(res, composite)
+		}
+	}
+	c.JSONOK(res)
+}
+
+// InfoComposite  godoc
+// @Summary	     Composite alarm detail
+// @Description  The composite alarm with the current states of the alarms of its expression
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        composite-id path int true "composite alarm id"
+// @Success      200 {object} core.Res{data=view.RespAlarmCompositeInfo}
+// @Router       /api/v2/alert/composites/{composite-id} [get]
+func InfoComposite(c *core.Context) {
+	id := cast.ToInt(c.Param("composite-id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	res, err := service.CompositeInfo(id)
+	if err != nil {
+		c.JSONE(core.CodeErr, err.Error(), err)
+		return
+	}
+	if err = compositeViewPermission(c.Uid(), res.AlarmComposite); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	c.JSONOK(res)
+}
+
+// CreateComposite  godoc
+// @Summary	     Composite alarm create
+// @Description  Fires when the expression over the firing states of the alarms holds for the for-duration, e.g. 12 && (15 || !20)
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        req body view.ReqAlarmCompositeCreate true "params"
+// @Success      200 {object} core.Res{data=db.AlarmComposite}
+// @Router       /api/v2/alert/composites [post]
+func CreateComposite(c *core.Context) {
+	var req view.ReqAlarmCompositeCreate
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	if err := compositeValidate(c.Uid(), req); err != nil {
+		c.JSONE(1, err.Error(), err)
+		return
+	}
+	obj := &db2.AlarmComposite{
+		Name:       req.Name,
+		Desc:       req.Desc,
+		Expr:       req.Expr,
+		For:        req.For,
+		ChannelIds: req.ChannelIds,
+		Status:     compositeStatus(req.Status),
+		Uid:        c.Uid(),
+	}
+	if err := db2.AlarmCompositeCreate(invoker.Db, obj); err != nil {
+		c.JSONE(1, "create failed: "+err.Error(), err)
+		return
+	}
+	service.Compositor.Trigger()
+	event.Event.AlarmCMDB(c.User(), db2.OpnAlarmsCompositesCreate, map[string]interface{}{"req": req})
+	c.JSONOK(obj)
+}
+
+// UpdateComposite  godoc
+// @Summary	     Composite alarm update
+// @Description  A closed composite alarm is not evaluated, a firing one is closed without resolve message
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        composite-id path int true "composite alarm id"
+// @Param        req body view.ReqAlarmCompositeCreate true "params"
+// @Success      200 {object} core.Res{}
+// @Router       /api/v2/alert/composites/{composite-id} [patch]
+func UpdateComposite(c *core.Context) {
+	id := cast.ToInt(c.Param("composite-id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	var req view.ReqAlarmCompositeCreate
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	current, err := db2.AlarmCompositeInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "update failed 01: "+err.Error(), err)
+		return
+	}
+	if err = ownerPermission(c.Uid(), current.Uid); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	if err = compositeValidate(c.Uid(), req); err != nil {
+		c.JSONE(1, err.Error(), err)
+		return
+	}
+	ups := make(map[string]interface{}, 0)
+	ups["name"] = req.Name
+	ups["desc"] = req.Desc
+	ups["expr"] = req.Expr
+	ups["for_duration"] = req.For
+	ups["channel_ids"] = req.ChannelIds
+	if status := compositeStatus(req.Status); status == db2.AlarmStatusClose {
+		ups["status"], ups["pending_at"] = status, 0
+	} else if current.Status == db2.AlarmStatusClose {
+		ups["status"] = status
+	}
+	if err = db2.AlarmCompositeUpdate(invoker.Db, id, ups); err != nil {
+		c.JSONE(1, "update failed 02: "+err.Error(), err)
+		return
+	}
+	service.Compositor.Trigger()
+	event.Event.AlarmCMDB(c.User(), db2.OpnAlarmsCompositesUpdate, map[string]interface{}{"req": req})
+	c.JSONOK()
+}
+
+// DeleteComposite  godoc
+// @Summary	     Composite alarm delete
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        composite-id path int true "composite alarm id"
+// @Success      200 {object} core.Res{}
+// @Router       /api/v2/alert/composites/{composite-id} [delete]
+func DeleteComposite(c *core.Context) {
+	id := cast.ToInt(c.Param("composite-id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	current, err := db2.AlarmCompositeInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "delete failed 01: "+err.Error(), err)
+		return
+	}
+	if err = ownerPermission(c.Uid(), current.Uid); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	if err = db2.AlarmCompositeDelete(invoker.Db, id); err != nil {
+		c.JSONE(1, "delete failed 02: "+err.Error(), err)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db2.OpnAlarmsCompositesDelete, map[string]interface{}{"composite": current})
+	c.JSONOK()
+}
+
+// compositeValidate the user must be able to view every alarm of the expression
+func compositeValidate(uid int, req view.ReqAlarmCompositeCreate) error {
+	ids, err := service.CompositeValidate(req)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err = silencePermission(uid, &db2.AlarmSilence{AlarmId: id}, pmsplugin.ActView); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compositeViewPermission the creator and root users view the composite alarm, the others must be able to view all its alarms
+func compositeViewPermission(uid int, composite *db2.AlarmComposite) error {
+	if ownerPermission(uid, composite.Uid) == nil {
+		return nil
+	}
+	ids, err := service.CompositeAlarmIds(composite.Expr)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err = silencePermission(uid, &db2.AlarmSilence{AlarmId: id}, pmsplugin.ActView); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compositeStatus 1 closes the composite alarm, it is opened otherwise
+func compositeStatus(status int) int {
+	if status == db2.AlarmStatusClose {
+		return db2.AlarmStatusClose
+	}
+	return db2.AlarmStatusNormal
+}
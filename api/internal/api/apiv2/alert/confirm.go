@@ -0,0 +1,42 @@
+package alert
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
+)
+
+// confirmTpl page of the links in the notifications, the action is only taken by the POST of its form
+// so that link previews and prefetching of the chat tools do not change anything
+var confirmTpl = template.Must(template.New("confirm").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><title>{{.Title}}</title></head>
+<body>
+<h3>{{.Title}}</h3>
+<p>{{.Text}}</p>
+<form method="post" action="{{.Action}}">
+{{- range $k, $v := .Fields}}
+<input type="hidden" name="{{$k}}" value="{{$v}}">
+{{- end}}
+<button type="submit">{{.Title}}</button>
+</form>
+</body>
+</html>`))
+
+type confirmPage struct {
+	Title  string
+	Text   string
+	Action string
+	Fields map[string]string
+}
+
+func renderConfirm(c *core.Context, page confirmPage) {
+	var buf bytes.Buffer
+	if err := confirmTpl.Execute(&buf, page); err != nil {
+		c.JSONE(1, "render failed: "+err.Error(), err)
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
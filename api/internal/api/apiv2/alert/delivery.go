@@ -0,0 +1,116 @@
+package alert
+
+import (
+	"github.com/ego-component/egorm"
+	"github.com/spf13/cast"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
+	db2 "github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/event"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission"
+)
+
+// ListDelivery  godoc
+// @Summary	     Alarm delivery list
+// @Description  Pushes to the alarm channels which failed at first, pending ones are retried with a backoff.
+// @Description  Root users see every delivery, the other users the ones of the channels they created.
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        req query view.ReqAlarmDeliveryList true "params"
+// @Success      200 {object} core.Res{data=[]db.AlarmDelivery}
+// @Router       /api/v2/alert/deliveries [get]
+func ListDelivery(c *core.Context) {
+	var req view.ReqAlarmDeliveryList
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	conds := egorm.Conds{}
+	if req.Status != 0 {
+		conds["status"] = req.Status
+	}
+	switch {
+	case req.ChannelId != 0:
+		if err := deliveryPermission(c.Uid(), req.ChannelId); err != nil {
+			c.JSONE(1, "permission verification failed", err)
+			return
+		}
+		conds["channel_id"] = req.ChannelId
+	case permission.Manager.IsRootUser(c.Uid()) != nil:
+		channelIds, err := deliveryChannelIds(c.Uid())
+		if err != nil {
+			c.JSONE(1, "list failed: "+err.Error(), err)
+			return
+		}
+		if len(channelIds) == 0 {
+			c.JSONPage([]*db2.AlarmDelivery{}, core.Pagination{Current: req.Current, PageSize: req.PageSize})
+			return
+		}
+		conds["channel_id"] = egorm.Cond{Op: "in", Val: channelIds}
+	}
+	total, list := db2.AlarmDeliveryPage(conds, &req.ReqPage)
+	c.JSONPage(list, core.Pagination{
+		Current:  req.Current,
+		PageSize: req.PageSize,
+		Total:    total,
+	})
+}
+
+// ResendDelivery  godoc
+// @Summary	     Alarm delivery resend
+// @Description  Pushes the pending or failed delivery at once, even when the circuit breaker of its channel is open.
+// @Description  Only root users and the creator of the channel resend it.
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        delivery-id path int true "delivery id"
+// @Success      200 {object} core.Res{}
+// @Router       /api/v2/alert/deliveries/{delivery-id}/resend [post]
+func ResendDelivery(c *core.Context) {
+	id := cast.ToInt(c.Param("delivery-id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	delivery, err := db2.AlarmDeliveryInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "resend failed 01: "+err.Error(), err)
+		return
+	}
+	if err = deliveryPermission(c.Uid(), delivery.ChannelId); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	if err = service.Deliverer.Resend(id); err != nil {
+		c.JSONE(1, "resend failed: "+err.Error(), err)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db2.OpnAlarmsDeliveriesResend, map[string]interface{}{"deliveryId": id})
+	c.JSONOK()
+}
+
+// deliveryChannelIds the channels created by the user
+func deliveryChannelIds(uid int) ([]int, error) {
+	channels, err := db2.AlarmChannelList(egorm.Conds{"uid": uid})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]int, 0, len(channels))
+	for _, channel := range channels {
+		res = append(res, channel.ID)
+	}
+	return res, nil
+}
+
+// deliveryPermission root users and the creator of the channel
+func deliveryPermission(uid, channelId int) error {
+	channel, err := db2.AlarmChannelInfo(invoker.Db, channelId)
+	if err != nil {
+		return err
+	}
+	return ownerPermission(uid, channel.Uid)
+}
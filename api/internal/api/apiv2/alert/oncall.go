@@ -0,0 +1,406 @@
+package alert
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ego-component/egorm"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
+	db2 "github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/event"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission/pmsplugin"
+)
+
+// ListOncall  godoc
+// @Summary	     On-call schedule list
+// @Description  Schedules with the user on call now
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Success      200 {object} core.Res{data=[]view.RespAlarmOncallItem}
+// @Router       /api/v2/alert/oncalls [get]
+func ListOncall(c *core.Context) {
+	oncalls, err := db2.AlarmOncallList(invoker.Db, egorm.Conds{})
+	if err != nil {
+		c.JSONE(core.CodeErr, err.Error(), err)
+		return
+	}
+	now := time.Now().Unix()
+	res := make([]view.RespAlarmOncallItem, 0, len(oncalls))
+	for _, o := range oncalls {
+		res = append(res, view.RespAlarmOncallItem{AlarmOncall: o, Current: o.Current(now)})
+	}
+	c.JSONOK(res)
+}
+
+// CreateOncall  godoc
+// @Summary	     On-call schedule create
+// @Description  Users take turns for a day or a week from the start time, overrides take a shift for another user
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        req body view.ReqAlarmOncallCreate true "params"
+// @Success      200 {object} core.Res{data=db.AlarmOncall}
+// @Router       /api/v2/alert/oncalls [post]
+func CreateOncall(c *core.Context) {
+	var req view.ReqAlarmOncallCreate
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	if err := service.OncallValidate(req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	if req.StartTime == 0 {
+		req.StartTime = time.Now().Unix()
+	}
+	obj := &db2.AlarmOncall{
+		Name:      req.Name,
+		Desc:      req.Desc,
+		Rotation:  req.Rotation,
+		Users:     req.Users,
+		StartTime: req.StartTime,
+		Overrides: req.Overrides,
+		Uid:       c.Uid(),
+	}
+	if err := db2.AlarmOncallCreate(invoker.Db, obj); err != nil {
+		c.JSONE(1, "create failed: "+err.Error(), err)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db2.OpnAlarmsOncallsCreate, map[string]interface{}{"req": req})
+	c.JSONOK(obj)
+}
+
+// UpdateOncall  godoc
+// @Summary	     On-call schedule update
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        oncall-id path int true "on-call schedule id"
+// @Param        req body view.ReqAlarmOncallCreate true "params"
+// @Success      200 {object} core.Res{}
+// @Router       /api/v2/alert/oncalls/{oncall-id} [patch]
+func UpdateOncall(c *core.Context) {
+	id := cast.ToInt(c.Param("oncall-id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	var req view.ReqAlarmOncallCreate
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	if err := service.OncallValidate(req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	current, err := db2.AlarmOncallInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "update failed 01: "+err.Error(), err)
+		return
+	}
+	if err = ownerPermission(c.Uid(), current.Uid); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	ups := make(map[string]interface{}, 0)
+	ups["name"] = req.Name
+	ups["desc"] = req.Desc
+	ups["rotation"] = req.Rotation
+	ups["users"] = db2.Ints(req.Users)
+	if req.StartTime != 0 {
+		ups["start_time"] = req.StartTime
+	}
+	ups["overrides"] = req.Overrides
+	if err = db2.AlarmOncallUpdate(invoker.Db, id, ups); err != nil {
+		c.JSONE(1, "update failed 02: "+err.Error(), err)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db2.OpnAlarmsOncallsUpdate, map[string]interface{}{"req": req})
+	c.JSONOK()
+}
+
+// DeleteOncall  godoc
+// @Summary	     On-call schedule delete
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        oncall-id path int true "on-call schedule id"
+// @Success      200 {object} core.Res{}
+// @Router       /api/v2/alert/oncalls/{oncall-id} [delete]
+func DeleteOncall(c *core.Context) {
+	id := cast.ToInt(c.Param("oncall-id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	current, err := db2.AlarmOncallInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "delete failed 01: "+err.Error(), err)
+		return
+	}
+	if err = ownerPermission(c.Uid(), current.Uid); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	escalations, err := db2.AlarmEscalationList(invoker.Db, egorm.Conds{})
+	if err != nil {
+		c.JSONE(1, "delete failed 02: "+err.Error(), err)
+		return
+	}
+	for _, e := range escalations {
+		for _, step := range e.Steps {
+			if step.OncallId == id {
+				c.JSONE(1, "delete failed 03: used by escalation policy "+e.Name, nil)
+				return
+			}
+		}
+	}
+	if err = db2.AlarmOncallDelete(invoker.Db, id); err != nil {
+		c.JSONE(1, "delete failed 04: "+err.Error(), err)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db2.OpnAlarmsOncallsDelete, map[string]interface{}{"oncall": current})
+	c.JSONOK()
+}
+
+// ListEscalation  godoc
+// @Summary	     Escalation policy list
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Success      200 {object} core.Res{data=[]db.AlarmEscalation}
+// @Router       /api/v2/alert/escalations [get]
+func ListEscalation(c *core.Context) {
+	res, err := db2.AlarmEscalationList(invoker.Db, egorm.Conds{})
+	if err != nil {
+		c.JSONE(core.CodeErr, err.Error(), err)
+		return
+	}
+	c.JSONOK(res)
+}
+
+// CreateEscalation  godoc
+// @Summary	     Escalation policy create
+// @Description  The first step is mentioned in the alarm message, the next ones are notified after their delay while the alarm is not acknowledged
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        req body view.ReqAlarmEscalationCreate true "params"
+// @Success      200 {object} core.Res{data=db.AlarmEscalation}
+// @Router       /api/v2/alert/escalations [post]
+func CreateEscalation(c *core.Context) {
+	var req view.ReqAlarmEscalationCreate
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	if err := service.EscalationValidate(req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	obj := &db2.AlarmEscalation{
+		Name:  req.Name,
+		Desc:  req.Desc,
+		Steps: req.Steps,
+		Uid:   c.Uid(),
+	}
+	if err := db2.AlarmEscalationCreate(invoker.Db, obj); err != nil {
+		c.JSONE(1, "create failed: "+err.Error(), err)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db2.OpnAlarmsEscalationsCreate, map[string]interface{}{"req": req})
+	c.JSONOK(obj)
+}
+
+// UpdateEscalation  godoc
+// @Summary	     Escalation policy update
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        escalation-id path int true "escalation policy id"
+// @Param        req body view.ReqAlarmEscalationCreate true "params"
+// @Success      200 {object} core.Res{}
+// @Router       /api/v2/alert/escalations/{escalation-id} [patch]
+func UpdateEscalation(c *core.Context) {
+	id := cast.ToInt(c.Param("escalation-id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	var req view.ReqAlarmEscalationCreate
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	if err := service.EscalationValidate(req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	current, err := db2.AlarmEscalationInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "update failed 01: "+err.Error(), err)
+		return
+	}
+	if err = ownerPermission(c.Uid(), current.Uid); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	ups := make(map[string]interface{}, 0)
+	ups["name"] = req.Name
+	ups["desc"] = req.Desc
+	ups["steps"] = req.Steps
+	if err = db2.AlarmEscalationUpdate(invoker.Db, id, ups); err != nil {
+		c.JSONE(1, "update failed 02: "+err.Error(), err)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db2.OpnAlarmsEscalationsUpdate, map[string]interface{}{"req": req})
+	c.JSONOK()
+}
+
+// DeleteEscalation  godoc
+// @Summary	     Escalation policy delete
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        escalation-id path int true "escalation policy id"
+// @Success      200 {object} core.Res{}
+// @Router       /api/v2/alert/escalations/{escalation-id} [delete]
+func DeleteEscalation(c *core.Context) {
+	id := cast.ToInt(c.Param("escalation-id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	current, err := db2.AlarmEscalationInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "delete failed 01: "+err.Error(), err)
+		return
+	}
+	if err = ownerPermission(c.Uid(), current.Uid); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	conds := egorm.Conds{}
+	conds["escalation_id"] = id
+	alarms, err := db2.AlarmList(conds)
+	if err != nil {
+		c.JSONE(1, "delete failed 02: "+err.Error(), err)
+		return
+	}
+	if len(alarms) > 0 {
+		c.JSONE(1, "delete failed 03: used by alarm "+alarms[0].Name, nil)
+		return
+	}
+	if err = db2.AlarmEscalationDelete(invoker.Db, id); err != nil {
+		c.JSONE(1, "delete failed 04: "+err.Error(), err)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db2.OpnAlarmsEscalationsDelete, map[string]interface{}{"escalation": current})
+	c.JSONOK()
+}
+
+// Ack  godoc
+// @Summary	     Alarm acknowledge
+// @Description  Acknowledge the firing alarm and stop its escalation
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        alarm-id path int true "alarm id"
+// @Success      200 {object} core.Res{}
+// @Router       /api/v2/alert/alarms/{alarm-id}/ack [post]
+func Ack(c *core.Context) {
+	alarmId := cast.ToInt(c.Param("alarm-id"))
+	if alarmId == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	if err := silencePermission(c.Uid(), &db2.AlarmSilence{AlarmId: alarmId}, pmsplugin.ActView); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	res, err := service.Alert.Ack(c.Uid(), alarmId)
+	if err != nil {
+		c.JSONE(1, "acknowledge failed: "+err.Error(), err)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db2.OpnAlarmsAck, map[string]interface{}{"alarmId": alarmId})
+	c.JSONOK(res)
+}
+
+// AckConfirm  godoc
+// @Summary	     Alarm acknowledge confirm
+// @Description  Page linked in the firing and escalation notifications, the alarm is only acknowledged by the POST of its form
+// @Tags         ALARM
+// @Produce      html
+// @Param        alarm-id path int true "alarm id"
+// @Success      200 {string} string
+// @Router       /api/v2/alert/alarms/{alarm-id}/ack [get]
+func AckConfirm(c *core.Context) {
+	alarmId := cast.ToInt(c.Param("alarm-id"))
+	if alarmId == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	if err := silencePermission(c.Uid(), &db2.AlarmSilence{AlarmId: alarmId}, pmsplugin.ActView); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	alarm, err := db2.AlarmInfo(invoker.Db, alarmId)
+	if err != nil {
+		c.JSONE(1, "alarm not found", err)
+		return
+	}
+	renderConfirm(c, confirmPage{
+		Title:  "Acknowledge",
+		Text:   fmt.Sprintf("Acknowledge the alarm %s and stop its escalation", alarm.Name),
+		Action: c.Request.URL.Path,
+	})
+}
+
+// Incident  godoc
+// @Summary	     Alarm incident
+// @Description  Current firing period of the alarm, with its acknowledgement and escalation step
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        alarm-id path int true "alarm id"
+// @Success      200 {object} core.Res{}
+// @Router       /api/v2/alert/alarms/{alarm-id}/incident [get]
+func Incident(c *core.Context) {
+	alarmId := cast.ToInt(c.Param("alarm-id"))
+	if alarmId == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	if err := silencePermission(c.Uid(), &db2.AlarmSilence{AlarmId: alarmId}, pmsplugin.ActView); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	res, err := service.Alert.Incident(alarmId)
+	if err != nil {
+		c.JSONE(core.CodeErr, err.Error(), err)
+		return
+	}
+	c.JSONOK(res)
+}
+
+// ownerPermission on-call schedules and escalation policies are changed by their creator or root users
+func ownerPermission(uid, ownerUid int) error {
+	if uid == ownerUid {
+		return nil
+	}
+	if err := permission.Manager.IsRootUser(uid); err != nil {
+		return errors.Wrap(err, "only the creator or root users")
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package alert
+
+import (
+	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission"
+)
+
+// ListRuleDrift  godoc
+// @Summary	     Prometheus rule drift
+// @Description  Rules of the open alarms missing from or different in the rule stores, and rules of the stores without open alarm.
+// @Description  The latest periodic check is returned unless refresh is 1.
+// @Tags         ALARM
+// @Produce      json
+// @Param        req query view.ReqRuleDriftList true "params"
+// @Success      200 {object} core.Res{data=[]view.RuleDrift}
+// @Router       /api/v2/alert/rules/drift [get]
+func ListRuleDrift(c *core.Context) {
+	if err := permission.Manager.IsRootUser(c.Uid()); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	var req view.ReqRuleDriftList
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	if req.Refresh != 1 {
+		c.JSONOK(service.RuleReconciler.Drifts(req.InstanceId))
+		return
+	}
+	res, err := service.RuleReconciler.Reconcile(req.InstanceId, false, c.User())
+	if err != nil {
+		c.JSONE(1, "rule drift check failed: "+err.Error(), err)
+		return
+	}
+	c.JSONOK(res)
+}
+
+// RepairRuleDrift  godoc
+// @Summary	     Prometheus rule drift repair
+// @Description  Writes the missing and changed rules and deletes the orphaned ones, each repair is recorded as an event.
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        req body view.ReqRuleDriftRepair true "params"
+// @Success      200 {object} core.Res{data=[]view.RuleDrift}
+// @Router       /api/v2/alert/rules/drift/repair [post]
+func RepairRuleDrift(c *core.Context) {
+	if err := permission.Manager.IsRootUser(c.Uid()); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	var req view.ReqRuleDriftRepair
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	res, err := service.RuleReconciler.Reconcile(req.InstanceId, true, c.User())
+	if err != nil {
+		c.JSONE(1, "rule drift repair failed: "+err.Error(), err)
+		return
+	}
+	c.JSONOK(res)
+}
@@ -0,0 +1,326 @@
+package alert
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ego-component/egorm"
+	"github.com/spf13/cast"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
+	db2 "github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/event"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission/pmsplugin"
+)
+
+// ListSilence  godoc
+// @Summary	     Alarm silence list
+// @Description  Silences and maintenance windows visible to the user
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        req query view.ReqAlarmSilenceList true "params"
+// @Success      200 {object} core.Res{data=[]view.RespAlarmSilenceItem}
+// @Router       /api/v2/alert/silences [get]
+func ListSilence(c *core.Context) {
+	var req view.ReqAlarmSilenceList
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	conds := egorm.Conds{}
+	if req.AlarmId != 0 {
+		conds["alarm_id"] = req.AlarmId
+	}
+	if req.Tid != 0 {
+		conds["tid"] = req.Tid
+	}
+	if req.Iid != 0 {
+		conds["iid"] = req.Iid
+	}
+	silences, err := db2.AlarmSilenceList(invoker.Db, conds)
+	if err != nil {
+		c.JSONE(core.CodeErr, err.Error(), err)
+		return
+	}
+	now := time.Now()
+	res := make([]view.RespAlarmSilenceItem, 0)
+	for _, s := range silences {
+		isActive := 0
+		if service.SilenceActive(s, now) {
+			isActive = 1
+		}
+		if req.IsActive == 1 && isActive == 0 {
+			continue
+		}
+		if silencePermission(c.Uid(), s, pmsplugin.ActView) != nil {
+			continue
+		}
+		if s.User != nil {
+			s.User.Password = "*"
+		}
+		res = append(res, view.RespAlarmSilenceItem{AlarmSilence: s, IsActive: isActive})
+	}
+	c.JSONOK(res)
+}
+
+// CreateSilence  godoc
+// @Summary	     Alarm silence create
+// @Description  Mute the notifications of the matched alarms during a time range, or in recurring maintenance windows when cron is set
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        req body view.ReqAlarmSilenceCreate true "params"
+// @Success      200 {object} core.Res{data=db.AlarmSilence}
+// @Router       /api/v2/alert/silences [post]
+func CreateSilence(c *core.Context) {
+	var req view.ReqAlarmSilenceCreate
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	if req.StartTime == 0 {
+		req.StartTime = time.Now().Unix()
+	}
+	if err := service.SilenceValidate(req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	obj := silenceFromReq(req)
+	obj.Uid = c.Uid()
+	if err := silencePermission(c.Uid(), obj, pmsplugin.ActEdit); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	if err := db2.AlarmSilenceCreate(invoker.Db, obj); err != nil {
+		c.JSONE(1, "create failed: "+err.Error(), err)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db2.OpnAlarmsSilencesCreate, map[string]interface{}{"req": req})
+	c.JSONOK(obj)
+}
+
+// UpdateSilence  godoc
+// @Summary	     Alarm silence update
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        silence-id path int true "silence id"
+// @Param        req body view.ReqAlarmSilenceCreate true "params"
+// @Success      200 {object} core.Res{}
+// @Router       /api/v2/alert/silences/{silence-id} [patch]
+func UpdateSilence(c *core.Context) {
+	id := cast.ToInt(c.Param("silence-id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	var req view.ReqAlarmSilenceCreate
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	if err := service.SilenceValidate(req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	current, err := db2.AlarmSilenceInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "update failed 01: "+err.Error(), err)
+		return
+	}
+	obj := silenceFromReq(req)
+	for _, s := range []*db2.AlarmSilence{&current, obj} {
+		if err = silencePermission(c.Uid(), s, pmsplugin.ActEdit); err != nil {
+			c.JSONE(1, "permission verification failed", err)
+			return
+		}
+	}
+	ups := make(map[string]interface{}, 0)
+	ups["alarm_id"] = obj.AlarmId
+	ups["tid"] = obj.Tid
+	ups["iid"] = obj.Iid
+	ups["matchers"] = obj.Matchers
+	ups["start_time"] = obj.StartTime
+	ups["end_time"] = obj.EndTime
+	ups["cron"] = obj.Cron
+	ups["duration"] = obj.Duration
+	ups["reason"] = obj.Reason
+	if err = db2.AlarmSilenceUpdate(invoker.Db, id, ups); err != nil {
+		c.JSONE(1, "update failed 02: "+err.Error(), err)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db2.OpnAlarmsSilencesUpdate, map[string]interface{}{"req": req})
+	c.JSONOK()
+}
+
+// DeleteSilence  godoc
+// @Summary	     Alarm silence delete
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        silence-id path int true "silence id"
+// @Success      200 {object} core.Res{}
+// @Router       /api/v2/alert/silences/{silence-id} [delete]
+func DeleteSilence(c *core.Context) {
+	id := cast.ToInt(c.Param("silence-id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	current, err := db2.AlarmSilenceInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "delete failed 01: "+err.Error(), err)
+		return
+	}
+	if err = silencePermission(c.Uid(), &current, pmsplugin.ActEdit); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	if err = db2.AlarmSilenceDelete(invoker.Db, id); err != nil {
+		c.JSONE(1, "delete failed 02: "+err.Error(), err)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db2.OpnAlarmsSilencesDelete, map[string]interface{}{"silence": current})
+	c.JSONOK()
+}
+
+// Snooze  godoc
+// @Summary	     Alarm snooze
+// @Description  Silence the alarm from now on for the duration
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        alarm-id path int true "alarm id"
+// @Param        req query view.ReqAlarmSnooze true "params"
+// @Success      200 {object} core.Res{data=db.AlarmSilence}
+// @Router       /api/v2/alert/alarms/{alarm-id}/snooze [post]
+func Snooze(c *core.Context) {
+	alarmId := cast.ToInt(c.Param("alarm-id"))
+	if alarmId == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	var req view.ReqAlarmSnooze
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	if err := silencePermission(c.Uid(), &db2.AlarmSilence{AlarmId: alarmId}, pmsplugin.ActEdit); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	res, err := service.Alert.Snooze(c.Uid(), alarmId, req)
+	if err != nil {
+		c.JSONE(1, "snooze failed: "+err.Error(), err)
+		return
+	}
+	event.Event.AlarmCMDB(c.User(), db2.OpnAlarmsSilencesCreate, map[string]interface{}{"alarmId": alarmId, "req": req})
+	c.JSONOK(res)
+}
+
+// SnoozeConfirm  godoc
+// @Summary	     Alarm snooze confirm
+// @Description  Page linked in the firing notification, the snooze is only created by the POST of its form
+// @Tags         ALARM
+// @Produce      html
+// @Param        alarm-id path int true "alarm id"
+// @Param        req query view.ReqAlarmSnooze true "params"
+// @Success      200 {string} string
+// @Router       /api/v2/alert/alarms/{alarm-id}/snooze [get]
+func SnoozeConfirm(c *core.Context) {
+	alarmId := cast.ToInt(c.Param("alarm-id"))
+	if alarmId == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	var req view.ReqAlarmSnooze
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	if err := silencePermission(c.Uid(), &db2.AlarmSilence{AlarmId: alarmId}, pmsplugin.ActEdit); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	alarm, err := db2.AlarmInfo(invoker.Db, alarmId)
+	if err != nil {
+		c.JSONE(1, "alarm not found", err)
+		return
+	}
+	if req.Duration == "" {
+		req.Duration = "1h"
+	}
+	renderConfirm(c, confirmPage{
+		Title:  "Snooze",
+		Text:   fmt.Sprintf("Silence the alarm %s for %s", alarm.Name, req.Duration),
+		Action: c.Request.URL.Path,
+		Fields: map[string]string{"duration": req.Duration},
+	})
+}
+
+func silenceFromReq(req view.ReqAlarmSilenceCreate) *db2.AlarmSilence {
+	return &db2.AlarmSilence{
+		AlarmId:   req.AlarmId,
+		Tid:       req.Tid,
+		Iid:       req.Iid,
+		Matchers:  req.Matchers,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		Cron:      req.Cron,
+		Duration:  req.Duration,
+		Reason:    req.Reason,
+	}
+}
+
+// silencePermission the alarm permission of every scope of the silence is required, silences matching only tags are for root users
+func silencePermission(uid int, s *db2.AlarmSilence, act string) error {
+	if s.AlarmId == 0 && s.Tid == 0 && s.Iid == 0 {
+		return permission.Manager.IsRootUser(uid)
+	}
+	tables := make([]db2.BaseTable, 0)
+	if s.AlarmId != 0 {
+		_, relatedList, err := db2.GetAlarmTableInstanceInfo(s.AlarmId)
+		if err != nil {
+			return err
+		}
+		for _, ri := range relatedList {
+			tables = append(tables, ri.Table)
+		}
+	}
+	if s.Tid != 0 {
+		table, err := db2.TableInfo(invoker.Db, s.Tid)
+		if err != nil {
+			return err
+		}
+		tables = append(tables, table)
+	}
+	for _, table := range tables {
+		if err := permission.Manager.CheckNormalPermission(view.ReqPermission{
+			UserId:      uid,
+			ObjectType:  pmsplugin.PrefixInstance,
+			ObjectIdx:   strconv.Itoa(table.Database.Iid),
+			SubResource: pmsplugin.Alarm,
+			Acts:        []string{act},
+			DomainType:  pmsplugin.PrefixTable,
+			DomainId:    strconv.Itoa(table.ID),
+		}); err != nil {
+			return err
+		}
+	}
+	if s.Iid != 0 {
+		return permission.Manager.CheckNormalPermission(view.ReqPermission{
+			UserId:      uid,
+			ObjectType:  pmsplugin.PrefixInstance,
+			ObjectIdx:   strconv.Itoa(s.Iid),
+			SubResource: pmsplugin.Alarm,
+			Acts:        []string{act},
+		})
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package alert
+
+import (
+	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
+	db2 "github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission/pmsplugin"
+)
+
+// PreviewTemplate  godoc
+// @Summary	     Notification template preview
+// @Description  Render a go template of the alarm messages against a firing notification of the alarm, or a sample one without alarm id.
+// @Description  The template named title renders the title, the fields are those of pusher.TemplateData.
+// @Tags         ALARM
+// @Accept       json
+// @Produce      json
+// @Param        req body view.ReqAlarmTemplatePreview true "params"
+// @Success      200 {object} core.Res{data=view.RespAlarmTemplatePreview}
+// @Router       /api/v2/alert/templates/preview [post]
+func PreviewTemplate(c *core.Context) {
+	var req view.ReqAlarmTemplatePreview
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	if req.AlarmId != 0 {
+		if err := silencePermission(c.Uid(), &db2.AlarmSilence{AlarmId: req.AlarmId}, pmsplugin.ActEdit); err != nil {
+			c.JSONE(1, "permission verification failed", err)
+			return
+		}
+	}
+	res, err := service.Alert.TemplatePreview(req)
+	if err != nil {
+		c.JSONE(1, "preview failed: "+err.Error(), err)
+		return
+	}
+	c.JSONOK(res)
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"strconv"
+
+	"github.com/spf13/cast"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
+	db2 "github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	view2 "github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/event"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission/pmsplugin"
+)
+
+// ListDeadLetter  godoc
+// @Summary	     iStorage dead-letter messages
+// @Description  iStorage messages that failed json or time parsing, newest first
+// @Tags         LOGSTORE
+// @Accept       json
+// @Produce      json
+// @Param        storage-id path int true "table id"
+// @Param        req query view.ReqStorageDeadLetterList true "params"
+// @Success      200 {object} core.Res{data=view.RespStorageDeadLetterList}
+// @Router       /api/v2/storage/{storage-id}/dead-letters [get]
+func ListDeadLetter(c *core.Context) {
+	id := cast.ToInt(c.Param("storage-id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	var (
+		req view2.ReqStorageDeadLetterList
+		err error
+	)
+	if err = c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	tableInfo, err := db2.TableInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "list failed 01: "+err.Error(), nil)
+		return
+	}
+	if err = permission.Manager.CheckNormalPermission(view2.ReqPermission{
+		UserId:      c.Uid(),
+		ObjectType:  pmsplugin.PrefixInstance,
+		ObjectIdx:   strconv.Itoa(tableInfo.Database.Iid),
+		SubResource: pmsplugin.Log,
+		Acts:        []string{pmsplugin.ActView},
+		DomainType:  pmsplugin.PrefixTable,
+		DomainId:    strconv.Itoa(id),
+	}); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	op, err := service.InstanceManager.Load(tableInfo.Database.Iid)
+	if err != nil {
+		c.JSONE(1, "list failed 02: "+err.Error(), nil)
+		return
+	}
+	res, err := op.ListDeadLetter(&tableInfo, req)
+	if err != nil {
+		c.JSONE(1, "list failed 03: "+err.Error(), nil)
+		return
+	}
+	c.JSONOK(res)
+}
+
+// ReplayDeadLetter  godoc
+// @Summary	     iStorage dead-letter replay
+// @Description  iStorage writes the dead-letter messages in the time range back to kafka and removes them from the dead-letter table
+// @Tags         LOGSTORE
+// @Accept       json
+// @Produce      json
+// @Param        storage-id path int true "table id"
+// @Param        req body view.ReqStorageDeadLetterReplay true "params"
+// @Success      200 {object} core.Res{data=uint64}
+// @Router       /api/v2/storage/{storage-id}/dead-letters/replay [post]
+func ReplayDeadLetter(c *core.Context) {
+	id := cast.ToInt(c.Param("storage-id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	var (
+		req view2.ReqStorageDeadLetterReplay
+		err error
+	)
+	if err = c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	tableInfo, err := db2.TableInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "replay failed 01: "+err.Error(), nil)
+		return
+	}
+	if err = permission.Manager.CheckNormalPermission(view2.ReqPermission{
+		UserId:      c.Uid(),
+		ObjectType:  pmsplugin.PrefixInstance,
+		ObjectIdx:   strconv.Itoa(tableInfo.Database.Iid),
+		SubResource: pmsplugin.Log,
+		Acts:        []string{pmsplugin.ActEdit},
+		DomainType:  pmsplugin.PrefixTable,
+		DomainId:    strconv.Itoa(id),
+	}); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	op, err := service.InstanceManager.Load(tableInfo.Database.Iid)
+	if err != nil {
+		c.JSONE(1, "replay failed 02: "+err.Error(), nil)
+		return
+	}
+	count, err := op.ReplayDeadLetter(&tableInfo, req)
+	if err != nil {
+		c.JSONE(1, "replay failed 03: "+err.Error(), nil)
+		return
+	}
+	event.Event.InquiryCMDB(c.User(), db2.OpnTablesUpdate, map[string]interface{}{"req": req, "count": count})
+	c.JSONOK(count)
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"strconv"
+
+	"github.com/ego-component/egorm"
+	"github.com/spf13/cast"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
+	db2 "github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	view2 "github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/event"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission/pmsplugin"
+)
+
+// GetIngestion  godoc
+// @Summary	     iStorage ingestion health
+// @Description  iStorage kafka consumers, lag and freshness of the latest check, and the monitor settings
+// @Tags         LOGSTORE
+// @Accept       json
+// @Produce      json
+// @Param        storage-id path int true "table id"
+// @Success      200 {object} core.Res{data=view.RespStorageIngestion}
+// @Router       /api/v2/storage/{storage-id}/ingestion [get]
+func GetIngestion(c *core.Context) {
+	id := cast.ToInt(c.Param("storage-id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	tableInfo, err := db2.TableInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "get failed 01: "+err.Error(), nil)
+		return
+	}
+	if err = permission.Manager.CheckNormalPermission(view2.ReqPermission{
+		UserId:      c.Uid(),
+		ObjectType:  pmsplugin.PrefixInstance,
+		ObjectIdx:   strconv.Itoa(tableInfo.Database.Iid),
+		SubResource: pmsplugin.Log,
+		Acts:        []string{pmsplugin.ActView},
+		DomainType:  pmsplugin.PrefixTable,
+		DomainId:    strconv.Itoa(id),
+	}); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	conds := egorm.Conds{}
+	conds["tid"] = id
+	monitor, err := db2.IngestionInfoX(invoker.Db, conds)
+	if err != nil {
+		c.JSONE(1, "get failed 02: "+err.Error(), nil)
+		return
+	}
+	res := view2.RespStorageIngestion{Monitor: monitor}
+	if health, ok := service.Ingestion.Health(id); ok {
+		res.Health = &health
+	}
+	c.JSONOK(res)
+}
+
+// UpdateIngestion  godoc
+// @Summary	     iStorage ingestion monitor update
+// @Description  iStorage ingestion monitor update, channels are notified when ingestion stalls or recovers
+// @Tags         LOGSTORE
+// @Accept       json
+// @Produce      json
+// @Param        storage-id path int true "table id"
+// @Param        req body view.ReqStorageUpdateIngestion true "params"
+// @Success      200 {object} core.Res{}
+// @Router       /api/v2/storage/{storage-id}/ingestion [patch]
+func UpdateIngestion(c *core.Context) {
+	id := cast.ToInt(c.Param("storage-id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	var (
+		req view2.ReqStorageUpdateIngestion
+		err error
+	)
+	if err = c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	tableInfo, err := db2.TableInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "update failed 01: "+err.Error(), nil)
+		return
+	}
+	if tableInfo.SqlStream == "" {
+		c.JSONE(1, "update failed: storage is not consuming from kafka", nil)
+		return
+	}
+	if err = permission.Manager.CheckNormalPermission(view2.ReqPermission{
+		UserId:      c.Uid(),
+		ObjectType:  pmsplugin.PrefixInstance,
+		ObjectIdx:   strconv.Itoa(tableInfo.Database.Iid),
+		SubResource: pmsplugin.Log,
+		Acts:        []string{pmsplugin.ActEdit},
+		DomainType:  pmsplugin.PrefixTable,
+		DomainId:    strconv.Itoa(id),
+	}); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	if err = service.Ingestion.UpdateMonitor(id, req); err != nil {
+		c.JSONE(1, "update failed 02: "+err.Error(), nil)
+		return
+	}
+	event.Event.InquiryCMDB(c.User(), db2.OpnTablesUpdate, map[string]interface{}{"req": req})
+	c.JSONOK()
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/clickvisual/clickvisual/api/internal/service/event"
 	"github.com/clickvisual/clickvisual/api/internal/service/permission"
 	"github.com/clickvisual/clickvisual/api/internal/service/permission/pmsplugin"
+	"github.com/clickvisual/clickvisual/api/internal/service/storage/storagetemplate"
 )
 
 // KafkaJsonMapping  godoc
@@ -45,6 +46,46 @@ func KafkaJsonMapping(c *core.Context) {
 	c.JSONOK(res)
 }
 
+// SchemaInference  godoc
+// @Summary	     Infer the storage schema from samples
+// @Description  Infer field types, time field and raw log field from pasted samples or messages read from kafka
+// @Tags         LOGSTORE
+// @Accept       json
+// @Produce      json
+// @Param        req body view.ReqStorageInfer true "params"
+// @Success      200 {object} core.Res{data=view.RespStorageInfer}
+// @Router       /api/v2/storage/schema-inference [post]
+func SchemaInference(c *core.Context) {
+	var req view.ReqStorageInfer
+	if err := c.Bind(&req); err != nil {
+		c.JSONE(core.CodeErr, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	databaseInfo, err := db.DatabaseInfo(invoker.Db, req.DatabaseId)
+	if err != nil {
+		c.JSONE(core.CodeErr, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	if err = permission.Manager.CheckNormalPermission(view.ReqPermission{
+		UserId:      c.Uid(),
+		ObjectType:  pmsplugin.PrefixInstance,
+		ObjectIdx:   strconv.Itoa(databaseInfo.Iid),
+		SubResource: pmsplugin.Log,
+		Acts:        []string{pmsplugin.ActEdit},
+		DomainType:  pmsplugin.PrefixDatabase,
+		DomainId:    strconv.Itoa(databaseInfo.ID),
+	}); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	res, err := service.Storage.Infer(databaseInfo, req)
+	if err != nil {
+		c.JSONE(core.CodeErr, err.Error(), err)
+		return
+	}
+	c.JSONOK(res)
+}
+
 // Create  godoc
 // @Summary	     Creating a log library
 // @Description  Creating a log library
@@ -78,7 +119,10 @@ func Create(c *core.Context) {
 		c.JSONE(1, "permission verification failed", err)
 		return
 	}
-	param.CreateType = constx.TableCreateTypeJSONEachRow
+	// JSONAsString is kept for the params proposed by schema inference
+	if param.CreateType != constx.TableCreateTypeJSONAsString {
+		param.CreateType = constx.TableCreateTypeJSONEachRow
+	}
 	_, err = service.StorageCreate(c.Uid(), databaseInfo, param)
 	if err != nil {
 		c.JSONE(core.CodeErr, err.Error(), err)
@@ -266,6 +310,9 @@ func CreateStorageByTemplate(c *core.Context) {
 	case "agent":
 		createStorageByTemplateAgent(c)
 		return
+	case storagetemplate.NameFluentBit, storagetemplate.NameVector, storagetemplate.NameOTLP:
+		createStorageByTemplateCollector(c, tpl)
+		return
 	}
 	c.JSONE(core.CodeErr, "template error", nil)
 }
@@ -415,3 +462,35 @@ func createStorageByTemplateILogtail(c *core.Context) {
 	event.Event.InquiryCMDB(c.User(), db.OpnTablesCreate, map[string]interface{}{"param": param})
 	c.JSONOK()
 }
+
+func createStorageByTemplateCollector(c *core.Context, tpl string) {
+	var param view.ReqCreateStorageByTemplate
+	err := c.Bind(&param)
+	if err != nil {
+		c.JSONE(core.CodeErr, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	databaseInfo, err := db.DatabaseInfo(invoker.Db, param.DatabaseId)
+	if err != nil {
+		c.JSONE(core.CodeErr, "invalid parameter: "+err.Error(), err)
+		return
+	}
+	if err = permission.Manager.CheckNormalPermission(view.ReqPermission{
+		UserId:      c.Uid(),
+		ObjectType:  pmsplugin.PrefixInstance,
+		ObjectIdx:   strconv.Itoa(databaseInfo.Iid),
+		SubResource: pmsplugin.Log,
+		Acts:        []string{pmsplugin.ActEdit},
+		DomainType:  pmsplugin.PrefixDatabase,
+		DomainId:    strconv.Itoa(databaseInfo.ID),
+	}); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	if err = service.Storage.CreateByTemplate(c.Uid(), databaseInfo, tpl, param); err != nil {
+		c.JSONE(core.CodeErr, err.Error(), err)
+		return
+	}
+	event.Event.InquiryCMDB(c.User(), db.OpnTablesCreate, map[string]interface{}{"param": param})
+	c.JSONOK()
+}
@@ -100,6 +100,76 @@ func UpdateTraceInfo(c *core.Context) {
 	c.JSONOK()
 }
 
+// UpdateRawLogIndex  godoc
+// @Summary	     iStorage raw log full-text index update
+// @Description  iStorage raw log full-text index update, keyword search uses hasToken/multiSearchAny when the index exists
+// @Tags         LOGSTORE
+// @Accept       json
+// @Produce      json
+// @Param        storage-id path int true "table id"
+// @Param        req query view.ReqStorageUpdateRawLogIndex true "params"
+// @Success      200 {object} core.Res{}
+// @Router       /api/v2/storage/{storage-id}/raw-log-index [patch]
+func UpdateRawLogIndex(c *core.Context) {
+	id := cast.ToInt(c.Param("storage-id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	var (
+		req view2.ReqStorageUpdateRawLogIndex
+		err error
+	)
+	if err = c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	tableInfo, err := db2.TableInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "update failed 01: "+err.Error(), nil)
+		return
+	}
+	if err = permission.Manager.CheckNormalPermission(view2.ReqPermission{
+		UserId:      c.Uid(),
+		ObjectType:  pmsplugin.PrefixInstance,
+		ObjectIdx:   strconv.Itoa(tableInfo.Database.Iid),
+		SubResource: pmsplugin.Log,
+		Acts:        []string{pmsplugin.ActEdit},
+		DomainType:  pmsplugin.PrefixTable,
+		DomainId:    strconv.Itoa(id),
+	}); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	if req.RawLogIndex < db2.RawLogIndexDefault || req.RawLogIndex > db2.RawLogIndexNone {
+		c.JSONE(1, "invalid parameter: rawLogIndex", nil)
+		return
+	}
+	// the index of the table is only rebuilt when the effective one changes
+	next := tableInfo
+	next.RawLogIndex = req.RawLogIndex
+	if next.GetRawLogIndex() != tableInfo.GetRawLogIndex() {
+		op, errLoad := service.InstanceManager.Load(tableInfo.Database.Iid)
+		if errLoad != nil {
+			c.JSONE(1, "update failed 02: "+errLoad.Error(), nil)
+			return
+		}
+		if err = op.UpdateRawLogIndex(&tableInfo, next.GetRawLogIndex()); err != nil {
+			c.JSONE(1, "update failed 03: "+err.Error(), nil)
+			return
+		}
+	}
+	ups := make(map[string]interface{}, 0)
+	ups["uid"] = c.Uid()
+	ups["raw_log_index"] = req.RawLogIndex
+	if err = db2.TableUpdate(invoker.Db, id, ups); err != nil {
+		c.JSONE(1, "update failed 04: "+err.Error(), nil)
+		return
+	}
+	event.Event.InquiryCMDB(c.User(), db2.OpnTablesUpdate, map[string]interface{}{"req": req})
+	c.JSONOK()
+}
+
 // GetTraceGraph  godoc
 // @Summary	     Get trace graph
 // @Description  Get trace graph
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"strconv"
+
+	"github.com/ego-component/egorm"
+	"github.com/spf13/cast"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
+	db2 "github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	view2 "github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service"
+	"github.com/clickvisual/clickvisual/api/internal/service/event"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission/pmsplugin"
+)
+
+// GetWatchdog  godoc
+// @Summary	     iStorage watchdog
+// @Description  iStorage watchdog switch, the volume and error ratio learned for each hour of the day and the anomalies firing
+// @Tags         LOGSTORE
+// @Accept       json
+// @Produce      json
+// @Param        storage-id path int true "table id"
+// @Success      200 {object} core.Res{data=view.RespStorageWatchdog}
+// @Router       /api/v2/storage/{storage-id}/watchdog [get]
+func GetWatchdog(c *core.Context) {
+	id := cast.ToInt(c.Param("storage-id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	tableInfo, err := db2.TableInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "get failed 01: "+err.Error(), nil)
+		return
+	}
+	if err = permission.Manager.CheckNormalPermission(view2.ReqPermission{
+		UserId:      c.Uid(),
+		ObjectType:  pmsplugin.PrefixInstance,
+		ObjectIdx:   strconv.Itoa(tableInfo.Database.Iid),
+		SubResource: pmsplugin.Log,
+		Acts:        []string{pmsplugin.ActView},
+		DomainType:  pmsplugin.PrefixTable,
+		DomainId:    strconv.Itoa(id),
+	}); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	conds := egorm.Conds{}
+	conds["tid"] = id
+	watchdog, err := db2.WatchdogInfoX(invoker.Db, conds)
+	if err != nil {
+		c.JSONE(1, "get failed 02: "+err.Error(), nil)
+		return
+	}
+	res := view2.RespStorageWatchdog{Watchdog: watchdog, Patterns: len(watchdog.Patterns)}
+	if res.LevelField, res.MessageField, err = service.Watchdog.Fields(id); err != nil {
+		c.JSONE(1, "get failed 03: "+err.Error(), nil)
+		return
+	}
+	c.JSONOK(res)
+}
+
+// UpdateWatchdog  godoc
+// @Summary	     iStorage watchdog update
+// @Description  iStorage watchdog switch, channels are notified when the volume drops to zero or spikes, the error ratio spikes or a new error pattern appears
+// @Tags         LOGSTORE
+// @Accept       json
+// @Produce      json
+// @Param        storage-id path int true "table id"
+// @Param        req body view.ReqStorageUpdateWatchdog true "params"
+// @Success      200 {object} core.Res{}
+// @Router       /api/v2/storage/{storage-id}/watchdog [patch]
+func UpdateWatchdog(c *core.Context) {
+	id := cast.ToInt(c.Param("storage-id"))
+	if id == 0 {
+		c.JSONE(1, "invalid parameter", nil)
+		return
+	}
+	var (
+		req view2.ReqStorageUpdateWatchdog
+		err error
+	)
+	if err = c.Bind(&req); err != nil {
+		c.JSONE(1, "invalid parameter: "+err.Error(), nil)
+		return
+	}
+	if req.Enabled != 0 && req.Enabled != 1 {
+		c.JSONE(1, "invalid parameter: enabled is 0 or 1", nil)
+		return
+	}
+	tableInfo, err := db2.TableInfo(invoker.Db, id)
+	if err != nil {
+		c.JSONE(1, "update failed 01: "+err.Error(), nil)
+		return
+	}
+	if err = permission.Manager.CheckNormalPermission(view2.ReqPermission{
+		UserId:      c.Uid(),
+		ObjectType:  pmsplugin.PrefixInstance,
+		ObjectIdx:   strconv.Itoa(tableInfo.Database.Iid),
+		SubResource: pmsplugin.Log,
+		Acts:        []string{pmsplugin.ActEdit},
+		DomainType:  pmsplugin.PrefixTable,
+		DomainId:    strconv.Itoa(id),
+	}); err != nil {
+		c.JSONE(1, "permission verification failed", err)
+		return
+	}
+	if err = service.Watchdog.UpdateWatchdog(id, req); err != nil {
+		c.JSONE(1, "update failed 02: "+err.Error(), nil)
+		return
+	}
+	event.Event.InquiryCMDB(c.User(), db2.OpnTablesUpdate, map[string]interface{}{"req": req})
+	c.JSONOK()
+}
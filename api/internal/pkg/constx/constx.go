@@ -17,6 +17,12 @@ const (
 	UBWKafkaStreamField = "body"
 )
 
+const (
+	// SourceFormatOTLP OTLP JSON logs, every message holds resourceLogs/scopeLogs/logRecords
+	// and each log record is stored as a row together with its resource attributes
+	SourceFormatOTLP = "otlp"
+)
+
 var (
 	DefaultFields = map[string]interface{}{
 		"_raw_log_":         struct{}{},
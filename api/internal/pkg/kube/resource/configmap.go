@@ -82,6 +82,22 @@ func GetConfigmap(clusterId int, namespace, name string, key string) (data strin
 	return
 }
 
+// ListConfigmap data of the configmap, empty when it does not exist
+func ListConfigmap(clusterId int, namespace, name string) (map[string]string, error) {
+	client, err := kube.ClusterManager.GetClusterManager(clusterId)
+	if err != nil {
+		return nil, errors.Wrap(err, "cluster data acquisition failed")
+	}
+	obj, err := client.KubeClient.Get(api.ResourceNameConfigMap, namespace, name)
+	if err != nil {
+		if NotFound(err) {
+			return map[string]string{}, nil
+		}
+		return nil, errors.Wrap(err, "configmap data read failed")
+	}
+	return obj.(*corev1.ConfigMap).Data, nil
+}
+
 func createConfigmap(client *kube.ClusterClient, namespace, name string, data map[string]string) error {
 	acm := corev1.ConfigMap{
 		TypeMeta: metaV1.TypeMeta{},
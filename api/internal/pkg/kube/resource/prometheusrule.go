@@ -78,6 +78,22 @@ func DeletePrometheusRuleByGroupName(clusterId int, namespace, name string, grou
 	return updatePrometheusRule(client, namespace, name, prometheusRules)
 }
 
+// ListPrometheusRuleGroups groups of the PrometheusRule, empty when it does not exist
+func ListPrometheusRuleGroups(clusterId int, namespace, name string) ([]monitoringv1.RuleGroup, error) {
+	client, err := kube.ClusterManager.GetClusterManager(clusterId)
+	if err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("cluster data acquisition failed: %s, cluster id: %d", err.Error(), clusterId))
+	}
+	obj, err := client.KubeClient.Get(api.ResourceNamePrometheusRule, namespace, name)
+	if err != nil {
+		if NotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "Get PrometheusRule failed, in cluster")
+	}
+	return obj.(*monitoringv1.PrometheusRule).Spec.Groups, nil
+}
+
 func createPrometheusRule(client *kube.ClusterClient, groupName string, po db.ConfigPrometheusOperator, rules []monitoringv1.Rule) error {
 	// 新建 clickvisual 配置
 	clickvisualGroup := monitoringv1.RuleGroup{
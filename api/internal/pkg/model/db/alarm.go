@@ -30,6 +30,14 @@ const (
 	_ int = iota
 	AlarmModeAggregation
 	AlarmModeAggregationCheck
+	AlarmModeTraceEdge // the metric of a parent to child edge of the jaeger dependencies
+)
+
+// metrics of the trace edge filters, the values of the conditions are integers
+const (
+	TraceMetricCallCount   = "callCount"   // calls of the edge in the window
+	TraceMetricP99         = "p99"         // highest p99 of the server duration in the window, in milliseconds
+	TraceMetricSuccessRate = "successRate" // server success rate of the calls in the window, in percent
 )
 
 const (
@@ -44,8 +52,24 @@ const (
 	RuleStoreTypeFile         = 1
 	RuleStoreTypeK8sConfigMap = 2
 	RuleStoreTypeK8sOperator  = 3
+	RuleStoreTypeRuler        = 4
+)
+
+const (
+	AlertEvaluatorPrometheus = 0
+	AlertEvaluatorNative     = 1
+)
+
+// authentication of the alertmanager webhook of an instance
+const (
+	WebhookAuthNone  = 0
+	WebhookAuthToken = 1 // Authorization: Bearer <secret>
+	WebhookAuthHMAC  = 2 // X-Clickvisual-Signature: sha256=<hex of the hmac of the body keyed by the secret>
 )
 
+// AlarmNotifyLogsMax sample logs of a message at most
+const AlarmNotifyLogsMax = 20
+
 var UnitMap = map[int]UnitItem{
 	0: {
 		Alias:    "m",
@@ -86,6 +110,20 @@ type Alert struct {
 	EndsAt      time.Time         `json:"endsAt"`
 }
 
+// AlarmRuleHealth evaluation status of the prometheus rule of an alarm filter
+type AlarmRuleHealth struct {
+	Rule           string  `json:"rule"`
+	Loaded         bool    `json:"loaded"`          // the rule is known by prometheus
+	State          string  `json:"state"`           // inactive, pending or firing
+	Health         string  `json:"health"`          // ok, err or unknown
+	LastError      string  `json:"lastError"`       // error of the last evaluation
+	LastEvaluation int64   `json:"lastEvaluation"`  // unix seconds
+	EvaluationTime float64 `json:"evaluationTime"`  // seconds taken by the last evaluation
+	ActiveAlerts   int     `json:"activeAlerts"`    // alerts pending or firing
+	ActiveAt       int64   `json:"activeAt"`        // since when the earliest active alert is active, unix seconds
+	Error          string  `json:"error,omitempty"` // the status could not be pulled from prometheus
+}
+
 type Notification struct {
 	Version           string            `json:"version"`
 	GroupKey          string            `json:"groupKey"`
@@ -123,6 +161,11 @@ type Alarm struct {
 	Status           int           `gorm:"column:status;type:int(11)" json:"status"`                          // status
 	DutyOfficers     Ints          `gorm:"column:duty_officers;type:varchar(255)" json:"dutyOfficers"`        // duty officer id list
 	IsDisableResolve int           `gorm:"column:is_disable_resolve;type:tinyint(1)" json:"isDisableResolve"` // is disable resolve message
+	EscalationId     int           `gorm:"column:escalation_id;type:int(11);default:0" json:"escalationId"`   // escalation policy, 0 none
+	Template         string        `gorm:"column:template;type:text" json:"template"`                         // go template of the messages, overrides the one of the channels
+	NotifyLogs       int           `gorm:"column:notify_logs;type:int(11);default:0" json:"notifyLogs"`       // sample logs of the messages, 1 when 0
+	NotifyTopField   string        `gorm:"column:notify_top_field;type:varchar(128)" json:"notifyTopField"`   // field whose top values over the alert window are in the messages
+	NotifyChart      int           `gorm:"column:notify_chart;type:tinyint(1);default:0" json:"notifyChart"`  // 1 chart of the condition metric for the channels supporting images
 
 	User *User `json:"user,omitempty" gorm:"foreignKey:uid;references:id"`
 
@@ -190,6 +233,17 @@ func (m *Alarm) GetInterval() time.Duration {
 	return UnitMap[m.Unit].Duration * time.Duration(m.Interval)
 }
 
+// GetNotifyLogs sample logs of the messages, one by default
+func (m *Alarm) GetNotifyLogs() int {
+	if m.NotifyLogs <= 0 {
+		return 1
+	}
+	if m.NotifyLogs > AlarmNotifyLogsMax {
+		return AlarmNotifyLogsMax
+	}
+	return m.NotifyLogs
+}
+
 func (m *Alarm) AlertInterval() string {
 	return fmt.Sprintf("%d%s", m.Interval, UnitMap[m.Unit].Alias)
 }
@@ -437,7 +491,8 @@ func AlarmDelete(db *gorm.DB, id int) (err error) {
 }
 
 type ReqAlertSettingUpdate struct {
-	RuleStoreType    int    `json:"ruleStoreType" form:"ruleStoreType"` // ruleStoreType 1 文件 2 configmap 3 prometheus operator
+	AlertEvaluator   int    `json:"alertEvaluator" form:"alertEvaluator"` // alertEvaluator 0 prometheus 1 native
+	RuleStoreType    int    `json:"ruleStoreType" form:"ruleStoreType"`   // ruleStoreType 1 文件 2 configmap 3 prometheus operator 4 ruler api
 	PrometheusTarget string `json:"prometheusTarget" form:"prometheusTarget"`
 
 	// file
@@ -456,6 +511,16 @@ type ReqAlertSettingUpdate struct {
 	//  name: prometheus-example-rules-2
 	//  namespace: default
 	ConfigPrometheusOperator string `json:"configPrometheusOperator" form:"configPrometheusOperator"`
+
+	// ruler api of mimir, cortex or compatible rulers
+	RulerUrl       string `json:"rulerUrl" form:"rulerUrl"` // eg: http://mimir:8080/prometheus/config/v1/rules
+	RulerNamespace string `json:"rulerNamespace" form:"rulerNamespace"`
+	RulerTenant    string `json:"rulerTenant" form:"rulerTenant"`
+
+	// authentication of the alertmanager webhook
+	WebhookAuth     int     `json:"webhookAuth" form:"webhookAuth"` // 0 none 1 bearer token 2 hmac signature
+	WebhookSecret   string  `json:"webhookSecret" form:"webhookSecret"`
+	WebhookAllowIPs Strings `json:"webhookAllowIps" form:"webhookAllowIps"` // ips or cidrs allowed to call the webhook, any when empty
 }
 
 type ConfigPrometheusOperator struct {
@@ -485,7 +550,8 @@ type RespAlertSettingInfo struct {
 type RespAlertSettingListItem struct {
 	InstanceId       int    `json:"instanceId"`
 	InstanceName     string `json:"instanceName"`
-	RuleStoreType    int    `json:"ruleStoreType"` // rule_store_type 1 文件 2 集群
+	AlertEvaluator   int    `json:"alertEvaluator"` // alert_evaluator 0 prometheus 1 native
+	RuleStoreType    int    `json:"ruleStoreType"`  // rule_store_type 1 文件 2 集群
 	PrometheusTarget string `json:"prometheusTarget"`
 
 	// check
@@ -1,6 +1,7 @@
 package db
 
 import (
+	"encoding/json"
 	"strings"
 
 	"github.com/ego-component/egorm"
@@ -13,14 +14,28 @@ import (
 )
 
 const (
-	ChannelDingDing = 1
-	ChannelWeChat   = 2
-	ChannelFeiShu   = 3
-	ChannelSlack    = 4
-	ChannelWebHook  = 5
-	ChannelTelegram = 6
+	ChannelDingDing  = 1
+	ChannelWeChat    = 2
+	ChannelFeiShu    = 3
+	ChannelSlack     = 4
+	ChannelWebHook   = 5
+	ChannelTelegram  = 6
+	ChannelEmail     = 7
+	ChannelPagerDuty = 8
 )
 
+const (
+	EmailTLSNone     = "none"
+	EmailTLSStartTLS = "starttls"
+	EmailTLSImplicit = "tls"
+)
+
+// ChannelPasswordRedacted password of the email channels in the responses, an update sending it back keeps the current one
+const ChannelPasswordRedacted = "<redacted>"
+
+// PagerDutyEventsURL events api v2 endpoint used when the channel sets no url
+const PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
 const (
 	FEISHUURL = "https://open.feishu.cn"
 	LARKSUITE = "https://open.larksuite.com"
@@ -35,6 +50,28 @@ type AlarmChannel struct {
 	Key  string `gorm:"column:key;type:text" json:"key"`                    // 关键信息
 	Typ  int    `gorm:"column:typ;type:int(11)" json:"typ"`                 // 告警类型：0 dd
 	Uid  int    `gorm:"column:uid;type:int(11)" json:"uid"`                 // 操作人
+
+	// GroupBy labels the notifications are grouped by, the alarm id by default
+	GroupBy        Strings `gorm:"column:group_by;type:text" json:"groupBy"`
+	GroupWait      int     `gorm:"column:group_wait;type:int(11);default:0" json:"groupWait"`           // seconds to wait for other notifications of a new group
+	GroupInterval  int     `gorm:"column:group_interval;type:int(11);default:0" json:"groupInterval"`   // seconds between two messages of the same group
+	RepeatInterval int     `gorm:"column:repeat_interval;type:int(11);default:0" json:"repeatInterval"` // seconds an unchanged alert is not sent again
+	RateLimit      int     `gorm:"column:rate_limit;type:int(11);default:0" json:"rateLimit"`           // messages per minute, 0 unlimited
+	// Template go template of the alarm messages, the built-in message when empty
+	Template string `gorm:"column:template;type:text" json:"template"`
+	// ChatOps 1 adds the acknowledge, snooze and logs buttons to the firing messages of dingding, feishu and slack
+	ChatOps int `gorm:"column:chat_ops;type:tinyint(1);default:0" json:"chatOps"`
+	// CallbackSecret verifies the button callbacks: the verification token of the feishu app,
+	// the signing secret of the slack app, the key signing the links of dingding.
+	// It is set by ReqAlarmChannel and never returned, HasCallbackSecret tells whether it is set.
+	CallbackSecret    string `gorm:"column:callback_secret;type:varchar(255);default:''" json:"-"`
+	HasCallbackSecret bool   `gorm:"-" json:"hasCallbackSecret"`
+}
+
+// ReqAlarmChannel channel of the create and update requests, an empty callback secret keeps the current one on update
+type ReqAlarmChannel struct {
+	AlarmChannel
+	CallbackSecret string `json:"callbackSecret"`
 }
 
 type ReqAlarmWebhook struct {
@@ -48,6 +85,94 @@ type PushMsg struct {
 	Title   string   `json:"title"`
 	Text    string   `json:"text"`
 	Mobiles []string `json:"mobiles"`
+	// DedupKey identifies the alert for incident channels, alarm uuid and filter id
+	DedupKey string `json:"dedupKey,omitempty"`
+	// Status of the alert, incident channels resolve the incident of the dedup key when it is AlarmStatusNormal
+	Status int `json:"status,omitempty"`
+	// Image png chart of the alert, sent by the channels supporting images
+	Image []byte `json:"-"`
+	// ImageURL link of the image for the channels which can only reference images by url
+	ImageURL string `json:"imageUrl,omitempty"`
+	// AlarmId the chat-ops buttons act on, only set for the firing message of a single alarm
+	AlarmId int `json:"alarmId,omitempty"`
+	// LogsURL link of the logs around the alert opened by the logs button
+	LogsURL string `json:"logsUrl,omitempty"`
+}
+
+// ChannelEmailKey json stored in the key of email channels
+type ChannelEmailKey struct {
+	Host               string   `json:"host"`
+	Port               int      `json:"port"`
+	Username           string   `json:"username"`
+	Password           string   `json:"password"`
+	From               string   `json:"from"`
+	To                 []string `json:"to"`
+	TLS                string   `json:"tls"` // none, starttls or tls, starttls by default, none only authenticates to localhost
+	InsecureSkipVerify bool     `json:"insecureSkipVerify"`
+}
+
+// ChannelPagerDutyKey json stored in the key of events api v2 compatible channels
+type ChannelPagerDutyKey struct {
+	URL        string `json:"url"` // PagerDutyEventsURL by default
+	RoutingKey string `json:"routingKey"`
+	Severity   string `json:"severity"` // critical, error, warning or info, error by default
+}
+
+// ParseChannelEmailKey parses the key of an email channel and fills the default values
+func ParseChannelEmailKey(key string) (res ChannelEmailKey, err error) {
+	if err = json.Unmarshal([]byte(key), &res); err != nil {
+		return res, errors.Wrap(err, "invalid email channel key")
+	}
+	if res.TLS == "" {
+		res.TLS = EmailTLSStartTLS
+	}
+	if res.Port == 0 {
+		res.Port = 25
+		if res.TLS == EmailTLSImplicit {
+			res.Port = 465
+		}
+	}
+	if res.From == "" {
+		res.From = res.Username
+	}
+	switch {
+	case res.Host == "":
+		return res, errors.New("email host is required")
+	case res.From == "":
+		return res, errors.New("email sender is required")
+	case len(res.To) == 0:
+		return res, errors.New("email recipients are required")
+	case res.TLS != EmailTLSNone && res.TLS != EmailTLSStartTLS && res.TLS != EmailTLSImplicit:
+		return res, errors.Errorf("invalid email tls %s", res.TLS)
+	case res.TLS == EmailTLSNone && res.Username != "" && !isLocalhost(res.Host):
+		// smtp.PlainAuth refuses to send the password unencrypted to other hosts
+		return res, errors.New("email authentication requires starttls or tls unless the host is localhost")
+	}
+	return res, nil
+}
+
+func isLocalhost(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+// ParseChannelPagerDutyKey parses the key of an events api v2 channel and fills the default values
+func ParseChannelPagerDutyKey(key string) (res ChannelPagerDutyKey, err error) {
+	if err = json.Unmarshal([]byte(key), &res); err != nil {
+		return res, errors.Wrap(err, "invalid pagerduty channel key")
+	}
+	if res.URL == "" {
+		res.URL = PagerDutyEventsURL
+	}
+	if res.Severity == "" {
+		res.Severity = "error"
+	}
+	switch {
+	case res.RoutingKey == "":
+		return res, errors.New("pagerduty routing key is required")
+	case res.Severity != "critical" && res.Severity != "error" && res.Severity != "warning" && res.Severity != "info":
+		return res, errors.Errorf("invalid pagerduty severity %s", res.Severity)
+	}
+	return res, nil
 }
 
 type DingTalkText struct {
@@ -89,10 +214,89 @@ func (m *AlarmChannel) JudgmentType() (err error) {
 			err = errors.New("invalid Slack webhook url")
 			return
 		}
+	case ChannelEmail:
+		if _, err = ParseChannelEmailKey(m.Key); err != nil {
+			return
+		}
+	case ChannelPagerDuty:
+		if _, err = ParseChannelPagerDutyKey(m.Key); err != nil {
+			return
+		}
+	}
+	if m.ChatOps == 1 {
+		if m.Typ != ChannelDingDing && m.Typ != ChannelFeiShu && m.Typ != ChannelSlack {
+			return errors.New("chat-ops is only supported by dingding, feishu and slack channels")
+		}
+		if m.CallbackSecret == "" {
+			return errors.New("callback secret is required by chat-ops")
+		}
+	}
+	if m.GroupWait < 0 || m.GroupInterval < 0 || m.RepeatInterval < 0 || m.RateLimit < 0 {
+		return errors.New("group wait, group interval, repeat interval and rate limit must not be negative")
 	}
 	return nil
 }
 
+func (m *AlarmChannel) AfterFind(tx *gorm.DB) error {
+	m.HasCallbackSecret = m.CallbackSecret != ""
+	return nil
+}
+
+// RedactKey hides the password of an email channel before it is returned, a key which can not be parsed is hidden entirely
+func (m *AlarmChannel) RedactKey() {
+	if m.Typ != ChannelEmail || m.Key == "" {
+		return
+	}
+	key := ChannelEmailKey{}
+	if err := json.Unmarshal([]byte(m.Key), &key); err != nil {
+		m.Key = ChannelPasswordRedacted
+		return
+	}
+	if key.Password == "" {
+		return
+	}
+	key.Password = ChannelPasswordRedacted
+	b, _ := json.Marshal(key)
+	m.Key = string(b)
+}
+
+// UnredactKey the redacted password of the request is the one of the current channel
+func (m *AlarmChannel) UnredactKey(cur *AlarmChannel) error {
+	if m.Typ != ChannelEmail {
+		return nil
+	}
+	if m.Key == ChannelPasswordRedacted {
+		m.Key = cur.Key
+		return nil
+	}
+	key := ChannelEmailKey{}
+	if err := json.Unmarshal([]byte(m.Key), &key); err != nil || key.Password != ChannelPasswordRedacted {
+		// JudgmentType reports the invalid keys
+		return nil
+	}
+	curKey := ChannelEmailKey{}
+	if cur.Typ != ChannelEmail || json.Unmarshal([]byte(cur.Key), &curKey) != nil {
+		return errors.New("the password of the email channel can not be redacted")
+	}
+	key.Password = curKey.Password
+	b, err := json.Marshal(key)
+	if err != nil {
+		return errors.Wrap(err, "invalid email channel key")
+	}
+	m.Key = string(b)
+	return nil
+}
+
+// IsChatOps the firing messages have buttons calling back clickvisual
+func (m *AlarmChannel) IsChatOps() bool {
+	return m.ChatOps == 1 && m.CallbackSecret != ""
+}
+
+// IsGrouped notifications are sent at once when grouping, repeat and rate limit are all disabled
+func (m *AlarmChannel) IsGrouped() bool {
+	return m.GroupWait > 0 || m.GroupInterval > 0 || m.RepeatInterval > 0 || m.RateLimit > 0
+}
+
 func AlarmChannelInfo(db *gorm.DB, id int) (resp AlarmChannel, err error) {
 	var sql = "`id`= ?"
 	var binds = []interface{}{id}
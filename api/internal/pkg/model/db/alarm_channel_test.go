@@ -1,6 +1,7 @@
 package db
 
 import (
+	"encoding/json"
 	"testing"
 )
 
@@ -11,6 +12,9 @@ func TestAlarmChannel_JudgmentType(t *testing.T) {
 		Key       string
 		Typ       int
 		Uid       int
+
+		ChatOps        int
+		CallbackSecret string
 	}
 	tests := []struct {
 		name    string
@@ -48,6 +52,90 @@ func TestAlarmChannel_JudgmentType(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "email",
+			fields: fields{
+				Key: `{"host":"smtp.example.com","username":"alert@example.com","to":["a@example.com"]}`,
+				Typ: ChannelEmail,
+			},
+			wantErr: false,
+		},
+		{
+			name: "email without recipients",
+			fields: fields{
+				Key: `{"host":"smtp.example.com","username":"alert@example.com"}`,
+				Typ: ChannelEmail,
+			},
+			wantErr: true,
+		},
+		{
+			name: "email invalid tls",
+			fields: fields{
+				Key: `{"host":"smtp.example.com","from":"alert@example.com","to":["a@example.com"],"tls":"ssl"}`,
+				Typ: ChannelEmail,
+			},
+			wantErr: true,
+		},
+		{
+			name: "email authentication without tls",
+			fields: fields{
+				Key: `{"host":"smtp.example.com","username":"alert@example.com","password":"secret","to":["a@example.com"],"tls":"none"}`,
+				Typ: ChannelEmail,
+			},
+			wantErr: true,
+		},
+		{
+			name: "email authentication without tls to localhost",
+			fields: fields{
+				Key: `{"host":"127.0.0.1","username":"alert@example.com","password":"secret","to":["a@example.com"],"tls":"none"}`,
+				Typ: ChannelEmail,
+			},
+			wantErr: false,
+		},
+		{
+			name: "pagerduty",
+			fields: fields{
+				Key: `{"routingKey":"routing","severity":"critical"}`,
+				Typ: ChannelPagerDuty,
+			},
+			wantErr: false,
+		},
+		{
+			name: "pagerduty without routing key",
+			fields: fields{
+				Key: `{"url":"http://127.0.0.1/v2/enqueue"}`,
+				Typ: ChannelPagerDuty,
+			},
+			wantErr: true,
+		},
+		{
+			name: "chat-ops",
+			fields: fields{
+				Key:            "https://hooks.slack.com/services/T/B/X",
+				Typ:            ChannelSlack,
+				ChatOps:        1,
+				CallbackSecret: "secret",
+			},
+			wantErr: false,
+		},
+		{
+			name: "chat-ops without callback secret",
+			fields: fields{
+				Key:     "https://hooks.slack.com/services/T/B/X",
+				Typ:     ChannelSlack,
+				ChatOps: 1,
+			},
+			wantErr: true,
+		},
+		{
+			name: "chat-ops of an unsupported channel",
+			fields: fields{
+				Typ:            ChannelWebHook,
+				ChatOps:        1,
+				CallbackSecret: "secret",
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -57,6 +145,9 @@ func TestAlarmChannel_JudgmentType(t *testing.T) {
 				Key:       tt.fields.Key,
 				Typ:       tt.fields.Typ,
 				Uid:       tt.fields.Uid,
+
+				ChatOps:        tt.fields.ChatOps,
+				CallbackSecret: tt.fields.CallbackSecret,
 			}
 			if err := m.JudgmentType(); (err != nil) != tt.wantErr {
 				t.Errorf("JudgmentType() error = %v, wantErr %v", err, tt.wantErr)
@@ -64,3 +155,36 @@ func TestAlarmChannel_JudgmentType(t *testing.T) {
 		})
 	}
 }
+
+func TestAlarmChannel_RedactKey(t *testing.T) {
+	cur := &AlarmChannel{Typ: ChannelEmail, Key: `{"host":"smtp.example.com","username":"alert@example.com","password":"secret","to":["a@example.com"]}`}
+	m := *cur
+	m.RedactKey()
+	key := ChannelEmailKey{}
+	if err := json.Unmarshal([]byte(m.Key), &key); err != nil || key.Password != ChannelPasswordRedacted || key.Host != "smtp.example.com" {
+		t.Fatalf("RedactKey() key = %s, err %v", m.Key, err)
+	}
+	if err := m.UnredactKey(cur); err != nil {
+		t.Fatalf("UnredactKey() error = %v", err)
+	}
+	if err := json.Unmarshal([]byte(m.Key), &key); err != nil || key.Password != "secret" {
+		t.Errorf("UnredactKey() key = %s, err %v", m.Key, err)
+	}
+
+	invalid := AlarmChannel{Typ: ChannelEmail, Key: "secret"}
+	if invalid.RedactKey(); invalid.Key != ChannelPasswordRedacted {
+		t.Errorf("RedactKey() of an invalid key = %s", invalid.Key)
+	}
+	if err := invalid.UnredactKey(cur); err != nil || invalid.Key != cur.Key {
+		t.Errorf("UnredactKey() of a redacted key = %s, err %v", invalid.Key, err)
+	}
+
+	webhook := AlarmChannel{Typ: ChannelWebHook, Key: "https://example.com/hook"}
+	if webhook.RedactKey(); webhook.Key != "https://example.com/hook" {
+		t.Errorf("RedactKey() of a webhook = %s", webhook.Key)
+	}
+	m = AlarmChannel{Typ: ChannelEmail, Key: `{"host":"smtp.example.com","password":"<redacted>"}`}
+	if err := m.UnredactKey(&webhook); err == nil {
+		t.Error("UnredactKey() of a channel which was no email channel succeeded")
+	}
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// AlarmChart png chart of a notification, served by its token to the channels which only reference images by url.
+// Charts are neither updated nor soft deleted, the expired ones are deleted by ctime.
+type AlarmChart struct {
+	ID    int   `gorm:"not null;primary_key;AUTO_INCREMENT;comment:自增id" json:"id"`
+	Ctime int64 `gorm:"bigint;autoCreateTime;index:idx_ctime;comment:创建时间" json:"ctime"`
+
+	Token   string `gorm:"column:token;type:varchar(64);NOT NULL;uniqueIndex:uix_token" json:"token"`
+	AlarmId int    `gorm:"column:alarm_id;type:int(11);default:0;NOT NULL" json:"alarmId"`
+	Image   []byte `gorm:"column:image;type:mediumblob" json:"-"`
+}
+
+func (m *AlarmChart) TableName() string {
+	return TableNameAlarmChart
+}
+
+func AlarmChartInfoByToken(db *gorm.DB, token string) (resp AlarmChart, err error) {
+	var sql = "`token`= ?"
+	var binds = []interface{}{token}
+	if err = db.Model(AlarmChart{}).Where(sql, binds...).First(&resp).Error; err != nil {
+		err = errors.Wrapf(err, "alarm chart token: %s", token)
+		return
+	}
+	return
+}
+
+func AlarmChartCreate(db *gorm.DB, data *AlarmChart) (err error) {
+	if err = db.Model(AlarmChart{}).Create(data).Error; err != nil {
+		return errors.Wrapf(err, "alarm chart of alarm: %d", data.AlarmId)
+	}
+	return
+}
+
+// AlarmChartDeleteBefore removes the charts created before ctime
+func AlarmChartDeleteBefore(db *gorm.DB, ctime int64) (err error) {
+	if err = db.Model(AlarmChart{}).Where("`ctime` < ?", ctime).Unscoped().Delete(&AlarmChart{}).Error; err != nil {
+		return errors.Wrapf(err, "ctime: %d", ctime)
+	}
+	return
+}
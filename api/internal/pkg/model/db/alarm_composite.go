@@ -0,0 +1,78 @@
+package db
+
+import (
+	"github.com/ego-component/egorm"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// AlarmComposite fires when the boolean expression over the states of other alarms holds for For seconds.
+// The operands of the expression are alarm ids, true when the alarm is firing, e.g. 12 && (15 || !20).
+type AlarmComposite struct {
+	BaseModel
+
+	Name       string `gorm:"column:name;type:varchar(128);NOT NULL" json:"name"`
+	Desc       string `gorm:"column:desc;type:varchar(255);default:'';NOT NULL" json:"desc"`
+	Expr       string `gorm:"column:expr;type:varchar(1024);NOT NULL" json:"expr"`
+	For        int    `gorm:"column:for_duration;type:int(11);default:0" json:"for"`           // seconds the expression holds before firing
+	ChannelIds Ints   `gorm:"column:channel_ids;type:varchar(255);NOT NULL" json:"channelIds"` // channels of the composite alarm
+	Status     int    `gorm:"column:status;type:int(11)" json:"status"`                        // AlarmStatusClose, AlarmStatusNormal or AlarmStatusFiring
+	PendingAt  int64  `gorm:"column:pending_at;type:bigint(20);default:0" json:"pendingAt"`    // since when the expression holds, 0 when it does not
+	Uid        int    `gorm:"column:uid;type:int(11)" json:"uid"`                              // creator
+}
+
+func (m *AlarmComposite) TableName() string {
+	return TableNameAlarmComposite
+}
+
+func AlarmCompositeInfo(db *gorm.DB, id int) (resp AlarmComposite, err error) {
+	var sql = "`id`= ?"
+	var binds = []interface{}{id}
+	if err = db.Model(AlarmComposite{}).Where(sql, binds...).First(&resp).Error; err != nil {
+		err = errors.Wrapf(err, "alarm composite id: %d", id)
+		return
+	}
+	return
+}
+
+func AlarmCompositeList(db *gorm.DB, conds egorm.Conds) (resp []*AlarmComposite, err error) {
+	sql, binds := egorm.BuildQuery(conds)
+	if err = db.Model(AlarmComposite{}).Where(sql, binds...).Order("id desc").Find(&resp).Error; err != nil {
+		err = errors.Wrapf(err, "conds: %v", conds)
+		return
+	}
+	return
+}
+
+func AlarmCompositeCreate(db *gorm.DB, data *AlarmComposite) (err error) {
+	if err = db.Model(AlarmComposite{}).Create(data).Error; err != nil {
+		return errors.Wrapf(err, "alarm composite: %v", data)
+	}
+	return
+}
+
+func AlarmCompositeUpdate(db *gorm.DB, id int, ups map[string]interface{}) (err error) {
+	var sql = "`id`=?"
+	var binds = []interface{}{id}
+	if err = db.Model(AlarmComposite{}).Where(sql, binds...).Updates(ups).Error; err != nil {
+		return errors.Wrapf(err, "ups: %v", ups)
+	}
+	return
+}
+
+// AlarmCompositeUpdateFrom updates the composite alarm only if its status is still from,
+// false when it was changed meanwhile, e.g. closed by a user
+func AlarmCompositeUpdateFrom(db *gorm.DB, id int, from int, ups map[string]interface{}) (bool, error) {
+	res := db.Model(AlarmComposite{}).Where("`id` = ? AND `status` = ?", id, from).Updates(ups)
+	if res.Error != nil {
+		return false, errors.Wrapf(res.Error, "ups: %v", ups)
+	}
+	return res.RowsAffected == 1, nil
+}
+
+func AlarmCompositeDelete(db *gorm.DB, id int) (err error) {
+	if err = db.Model(AlarmComposite{}).Unscoped().Delete(&AlarmComposite{}, id).Error; err != nil {
+		return errors.Wrapf(err, "alarm composite id: %d", id)
+	}
+	return
+}
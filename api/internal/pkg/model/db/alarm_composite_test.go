@@ -0,0 +1,51 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestAlarmCompositeUpdateFrom(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open("file:composite?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = gdb.AutoMigrate(&AlarmComposite{}); err != nil {
+		t.Fatal(err)
+	}
+	composite := &AlarmComposite{Name: "checkout", Expr: "1", ChannelIds: Ints{1}, Status: AlarmStatusNormal}
+	if err = AlarmCompositeCreate(gdb, composite); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		from int
+		to   int
+		want bool
+	}{
+		{name: "fires", from: AlarmStatusNormal, to: AlarmStatusFiring, want: true},
+		{name: "status changed meanwhile", from: AlarmStatusNormal, to: AlarmStatusFiring, want: false},
+		{name: "closed", from: AlarmStatusFiring, to: AlarmStatusClose, want: true},
+		{name: "closed is not overwritten", from: AlarmStatusFiring, to: AlarmStatusNormal, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AlarmCompositeUpdateFrom(gdb, composite.ID, tt.from, map[string]interface{}{"status": tt.to})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("AlarmCompositeUpdateFrom() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	var status int
+	if err = gdb.Model(&AlarmComposite{}).Select("status").Where("id = ?", composite.ID).Scan(&status).Error; err != nil {
+		t.Fatal(err)
+	}
+	if status != AlarmStatusClose {
+		t.Errorf("status = %v, want %v", status, AlarmStatusClose)
+	}
+}
@@ -1,6 +1,8 @@
 package db
 
 import (
+	"fmt"
+
 	"github.com/ego-component/egorm"
 	"github.com/pkg/errors"
 	"gorm.io/gorm"
@@ -8,6 +10,20 @@ import (
 	"github.com/clickvisual/clickvisual/api/internal/invoker"
 )
 
+const (
+	// ConditionTypThreshold static threshold on the logs of the alarm interval
+	ConditionTypThreshold = iota
+	// ConditionTypChange percentage change versus the same window Offset seconds ago
+	ConditionTypChange
+	// ConditionTypBaseline deviation from the mean ± Factor·stddev of the Periods previous windows
+	ConditionTypBaseline
+	// ConditionTypSeasonal percentage change versus the mean of the same window in the Periods previous seasons of Offset seconds
+	ConditionTypSeasonal
+)
+
+// ConditionMaxPeriods each period of an anomaly condition is one more window queried by the evaluation
+const ConditionMaxPeriods = 24
+
 // AlarmCondition alarm statement，the trigger condition
 type AlarmCondition struct {
 	BaseModel
@@ -19,12 +35,62 @@ type AlarmCondition struct {
 	Cond           int `gorm:"column:cond;type:int(11)" json:"cond"`                     // 0 above 1 below 2 outside range 3 within range
 	Val1           int `gorm:"column:val_1;type:int(11)" json:"val1"`                    // 基准值/最小值
 	Val2           int `gorm:"column:val_2;type:int(11)" json:"val2"`                    // 最大值
+
+	CondTyp int     `gorm:"column:cond_typ;type:int(11);default:0;NOT NULL" json:"condTyp"` // 0 threshold 1 change 2 baseline 3 seasonal
+	Offset  int     `gorm:"column:offset;type:int(11);default:0;NOT NULL" json:"offset"`    // seconds, window compared by change, season length of seasonal
+	Periods int     `gorm:"column:periods;type:int(11);default:0;NOT NULL" json:"periods"`  // windows of baseline, seasons of seasonal
+	Factor  float64 `gorm:"column:factor;type:double;default:0;NOT NULL" json:"factor"`     // k of baseline
 }
 
 func (m *AlarmCondition) TableName() string {
 	return TableNameAlarmCondition
 }
 
+// IsAnomaly the condition compares the logs with their history instead of a static threshold
+func (m *AlarmCondition) IsAnomaly() bool {
+	return m.CondTyp != ConditionTypThreshold
+}
+
+func (m *AlarmCondition) JudgmentType() error {
+	if m.Cond < 0 || m.Cond > 3 {
+		return fmt.Errorf("invalid cond %d", m.Cond)
+	}
+	switch m.CondTyp {
+	case ConditionTypThreshold:
+	case ConditionTypChange:
+		if m.Offset <= 0 {
+			return errors.New("offset is required by change conditions")
+		}
+	case ConditionTypBaseline:
+		if m.Periods <= 0 || m.Periods > ConditionMaxPeriods {
+			return fmt.Errorf("periods of baseline conditions must be between 1 and %d", ConditionMaxPeriods)
+		}
+		if m.Factor <= 0 {
+			return errors.New("factor is required by baseline conditions")
+		}
+	case ConditionTypSeasonal:
+		if m.Offset <= 0 {
+			return errors.New("offset is required by seasonal conditions")
+		}
+		if m.Periods <= 0 || m.Periods > ConditionMaxPeriods {
+			return fmt.Errorf("periods of seasonal conditions must be between 1 and %d", ConditionMaxPeriods)
+		}
+	default:
+		return fmt.Errorf("invalid condition type %d", m.CondTyp)
+	}
+	return nil
+}
+
+// HasAnomaly the filter is compiled to the anomaly sql when one of its conditions is an anomaly
+func HasAnomaly(conditions []*AlarmCondition) bool {
+	for _, condition := range conditions {
+		if condition.IsAnomaly() {
+			return true
+		}
+	}
+	return false
+}
+
 func AlarmConditionList(conds egorm.Conds) (resp []*AlarmCondition, err error) {
 	sql, binds := egorm.BuildQuery(conds)
 	if err = invoker.Db.Model(AlarmCondition{}).Where(sql, binds...).Find(&resp).Error; err != nil {
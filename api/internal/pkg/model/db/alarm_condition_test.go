@@ -0,0 +1,58 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestAlarmCondition_JudgmentType(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition AlarmCondition
+		wantErr   bool
+	}{
+		{
+			name:      "threshold",
+			condition: AlarmCondition{Cond: 2, Val1: 1, Val2: 10},
+		},
+		{
+			name:      "invalid cond",
+			condition: AlarmCondition{Cond: 4},
+			wantErr:   true,
+		},
+		{
+			name:      "change",
+			condition: AlarmCondition{CondTyp: ConditionTypChange, Offset: 86400, Val1: 100},
+		},
+		{
+			name:      "change without offset",
+			condition: AlarmCondition{CondTyp: ConditionTypChange, Val1: 100},
+			wantErr:   true,
+		},
+		{
+			name:      "baseline",
+			condition: AlarmCondition{CondTyp: ConditionTypBaseline, Periods: 10, Factor: 2.5},
+		},
+		{
+			name:      "baseline without factor",
+			condition: AlarmCondition{CondTyp: ConditionTypBaseline, Periods: 10},
+			wantErr:   true,
+		},
+		{
+			name:      "seasonal too many periods",
+			condition: AlarmCondition{CondTyp: ConditionTypSeasonal, Offset: 86400, Periods: ConditionMaxPeriods + 1},
+			wantErr:   true,
+		},
+		{
+			name:      "unknown type",
+			condition: AlarmCondition{CondTyp: 9},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.condition.JudgmentType(); (err != nil) != tt.wantErr {
+				t.Errorf("JudgmentType() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"github.com/ego-component/egorm"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+)
+
+const (
+	DeliveryStatusPending = iota + 1
+	DeliveryStatusSuccess
+	DeliveryStatusFailed
+	DeliveryStatusSuperseded // a newer message of the same dedup key was sent to the channel
+)
+
+// AlarmDelivery message whose push to a channel failed, or was held back by the circuit breaker of the channel.
+// It is retried with an exponential backoff until it is delivered or runs out of attempts.
+type AlarmDelivery struct {
+	BaseModel
+
+	ChannelId  int    `gorm:"column:channel_id;type:int(11);index:idx_channel_id" json:"channelId"`
+	DedupKey   string `gorm:"column:dedup_key;type:varchar(255);default:'';NOT NULL;index:idx_dedup_key" json:"dedupKey"`
+	HistoryIds Ints   `gorm:"column:history_ids;type:text" json:"historyIds"` // histories updated once the delivery ends
+	Title      string `gorm:"column:title;type:varchar(255);default:'';NOT NULL" json:"title"`
+	Msg        string `gorm:"column:msg;type:longtext" json:"-"` // json of the PushMsg
+	Status     int    `gorm:"column:status;type:tinyint(1);index:idx_status_next_at" json:"status"`
+	Attempts   int    `gorm:"column:attempts;type:int(11);default:0" json:"attempts"`
+	NextAt     int64  `gorm:"column:next_at;type:bigint(20);default:0;index:idx_status_next_at" json:"nextAt"` // time of the next attempt
+	LastError  string `gorm:"column:last_error;type:varchar(1024);default:''" json:"lastError"`
+}
+
+func (m *AlarmDelivery) TableName() string {
+	return TableNameAlarmDelivery
+}
+
+func AlarmDeliveryInfo(db *gorm.DB, id int) (resp AlarmDelivery, err error) {
+	var sql = "`id`= ?"
+	var binds = []interface{}{id}
+	if err = db.Model(AlarmDelivery{}).Where(sql, binds...).First(&resp).Error; err != nil {
+		err = errors.Wrapf(err, "alarm delivery id: %d", id)
+		return
+	}
+	return
+}
+
+func AlarmDeliveryPage(conds egorm.Conds, reqList *ReqPage) (total int64, respList []*AlarmDelivery) {
+	respList = make([]*AlarmDelivery, 0)
+	if reqList.PageSize == 0 {
+		reqList.PageSize = 10
+	}
+	if reqList.Current == 0 {
+		reqList.Current = 1
+	}
+	sql, binds := egorm.BuildQuery(conds)
+	db := invoker.Db.Model(AlarmDelivery{}).Where(sql, binds...).Order("id desc")
+	db.Count(&total)
+	db.Offset((reqList.Current - 1) * reqList.PageSize).Limit(reqList.PageSize).Find(&respList)
+	return
+}
+
+// AlarmDeliveryDue pending deliveries whose next attempt is due, oldest first
+func AlarmDeliveryDue(db *gorm.DB, now int64, limit int) (resp []*AlarmDelivery, err error) {
+	if err = db.Model(AlarmDelivery{}).Where("`status` = ? AND `next_at` <= ?", DeliveryStatusPending, now).
+		Order("next_at asc").Limit(limit).Find(&resp).Error; err != nil {
+		err = errors.Wrapf(err, "next_at: %d", now)
+		return
+	}
+	return
+}
+
+// AlarmDeliveryClaim moves the next attempt of the delivery to leaseUntil, false when another copy claimed it first
+func AlarmDeliveryClaim(db *gorm.DB, id int, nextAt, leaseUntil int64) (bool, error) {
+	res := db.Model(AlarmDelivery{}).Where("`id` = ? AND `next_at` = ?", id, nextAt).Update("next_at", leaseUntil)
+	if res.Error != nil {
+		return false, errors.Wrapf(res.Error, "alarm delivery id: %d", id)
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// AlarmDeliverySupersede ends the pending deliveries of the dedup key to the channel and returns them
+func AlarmDeliverySupersede(db *gorm.DB, channelId int, dedupKey string) (resp []*AlarmDelivery, err error) {
+	if err = db.Model(AlarmDelivery{}).Where("`channel_id` = ? AND `dedup_key` = ? AND `status` = ?", channelId, dedupKey, DeliveryStatusPending).
+		Find(&resp).Error; err != nil || len(resp) == 0 {
+		return nil, errors.Wrapf(err, "dedup_key: %s", dedupKey)
+	}
+	ids := make([]int, 0, len(resp))
+	for _, delivery := range resp {
+		ids = append(ids, delivery.ID)
+	}
+	ups := map[string]interface{}{"status": DeliveryStatusSuperseded, "last_error": "superseded by a newer message"}
+	if err = db.Model(AlarmDelivery{}).Where("`id` IN ? AND `status` = ?", ids, DeliveryStatusPending).Updates(ups).Error; err != nil {
+		return nil, errors.Wrapf(err, "ids: %v", ids)
+	}
+	return resp, nil
+}
+
+func AlarmDeliveryCreate(db *gorm.DB, data *AlarmDelivery) (err error) {
+	if err = db.Model(AlarmDelivery{}).Create(data).Error; err != nil {
+		return errors.Wrapf(err, "alarm delivery: %v", data.Title)
+	}
+	return
+}
+
+func AlarmDeliveryUpdate(db *gorm.DB, id int, ups map[string]interface{}) (err error) {
+	var sql = "`id`=?"
+	var binds = []interface{}{id}
+	if err = db.Model(AlarmDelivery{}).Where(sql, binds...).Updates(ups).Error; err != nil {
+		return errors.Wrapf(err, "ups: %v", ups)
+	}
+	return
+}
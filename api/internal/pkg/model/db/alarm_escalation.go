@@ -0,0 +1,112 @@
+package db
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+
+	"github.com/ego-component/egorm"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// AlarmEscalation escalation policy of firing alarms which are not acknowledged.
+// The users of the first step are mentioned in the alarm message,
+// each next step is notified when the alarm is still not acknowledged after its delay.
+type AlarmEscalation struct {
+	BaseModel
+
+	Name  string          `gorm:"column:name;type:varchar(128);NOT NULL" json:"name"`
+	Desc  string          `gorm:"column:desc;type:varchar(255);default:'';NOT NULL" json:"desc"`
+	Steps EscalationSteps `gorm:"column:steps;type:text" json:"steps"`
+	Uid   int             `gorm:"column:uid;type:int(11)" json:"uid"` // creator
+}
+
+// EscalationStep users on call of the schedule and the users are mentioned in the channels
+type EscalationStep struct {
+	Delay      int  `json:"delay"`      // minutes after the alarm fired
+	OncallId   int  `json:"oncallId"`   // on-call schedule, 0 none
+	Uids       Ints `json:"uids"`       // users notified besides the on-call one
+	ChannelIds Ints `json:"channelIds"` // the channels of the alarm when empty
+}
+
+type EscalationSteps []EscalationStep
+
+func (t EscalationSteps) Value() (driver.Value, error) {
+	b, err := json.Marshal(t)
+	return string(b), err
+}
+
+func (t *EscalationSteps) Scan(input interface{}) error {
+	in, _ := input.([]byte)
+	if len(in) == 0 {
+		in = []byte("[]")
+	}
+	return json.Unmarshal(in, t)
+}
+
+func (m *AlarmEscalation) TableName() string {
+	return TableNameAlarmEscalation
+}
+
+// EscalationStepUids users notified by the step, the one on call first
+func EscalationStepUids(db *gorm.DB, step EscalationStep, now int64) (Ints, error) {
+	res := make(Ints, 0, len(step.Uids)+1)
+	if step.OncallId != 0 {
+		oncall, err := AlarmOncallInfo(db, step.OncallId)
+		if err != nil {
+			return nil, err
+		}
+		if uid := oncall.Current(now); uid != 0 {
+			res = append(res, uid)
+		}
+	}
+	for _, uid := range step.Uids {
+		if len(res) > 0 && res[0] == uid {
+			continue
+		}
+		res = append(res, uid)
+	}
+	return res, nil
+}
+
+func AlarmEscalationInfo(db *gorm.DB, id int) (resp AlarmEscalation, err error) {
+	var sql = "`id`= ?"
+	var binds = []interface{}{id}
+	if err = db.Model(AlarmEscalation{}).Where(sql, binds...).First(&resp).Error; err != nil {
+		err = errors.Wrapf(err, "alarm escalation id: %d", id)
+		return
+	}
+	return
+}
+
+func AlarmEscalationList(db *gorm.DB, conds egorm.Conds) (resp []*AlarmEscalation, err error) {
+	sql, binds := egorm.BuildQuery(conds)
+	if err = db.Model(AlarmEscalation{}).Where(sql, binds...).Order("id desc").Find(&resp).Error; err != nil {
+		err = errors.Wrapf(err, "conds: %v", conds)
+		return
+	}
+	return
+}
+
+func AlarmEscalationCreate(db *gorm.DB, data *AlarmEscalation) (err error) {
+	if err = db.Model(AlarmEscalation{}).Create(data).Error; err != nil {
+		return errors.Wrapf(err, "alarm escalation: %v", data)
+	}
+	return
+}
+
+func AlarmEscalationUpdate(db *gorm.DB, id int, ups map[string]interface{}) (err error) {
+	var sql = "`id`=?"
+	var binds = []interface{}{id}
+	if err = db.Model(AlarmEscalation{}).Where(sql, binds...).Updates(ups).Error; err != nil {
+		return errors.Wrapf(err, "ups: %v", ups)
+	}
+	return
+}
+
+func AlarmEscalationDelete(db *gorm.DB, id int) (err error) {
+	if err = db.Model(AlarmEscalation{}).Unscoped().Delete(&AlarmEscalation{}, id).Error; err != nil {
+		return errors.Wrapf(err, "alarm escalation id: %d", id)
+	}
+	return
+}
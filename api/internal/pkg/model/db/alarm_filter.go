@@ -16,12 +16,25 @@ type AlarmFilter struct {
 	SetOperatorExp string `gorm:"column:set_operator_exp;type:varchar(255);NOT NULL" json:"exp"` // 操作
 	Mode           int    `gorm:"column:mode;type:int(11)" json:"mode"`                          // 0 m 1 s 2 h 3 d 4 w 5 y
 	Status         int    `gorm:"column:status;type:int(11)" json:"status"`
+	TraceParent    string `gorm:"column:trace_parent;type:varchar(255)" json:"traceParent"` // parent service of the edge, trace edge mode
+	TraceChild     string `gorm:"column:trace_child;type:varchar(255)" json:"traceChild"`   // child service of the edge, trace edge mode
+	TraceMetric    string `gorm:"column:trace_metric;type:varchar(32)" json:"traceMetric"`  // callCount, p99 or successRate, trace edge mode
 }
 
 func (m *AlarmFilter) TableName() string {
 	return TableNameAlarmFilter
 }
 
+// IsAggregation the when of the filter is a sql whose val column is the metric
+func (m *AlarmFilter) IsAggregation() bool {
+	return IsAggregationMode(m.Mode)
+}
+
+// IsAggregationMode aggregation filters and trace edge filters compiled to aggregation sql
+func IsAggregationMode(mode int) bool {
+	return mode == AlarmModeAggregation || mode == AlarmModeAggregationCheck || mode == AlarmModeTraceEdge
+}
+
 func (m *AlarmFilter) UpdateStatus(db *gorm.DB) error {
 	ups := make(map[string]interface{}, 0)
 	ups["status"] = m.Status
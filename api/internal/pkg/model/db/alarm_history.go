@@ -14,6 +14,15 @@ const (
 	PushedStatusRepeat = iota
 	PushedStatusSuccess
 	PushedStatusFail
+	PushedStatusSilenced
+	PushedStatusPending
+	PushedStatusGrouped
+)
+
+const (
+	HistoryTypNotification = iota
+	HistoryTypEscalation
+	HistoryTypAck
 )
 
 // AlarmHistory 告警渠道
@@ -23,7 +32,15 @@ type AlarmHistory struct {
 	AlarmId      int `gorm:"column:alarm_id;type:int(11)" json:"alarmId"`   // alarm id
 	FilterId     int `gorm:"column:filter_id;type:int(11)" json:"filterId"` // filter id
 	FilterStatus int `gorm:"column:filter_status;type:int(11)" json:"filterStatus"`
-	IsPushed     int `gorm:"column:is_pushed;type:int(11)" json:"isPushed"` // alarm id
+	IsPushed     int `gorm:"column:is_pushed;type:int(11)" json:"isPushed"`                 // 0 repeat 1 success 2 fail 3 silenced 4 pending 5 grouped
+	SilenceId    int `gorm:"column:silence_id;type:int(11);default:0" json:"silenceId"`     // silence which suppressed the notification
+	Typ          int `gorm:"column:typ;type:int(11);default:0" json:"typ"`                  // 0 notification 1 escalation 2 acknowledgement
+	Step         int `gorm:"column:step;type:int(11);default:0" json:"step"`                // escalation step
+	Uid          int `gorm:"column:uid;type:int(11);default:0" json:"uid"`                  // user who acknowledged
+	CompositeId  int `gorm:"column:composite_id;type:int(11);default:0" json:"compositeId"` // composite alarm, the alarm id is 0
+	WatchdogId   int `gorm:"column:watchdog_id;type:int(11);default:0" json:"watchdogId"`   // watchdog of a storage, the alarm id is 0
+	// IsIncidentStart 1 when the notification fired the alarm while none of its filters was firing
+	IsIncidentStart int `gorm:"column:is_incident_start;type:tinyint(1);default:0" json:"isIncidentStart"`
 }
 
 func (m *AlarmHistory) TableName() string {
@@ -71,3 +88,30 @@ func AlarmHistoryUpdate(db *gorm.DB, id int, ups map[string]interface{}) (err er
 	}
 	return
 }
+
+// AlarmHistoryUpdatePushed moves the histories of a push status to another one
+func AlarmHistoryUpdatePushed(db *gorm.DB, from, to int) (err error) {
+	if err = db.Model(AlarmHistory{}).Where("`is_pushed` = ?", from).Update("is_pushed", to).Error; err != nil {
+		return errors.Wrapf(err, "is_pushed: %d", from)
+	}
+	return
+}
+
+// AlarmHistoryList histories of the alarm in id order
+func AlarmHistoryList(db *gorm.DB, conds egorm.Conds) (resp []*AlarmHistory, err error) {
+	sql, binds := egorm.BuildQuery(conds)
+	if err = db.Model(AlarmHistory{}).Where(sql, binds...).Order("id asc").Find(&resp).Error; err != nil {
+		err = errors.Wrapf(err, "conds: %v", conds)
+		return
+	}
+	return
+}
+
+// AlarmHistoryLatest latest history matching the conds, gorm.ErrRecordNotFound when there is none
+func AlarmHistoryLatest(db *gorm.DB, conds egorm.Conds) (resp AlarmHistory, err error) {
+	sql, binds := egorm.BuildQuery(conds)
+	if err = db.Model(AlarmHistory{}).Where(sql, binds...).Order("id desc").First(&resp).Error; err != nil {
+		return
+	}
+	return
+}
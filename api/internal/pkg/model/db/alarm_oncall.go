@@ -0,0 +1,116 @@
+package db
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+
+	"github.com/ego-component/egorm"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	OncallRotationDaily = iota
+	OncallRotationWeekly
+)
+
+// AlarmOncall on-call rotation, the users take turns for a day or a week from StartTime
+type AlarmOncall struct {
+	BaseModel
+
+	Name      string          `gorm:"column:name;type:varchar(128);NOT NULL" json:"name"`
+	Desc      string          `gorm:"column:desc;type:varchar(255);default:'';NOT NULL" json:"desc"`
+	Rotation  int             `gorm:"column:rotation;type:int(11);default:0;NOT NULL" json:"rotation"` // 0 daily 1 weekly
+	Users     Ints            `gorm:"column:users;type:varchar(255);NOT NULL" json:"users"`            // user ids in the order of the shifts
+	StartTime int64           `gorm:"column:start_time;type:bigint(20);default:0;NOT NULL" json:"startTime"`
+	Overrides OncallOverrides `gorm:"column:overrides;type:text" json:"overrides"` // shifts taken by another user
+	Uid       int             `gorm:"column:uid;type:int(11)" json:"uid"`          // creator
+}
+
+// OncallOverride the user is on call instead during the time range
+type OncallOverride struct {
+	Uid       int   `json:"uid"`
+	StartTime int64 `json:"startTime"`
+	EndTime   int64 `json:"endTime"`
+}
+
+type OncallOverrides []OncallOverride
+
+func (t OncallOverrides) Value() (driver.Value, error) {
+	b, err := json.Marshal(t)
+	return string(b), err
+}
+
+func (t *OncallOverrides) Scan(input interface{}) error {
+	in, _ := input.([]byte)
+	if len(in) == 0 {
+		in = []byte("[]")
+	}
+	return json.Unmarshal(in, t)
+}
+
+func (m *AlarmOncall) TableName() string {
+	return TableNameAlarmOncall
+}
+
+// Current user on call at the time, overrides first, 0 when the schedule has no users
+func (m *AlarmOncall) Current(now int64) int {
+	for _, o := range m.Overrides {
+		if o.StartTime <= now && now < o.EndTime {
+			return o.Uid
+		}
+	}
+	if len(m.Users) == 0 {
+		return 0
+	}
+	if now < m.StartTime {
+		return m.Users[0]
+	}
+	shift := int64(24 * 3600)
+	if m.Rotation == OncallRotationWeekly {
+		shift *= 7
+	}
+	return m.Users[int((now-m.StartTime)/shift)%len(m.Users)]
+}
+
+func AlarmOncallInfo(db *gorm.DB, id int) (resp AlarmOncall, err error) {
+	var sql = "`id`= ?"
+	var binds = []interface{}{id}
+	if err = db.Model(AlarmOncall{}).Where(sql, binds...).First(&resp).Error; err != nil {
+		err = errors.Wrapf(err, "alarm oncall id: %d", id)
+		return
+	}
+	return
+}
+
+func AlarmOncallList(db *gorm.DB, conds egorm.Conds) (resp []*AlarmOncall, err error) {
+	sql, binds := egorm.BuildQuery(conds)
+	if err = db.Model(AlarmOncall{}).Where(sql, binds...).Order("id desc").Find(&resp).Error; err != nil {
+		err = errors.Wrapf(err, "conds: %v", conds)
+		return
+	}
+	return
+}
+
+func AlarmOncallCreate(db *gorm.DB, data *AlarmOncall) (err error) {
+	if err = db.Model(AlarmOncall{}).Create(data).Error; err != nil {
+		return errors.Wrapf(err, "alarm oncall: %v", data)
+	}
+	return
+}
+
+func AlarmOncallUpdate(db *gorm.DB, id int, ups map[string]interface{}) (err error) {
+	var sql = "`id`=?"
+	var binds = []interface{}{id}
+	if err = db.Model(AlarmOncall{}).Where(sql, binds...).Updates(ups).Error; err != nil {
+		return errors.Wrapf(err, "ups: %v", ups)
+	}
+	return
+}
+
+func AlarmOncallDelete(db *gorm.DB, id int) (err error) {
+	if err = db.Model(AlarmOncall{}).Unscoped().Delete(&AlarmOncall{}, id).Error; err != nil {
+		return errors.Wrapf(err, "alarm oncall id: %d", id)
+	}
+	return
+}
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestAlarmOncall_Current(t *testing.T) {
+	const day = 24 * 3600
+	oncall := &AlarmOncall{
+		Rotation:  OncallRotationDaily,
+		Users:     Ints{1, 2, 3},
+		StartTime: 1000,
+		Overrides: OncallOverrides{{Uid: 9, StartTime: 1000 + 4*day, EndTime: 1000 + 5*day}},
+	}
+	weekly := &AlarmOncall{Rotation: OncallRotationWeekly, Users: Ints{1, 2}, StartTime: 1000}
+	tests := []struct {
+		name   string
+		oncall *AlarmOncall
+		now    int64
+		want   int
+	}{
+		{name: "before start", oncall: oncall, now: 0, want: 1},
+		{name: "first shift", oncall: oncall, now: 1000 + day - 1, want: 1},
+		{name: "second shift", oncall: oncall, now: 1000 + day, want: 2},
+		{name: "rotation wraps", oncall: oncall, now: 1000 + 3*day, want: 1},
+		{name: "override", oncall: oncall, now: 1000 + 4*day, want: 9},
+		{name: "after override", oncall: oncall, now: 1000 + 5*day, want: 3},
+		{name: "weekly", oncall: weekly, now: 1000 + 6*day, want: 1},
+		{name: "next week", oncall: weekly, now: 1000 + 7*day, want: 2},
+		{name: "no users", oncall: &AlarmOncall{}, now: 1000, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.oncall.Current(tt.now); got != tt.want {
+				t.Errorf("Current() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
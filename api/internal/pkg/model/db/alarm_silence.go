@@ -0,0 +1,81 @@
+package db
+
+import (
+	"github.com/ego-component/egorm"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// AlarmSilence mutes the notifications of the matched alarms,
+// it is a recurring maintenance window when Cron is set
+type AlarmSilence struct {
+	BaseModel
+
+	AlarmId   int           `gorm:"column:alarm_id;type:int(11);default:0;NOT NULL" json:"alarmId"` // alarm id, 0 matches any alarm
+	Tid       int           `gorm:"column:tid;type:int(11);default:0;NOT NULL" json:"tid"`          // table id, 0 matches any table
+	Iid       int           `gorm:"column:iid;type:int(11);default:0;NOT NULL" json:"iid"`          // instance id, 0 matches any instance
+	Matchers  String2String `gorm:"column:matchers;type:text" json:"matchers"`                      // alarm tags which must all be equal
+	StartTime int64         `gorm:"column:start_time;type:bigint(20);default:0;NOT NULL" json:"startTime"`
+	EndTime   int64         `gorm:"column:end_time;type:bigint(20);default:0;NOT NULL" json:"endTime"` // 0 never ends
+	Cron      string        `gorm:"column:cron;type:varchar(128);default:'';NOT NULL" json:"cron"`     // start of each maintenance window, standard cron expression
+	Duration  int           `gorm:"column:duration;type:int(11);default:0;NOT NULL" json:"duration"`   // seconds of each maintenance window
+	Uid       int           `gorm:"column:uid;type:int(11)" json:"uid"`                                // creator
+	Reason    string        `gorm:"column:reason;type:varchar(255);default:'';NOT NULL" json:"reason"`
+
+	User *User `json:"user,omitempty" gorm:"foreignKey:uid;references:id"`
+}
+
+func (m *AlarmSilence) TableName() string {
+	return TableNameAlarmSilence
+}
+
+func AlarmSilenceInfo(db *gorm.DB, id int) (resp AlarmSilence, err error) {
+	var sql = "`id`= ?"
+	var binds = []interface{}{id}
+	if err = db.Model(AlarmSilence{}).Where(sql, binds...).First(&resp).Error; err != nil {
+		err = errors.Wrapf(err, "alarm silence id: %d", id)
+		return
+	}
+	return
+}
+
+func AlarmSilenceList(db *gorm.DB, conds egorm.Conds) (resp []*AlarmSilence, err error) {
+	sql, binds := egorm.BuildQuery(conds)
+	if err = db.Model(AlarmSilence{}).Preload("User").Where(sql, binds...).Order("id desc").Find(&resp).Error; err != nil {
+		err = errors.Wrapf(err, "conds: %v", conds)
+		return
+	}
+	return
+}
+
+// AlarmSilenceListActive silences whose time range contains now
+func AlarmSilenceListActive(db *gorm.DB, now int64) (resp []*AlarmSilence, err error) {
+	if err = db.Model(AlarmSilence{}).Where("`start_time` <= ? AND (`end_time` = 0 OR `end_time` > ?)", now, now).Find(&resp).Error; err != nil {
+		err = errors.Wrapf(err, "now: %d", now)
+		return
+	}
+	return
+}
+
+func AlarmSilenceCreate(db *gorm.DB, data *AlarmSilence) (err error) {
+	if err = db.Model(AlarmSilence{}).Create(data).Error; err != nil {
+		return errors.Wrapf(err, "alarm silence: %v", data)
+	}
+	return
+}
+
+func AlarmSilenceUpdate(db *gorm.DB, id int, ups map[string]interface{}) (err error) {
+	var sql = "`id`=?"
+	var binds = []interface{}{id}
+	if err = db.Model(AlarmSilence{}).Where(sql, binds...).Updates(ups).Error; err != nil {
+		return errors.Wrapf(err, "ups: %v", ups)
+	}
+	return
+}
+
+func AlarmSilenceDelete(db *gorm.DB, id int) (err error) {
+	if err = db.Model(AlarmSilence{}).Unscoped().Delete(&AlarmSilence{}, id).Error; err != nil {
+		return errors.Wrapf(err, "alarm silence id: %d", id)
+	}
+	return
+}
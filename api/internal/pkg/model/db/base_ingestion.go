@@ -0,0 +1,59 @@
+package db
+
+import (
+	"github.com/ego-component/egorm"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+const (
+	IngestionStatusNormal = iota
+	IngestionStatusStalled
+)
+
+// BaseIngestion ingestion monitor settings of a kafka engine storage
+type BaseIngestion struct {
+	BaseModel
+
+	Tid            int  `gorm:"column:tid;type:int(11);index:uix_tid,unique" json:"tid"`
+	StallSeconds   int  `gorm:"column:stall_seconds;type:int(11);default:600;NOT NULL" json:"stallSeconds"`   // no new data for these seconds means stalled
+	ErrorThreshold int  `gorm:"column:error_threshold;type:int(11);default:0;NOT NULL" json:"errorThreshold"` // consumer errors plus dead-letter messages in one check, 0 means ignored
+	ChannelIds     Ints `gorm:"column:channel_ids;type:varchar(255)" json:"channelIds"`                       // alarm channels, empty means no alarm
+	Status         int  `gorm:"column:status;type:tinyint(1);default:0;NOT NULL" json:"status"`               // 0 normal 1 stalled
+}
+
+func (m *BaseIngestion) TableName() string {
+	return TableNameBaseIngestion
+}
+
+func IngestionInfoX(db *gorm.DB, conds map[string]interface{}) (resp BaseIngestion, err error) {
+	sql, binds := egorm.BuildQuery(conds)
+	if err = db.Model(BaseIngestion{}).Where(sql, binds...).First(&resp).Error; err != nil && err != gorm.ErrRecordNotFound {
+		return resp, errors.Wrapf(err, "conds: %v", conds)
+	}
+	return resp, nil
+}
+
+func IngestionList(db *gorm.DB, conds egorm.Conds) (resp []*BaseIngestion, err error) {
+	sql, binds := egorm.BuildQuery(conds)
+	if err = db.Model(BaseIngestion{}).Where(sql, binds...).Find(&resp).Error; err != nil {
+		return nil, errors.Wrapf(err, "conds: %v", conds)
+	}
+	return
+}
+
+func IngestionCreate(db *gorm.DB, data *BaseIngestion) (err error) {
+	if err = db.Model(BaseIngestion{}).Create(data).Error; err != nil {
+		return errors.Wrapf(err, "data: %v", data)
+	}
+	return
+}
+
+func IngestionUpdate(db *gorm.DB, id int, ups map[string]interface{}) (err error) {
+	var sql = "`id`=?"
+	var binds = []interface{}{id}
+	if err = db.Model(BaseIngestion{}).Where(sql, binds...).Updates(ups).Error; err != nil {
+		return errors.Wrapf(err, "ups: %v", ups)
+	}
+	return
+}
@@ -42,6 +42,16 @@ type BaseInstance struct {
 	K8sConfigmap string `gorm:"column:configmap;type:varchar(128)" json:"configmap"` // configmap
 	// operator
 	ConfigPrometheusOperator string `gorm:"column:config_prometheus_operator;type:text" json:"ConfigPrometheusOperator"` // configmap
+	// ruler
+	RulerUrl       string `gorm:"column:ruler_url;type:varchar(255)" json:"rulerUrl"`             // rules endpoint of the ruler api, eg: http://mimir:8080/prometheus/config/v1/rules
+	RulerNamespace string `gorm:"column:ruler_namespace;type:varchar(128)" json:"rulerNamespace"` // namespace of the rule groups
+	RulerTenant    string `gorm:"column:ruler_tenant;type:varchar(128)" json:"rulerTenant"`       // X-Scope-OrgID of multi-tenant rulers
+	// evaluator
+	AlertEvaluator int `gorm:"column:alert_evaluator;type:int(11);default:0;NOT NULL" json:"alertEvaluator"` // alert_evaluator 0 prometheus 1 native
+	// webhook
+	WebhookAuth     int     `gorm:"column:webhook_auth;type:int(11);default:0;NOT NULL" json:"webhookAuth"` // webhook_auth 0 none 1 bearer token 2 hmac signature
+	WebhookSecret   string  `gorm:"column:webhook_secret;type:varchar(255);default:''" json:"-"`            // token or hmac key of the webhook
+	WebhookAllowIPs Strings `gorm:"column:webhook_allow_ips;type:text" json:"webhookAllowIps"`              // ips or cidrs allowed to call the webhook
 }
 
 func (b *BaseInstance) TableName() string {
@@ -52,6 +62,11 @@ func (b *BaseInstance) DsKey() string {
 	return InstanceKey(b.ID)
 }
 
+// IsRuleStoreByRule rules are stored one by one, the prometheus operator stores the rules of an alarm as one group
+func (b *BaseInstance) IsRuleStoreByRule() bool {
+	return b.RuleStoreType == RuleStoreTypeFile || b.RuleStoreType == RuleStoreTypeK8sConfigMap || b.RuleStoreType == RuleStoreTypeRuler
+}
+
 func (b *BaseInstance) GetRuleStoreKey() string {
 	return fmt.Sprintf("%d_%d", b.K8sClusterId, b.ID)
 }
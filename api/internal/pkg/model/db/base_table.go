@@ -6,6 +6,8 @@ import (
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/constx"
 )
 
 const (
@@ -39,6 +41,8 @@ type BaseTable struct {
 	TimeField               string `gorm:"column:time_field;type:varchar(128);NOT NULL" json:"timeField"`
 	RawLogField             string `gorm:"column:raw_log_field;type:varchar(255)" json:"rawLogField"`
 	KafkaSkipBrokenMessages int    `gorm:"column:kafka_skip_broken_messages;type:int(11)" json:"kafkaSkipBrokenMessages"`
+	RawLogIndex             int    `gorm:"column:raw_log_index;type:tinyint(1);default:0;NOT NULL" json:"rawLogIndex"` // raw log full-text index: 0 default 1 tokenbf_v1 2 inverted 3 none
+	DeadLetter              int    `gorm:"column:dead_letter;type:tinyint(1);default:0;NOT NULL" json:"deadLetter"`    // 1 unparsable messages are stored in <table>_dlq
 
 	// Deprecated: use CreateType instead
 	IsKafkaTimestamp int `gorm:"column:is_kafka_timestamp;type:tinyint(1)" json:"isKafkaTimestamp"`
@@ -71,6 +75,19 @@ func (b *BaseTable) TableName() string {
 	return TableNameBaseTable
 }
 
+// GetRawLogIndex full-text index of the raw log field, the tables created by clickvisual
+// have a tokenbf_v1 one unless it was changed, the existing tables have none
+func (b *BaseTable) GetRawLogIndex() int {
+	if b.RawLogIndex != RawLogIndexDefault {
+		return b.RawLogIndex
+	}
+	switch b.CreateType {
+	case constx.TableCreateTypeExist, constx.TableCreateTypeTraceCalculation:
+		return RawLogIndexNone
+	}
+	return RawLogIndexTokenBF
+}
+
 func (b *BaseTable) GetTimeField() string {
 	if b.TimeField == "" {
 		return TimeFieldSecond
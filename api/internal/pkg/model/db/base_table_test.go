@@ -0,0 +1,48 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/constx"
+)
+
+func TestBaseTable_GetRawLogIndex(t *testing.T) {
+	tests := []struct {
+		name  string
+		table BaseTable
+		want  int
+	}{
+		{
+			name:  "created by clickvisual",
+			table: BaseTable{CreateType: constx.TableCreateTypeJSONEachRow},
+			want:  RawLogIndexTokenBF,
+		},
+		{
+			name:  "buffer null data pipe",
+			table: BaseTable{CreateType: constx.TableCreateTypeBufferNullDataPipe},
+			want:  RawLogIndexTokenBF,
+		},
+		{
+			name:  "existing table",
+			table: BaseTable{CreateType: constx.TableCreateTypeExist},
+			want:  RawLogIndexNone,
+		},
+		{
+			name:  "index dropped",
+			table: BaseTable{CreateType: constx.TableCreateTypeJSONEachRow, RawLogIndex: RawLogIndexNone},
+			want:  RawLogIndexNone,
+		},
+		{
+			name:  "inverted index",
+			table: BaseTable{CreateType: constx.TableCreateTypeExist, RawLogIndex: RawLogIndexInverted},
+			want:  RawLogIndexInverted,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.table.GetRawLogIndex(); got != tt.want {
+				t.Errorf("GetRawLogIndex() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"strings"
+
+	"github.com/ego-component/egorm"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// anomalies found by the watchdog of a storage
+const (
+	WatchdogVolumeZero  = "volumeZero"
+	WatchdogVolumeSpike = "volumeSpike"
+	WatchdogErrorRatio  = "errorRatio"
+	WatchdogNewError    = "newError"
+)
+
+// WatchdogAnomaly anomaly of the kind, the new error patterns are told apart by their fingerprint
+func WatchdogAnomaly(kind, fingerprint string) string {
+	if fingerprint == "" {
+		return kind
+	}
+	return kind + ":" + fingerprint
+}
+
+// WatchdogAnomalyKind kind and fingerprint of the anomaly
+func WatchdogAnomalyKind(anomaly string) (kind, fingerprint string) {
+	kind, fingerprint, _ = strings.Cut(anomaly, ":")
+	return kind, fingerprint
+}
+
+// BaseWatchdog anomaly detection of a storage without alarm rules,
+// the usual volume and error ratio of each hour of the day are learned from the checked windows
+type BaseWatchdog struct {
+	BaseModel
+
+	Tid        int              `gorm:"column:tid;type:int(11);index:uix_tid,unique" json:"tid"`
+	Enabled    int              `gorm:"column:enabled;type:tinyint(1);default:0;NOT NULL" json:"enabled"`      // 1 on
+	ChannelIds Ints             `gorm:"column:channel_ids;type:varchar(255)" json:"channelIds"`                // the channels of the alarms of the storage when empty
+	Baseline   WatchdogBaseline `gorm:"column:baseline;type:text" json:"baseline"`                             // learned statistics by hour of the day
+	Patterns   Strings          `gorm:"column:patterns;type:text" json:"-"`                                    // fingerprints of the known error patterns
+	Anomalies  Strings          `gorm:"column:anomalies;type:text" json:"anomalies"`                           // anomalies firing, newError:<fingerprint> until the next window
+	CheckedAt  int64            `gorm:"column:checked_at;type:bigint(20);default:0;NOT NULL" json:"checkedAt"` // end of the last checked window
+}
+
+// WatchdogHour statistics of the checked windows of an hour of the day
+type WatchdogHour struct {
+	Samples   int     `json:"samples"`
+	Mean      float64 `json:"mean"` // logs per window
+	Var       float64 `json:"var"`
+	ErrorMean float64 `json:"errorMean"` // ratio of the error logs
+	ErrorVar  float64 `json:"errorVar"`
+}
+
+type WatchdogBaseline [24]WatchdogHour
+
+func (t WatchdogBaseline) Value() (driver.Value, error) {
+	b, err := json.Marshal(t)
+	return string(b), err
+}
+
+func (t *WatchdogBaseline) Scan(input interface{}) error {
+	in, _ := input.([]byte)
+	if len(in) == 0 {
+		*t = WatchdogBaseline{}
+		return nil
+	}
+	return json.Unmarshal(in, t)
+}
+
+func (m *BaseWatchdog) TableName() string {
+	return TableNameBaseWatchdog
+}
+
+func WatchdogInfoX(db *gorm.DB, conds map[string]interface{}) (resp BaseWatchdog, err error) {
+	sql, binds := egorm.BuildQuery(conds)
+	if err = db.Model(BaseWatchdog{}).Where(sql, binds...).First(&resp).Error; err != nil && err != gorm.ErrRecordNotFound {
+		return resp, errors.Wrapf(err, "conds: %v", conds)
+	}
+	return resp, nil
+}
+
+func WatchdogList(db *gorm.DB, conds egorm.Conds) (resp []*BaseWatchdog, err error) {
+	sql, binds := egorm.BuildQuery(conds)
+	if err = db.Model(BaseWatchdog{}).Where(sql, binds...).Find(&resp).Error; err != nil {
+		return nil, errors.Wrapf(err, "conds: %v", conds)
+	}
+	return
+}
+
+func WatchdogCreate(db *gorm.DB, data *BaseWatchdog) (err error) {
+	if err = db.Model(BaseWatchdog{}).Create(data).Error; err != nil {
+		return errors.Wrapf(err, "data: %v", data)
+	}
+	return
+}
+
+func WatchdogUpdate(db *gorm.DB, id int, ups map[string]interface{}) (err error) {
+	var sql = "`id`=?"
+	var binds = []interface{}{id}
+	if err = db.Model(BaseWatchdog{}).Where(sql, binds...).Updates(ups).Error; err != nil {
+		return errors.Wrapf(err, "ups: %v", ups)
+	}
+	return
+}
+
+// WatchdogDeleteByTid the watchdog goes with its storage
+func WatchdogDeleteByTid(db *gorm.DB, tid int) (err error) {
+	if err = db.Model(BaseWatchdog{}).Where("`tid` = ?", tid).Unscoped().Delete(&BaseWatchdog{}).Error; err != nil {
+		return errors.Wrapf(err, "tid: %d", tid)
+	}
+	return
+}
@@ -61,12 +61,29 @@ const (
 	OpnClustersConfigMapCreate = "opn_clusters_config_map_create"
 	OpnClustersConfigMapUpdate = "opn_clusters_config_map_update"
 
-	OpnAlarmsDelete         = "opn_alarms_delete"
-	OpnAlarmsCreate         = "opn_alarms_create"
-	OpnAlarmsUpdate         = "opn_alarms_update"
-	OpnAlarmsChannelsDelete = "opn_alarms_channels_delete"
-	OpnAlarmsChannelsCreate = "opn_alarms_channels_create"
-	OpnAlarmsChannelsUpdate = "opn_alarms_channels_update"
+	OpnAlarmsDelete            = "opn_alarms_delete"
+	OpnAlarmsCreate            = "opn_alarms_create"
+	OpnAlarmsUpdate            = "opn_alarms_update"
+	OpnAlarmsChannelsDelete    = "opn_alarms_channels_delete"
+	OpnAlarmsChannelsCreate    = "opn_alarms_channels_create"
+	OpnAlarmsChannelsUpdate    = "opn_alarms_channels_update"
+	OpnAlarmsSilencesDelete    = "opn_alarms_silences_delete"
+	OpnAlarmsSilencesCreate    = "opn_alarms_silences_create"
+	OpnAlarmsSilencesUpdate    = "opn_alarms_silences_update"
+	OpnAlarmsOncallsDelete     = "opn_alarms_oncalls_delete"
+	OpnAlarmsOncallsCreate     = "opn_alarms_oncalls_create"
+	OpnAlarmsOncallsUpdate     = "opn_alarms_oncalls_update"
+	OpnAlarmsEscalationsDelete = "opn_alarms_escalations_delete"
+	OpnAlarmsEscalationsCreate = "opn_alarms_escalations_create"
+	OpnAlarmsEscalationsUpdate = "opn_alarms_escalations_update"
+	OpnAlarmsAck               = "opn_alarms_ack"
+	OpnAlarmsCodeApply         = "opn_alarms_code_apply"
+	OpnAlarmsRuleRepair        = "opn_alarms_rule_repair"
+	OpnAlarmsCompositesDelete  = "opn_alarms_composites_delete"
+	OpnAlarmsCompositesCreate  = "opn_alarms_composites_create"
+	OpnAlarmsCompositesUpdate  = "opn_alarms_composites_update"
+	OpnAlarmsDeliveriesResend  = "opn_alarms_deliveries_resend"
+	OpnAlarmsWebhookReject     = "opn_alarms_webhook_reject"
 
 	OpnBigDataNodeCreate        = "opn_big_data_node_create"
 	OpnBigDataNodeUpdate        = "opn_big_data_node_update"
@@ -127,12 +144,29 @@ var OperationMap = map[string]string{
 	OpnClustersConfigMapCreate: "cluster configmap create",
 	OpnClustersConfigMapUpdate: "cluster configmap update",
 
-	OpnAlarmsDelete:         "alarm delete",
-	OpnAlarmsCreate:         "alarm create",
-	OpnAlarmsUpdate:         "alarm update",
-	OpnAlarmsChannelsDelete: "alarm channel delete",
-	OpnAlarmsChannelsCreate: "alarm channel create",
-	OpnAlarmsChannelsUpdate: "alarm channel update",
+	OpnAlarmsDelete:            "alarm delete",
+	OpnAlarmsCreate:            "alarm create",
+	OpnAlarmsUpdate:            "alarm update",
+	OpnAlarmsChannelsDelete:    "alarm channel delete",
+	OpnAlarmsChannelsCreate:    "alarm channel create",
+	OpnAlarmsChannelsUpdate:    "alarm channel update",
+	OpnAlarmsSilencesDelete:    "alarm silence delete",
+	OpnAlarmsSilencesCreate:    "alarm silence create",
+	OpnAlarmsSilencesUpdate:    "alarm silence update",
+	OpnAlarmsOncallsDelete:     "alarm on-call schedule delete",
+	OpnAlarmsOncallsCreate:     "alarm on-call schedule create",
+	OpnAlarmsOncallsUpdate:     "alarm on-call schedule update",
+	OpnAlarmsEscalationsDelete: "alarm escalation policy delete",
+	OpnAlarmsEscalationsCreate: "alarm escalation policy create",
+	OpnAlarmsEscalationsUpdate: "alarm escalation policy update",
+	OpnAlarmsAck:               "alarm acknowledge",
+	OpnAlarmsCodeApply:         "alarm code apply",
+	OpnAlarmsRuleRepair:        "alarm rule repair",
+	OpnAlarmsCompositesDelete:  "composite alarm delete",
+	OpnAlarmsCompositesCreate:  "composite alarm create",
+	OpnAlarmsCompositesUpdate:  "composite alarm update",
+	OpnAlarmsDeliveriesResend:  "alarm delivery resend",
+	OpnAlarmsWebhookReject:     "alertmanager webhook rejected",
 
 	OpnMigration: "upgrading the database structure",
 
@@ -214,6 +248,23 @@ var (
 			OpnAlarmsChannelsDelete,
 			OpnAlarmsChannelsCreate,
 			OpnAlarmsChannelsUpdate,
+			OpnAlarmsSilencesDelete,
+			OpnAlarmsSilencesCreate,
+			OpnAlarmsSilencesUpdate,
+			OpnAlarmsOncallsDelete,
+			OpnAlarmsOncallsCreate,
+			OpnAlarmsOncallsUpdate,
+			OpnAlarmsEscalationsDelete,
+			OpnAlarmsEscalationsCreate,
+			OpnAlarmsEscalationsUpdate,
+			OpnAlarmsAck,
+			OpnAlarmsCodeApply,
+			OpnAlarmsRuleRepair,
+			OpnAlarmsCompositesDelete,
+			OpnAlarmsCompositesCreate,
+			OpnAlarmsCompositesUpdate,
+			OpnAlarmsDeliveriesResend,
+			OpnAlarmsWebhookReject,
 		},
 		SourceConfigMgtCenter: {
 			OpnConfigsDelete,
@@ -23,6 +23,14 @@ const (
 	V3TableTypeJaegerJSON
 )
 
+// full-text index type of the raw log field
+const (
+	RawLogIndexDefault  = iota // the one of the create type, see BaseTable.GetRawLogIndex
+	RawLogIndexTokenBF         // tokenbf_v1
+	RawLogIndexInverted        // inverted
+	RawLogIndexNone            // no index
+)
+
 const (
 	DatasourceMySQL      = "mysql"
 	DatasourceClickHouse = "ch"
@@ -69,12 +77,20 @@ const (
 	TableNameBaseInstance    = "cv_base_instance"
 	TableNameBaseShortURL    = "cv_base_short_url"
 	TableNameBaseHiddenField = "cv_base_hidden_field"
-
-	TableNameAlarm          = "cv_alarm"
-	TableNameAlarmFilter    = "cv_alarm_filter"
-	TableNameAlarmHistory   = "cv_alarm_history"
-	TableNameAlarmChannel   = "cv_alarm_channel"
-	TableNameAlarmCondition = "cv_alarm_condition"
+	TableNameBaseIngestion   = "cv_base_ingestion"
+	TableNameBaseWatchdog    = "cv_base_watchdog"
+
+	TableNameAlarm           = "cv_alarm"
+	TableNameAlarmFilter     = "cv_alarm_filter"
+	TableNameAlarmHistory    = "cv_alarm_history"
+	TableNameAlarmChannel    = "cv_alarm_channel"
+	TableNameAlarmCondition  = "cv_alarm_condition"
+	TableNameAlarmSilence    = "cv_alarm_silence"
+	TableNameAlarmOncall     = "cv_alarm_oncall"
+	TableNameAlarmEscalation = "cv_alarm_escalation"
+	TableNameAlarmChart      = "cv_alarm_chart"
+	TableNameAlarmComposite  = "cv_alarm_composite"
+	TableNameAlarmDelivery   = "cv_alarm_delivery"
 
 	TableNameConfiguration        = "cv_configuration"
 	TableNameConfigurationHistory = "cv_configuration_history"
@@ -146,7 +162,8 @@ func (t Strings) Value() (driver.Value, error) {
 }
 
 func (t *Strings) Scan(input interface{}) error {
-	in := input.([]byte)
+	// nil for NULL columns added to existing rows
+	in, _ := input.([]byte)
 	if len(in) == 0 {
 		in = []byte("[]")
 	}
@@ -20,6 +20,11 @@ type ReqAlarmCreate struct {
 	Level            int                       `json:"level" form:"level"`
 	DutyOfficers     []int                     `json:"dutyOfficers" form:"dutyOfficers"`
 	IsDisableResolve int                       `json:"isDisableResolve" form:"isDisableResolve"`
+	EscalationId     int                       `json:"escalationId" form:"escalationId"`
+	Template         string                    `json:"template" form:"template"`
+	NotifyLogs       int                       `json:"notifyLogs" form:"notifyLogs"`         // sample logs of the messages, 1 when 0
+	NotifyTopField   string                    `json:"notifyTopField" form:"notifyTopField"` // field whose top values are in the messages
+	NotifyChart      int                       `json:"notifyChart" form:"notifyChart"`       // 1 chart of the condition metric
 }
 
 func (r *ReqAlarmCreate) ConvertV2() {
@@ -33,7 +38,8 @@ func (r *ReqAlarmCreate) ConvertV2() {
 
 type AlarmFilterItem struct {
 	*db2.AlarmFilter
-	Exp string
+	Exp        string
+	Conditions []*db2.AlarmCondition
 }
 
 type ReqAlarmFilterCreate struct {
@@ -43,14 +49,23 @@ type ReqAlarmFilterCreate struct {
 	SetOperatorExp string                    `json:"exp" form:"exp"`                      // 操作
 	Mode           int                       `json:"mode" form:"mode"`
 	Conditions     []ReqAlarmConditionCreate `json:"conditions" form:"conditions"`
+	// the edge of the jaeger dependencies of the table in trace edge mode, the when is generated from it
+	TraceParent string `json:"traceParent" form:"traceParent"`
+	TraceChild  string `json:"traceChild" form:"traceChild"`
+	TraceMetric string `json:"traceMetric" form:"traceMetric"` // callCount, p99 in ms or successRate in percent
 }
 
 type ReqAlarmConditionCreate struct {
-	SetOperatorTyp int `json:"typ" form:"typ"`                      // 0 when 1 and  2 or
-	SetOperatorExp int `json:"exp" form:"exp"`                      // 0 avg 1 min 2 max 3 sum 4 count
-	Cond           int `json:"cond" form:"cond"`                    // 0 above 1 below 2 outside range 3 within range
-	Val1           int `json:"val1" form:"val1" binding:"required"` // 基准值/最小值
-	Val2           int `json:"val2" form:"val2"`                    // 最大值
+	SetOperatorTyp int `json:"typ" form:"typ"`   // 0 when 1 and  2 or
+	SetOperatorExp int `json:"exp" form:"exp"`   // 0 avg 1 min 2 max 3 sum 4 count
+	Cond           int `json:"cond" form:"cond"` // 0 above 1 below 2 outside range 3 within range
+	Val1           int `json:"val1" form:"val1"` // 基准值/最小值
+	Val2           int `json:"val2" form:"val2"` // 最大值
+
+	CondTyp int     `json:"condTyp" form:"condTyp"` // 0 threshold 1 change 2 baseline 3 seasonal
+	Offset  int     `json:"offset" form:"offset"`   // seconds, window compared by change, season length of seasonal
+	Periods int     `json:"periods" form:"periods"` // windows of baseline, seasons of seasonal
+	Factor  float64 `json:"factor" form:"factor"`   // k of baseline
 }
 
 type (
@@ -92,14 +107,24 @@ type (
 		*db2.AlarmFilter
 		TableName  string                `json:"tableName"`
 		Conditions []*db2.AlarmCondition `json:"conditions"`
+		// RuleHealth evaluation status of the prometheus rule, none when the alarm is evaluated natively
+		RuleHealth *db2.AlarmRuleHealth `json:"ruleHealth,omitempty"`
 	}
 )
 
 type (
 	ReqAlarmHistoryList struct {
-		AlarmId   int `json:"alarmId" form:"alarmId"`
-		StartTime int `json:"startTime" form:"startTime"`
-		EndTime   int `json:"endTime" form:"endTime"` // 0 m 1 s 2 h 3 d 4 w 5 y
+		AlarmId     int `json:"alarmId" form:"alarmId"`
+		CompositeId int `json:"compositeId" form:"compositeId"` // history of the composite alarm
+		WatchdogId  int `json:"watchdogId" form:"watchdogId"`   // history of the watchdog of a storage
+		StartTime   int `json:"startTime" form:"startTime"`
+		EndTime     int `json:"endTime" form:"endTime"` // 0 m 1 s 2 h 3 d 4 w 5 y
+		db2.ReqPage
+	}
+
+	ReqAlarmDeliveryList struct {
+		Status    int `json:"status" form:"status"` // 1 pending, 2 delivered, 3 failed, 4 superseded
+		ChannelId int `json:"channelId" form:"channelId"`
 		db2.ReqPage
 	}
 
@@ -161,3 +186,194 @@ type (
 		Text  string `json:"text"`
 	}
 )
+
+type (
+	ReqAlarmSilenceCreate struct {
+		AlarmId   int               `json:"alarmId" form:"alarmId"`
+		Tid       int               `json:"tid" form:"tid"`
+		Iid       int               `json:"iid" form:"iid"`
+		Matchers  map[string]string `json:"matchers" form:"matchers"` // alarm tags which must all be equal
+		StartTime int64             `json:"startTime" form:"startTime"`
+		EndTime   int64             `json:"endTime" form:"endTime"`   // 0 never ends, only for maintenance windows
+		Cron      string            `json:"cron" form:"cron"`         // start of each maintenance window, e.g. "0 2 * * 6"
+		Duration  int               `json:"duration" form:"duration"` // seconds of each maintenance window
+		Reason    string            `json:"reason" form:"reason"`
+	}
+
+	ReqAlarmSilenceList struct {
+		AlarmId  int `json:"alarmId" form:"alarmId"`
+		Tid      int `json:"tid" form:"tid"`
+		Iid      int `json:"iid" form:"iid"`
+		IsActive int `json:"isActive" form:"isActive"` // 1 only silences muting at present
+	}
+
+	RespAlarmSilenceItem struct {
+		*db2.AlarmSilence
+		IsActive int `json:"isActive"`
+	}
+
+	ReqAlarmSnooze struct {
+		Duration string `json:"duration" form:"duration"` // e.g. 30m, 1h, default 1h
+		Reason   string `json:"reason" form:"reason"`
+	}
+)
+
+type (
+	ReqAlarmOncallCreate struct {
+		Name      string              `json:"name" form:"name" binding:"required"`
+		Desc      string              `json:"desc" form:"desc"`
+		Rotation  int                 `json:"rotation" form:"rotation"` // 0 daily 1 weekly
+		Users     []int               `json:"users" form:"users" binding:"required"`
+		StartTime int64               `json:"startTime" form:"startTime"` // start of the first shift, now by default
+		Overrides db2.OncallOverrides `json:"overrides" form:"overrides"`
+	}
+
+	RespAlarmOncallItem struct {
+		*db2.AlarmOncall
+		Current int `json:"current"` // user on call now
+	}
+
+	ReqAlarmEscalationCreate struct {
+		Name  string              `json:"name" form:"name" binding:"required"`
+		Desc  string              `json:"desc" form:"desc"`
+		Steps db2.EscalationSteps `json:"steps" form:"steps" binding:"required"`
+	}
+)
+
+type (
+	ReqAlarmCompositeCreate struct {
+		Name       string   `json:"name" form:"name" binding:"required"`
+		Desc       string   `json:"desc" form:"desc"`
+		Expr       string   `json:"expr" form:"expr" binding:"required"` // ids of firing alarms with &&, ||, ! and parentheses, e.g. 12 && (15 || !20)
+		For        int      `json:"for" form:"for"`                      // seconds the expression holds before firing
+		ChannelIds db2.Ints `json:"channelIds" form:"channelIds" binding:"required"`
+		Status     int      `json:"status" form:"status"` // 1 closed, opened otherwise
+	}
+
+	RespAlarmCompositeInfo struct {
+		*db2.AlarmComposite
+		Alarms []RespAlarmCompositeAlarm `json:"alarms"` // alarms of the expression, in order of appearance
+	}
+
+	RespAlarmCompositeAlarm struct {
+		Id     int    `json:"id"`
+		Name   string `json:"name"`   // empty when the alarm no longer exists
+		Status int    `json:"status"` // status of the alarm
+	}
+)
+
+type (
+	ReqAlarmTemplatePreview struct {
+		Template string `json:"template" form:"template" binding:"required"`
+		AlarmId  int    `json:"alarmId" form:"alarmId"` // render with the alarm instead of the sample one
+	}
+
+	RespAlarmTemplatePreview struct {
+		Title string `json:"title"`
+		Text  string `json:"text"`
+	}
+)
+
+// ReqAlarmBacktest the alarm is evaluated between st and et without being created
+type ReqAlarmBacktest struct {
+	ReqAlarmCreate
+	St int64 `json:"st" form:"st" binding:"required"`
+	Et int64 `json:"et" form:"et" binding:"required"`
+}
+
+type RespAlarmBacktest struct {
+	Points        int                  `json:"points"` // evaluations of each filter
+	Firing        int                  `json:"firing"`
+	Resolved      int                  `json:"resolved"`
+	Notifications int                  `json:"notifications"`
+	Timeline      []AlarmBacktestEvent `json:"timeline"`
+}
+
+// RespAlarmBacktestJob backtest evaluated in the background, polled until its status is done or failed
+type RespAlarmBacktestJob struct {
+	Id     string             `json:"id"`
+	Uid    int                `json:"uid"`
+	Status string             `json:"status"` // running, done or failed
+	Done   int                `json:"done"`   // evaluations done
+	Total  int                `json:"total"`  // evaluations of all the filters
+	Error  string             `json:"error,omitempty"`
+	Result *RespAlarmBacktest `json:"result,omitempty"`
+}
+
+// AlarmBacktestEvent status transition of a filter
+type AlarmBacktestEvent struct {
+	Time       int64 `json:"time"`
+	FilterIdx  int   `json:"filterIdx"` // index of the filter in the request
+	Status     int   `json:"status"`    // 2 normal 3 firing
+	IsNotified bool  `json:"isNotified"`
+}
+
+// ReqAlarmAnalytics quality of the alarms between st and et,
+// the team of an alarm is its tag teamTag, the database of its first table without the tag
+type ReqAlarmAnalytics struct {
+	St         int64  `json:"st" form:"st" binding:"required"`
+	Et         int64  `json:"et" form:"et" binding:"required"`
+	Limit      int    `json:"limit" form:"limit"`           // size of the noisiest alarms, 10 by default
+	FlapWindow int64  `json:"flapWindow" form:"flapWindow"` // seconds, a firing within it after the resolution flaps, 600 by default
+	TeamTag    string `json:"teamTag" form:"teamTag"`       // team by default
+}
+
+type RespAlarmAnalytics struct {
+	Total    AlarmAnalyticsStat    `json:"total"`
+	Alarms   []AlarmAnalyticsAlarm `json:"alarms"` // alarms which fired or notified, noisiest first
+	Teams    []AlarmAnalyticsTeam  `json:"teams"`
+	Noisiest []AlarmAnalyticsAlarm `json:"noisiest"`
+}
+
+// AlarmAnalyticsStat the times are in seconds, incidents fired before st are not counted
+type AlarmAnalyticsStat struct {
+	Firing        int     `json:"firing"`
+	Resolved      int     `json:"resolved"`
+	Acked         int     `json:"acked"`
+	Notifications int     `json:"notifications"` // successful pushes, escalations included
+	Mtta          float64 `json:"mtta"`          // mean time to acknowledge
+	Mttr          float64 `json:"mttr"`          // mean time to resolve
+	FlappingScore float64 `json:"flappingScore"` // share of the firings within flapWindow after the previous resolution
+}
+
+type AlarmAnalyticsAlarm struct {
+	AlarmId int    `json:"alarmId"`
+	Name    string `json:"name"`
+	Team    string `json:"team"`
+	AlarmAnalyticsStat
+}
+
+type AlarmAnalyticsTeam struct {
+	Team   string `json:"team"`
+	Alarms int    `json:"alarms"`
+	AlarmAnalyticsStat
+}
+
+// RuleDrift difference between the rules of the open alarms of the instance and the rules of its store
+type RuleDrift struct {
+	InstanceId    int             `json:"instanceId"`
+	InstanceName  string          `json:"instanceName"`
+	RuleStoreType int             `json:"ruleStoreType"`
+	Missing       []RuleDriftItem `json:"missing"`  // rules of the alarms not in the store
+	Changed       []RuleDriftItem `json:"changed"`  // rules of the store different from those of the alarms
+	Orphaned      []RuleDriftItem `json:"orphaned"` // rules of the store without open alarm
+	Error         string          `json:"error"`    // the store could not be read
+	CheckTime     int64           `json:"checkTime"`
+}
+
+type RuleDriftItem struct {
+	Name        string `json:"name"` // rule name, group name for the prometheus operator
+	AlarmId     int    `json:"alarmId"`
+	AlarmName   string `json:"alarmName"`
+	Repaired    bool   `json:"repaired"`
+	RepairError string `json:"repairError"`
+}
+
+type ReqRuleDriftList struct {
+	InstanceId int `json:"instanceId" form:"instanceId"`
+	Refresh    int `json:"refresh" form:"refresh"` // 1 compares the rules now instead of returning the latest check
+}
+
+type ReqRuleDriftRepair struct {
+	InstanceId int `json:"instanceId" form:"instanceId"` // 0 repairs all the instances
+}
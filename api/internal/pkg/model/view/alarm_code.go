@@ -0,0 +1,112 @@
+package view
+
+const (
+	AlarmCodeKindChannel = "channel"
+	AlarmCodeKindAlarm   = "alarm"
+	AlarmCodeKindSilence = "silence"
+
+	AlarmCodeActionCreate = "create"
+	AlarmCodeActionUpdate = "update"
+	AlarmCodeActionDelete = "delete"
+
+	// AlarmCodeRedacted value of the secrets in the export, the current value is kept when it is applied
+	AlarmCodeRedacted = "<redacted>"
+)
+
+// AlarmCode declarative alarms, channels and silences.
+// Channels and alarms are matched by name, tables by instance, database and table names,
+// silences have no name and are matched by all their fields.
+type AlarmCode struct {
+	Channels []AlarmCodeChannel `yaml:"channels,omitempty" json:"channels"`
+	Alarms   []AlarmCodeAlarm   `yaml:"alarms,omitempty" json:"alarms"`
+	Silences []AlarmCodeSilence `yaml:"silences,omitempty" json:"silences"`
+}
+
+type AlarmCodeChannel struct {
+	Name           string   `yaml:"name" json:"name"`
+	Typ            int      `yaml:"typ" json:"typ"`
+	Key            string   `yaml:"key" json:"key"`
+	GroupBy        []string `yaml:"groupBy,omitempty" json:"groupBy"`
+	GroupWait      int      `yaml:"groupWait,omitempty" json:"groupWait"`
+	GroupInterval  int      `yaml:"groupInterval,omitempty" json:"groupInterval"`
+	RepeatInterval int      `yaml:"repeatInterval,omitempty" json:"repeatInterval"`
+	RateLimit      int      `yaml:"rateLimit,omitempty" json:"rateLimit"`
+	Template       string   `yaml:"template,omitempty" json:"template"`
+	ChatOps        int      `yaml:"chatOps,omitempty" json:"chatOps"`
+	CallbackSecret string   `yaml:"callbackSecret,omitempty" json:"callbackSecret"`
+}
+
+type AlarmCodeAlarm struct {
+	Name             string            `yaml:"name" json:"name"`
+	Desc             string            `yaml:"desc,omitempty" json:"desc"`
+	Interval         int               `yaml:"interval" json:"interval"`
+	Unit             int               `yaml:"unit,omitempty" json:"unit"` // 0 m 1 s 2 h 3 d 4 w 5 y
+	Tags             map[string]string `yaml:"tags,omitempty" json:"tags"`
+	NoDataOp         int               `yaml:"noDataOp,omitempty" json:"noDataOp"`
+	Level            int               `yaml:"level,omitempty" json:"level"`
+	IsDisableResolve int               `yaml:"isDisableResolve,omitempty" json:"isDisableResolve"`
+	Channels         []string          `yaml:"channels" json:"channels"`                       // channel names
+	DutyOfficers     []string          `yaml:"dutyOfficers,omitempty" json:"dutyOfficers"`     // usernames
+	Escalation       string            `yaml:"escalation,omitempty" json:"escalation"`         // escalation policy name
+	Template         string            `yaml:"template,omitempty" json:"template"`             // go template of the messages
+	NotifyLogs       int               `yaml:"notifyLogs,omitempty" json:"notifyLogs"`         // sample logs of the messages
+	NotifyTopField   string            `yaml:"notifyTopField,omitempty" json:"notifyTopField"` // field whose top values are in the messages
+	NotifyChart      int               `yaml:"notifyChart,omitempty" json:"notifyChart"`       // 1 chart of the condition metric
+	Filters          []AlarmCodeFilter `yaml:"filters" json:"filters"`
+}
+
+type AlarmCodeFilter struct {
+	Instance string `yaml:"instance" json:"instance"`
+	Database string `yaml:"database" json:"database"`
+	Table    string `yaml:"table" json:"table"`
+	When     string `yaml:"when" json:"when"`
+	Typ      int    `yaml:"typ,omitempty" json:"typ"`
+	Exp      string `yaml:"exp,omitempty" json:"exp"`
+	Mode     int    `yaml:"mode,omitempty" json:"mode"`
+	// edge of the jaeger dependencies in trace edge mode, the when is generated
+	TraceParent string               `yaml:"traceParent,omitempty" json:"traceParent"`
+	TraceChild  string               `yaml:"traceChild,omitempty" json:"traceChild"`
+	TraceMetric string               `yaml:"traceMetric,omitempty" json:"traceMetric"`
+	Conditions  []AlarmCodeCondition `yaml:"conditions,omitempty" json:"conditions"`
+}
+
+// AlarmCodeCondition fields of ReqAlarmConditionCreate
+type AlarmCodeCondition struct {
+	Typ     int     `yaml:"typ,omitempty" json:"typ"` // 0 when 1 and 2 or
+	Exp     int     `yaml:"exp,omitempty" json:"exp"` // 0 avg 1 min 2 max 3 sum 4 count
+	Cond    int     `yaml:"cond,omitempty" json:"cond"`
+	Val1    int     `yaml:"val1,omitempty" json:"val1"`
+	Val2    int     `yaml:"val2,omitempty" json:"val2"`
+	CondTyp int     `yaml:"condTyp,omitempty" json:"condTyp"`
+	Offset  int     `yaml:"offset,omitempty" json:"offset"`
+	Periods int     `yaml:"periods,omitempty" json:"periods"`
+	Factor  float64 `yaml:"factor,omitempty" json:"factor"`
+}
+
+type AlarmCodeSilence struct {
+	Alarm     string            `yaml:"alarm,omitempty" json:"alarm"`       // alarm name
+	Instance  string            `yaml:"instance,omitempty" json:"instance"` // required with table
+	Database  string            `yaml:"database,omitempty" json:"database"`
+	Table     string            `yaml:"table,omitempty" json:"table"`
+	Matchers  map[string]string `yaml:"matchers,omitempty" json:"matchers"`
+	StartTime int64             `yaml:"startTime,omitempty" json:"startTime"`
+	EndTime   int64             `yaml:"endTime,omitempty" json:"endTime"`
+	Cron      string            `yaml:"cron,omitempty" json:"cron"`
+	Duration  int               `yaml:"duration,omitempty" json:"duration"`
+	Reason    string            `yaml:"reason,omitempty" json:"reason"`
+}
+
+// ReqAlarmCode content is the yaml of AlarmCode, objects missing from it are deleted with prune
+type ReqAlarmCode struct {
+	Content string `json:"content" form:"content" binding:"required"`
+	Prune   bool   `json:"prune" form:"prune"`
+}
+
+// AlarmCodeChange one step of the plan
+type AlarmCodeChange struct {
+	Kind   string   `json:"kind"`
+	Name   string   `json:"name"`
+	Action string   `json:"action"`
+	Id     int      `json:"id,omitempty"`     // existing object
+	Fields []string `json:"fields,omitempty"` // changed fields of updates
+}
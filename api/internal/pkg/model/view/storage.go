@@ -15,22 +15,43 @@ type ReqKafkaJSONMapping struct {
 }
 
 type ReqStorageCreate struct {
-	TableName               string       `form:"tableName" binding:"required"`
-	Typ                     int          `form:"typ" binding:"required"` // 1 string 2 float
-	Days                    int          `form:"days" binding:"required"`
-	Brokers                 string       `form:"brokers" binding:"required"`
-	Topics                  string       `form:"topics" binding:"required"`
-	Consumers               int          `form:"consumers" binding:"required"`
-	KafkaSkipBrokenMessages int          `form:"kafkaSkipBrokenMessages"`
-	Desc                    string       `form:"desc"`
-	Source                  string       `form:"source" binding:"required"` // Raw JSON data
-	DatabaseId              int          `form:"databaseId" binding:"required"`
-	TimeField               string       `form:"timeField" binding:"required"`
-	TimeFieldParent         string       `form:"timeFieldParent"`
-	RawLogField             string       `form:"rawLogField"`
-	RawLogFieldParent       string       `form:"rawLogFieldParent"`
-	SourceMapping           mapping.List `form:"-"`
-	CreateType              int          `form:"createType"`
+	TableName               string            `json:"tableName" form:"tableName" binding:"required"`
+	Typ                     int               `json:"typ" form:"typ" binding:"required"` // 1 string 2 float 3 unix nano string 4 unix milli 5 common log format 6 ansic
+	Days                    int               `json:"days" form:"days" binding:"required"`
+	Brokers                 string            `json:"brokers" form:"brokers" binding:"required"`
+	Topics                  string            `json:"topics" form:"topics" binding:"required"`
+	Consumers               int               `json:"consumers" form:"consumers" binding:"required"`
+	KafkaSkipBrokenMessages int               `json:"kafkaSkipBrokenMessages" form:"kafkaSkipBrokenMessages"`
+	Desc                    string            `json:"desc" form:"desc"`
+	Source                  string            `json:"source" form:"source" binding:"required"` // Raw JSON data
+	DatabaseId              int               `json:"databaseId" form:"databaseId" binding:"required"`
+	TimeField               string            `json:"timeField" form:"timeField" binding:"required"`
+	TimeFieldParent         string            `json:"timeFieldParent" form:"timeFieldParent"`
+	RawLogField             string            `json:"rawLogField" form:"rawLogField"`
+	RawLogFieldParent       string            `json:"rawLogFieldParent" form:"rawLogFieldParent"`
+	SourceMapping           mapping.List      `json:"-" form:"-"`
+	CreateType              int               `json:"createType" form:"createType"`
+	RawLogIndex             int               `json:"rawLogIndex" form:"rawLogIndex"` // 0 default 1 tokenbf_v1 2 inverted 3 none
+	FieldAlias              map[string]string `json:"fieldAlias" form:"fieldAlias"`   // parent.key or key -> column name
+	DeadLetter              int               `json:"deadLetter" form:"deadLetter"`   // 1 unparsable messages are stored in <table>_dlq, JSONAsString only
+	Format                  string            `json:"format" form:"format"`           // message envelope, empty or otlp, JSONAsString only
+}
+
+// ReqStorageInfer samples are read from kafka when they are not pasted
+type ReqStorageInfer struct {
+	DatabaseId int      `json:"databaseId" form:"databaseId" binding:"required"`
+	TableName  string   `json:"tableName" form:"tableName"`
+	Days       int      `json:"days" form:"days"`
+	Brokers    string   `json:"brokers" form:"brokers"`
+	Topic      string   `json:"topic" form:"topic"`
+	Limit      int      `json:"limit" form:"limit"` // messages read from kafka, default 100
+	Samples    []string `json:"samples" form:"samples"`
+}
+
+type RespStorageInfer struct {
+	Inference      mapping.Inference `json:"inference"`
+	Storage        ReqStorageCreate  `json:"storage"`
+	AnalysisFields []IndexItem       `json:"analysisFields"`
 }
 
 type ReqCreateStorageByTemplateEgo struct {
@@ -51,6 +72,15 @@ type ReqCreateStorageByTemplateILogtail struct {
 	Topic      string `form:"topic" binding:"required"`
 }
 
+type ReqCreateStorageByTemplate struct {
+	Brokers    string `form:"brokers" binding:"required"`
+	DatabaseId int    `form:"databaseId" binding:"required"`
+	Days       int    `form:"days" binding:"required"`
+	Name       string `form:"name" binding:"required"`
+	Topic      string `form:"topic" binding:"required"`
+	DeadLetter int    `form:"deadLetter"` // 1 unparsable messages are stored in <table>_dlq
+}
+
 type ReqCreateAgentStorage struct {
 	Name       string `form:"name" binding:"required"`
 	DatabaseId int    `form:"databaseId" binding:"required"`
@@ -80,10 +110,10 @@ func (r *ReqStorageCreate) SelectFields() string {
 			continue
 		}
 		if res == "" {
-			res = fmt.Sprintf("`%s`", v.Key)
+			res = fmt.Sprintf("`%s`", v.Name())
 			continue
 		}
-		res = fmt.Sprintf("%s,`%s`", res, v.Key)
+		res = fmt.Sprintf("%s,`%s`", res, v.Name())
 	}
 	if res == "" {
 		res = "_time_second_,_time_nanosecond_,_raw_log_"
@@ -140,7 +170,7 @@ func (r *ReqStorageCreate) Mapping2Fields(rawLogFieldParent string) string {
 // IsRawLogFieldString 判断 raw log 字段是否是 string 类型
 func (r *ReqStorageCreate) IsRawLogFieldString() bool {
 	for _, v := range r.SourceMapping.Data {
-		if r.RawLogField == v.Key {
+		if r.RawLogField == v.Key && r.RawLogFieldParent == v.Parent {
 			if v.Typ != mapping.FieldTypeJSON {
 				return true
 			} else {
@@ -201,6 +231,44 @@ type (
 	ReqStorageUpdateTraceInfo struct {
 		TraceTableId int `form:"traceTableId"`
 	}
+	ReqStorageUpdateRawLogIndex struct {
+		RawLogIndex int `form:"rawLogIndex"` // 0 default 1 tokenbf_v1 2 inverted 3 none
+	}
+	ReqStorageUpdateIngestion struct {
+		StallSeconds   int   `json:"stallSeconds" form:"stallSeconds" binding:"required"`
+		ErrorThreshold int   `json:"errorThreshold" form:"errorThreshold"`
+		ChannelIds     []int `json:"channelIds" form:"channelIds"`
+	}
+	ReqStorageUpdateWatchdog struct {
+		Enabled    int   `json:"enabled" form:"enabled"`       // 1 on
+		ChannelIds []int `json:"channelIds" form:"channelIds"` // the channels of the alarms of the storage when empty
+	}
+	ReqStorageDeadLetterList struct {
+		ST       int64  `json:"st" form:"st" binding:"required"`
+		ET       int64  `json:"et" form:"et" binding:"required"`
+		Error    string `json:"error" form:"error"` // error reason, empty means all
+		Page     uint32 `json:"page" form:"page"`
+		PageSize uint32 `json:"pageSize" form:"pageSize"`
+	}
+	ReqStorageDeadLetterReplay struct {
+		ST    int64  `json:"st" form:"st" binding:"required"`
+		ET    int64  `json:"et" form:"et" binding:"required"`
+		Error string `json:"error" form:"error"` // error reason, empty means all
+	}
+	RespStorageDeadLetterList struct {
+		Total uint64        `json:"total"`
+		List  []*DeadLetter `json:"list"`
+	}
+	RespStorageIngestion struct {
+		Health  *IngestionHealth  `json:"health"` // nil before the first check
+		Monitor db2.BaseIngestion `json:"monitor"`
+	}
+	RespStorageWatchdog struct {
+		Watchdog     db2.BaseWatchdog `json:"watchdog"`
+		LevelField   string           `json:"levelField"`   // error logs are not checked when empty
+		MessageField string           `json:"messageField"` // error patterns are not checked when empty
+		Patterns     int              `json:"patterns"`     // known error patterns
+	}
 	ReqStorageGetTraceGraph struct {
 		StartTime int `form:"startTime"`
 		EndTime   int `form:"endTime"`
@@ -252,3 +320,36 @@ type RespJaegerDependencyDataModel struct {
 	ServerSuccessRate float64 `json:"serverSuccessRate"`
 	ClientSuccessRate float64 `json:"clientSuccessRate"`
 }
+
+// IngestionHealth consuming state of a kafka engine storage
+type IngestionHealth struct {
+	Consumers      int    `json:"consumers"`
+	MessagesRead   uint64 `json:"messagesRead"`
+	Lag            int64  `json:"lag"` // messages not consumed yet, -1 means unknown
+	LastPollTime   int64  `json:"lastPollTime"`
+	LastCommitTime int64  `json:"lastCommitTime"`
+	RecentErrors   uint64 `json:"recentErrors"`   // consumer exceptions since the last check, clickhouse keeps the last 10 of each consumer
+	BrokenMessages int64  `json:"brokenMessages"` // messages stored in the dead-letter table since the last check, -1 means unknown
+	LastError      string `json:"lastError"`
+	LatestTime     int64  `json:"latestTime"` // latest _time_second_
+	Freshness      int64  `json:"freshness"`  // seconds since latest _time_second_
+	Stalled        bool   `json:"stalled"`
+	CheckTime      int64  `json:"checkTime"`
+}
+
+// DeadLetter message that can not be parsed by the storage
+type DeadLetter struct {
+	Time       int64  `json:"time"`
+	Topic      string `json:"topic"`
+	Partition  uint64 `json:"partition"`
+	Offset     uint64 `json:"offset"`
+	Error      string `json:"error"`
+	RawMessage string `json:"rawMessage"`
+}
+
+// ErrorPattern messages of a pattern, their quoted strings, ids, addresses and numbers are placeholders
+type ErrorPattern struct {
+	Pattern string `json:"pattern"`
+	Example string `json:"example"`
+	Count   uint64 `json:"count"`
+}
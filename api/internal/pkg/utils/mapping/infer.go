@@ -0,0 +1,339 @@
+package mapping
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TimeFormatEpochSecond = "epoch_second"
+	TimeFormatEpochMilli  = "epoch_milli"
+	TimeFormatEpochMicro  = "epoch_micro"
+	TimeFormatEpochNano   = "epoch_nano"
+	TimeFormatRFC3339     = "rfc3339"
+	// TimeFormatCLF common log format, nginx time_local
+	TimeFormatCLF = "02/Jan/2006:15:04:05 -0700"
+)
+
+// inferCardinalityLimit distinct values counted for each field
+const inferCardinalityLimit = 1000
+
+var (
+	// inferTimeLayouts custom layouts tried after RFC3339
+	inferTimeLayouts = []string{
+		"2006-01-02 15:04:05",
+		"2006-01-02T15:04:05",
+		"2006/01/02 15:04:05",
+		TimeFormatCLF,
+		time.RFC1123Z,
+		time.RFC1123,
+		time.ANSIC,
+	}
+	inferTimeNames   = []string{"_time_", "time", "timestamp", "@timestamp", "ts", "timeunixnano", "time_local", "datetime", "date"}
+	inferRawLogNames = []string{"_log_", "log", "message", "msg", "content", "stringvalue", "body", "_raw_log_", "raw"}
+)
+
+// InferField field observed in the samples
+type InferField struct {
+	Path        string `json:"path"`   // dot separated path from the root
+	Parent      string `json:"parent"` // first level key, only set for second level fields
+	Key         string `json:"key"`
+	Typ         string `json:"typ"`         // clickhouse type
+	Count       int    `json:"count"`       // samples containing the field
+	Cardinality int    `json:"cardinality"` // distinct values, at most 1000
+	Integer     bool   `json:"integer"`     // all Float64 values are integers
+
+	depth     int
+	values    map[string]struct{}
+	strLength int
+}
+
+// InferTime candidate time field
+type InferTime struct {
+	Parent  string `json:"parent"`
+	Key     string `json:"key"`
+	Format  string `json:"format"` // epoch_second, epoch_milli, epoch_micro, epoch_nano, rfc3339 or go layout
+	Quoted  bool   `json:"quoted"` // value is encoded as a string
+	Matched int    `json:"matched"`
+}
+
+// Inference result of schema inference
+type Inference struct {
+	Samples           int          `json:"samples"`
+	Invalid           int          `json:"invalid"`
+	Fields            []InferField `json:"fields"`
+	TimeFields        []InferTime  `json:"timeFields"` // best first
+	RawLogField       string       `json:"rawLogField"`
+	RawLogFieldParent string       `json:"rawLogFieldParent"`
+	// AnalysisFields first level fields of the raw log when it is a json string
+	AnalysisFields []InferField `json:"analysisFields"`
+	// Source merged sample containing every field observed
+	Source string `json:"source"`
+}
+
+// Infer infers the storage schema from sample messages
+func Infer(samples []string) (res Inference, err error) {
+	objs := make([]map[string]interface{}, 0, len(samples))
+	for _, sample := range samples {
+		if strings.TrimSpace(sample) == "" {
+			continue
+		}
+		res.Samples++
+		obj := map[string]interface{}{}
+		if errUnmarshal := json.Unmarshal([]byte(sample), &obj); errUnmarshal != nil {
+			res.Invalid++
+			continue
+		}
+		objs = append(objs, obj)
+	}
+	if len(objs) == 0 {
+		return res, fmt.Errorf("no valid json object in %d samples", res.Samples)
+	}
+	fields := map[string]*InferField{}
+	merged := map[string]interface{}{}
+	for _, obj := range objs {
+		inferWalk(fields, merged, obj, nil)
+	}
+	res.Fields = inferSortFields(fields)
+	source, err := json.Marshal(merged)
+	if err != nil {
+		return
+	}
+	res.Source = string(source)
+	res.TimeFields = inferTimeFields(objs, res.Fields)
+	res.RawLogFieldParent, res.RawLogField = inferRawLogField(res.Fields)
+	if res.RawLogField != "" {
+		res.AnalysisFields = inferAnalysisFields(objs, res.RawLogFieldParent, res.RawLogField)
+	}
+	return res, nil
+}
+
+func inferWalk(fields map[string]*InferField, merged map[string]interface{}, obj map[string]interface{}, path []string) {
+	for k, v := range obj {
+		cur := append(append([]string{}, path...), k)
+		if inner, ok := v.(map[string]interface{}); ok {
+			m, okMerged := merged[k].(map[string]interface{})
+			if !okMerged {
+				m = map[string]interface{}{}
+				merged[k] = m
+			}
+			inferWalk(fields, m, inner, cur)
+			continue
+		}
+		if _, ok := merged[k]; !ok || merged[k] == nil {
+			if arr, isArr := v.([]interface{}); !isArr || len(arr) > 0 {
+				merged[k] = v
+			}
+		}
+		if v == nil {
+			continue
+		}
+		if arr, ok := v.([]interface{}); ok && len(arr) == 0 {
+			continue
+		}
+		p := strings.Join(cur, ".")
+		f, ok := fields[p]
+		if !ok {
+			f = &InferField{Path: p, Key: k, Integer: true, depth: len(cur), values: map[string]struct{}{}}
+			if len(cur) == 2 {
+				f.Parent = cur[0]
+			}
+			fields[p] = f
+		}
+		f.Count++
+		f.Typ = inferMergeTyp(f.Typ, fieldTypeJudgment(v))
+		if _, isStr := merged[k].(string); f.Typ == "String" && !isStr {
+			raw, _ := json.Marshal(merged[k])
+			merged[k] = string(raw)
+		}
+		switch val := v.(type) {
+		case float64:
+			if val != math.Trunc(val) {
+				f.Integer = false
+			}
+		case string:
+			f.strLength += len(val)
+		}
+		if len(f.values) < inferCardinalityLimit {
+			raw, _ := json.Marshal(v)
+			f.values[string(raw)] = struct{}{}
+		}
+	}
+}
+
+// inferMergeTyp values with different types are stored as String
+func inferMergeTyp(prev, cur string) string {
+	if prev == "" || prev == cur {
+		return cur
+	}
+	return "String"
+}
+
+func inferSortFields(fields map[string]*InferField) []InferField {
+	res := make([]InferField, 0, len(fields))
+	for _, f := range fields {
+		f.Cardinality = len(f.values)
+		if f.Typ != "Float64" {
+			f.Integer = false
+		}
+		res = append(res, *f)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Path < res[j].Path
+	})
+	return res
+}
+
+// inferTimeFields first and second level fields whose values all parse as time, storage supports only one nested level
+func inferTimeFields(objs []map[string]interface{}, fields []InferField) []InferTime {
+	res := make([]InferTime, 0)
+	for _, f := range fields {
+		if f.depth > 2 {
+			continue
+		}
+		if f.Typ != "String" && f.Typ != "Float64" {
+			continue
+		}
+		var (
+			format  string
+			matched int
+			quoted  = f.Typ == "String"
+		)
+		for _, obj := range objs {
+			v, ok := inferValue(obj, f.Parent, f.Key)
+			if !ok || v == nil {
+				continue
+			}
+			cur := inferTimeFormat(v)
+			if cur == "" || (format != "" && cur != format) {
+				matched = 0
+				break
+			}
+			format = cur
+			matched++
+		}
+		if matched == 0 {
+			continue
+		}
+		res = append(res, InferTime{Parent: f.Parent, Key: f.Key, Format: format, Quoted: quoted, Matched: matched})
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Matched != res[j].Matched {
+			return res[i].Matched > res[j].Matched
+		}
+		return inferNameRank(inferTimeNames, res[i].Key) < inferNameRank(inferTimeNames, res[j].Key)
+	})
+	return res
+}
+
+func inferValue(obj map[string]interface{}, parent, key string) (interface{}, bool) {
+	if parent == "" {
+		v, ok := obj[key]
+		return v, ok
+	}
+	inner, ok := obj[parent].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	v, ok := inner[key]
+	return v, ok
+}
+
+// inferTimeFormat returns the time format of the value, epoch values must be between 2000 and 2100
+func inferTimeFormat(v interface{}) string {
+	switch val := v.(type) {
+	case float64:
+		return inferEpochFormat(val)
+	case string:
+		if f, err := strconv.ParseFloat(val, 64); err == nil {
+			return inferEpochFormat(f)
+		}
+		if _, err := time.Parse(time.RFC3339, val); err == nil {
+			return TimeFormatRFC3339
+		}
+		for _, layout := range inferTimeLayouts {
+			if _, err := time.Parse(layout, val); err == nil {
+				return layout
+			}
+		}
+	}
+	return ""
+}
+
+func inferEpochFormat(v float64) string {
+	const (
+		minSecond = 946684800  // 2000-01-01
+		maxSecond = 4102444800 // 2100-01-01
+	)
+	switch {
+	case v >= minSecond && v < maxSecond:
+		return TimeFormatEpochSecond
+	case v >= minSecond*1e3 && v < maxSecond*1e3:
+		return TimeFormatEpochMilli
+	case v >= minSecond*1e6 && v < maxSecond*1e6:
+		return TimeFormatEpochMicro
+	case v >= minSecond*1e9 && v < maxSecond*1e9:
+		return TimeFormatEpochNano
+	}
+	return ""
+}
+
+// inferRawLogField prefers well known names, then the longest string field
+func inferRawLogField(fields []InferField) (parent, key string) {
+	var (
+		best      *InferField
+		bestRank  int
+		bestAvgLn int
+	)
+	for k := range fields {
+		f := &fields[k]
+		if f.Typ != "String" || f.depth > 2 {
+			continue
+		}
+		rank := inferNameRank(inferRawLogNames, f.Key)
+		avg := f.strLength / f.Count
+		if best == nil || rank < bestRank || (rank == bestRank && avg > bestAvgLn) {
+			best, bestRank, bestAvgLn = f, rank, avg
+		}
+	}
+	if best == nil {
+		return "", ""
+	}
+	return best.Parent, best.Key
+}
+
+func inferAnalysisFields(objs []map[string]interface{}, parent, key string) []InferField {
+	fields := map[string]*InferField{}
+	for _, obj := range objs {
+		v, _ := inferValue(obj, parent, key)
+		str, ok := v.(string)
+		if !ok {
+			continue
+		}
+		inner := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(str), &inner); err != nil {
+			continue
+		}
+		for k, innerVal := range inner {
+			if _, isObj := innerVal.(map[string]interface{}); isObj {
+				delete(inner, k)
+			}
+		}
+		inferWalk(fields, map[string]interface{}{}, inner, nil)
+	}
+	return inferSortFields(fields)
+}
+
+func inferNameRank(names []string, key string) int {
+	for k, name := range names {
+		if strings.ToLower(key) == name {
+			return k
+		}
+	}
+	return len(names)
+}
@@ -0,0 +1,136 @@
+package mapping
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestInfer(t *testing.T) {
+	tests := []struct {
+		name              string
+		samples           []string
+		wantInvalid       int
+		wantTime          InferTime
+		wantRawLogField   string
+		wantRawLogParent  string
+		wantFields        map[string]string
+		wantCardinality   map[string]int
+		wantAnalysisTypes map[string]string
+	}{
+		{
+			name: "fluent-bit",
+			samples: []string{
+				`{"_time_":1681704438.624075,"_log_":"{\"level\":\"info\",\"cost\":1.5}","kubernetes":{"pod_name":"a","namespace_name":"default"}}`,
+				`{"_time_":1681704439.1,"_log_":"{\"level\":\"error\",\"cost\":2}","kubernetes":{"pod_name":"b","namespace_name":"default"}}`,
+				`not json`,
+			},
+			wantInvalid:      1,
+			wantTime:         InferTime{Key: "_time_", Format: TimeFormatEpochSecond, Matched: 2},
+			wantRawLogField:  "_log_",
+			wantRawLogParent: "",
+			wantFields: map[string]string{
+				"_time_":                    "Float64",
+				"_log_":                     "String",
+				"kubernetes.pod_name":       "String",
+				"kubernetes.namespace_name": "String",
+			},
+			wantCardinality: map[string]int{
+				"kubernetes.pod_name":       2,
+				"kubernetes.namespace_name": 1,
+			},
+			wantAnalysisTypes: map[string]string{"level": "String", "cost": "Float64"},
+		},
+		{
+			name: "otlp",
+			samples: []string{
+				`{"timeUnixNano":"1681704437624075074","severityNumber":9,"body":{"stringValue":"hello world"},"resource":{"k8s.pod.name":"a"}}`,
+			},
+			wantTime:         InferTime{Key: "timeUnixNano", Format: TimeFormatEpochNano, Quoted: true, Matched: 1},
+			wantRawLogField:  "stringValue",
+			wantRawLogParent: "body",
+			wantFields: map[string]string{
+				"timeUnixNano":          "String",
+				"severityNumber":        "Float64",
+				"body.stringValue":      "String",
+				"resource.k8s.pod.name": "String",
+			},
+		},
+		{
+			name: "rfc3339-and-mixed-types",
+			samples: []string{
+				`{"@timestamp":"2023-04-17T04:07:17.624Z","message":"a","code":1}`,
+				`{"@timestamp":"2023-04-17T04:07:18Z","message":"b","code":"E1"}`,
+			},
+			wantTime:        InferTime{Key: "@timestamp", Format: TimeFormatRFC3339, Quoted: true, Matched: 2},
+			wantRawLogField: "message",
+			wantFields: map[string]string{
+				"@timestamp": "String",
+				"message":    "String",
+				"code":       "String",
+			},
+		},
+		{
+			name: "epoch-milli-and-custom-layout",
+			samples: []string{
+				`{"ts":1681704437624,"time_local":"17/Apr/2023:04:07:17 +0800","content":"GET /"}`,
+			},
+			wantTime:        InferTime{Key: "ts", Format: TimeFormatEpochMilli, Matched: 1},
+			wantRawLogField: "content",
+			wantFields: map[string]string{
+				"ts":         "Float64",
+				"time_local": "String",
+				"content":    "String",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Infer(tt.samples)
+			if err != nil {
+				t.Fatalf("Infer() error = %v", err)
+			}
+			if got.Invalid != tt.wantInvalid {
+				t.Errorf("Infer() invalid = %d, want %d", got.Invalid, tt.wantInvalid)
+			}
+			if len(got.TimeFields) == 0 || !reflect.DeepEqual(got.TimeFields[0], tt.wantTime) {
+				t.Errorf("Infer() time fields = %+v, want first %+v", got.TimeFields, tt.wantTime)
+			}
+			if got.RawLogField != tt.wantRawLogField || got.RawLogFieldParent != tt.wantRawLogParent {
+				t.Errorf("Infer() raw log field = %s.%s, want %s.%s", got.RawLogFieldParent, got.RawLogField, tt.wantRawLogParent, tt.wantRawLogField)
+			}
+			fields := map[string]InferField{}
+			for _, f := range got.Fields {
+				fields[f.Path] = f
+			}
+			if len(fields) != len(tt.wantFields) {
+				t.Errorf("Infer() fields = %+v, want %v", got.Fields, tt.wantFields)
+			}
+			for path, typ := range tt.wantFields {
+				if fields[path].Typ != typ {
+					t.Errorf("Infer() field %s typ = %s, want %s", path, fields[path].Typ, typ)
+				}
+			}
+			for path, cardinality := range tt.wantCardinality {
+				if fields[path].Cardinality != cardinality {
+					t.Errorf("Infer() field %s cardinality = %d, want %d", path, fields[path].Cardinality, cardinality)
+				}
+			}
+			analysis := map[string]string{}
+			for _, f := range got.AnalysisFields {
+				analysis[f.Key] = f.Typ
+			}
+			if len(tt.wantAnalysisTypes) > 0 && !reflect.DeepEqual(analysis, tt.wantAnalysisTypes) {
+				t.Errorf("Infer() analysis fields = %v, want %v", analysis, tt.wantAnalysisTypes)
+			}
+			if _, err = Handle(got.Source, true); err != nil {
+				t.Errorf("Handle(source) error = %v", err)
+			}
+		})
+	}
+}
+
+func TestInferNoValidSample(t *testing.T) {
+	if _, err := Infer([]string{"", "[1,2]", "plain text"}); err == nil {
+		t.Error("Infer() want error when no sample is a json object")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/gotomicro/ego/core/elog"
@@ -17,13 +18,45 @@ type Item struct {
 	Key    string `json:"key"`
 	Typ    string `json:"value"`
 	Parent string `json:"parent"`
+	Alias  string `json:"alias"` // column name, default is the key
+}
+
+// SetAlias renames columns, the key of alias is parent.key or key.
+// Aliased lists come from templates and are sorted so their columns are created in a stable order.
+func (l *List) SetAlias(alias map[string]string) {
+	if len(alias) == 0 {
+		return
+	}
+	sort.Slice(l.Data, func(i, j int) bool {
+		if l.Data[i].Parent != l.Data[j].Parent {
+			return l.Data[i].Parent < l.Data[j].Parent
+		}
+		return l.Data[i].Key < l.Data[j].Key
+	})
+	for k, item := range l.Data {
+		path := item.Key
+		if item.Parent != "" {
+			path = item.Parent + "." + item.Key
+		}
+		if name, ok := alias[path]; ok {
+			l.Data[k].Alias = name
+		}
+	}
+}
+
+// Name column name
+func (m *Item) Name() string {
+	if m.Alias != "" {
+		return m.Alias
+	}
+	return m.Key
 }
 
 func (m *Item) Assemble(withType bool) string {
 	if withType {
-		return fmt.Sprintf("`%s` %s,", m.Key, fieldReplace(m.Typ))
+		return fmt.Sprintf("`%s` %s,", m.Name(), fieldReplace(m.Typ))
 	}
-	return fmt.Sprintf("`%s`,", m.Key)
+	return fmt.Sprintf("`%s`,", m.Name())
 }
 
 func (m *Item) AssembleJSONAsString() (res string) {
@@ -33,18 +66,18 @@ func (m *Item) AssembleJSONAsString() (res string) {
 	}
 	if strings.Contains(m.Typ, "JSON") {
 		// 需要将包含 JSON 类型的数据转换为 string
-		return fmt.Sprintf("toString(JSONExtractRaw(%s)) AS `%s`,", field, m.Key)
+		return fmt.Sprintf("toString(JSONExtractRaw(%s)) AS `%s`,", field, m.Name())
 	}
 	if m.Typ == "String" {
-		return fmt.Sprintf("JSONExtractString(%s) AS `%s`,", field, m.Key)
+		return fmt.Sprintf("JSONExtractString(%s) AS `%s`,", field, m.Name())
 	}
 	if m.Typ == "Float64" {
-		return fmt.Sprintf("JSONExtractFloat(%s) AS `%s`,", field, m.Key)
+		return fmt.Sprintf("JSONExtractFloat(%s) AS `%s`,", field, m.Name())
 	}
 	if m.Typ == "Bool" {
-		return fmt.Sprintf("JSONExtractBool(%s) AS `%s`,", field, m.Key)
+		return fmt.Sprintf("JSONExtractBool(%s) AS `%s`,", field, m.Name())
 	}
-	return fmt.Sprintf("JSONExtractRaw(%s) AS `%s`,", field, m.Key)
+	return fmt.Sprintf("JSONExtractRaw(%s) AS `%s`,", field, m.Name())
 }
 
 func Handle(req string, checkInner bool) (res List, err error) {
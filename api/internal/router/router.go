@@ -58,6 +58,9 @@ func GetServerRouter() *egin.Component {
 		v1Open.POST("/install", core.Handle(initialize.Install))
 		v1Open.GET("/install", core.Handle(initialize.IsInstall))
 		v1Open.POST("/prometheus/alerts", core.Handle(alert.Webhook))
+		v1Open.GET("/alert/charts/:token", core.Handle(alert.ChartImage))
+		v1Open.POST("/alert/chatops/:channel-id", core.Handle(alert.ChatOpsCallback))
+		v1Open.GET("/alert/chatops/:channel-id", core.Handle(alert.ChatOpsLink))
 	}
 	admin := g.Group("/api/admin")
 	{
@@ -77,6 +77,7 @@ func v2(r *gin.RouterGroup) {
 		r.POST("/storage", core.Handle(storage.Create))
 		r.PATCH("/storage/:storage-id", core.Handle(storage.Update))
 		r.POST("/storage/mapping-json", core.Handle(storage.KafkaJsonMapping))
+		r.POST("/storage/schema-inference", core.Handle(storage.SchemaInference))
 		r.POST("/storage/:template", core.Handle(storage.CreateStorageByTemplate))
 		r.GET("/storage/:storage-id/analysis-fields", core.Handle(storage.AnalysisFields))
 		// trace apis
@@ -84,6 +85,13 @@ func v2(r *gin.RouterGroup) {
 		r.PATCH("/storage/:storage-id/trace", core.Handle(storage.UpdateTraceInfo))
 		r.GET("/storage/:storage-id/trace-graph", core.Handle(storage.GetTraceGraph))
 		r.GET("/storage/:storage-id/columns", core.Handle(storage.GetStorageColumns))
+		r.PATCH("/storage/:storage-id/raw-log-index", core.Handle(storage.UpdateRawLogIndex))
+		r.GET("/storage/:storage-id/ingestion", core.Handle(storage.GetIngestion))
+		r.PATCH("/storage/:storage-id/ingestion", core.Handle(storage.UpdateIngestion))
+		r.GET("/storage/:storage-id/watchdog", core.Handle(storage.GetWatchdog))
+		r.PATCH("/storage/:storage-id/watchdog", core.Handle(storage.UpdateWatchdog))
+		r.GET("/storage/:storage-id/dead-letters", core.Handle(storage.ListDeadLetter))
+		r.POST("/storage/:storage-id/dead-letters/replay", core.Handle(storage.ReplayDeadLetter))
 		// collect
 		r.GET("/storage/collects", core.Handle(storage.ListCollect))
 		r.POST("/storage/collects", core.Handle(storage.CreateCollect))
@@ -96,5 +104,39 @@ func v2(r *gin.RouterGroup) {
 		r.GET("/alert/settings/:instance-id", core.Handle(alert.SettingInfo))
 		r.POST("/alert/metrics-samples", core.Handle(alert.CreateMetricsSamples))
 		r.PATCH("/alert/settings/:instance-id", core.Handle(alert.SettingUpdate))
+		// silence
+		r.GET("/alert/silences", core.Handle(alert.ListSilence))
+		r.POST("/alert/silences", core.Handle(alert.CreateSilence))
+		r.PATCH("/alert/silences/:silence-id", core.Handle(alert.UpdateSilence))
+		r.DELETE("/alert/silences/:silence-id", core.Handle(alert.DeleteSilence))
+		r.GET("/alert/alarms/:alarm-id/snooze", core.Handle(alert.SnoozeConfirm)) // link in the firing notification
+		r.POST("/alert/alarms/:alarm-id/snooze", core.Handle(alert.Snooze))
+		r.GET("/alert/oncalls", core.Handle(alert.ListOncall))
+		r.POST("/alert/oncalls", core.Handle(alert.CreateOncall))
+		r.PATCH("/alert/oncalls/:oncall-id", core.Handle(alert.UpdateOncall))
+		r.DELETE("/alert/oncalls/:oncall-id", core.Handle(alert.DeleteOncall))
+		r.GET("/alert/escalations", core.Handle(alert.ListEscalation))
+		r.POST("/alert/escalations", core.Handle(alert.CreateEscalation))
+		r.PATCH("/alert/escalations/:escalation-id", core.Handle(alert.UpdateEscalation))
+		r.DELETE("/alert/escalations/:escalation-id", core.Handle(alert.DeleteEscalation))
+		r.GET("/alert/composites", core.Handle(alert.ListComposite))
+		r.GET("/alert/composites/:composite-id", core.Handle(alert.InfoComposite))
+		r.POST("/alert/composites", core.Handle(alert.CreateComposite))
+		r.PATCH("/alert/composites/:composite-id", core.Handle(alert.UpdateComposite))
+		r.DELETE("/alert/composites/:composite-id", core.Handle(alert.DeleteComposite))
+		r.GET("/alert/deliveries", core.Handle(alert.ListDelivery))
+		r.POST("/alert/deliveries/:delivery-id/resend", core.Handle(alert.ResendDelivery))
+		r.GET("/alert/alarms/:alarm-id/incident", core.Handle(alert.Incident))
+		r.GET("/alert/alarms/:alarm-id/ack", core.Handle(alert.AckConfirm)) // link in the firing notification
+		r.POST("/alert/alarms/:alarm-id/ack", core.Handle(alert.Ack))
+		r.POST("/alert/templates/preview", core.Handle(alert.PreviewTemplate))
+		r.POST("/alert/alarms/backtest", core.Handle(alert.Backtest))
+		r.GET("/alert/alarms/backtest/:job-id", core.Handle(alert.BacktestJob))
+		r.GET("/alert/code", core.Handle(alert.ExportAlarmCode))
+		r.POST("/alert/code/plan", core.Handle(alert.PlanAlarmCode))
+		r.POST("/alert/code/apply", core.Handle(alert.ApplyAlarmCode))
+		r.GET("/alert/analytics", core.Handle(alert.Analytics))
+		r.GET("/alert/rules/drift", core.Handle(alert.ListRuleDrift))
+		r.POST("/alert/rules/drift/repair", core.Handle(alert.RepairRuleDrift))
 	}
 }
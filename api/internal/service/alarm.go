@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/ego-component/egorm"
+	"github.com/google/uuid"
 	"github.com/gotomicro/ego/core/econf"
 	"github.com/gotomicro/ego/core/elog"
 	"github.com/pkg/errors"
@@ -63,7 +64,7 @@ var _ iAlert = (*alert)(nil)
 
 type iAlert interface {
 	FilterCreate(tx *gorm.DB, alarmObj *db2.Alarm, filters []view.ReqAlarmFilterCreate) (res map[int]view.AlarmFilterItem, err error)
-	ConditionCreate(tx *gorm.DB, obj *db2.Alarm, conditions []view.ReqAlarmConditionCreate, filter *db2.AlarmFilter) (exp string, err error)
+	ConditionCreate(tx *gorm.DB, obj *db2.Alarm, conditions []view.ReqAlarmConditionCreate, filter *db2.AlarmFilter) (exp string, res []*db2.AlarmCondition, err error)
 	PrometheusReload(prometheusTarget string) (err error)
 	PrometheusRuleGen(obj *db2.Alarm, exp string, filterId int) string
 	PrometheusRuleCreateOrUpdate(instance db2.BaseInstance, groupName, ruleName, content string) (err error)
@@ -109,6 +110,11 @@ func (i *alert) FilterCreate(tx *gorm.DB, alarmObj *db2.Alarm, filters []view.Re
 			SetOperatorExp: filter.SetOperatorExp,
 			Mode:           filter.Mode,
 		}
+		if filter.Mode == db2.AlarmModeTraceEdge {
+			if err = traceEdgeFilter(alarmObj, filterObj, filter); err != nil {
+				return
+			}
+		}
 		if filterObj.When == "" {
 			filterObj.When = "1=1"
 		}
@@ -120,7 +126,7 @@ func (i *alert) FilterCreate(tx *gorm.DB, alarmObj *db2.Alarm, filters []view.Re
 			AlarmFilter: filterObj,
 		}
 		// create condition
-		row.Exp, err = i.ConditionCreate(tx, alarmObj, filter.Conditions, filterObj)
+		row.Exp, row.Conditions, err = i.ConditionCreate(tx, alarmObj, filter.Conditions, filterObj)
 		if err != nil {
 			return
 		}
@@ -129,27 +135,36 @@ func (i *alert) FilterCreate(tx *gorm.DB, alarmObj *db2.Alarm, filters []view.Re
 	return
 }
 
-func (i *alert) ConditionCreate(tx *gorm.DB, obj *db2.Alarm, conditions []view.ReqAlarmConditionCreate, filter *db2.AlarmFilter) (exp string, err error) {
+func (i *alert) ConditionCreate(tx *gorm.DB, obj *db2.Alarm, conditions []view.ReqAlarmConditionCreate, filter *db2.AlarmFilter) (exp string, res []*db2.AlarmCondition, err error) {
 	expVal := fmt.Sprintf("%s{%s} offset 10s", bumo.PrometheusMetricName, factory.TagsToString(obj, false, filter.ID))
 	sort.Slice(conditions, func(i, j int) bool {
 		return conditions[i].SetOperatorTyp < conditions[j].SetOperatorTyp
 	})
+	if res, err = newAlarmConditions(obj.ID, filter.ID, conditions); err != nil {
+		return
+	}
+	if db2.HasAnomaly(res) {
+		if filter.IsAggregation() {
+			err = errors.New("anomaly conditions are not supported by aggregation filters")
+			return
+		}
+		if res[0].SetOperatorTyp != 0 {
+			err = errors.New("conditions error")
+			return
+		}
+		for _, conditionObj := range res {
+			if err = db2.AlarmConditionCreate(tx, conditionObj); err != nil {
+				return
+			}
+		}
+		selector := fmt.Sprintf("%s{%s}", bumo.PrometheusMetricName, factory.TagsToString(obj, false, filter.ID))
+		exp = anomalyExp(selector, int64(obj.GetInterval().Seconds()), res)
+		exp = noDataOp(obj.NoDataOp, exp, expVal)
+		return
+	}
 	for _, condition := range conditions {
 		var innerCond string
-		var ot string
-		switch condition.SetOperatorExp {
-		case 0:
-			ot = "avg_over_time"
-		case 1:
-			ot = "min_over_time"
-		case 2:
-			ot = "max_over_time"
-		case 3:
-			ot = "sum_over_time"
-		case 4:
-			ot = "count_over_time"
-		}
-		expValOverTime := fmt.Sprintf("%s(%s{%s}[%s] offset 10s)", ot, bumo.PrometheusMetricName, factory.TagsToString(obj, false, filter.ID), obj.AlertInterval())
+		expValOverTime := fmt.Sprintf("%s(%s{%s}[%s] offset 10s)", overTimeFunc(condition.SetOperatorExp), bumo.PrometheusMetricName, factory.TagsToString(obj, false, filter.ID), obj.AlertInterval())
 		switch condition.Cond {
 		case 0:
 			innerCond = fmt.Sprintf("%s>%d", expValOverTime, condition.Val1)
@@ -176,25 +191,42 @@ func (i *alert) ConditionCreate(tx *gorm.DB, obj *db2.Alarm, conditions []view.R
 			}
 			exp = fmt.Sprintf("%s or %s", exp, innerCond)
 		}
+	}
+	for _, conditionObj := range res {
+		if err = db2.AlarmConditionCreate(tx, conditionObj); err != nil {
+			return
+		}
+	}
+
+	// empty data alert
+	exp = aggregationOp(filter.Mode, exp, expVal)
+	exp = noDataOp(obj.NoDataOp, exp, expVal)
+	return
+}
+
+// newAlarmConditions conditions of the filter to store or to evaluate
+func newAlarmConditions(alarmId, filterId int, conditions []view.ReqAlarmConditionCreate) ([]*db2.AlarmCondition, error) {
+	res := make([]*db2.AlarmCondition, 0, len(conditions))
+	for _, condition := range conditions {
 		conditionObj := &db2.AlarmCondition{
-			AlarmId:        obj.ID,
-			FilterId:       filter.ID,
+			AlarmId:        alarmId,
+			FilterId:       filterId,
 			SetOperatorTyp: condition.SetOperatorTyp,
 			SetOperatorExp: condition.SetOperatorExp,
 			Cond:           condition.Cond,
 			Val1:           condition.Val1,
 			Val2:           condition.Val2,
+			CondTyp:        condition.CondTyp,
+			Offset:         condition.Offset,
+			Periods:        condition.Periods,
+			Factor:         condition.Factor,
 		}
-		err = db2.AlarmConditionCreate(tx, conditionObj)
-		if err != nil {
-			return
+		if err := conditionObj.JudgmentType(); err != nil {
+			return nil, err
 		}
+		res = append(res, conditionObj)
 	}
-
-	// empty data alert
-	exp = aggregationOp(filter.Mode, exp, expVal)
-	exp = noDataOp(obj.NoDataOp, exp, expVal)
-	return
+	return res, nil
 }
 
 func (i *alert) PrometheusReload(prometheusTarget string) (err error) {
@@ -221,6 +253,9 @@ func (i *alert) PrometheusRuleCreateOrUpdate(instance db2.BaseInstance, groupNam
 		Namespace:          instance.K8sNamespace,
 		Configmap:          instance.K8sConfigmap,
 		PrometheusOperator: instance.ConfigPrometheusOperator,
+		RulerUrl:           instance.RulerUrl,
+		RulerNamespace:     instance.RulerNamespace,
+		RulerTenant:        instance.RulerTenant,
 	})
 	if err != nil {
 		return err
@@ -241,6 +276,9 @@ func (i *alert) PrometheusRuleBatchSet(clusterRuleGroups map[string]db2.ClusterR
 			Namespace:          clusterRuleGroup.Instance.K8sNamespace,
 			Configmap:          clusterRuleGroup.Instance.K8sConfigmap,
 			PrometheusOperator: clusterRuleGroup.Instance.ConfigPrometheusOperator,
+			RulerUrl:           clusterRuleGroup.Instance.RulerUrl,
+			RulerNamespace:     clusterRuleGroup.Instance.RulerNamespace,
+			RulerTenant:        clusterRuleGroup.Instance.RulerTenant,
 		})
 		if err != nil {
 			return errors.Wrap(err, "k8s configmap write error")
@@ -262,6 +300,9 @@ func (i *alert) PrometheusRuleBatchRemove(clusterRuleGroups map[string]db2.Clust
 			Namespace:          clusterRuleGroup.Instance.K8sNamespace,
 			Configmap:          clusterRuleGroup.Instance.K8sConfigmap,
 			PrometheusOperator: clusterRuleGroup.Instance.ConfigPrometheusOperator,
+			RulerUrl:           clusterRuleGroup.Instance.RulerUrl,
+			RulerNamespace:     clusterRuleGroup.Instance.RulerNamespace,
+			RulerTenant:        clusterRuleGroup.Instance.RulerTenant,
 		})
 		if err != nil {
 			return err
@@ -318,6 +359,7 @@ func (i *alert) CreateOrUpdate(alarmObj *db2.Alarm, req view.ReqAlarmCreate) (er
 	viewDDLs := db2.String2String{}
 	alertRules := db2.String2String{}
 	clusterRuleGroups := map[string]db2.ClusterRuleGroup{}
+	status := db2.AlarmStatusNormal
 	for filterId, filterItem := range filtersDB {
 		var tableInfo db2.BaseTable
 		// table info
@@ -366,6 +408,11 @@ func (i *alert) CreateOrUpdate(alarmObj *db2.Alarm, req view.ReqAlarmCreate) (er
 				}
 			}
 		}
+		if instance.AlertEvaluator == db2.AlertEvaluatorNative {
+			// evaluated by the native evaluator, no view or prometheus rule is required
+			continue
+		}
+		status = db2.AlarmStatusRuleCheck
 		// gen view table name & sql
 		table, ddl, errAlertViewGen := op.GetAlertViewSQL(alarmObj, tableInfo, filterId, &filterItem)
 		if errAlertViewGen != nil {
@@ -395,7 +442,7 @@ func (i *alert) CreateOrUpdate(alarmObj *db2.Alarm, req view.ReqAlarmCreate) (er
 				Content:  r,
 			})
 			clusterRuleGroups[instance.GetRuleStoreKey()] = clusterRuleGroup
-		} else if instance.RuleStoreType == db2.RuleStoreTypeFile || instance.RuleStoreType == db2.RuleStoreTypeK8sConfigMap {
+		} else if instance.IsRuleStoreByRule() {
 			if err = Alert.DeletePrometheusRule(&instance, alarmObj); err != nil {
 				return
 			}
@@ -407,7 +454,7 @@ func (i *alert) CreateOrUpdate(alarmObj *db2.Alarm, req view.ReqAlarmCreate) (er
 	ups := make(map[string]interface{}, 0)
 	ups["alert_rules"] = alertRules
 	ups["view_ddl_s"] = viewDDLs
-	ups["status"] = db2.AlarmStatusRuleCheck
+	ups["status"] = status
 	err = db2.AlarmUpdate(invoker.Db, alarmObj.ID, ups)
 	if err != nil {
 		return
@@ -426,8 +473,12 @@ func (i *alert) OpenOperator(id int) (err error) {
 		return
 	}
 	clusterRuleGroups := map[string]db2.ClusterRuleGroup{}
-
+	status := db2.AlarmStatusNormal
 	for _, ri := range relatedList {
+		if ri.Instance.AlertEvaluator == db2.AlertEvaluatorNative {
+			continue
+		}
+		status = db2.AlarmStatusRuleCheck
 		op, errInstanceManager := InstanceManager.Load(ri.Instance.ID)
 		if errInstanceManager != nil {
 			return errInstanceManager
@@ -481,7 +532,7 @@ func (i *alert) OpenOperator(id int) (err error) {
 						Content:  alertRule,
 					})
 					clusterRuleGroups[instance.GetRuleStoreKey()] = clusterRuleGroup
-				} else if instance.RuleStoreType == db2.RuleStoreTypeFile || instance.RuleStoreType == db2.RuleStoreTypeK8sConfigMap {
+				} else if instance.IsRuleStoreByRule() {
 					if err = i.PrometheusRuleCreateOrUpdate(instance, alarmInfo.GetGroupName(instance.ID), ruleName, alertRule); err != nil {
 						elog.Error("alert", elog.String("step", "prometheus rule delete failed"), elog.String("err", err.Error()))
 						return
@@ -506,7 +557,7 @@ func (i *alert) OpenOperator(id int) (err error) {
 					Content:  alarmInfo.RuleName(0),
 				})
 				clusterRuleGroups[instance.GetRuleStoreKey()] = clusterRuleGroup
-			} else if instance.RuleStoreType == db2.RuleStoreTypeFile || instance.RuleStoreType == db2.RuleStoreTypeK8sConfigMap {
+			} else if instance.IsRuleStoreByRule() {
 				if err = i.PrometheusRuleCreateOrUpdate(instance, alarmInfo.GetGroupName(instance.ID), alarmInfo.RuleName(0), alarmInfo.AlertRule); err != nil {
 					elog.Error("alert", elog.String("step", "prometheus rule delete failed"), elog.String("err", err.Error()))
 					return
@@ -520,17 +571,179 @@ func (i *alert) OpenOperator(id int) (err error) {
 		}
 	}
 	_ = db2.AlarmFilterUpdateStatus(invoker.Db, id, map[string]interface{}{"status": db2.AlarmStatusNormal})
-	if err = db2.AlarmUpdate(invoker.Db, id, map[string]interface{}{"status": db2.AlarmStatusRuleCheck}); err != nil {
+	if err = db2.AlarmUpdate(invoker.Db, id, map[string]interface{}{"status": status}); err != nil {
 		return
 	}
 	return
 }
 
+// Create stores the alarm, then creates its filters, views and rules
+func (i *alert) Create(uid int, req view.ReqAlarmCreate) (obj *db2.Alarm, err error) {
+	if err = pusher.TemplateValidate(req.Template); err != nil {
+		return nil, err
+	}
+	if err = alarmNotifyValidate(req); err != nil {
+		return nil, err
+	}
+	tx := invoker.Db.Begin()
+	if obj, err = alarmCreateRow(tx, uid, req); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err = tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return nil, errors.Wrap(err, "alarm create failed 03")
+	}
+	if err = i.CreateOrUpdate(obj, req); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// alarmCreateRow the alarm row, its filters, views and rules are created by CreateOrUpdate
+func alarmCreateRow(tx *gorm.DB, uid int, req view.ReqAlarmCreate) (obj *db2.Alarm, err error) {
+	tableIds := db2.Ints{}
+	for _, f := range req.Filters {
+		tableIds = append(tableIds, f.Tid)
+	}
+	obj = &db2.Alarm{
+		Uuid:             uuid.NewString(),
+		Name:             req.Name,
+		Desc:             req.Desc,
+		Interval:         req.Interval,
+		Unit:             req.Unit,
+		Tags:             req.Tags,
+		NoDataOp:         req.NoDataOp,
+		ChannelIds:       db2.Ints(req.ChannelIds),
+		Uid:              uid,
+		Level:            req.Level,
+		TableIds:         tableIds,
+		DutyOfficers:     db2.Ints(req.DutyOfficers),
+		IsDisableResolve: req.IsDisableResolve,
+		EscalationId:     req.EscalationId,
+		Template:         req.Template,
+		NotifyLogs:       req.NotifyLogs,
+		NotifyTopField:   req.NotifyTopField,
+		NotifyChart:      req.NotifyChart,
+	}
+	if err = db2.AlarmCreate(tx, obj); err != nil {
+		return nil, errors.Wrap(err, "alarm create failed 01")
+	}
+	return obj, nil
+}
+
+// Delete removes the alarm with its filters, conditions, views and rules
+func (i *alert) Delete(alarmId int) (err error) {
+	alarmInfo, relatedList, err := db2.GetAlarmTableInstanceInfo(alarmId)
+	if err != nil {
+		return err
+	}
+	tx := invoker.Db.Begin()
+	if err = alarmDeleteRows(tx, alarmId); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err = i.deleteViewsAndRules(&alarmInfo, relatedList); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// alarmDeleteRows the alarm with its filters and conditions
+func alarmDeleteRows(tx *gorm.DB, alarmId int) (err error) {
+	if err = db2.AlarmDelete(tx, alarmId); err != nil {
+		return err
+	}
+	// filter
+	if err = db2.AlarmFilterDeleteBatch(tx, alarmId); err != nil {
+		return err
+	}
+	// condition
+	return db2.AlarmConditionDeleteBatch(tx, alarmId)
+}
+
+// deleteViewsAndRules views and rules of the alarm on each of its instances
+func (i *alert) deleteViewsAndRules(alarmInfo *db2.Alarm, relatedList []*db2.RespAlarmListRelatedInfo) (err error) {
+	clusterRuleGroups := map[string]db2.ClusterRuleGroup{}
+	for _, ri := range relatedList {
+		instance := ri.Instance
+		if instance.RuleStoreType == db2.RuleStoreTypeK8sOperator {
+			clusterRuleGroup := db2.ClusterRuleGroup{}
+			if tmp, ok := clusterRuleGroups[instance.GetRuleStoreKey()]; ok {
+				clusterRuleGroup = tmp
+			} else {
+				clusterRuleGroup.ClusterId = instance.K8sClusterId
+				clusterRuleGroup.Instance = instance
+				clusterRuleGroup.GroupName = alarmInfo.GetGroupName(instance.ID)
+			}
+			clusterRuleGroups[instance.GetRuleStoreKey()] = clusterRuleGroup
+		} else if instance.IsRuleStoreByRule() {
+			_ = i.DeletePrometheusRule(&ri.Instance, alarmInfo)
+		}
+		var op factory.Operator
+		op, err = InstanceManager.Load(ri.Table.Database.Iid)
+		if err != nil {
+			return err
+		}
+		if len(alarmInfo.ViewDDLs) > 0 {
+			for iidTable := range alarmInfo.ViewDDLs {
+				table := iidTable
+				iidTableArr := strings.Split(iidTable, "|")
+				if len(iidTableArr) == 2 {
+					table = iidTableArr[1]
+					iid, _ := strconv.Atoi(iidTableArr[0])
+					op, err = InstanceManager.Load(iid)
+					if err != nil {
+						return err
+					}
+					if iid != ri.Table.Database.Iid {
+						continue
+					}
+				}
+				if err = op.DeleteAlertView(table, ri.Table.Database.Cluster); err != nil {
+					return err
+				}
+			}
+		} else {
+			if err = op.DeleteAlertView(alarmInfo.ViewTableName, ri.Table.Database.Cluster); err != nil {
+				return err
+			}
+		}
+	}
+	if len(clusterRuleGroups) > 0 {
+		_ = i.PrometheusRuleBatchRemove(clusterRuleGroups)
+	}
+	return nil
+}
+
 func (i *alert) Update(uid, alarmId int, req view.ReqAlarmCreate) (err error) {
+	tx := invoker.Db.Begin()
+	obj, err := alarmUpdateRows(tx, uid, alarmId, req)
+	if err != nil {
+		tx.Rollback()
+		return
+	}
+	if err = tx.Commit().Error; err != nil {
+		return
+	}
+	if err = i.CreateOrUpdate(&obj, req); err != nil {
+		return
+	}
+	return
+}
+
+// alarmUpdateRows the alarm row with its filters and conditions removed, they are created again by CreateOrUpdate
+func alarmUpdateRows(tx *gorm.DB, uid, alarmId int, req view.ReqAlarmCreate) (obj db2.Alarm, err error) {
 	if req.Name == "" || req.Interval == 0 || len(req.ChannelIds) == 0 {
-		return errors.New("error params")
+		return obj, errors.New("error params")
+	}
+	if err = pusher.TemplateValidate(req.Template); err != nil {
+		return obj, err
+	}
+	if err = alarmNotifyValidate(req); err != nil {
+		return obj, err
 	}
-	tx := invoker.Db.Begin()
 	ups := make(map[string]interface{}, 0)
 	ups["name"] = req.Name
 	ups["desc"] = req.Desc
@@ -542,37 +755,28 @@ func (i *alert) Update(uid, alarmId int, req view.ReqAlarmCreate) (err error) {
 	ups["channel_ids"] = db2.Ints(req.ChannelIds)
 	ups["duty_officers"] = db2.Ints(req.DutyOfficers)
 	ups["is_disable_resolve"] = req.IsDisableResolve
+	ups["escalation_id"] = req.EscalationId
+	ups["template"] = req.Template
+	ups["notify_logs"] = req.NotifyLogs
+	ups["notify_top_field"] = req.NotifyTopField
+	ups["notify_chart"] = req.NotifyChart
 	tableIds := db2.Ints{}
 	for _, f := range req.Filters {
 		tableIds = append(tableIds, f.Tid)
 	}
 	ups["table_ids"] = tableIds
 	if err = db2.AlarmUpdate(tx, alarmId, ups); err != nil {
-		tx.Rollback()
 		return
 	}
 	// filter
 	if err = db2.AlarmFilterDeleteBatch(tx, alarmId); err != nil {
-		tx.Rollback()
 		return
 	}
 	// condition
 	if err = db2.AlarmConditionDeleteBatch(tx, alarmId); err != nil {
-		tx.Rollback()
-		return
-	}
-	obj, err := db2.AlarmInfo(tx, alarmId)
-	if err != nil {
-		tx.Rollback()
 		return
 	}
-	if err = tx.Commit().Error; err != nil {
-		return
-	}
-	if err = i.CreateOrUpdate(&obj, req); err != nil {
-		return
-	}
-	return
+	return db2.AlarmInfo(tx, alarmId)
 }
 
 func (i *alert) AddPrometheusReloadChan() {
@@ -628,7 +832,8 @@ func AllPrometheusReload() {
 	}
 	pm := make(map[string]interface{})
 	for _, ins := range instances {
-		if ins.PrometheusTarget != "" {
+		// rulers load the rules of their api without reload
+		if ins.PrometheusTarget != "" && ins.RuleStoreType != db2.RuleStoreTypeRuler {
 			pm[ins.PrometheusTarget] = struct{}{}
 		}
 	}
@@ -710,14 +915,24 @@ func SendTestToChannel(c *db2.AlarmChannel) (err error) {
 	if err != nil {
 		return errors.Wrapf(err, "channel type %d not found", c.Typ)
 	}
-	err = ci.Send(c, &db2.PushMsg{
-		Title:   "Hello",
-		Text:    "test/alert/alarm/告警 the availability of the alarm channel",
-		Mobiles: econf.GetStringSlice("app.mobiles"),
-	})
+	msg := &db2.PushMsg{
+		Title:    "Hello",
+		Text:     "test/alert/alarm/告警 the availability of the alarm channel",
+		Mobiles:  econf.GetStringSlice("app.mobiles"),
+		DedupKey: "clickvisual-send-test",
+		Status:   db2.AlarmStatusFiring,
+	}
+	err = ci.Send(c, msg)
 	if err != nil {
 		return errors.Wrapf(err, "channel type %d send failed", c.Typ)
 	}
+	if c.Typ == db2.ChannelPagerDuty {
+		// the test incident is closed at once
+		msg.Status = db2.AlarmStatusNormal
+		if err = ci.Send(c, msg); err != nil {
+			return errors.Wrapf(err, "channel type %d resolve failed", c.Typ)
+		}
+	}
 	return
 }
 
@@ -762,7 +977,7 @@ func AlarmAttachInfo(respList []*db2.Alarm) []view.RespAlarmList {
 
 func aggregationOp(mode int, exp string, expVal string) string {
 	switch mode {
-	case db2.AlarmModeAggregation:
+	case db2.AlarmModeAggregation, db2.AlarmModeTraceEdge:
 		return fmt.Sprintf("%s and %s!=-1", exp, expVal)
 	default:
 		return exp
@@ -790,6 +1005,9 @@ func alarmRuleDelete(instance *db2.BaseInstance, groupName, ruleName string) (er
 		Namespace:          instance.K8sNamespace,
 		Configmap:          instance.K8sConfigmap,
 		PrometheusOperator: instance.ConfigPrometheusOperator,
+		RulerUrl:           instance.RulerUrl,
+		RulerNamespace:     instance.RulerNamespace,
+		RulerTenant:        instance.RulerTenant,
 	})
 	if err != nil {
 		return err
@@ -47,6 +47,10 @@ func (p *Prometheus) Health() error {
 	if err := sim2telnet(p.url); err != nil {
 		return err
 	}
+	if p.ruleStoreType == db.RuleStoreTypeRuler {
+		// the querier of mimir or cortex has neither lifecycle api nor remote read
+		return nil
+	}
 	// reload check
 	if err := p.checkLifecycleAPI(); err != nil {
 		return err
@@ -67,13 +71,13 @@ func (p *Prometheus) Health() error {
 func (p *Prometheus) CheckDependents() error {
 	urls, err := p.alertmanagerURLs()
 	if err != nil {
-		if p.ruleStoreType == db.RuleStoreTypeK8sOperator {
+		if p.ruleStoreType == db.RuleStoreTypeK8sOperator || p.ruleStoreType == db.RuleStoreTypeRuler {
 			return ErrCheckNotSupported
 		}
 		return err
 	}
 	if len(urls) == 0 {
-		if p.ruleStoreType == db.RuleStoreTypeK8sOperator {
+		if p.ruleStoreType == db.RuleStoreTypeK8sOperator || p.ruleStoreType == db.RuleStoreTypeRuler {
 			return ErrCheckNotSupported
 		}
 		return errors.Wrap(ErrPrometheusDependsEmpty, "webhook configuration is empty")
@@ -220,6 +224,7 @@ type prometheusApiV1RulesResp struct {
 				} `json:"annotations"`
 				Alerts         []interface{} `json:"alerts"`
 				Health         string        `json:"health"`
+				LastError      string        `json:"lastError"`
 				EvaluationTime float64       `json:"evaluationTime"`
 				LastEvaluation time.Time     `json:"lastEvaluation"`
 				Type           string        `json:"type"`
@@ -272,3 +277,83 @@ func (p *Prometheus) IsRuleTakeEffect(rules []string) (bool, error) {
 	}
 	return false, nil
 }
+
+type prometheusApiV1AlertsResp struct {
+	Status string `json:"status"`
+	Data   struct {
+		Alerts []struct {
+			Labels   map[string]string `json:"labels"`
+			State    string            `json:"state"`
+			ActiveAt time.Time         `json:"activeAt"`
+		} `json:"alerts"`
+	} `json:"data"`
+}
+
+// RuleHealth evaluation status of the alerting rules by rule name, from /api/v1/rules and /api/v1/alerts.
+// The tenant is sent as X-Scope-OrgID to the multi-tenant rulers.
+func (p *Prometheus) RuleHealth(tenant string) (map[string]db.AlarmRuleHealth, error) {
+	client := resty.New().SetTimeout(5 * time.Second)
+	if tenant != "" {
+		client.SetHeader("X-Scope-OrgID", tenant)
+	}
+	var rules prometheusApiV1RulesResp
+	if err := p.getJSON(client, "/api/v1/rules?type=alert", &rules); err != nil {
+		return nil, err
+	}
+	if rules.Status != "success" {
+		return nil, errors.Wrap(ErrPrometheusApiResponse, rules.Status)
+	}
+	var alerts prometheusApiV1AlertsResp
+	if err := p.getJSON(client, "/api/v1/alerts", &alerts); err != nil {
+		return nil, err
+	}
+	if alerts.Status != "success" {
+		return nil, errors.Wrap(ErrPrometheusApiResponse, alerts.Status)
+	}
+	res := make(map[string]db.AlarmRuleHealth)
+	for _, group := range rules.Data.Groups {
+		for _, rule := range group.Rules {
+			if rule.Type != "" && rule.Type != "alerting" {
+				continue
+			}
+			h := db.AlarmRuleHealth{
+				Rule:           rule.Name,
+				Loaded:         true,
+				State:          rule.State,
+				Health:         rule.Health,
+				LastError:      rule.LastError,
+				EvaluationTime: rule.EvaluationTime,
+			}
+			if !rule.LastEvaluation.IsZero() {
+				h.LastEvaluation = rule.LastEvaluation.Unix()
+			}
+			res[rule.Name] = h
+		}
+	}
+	for _, alert := range alerts.Data.Alerts {
+		h, ok := res[alert.Labels["alertname"]]
+		if !ok || (alert.State != "pending" && alert.State != "firing") {
+			continue
+		}
+		h.ActiveAlerts++
+		if activeAt := alert.ActiveAt.Unix(); !alert.ActiveAt.IsZero() && (h.ActiveAt == 0 || activeAt < h.ActiveAt) {
+			h.ActiveAt = activeAt
+		}
+		res[h.Rule] = h
+	}
+	return res, nil
+}
+
+func (p *Prometheus) getJSON(client *resty.Client, path string, res interface{}) error {
+	resp, err := client.R().Get(p.url + path)
+	if err != nil {
+		return errors.Wrapf(err, "http.Get %s", path)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return errors.Wrapf(ErrPrometheusApiResponse, "%s status %d", path, resp.StatusCode())
+	}
+	if err = json.Unmarshal(resp.Body(), res); err != nil {
+		return errors.Wrap(err, "json.Unmarshal")
+	}
+	return nil
+}
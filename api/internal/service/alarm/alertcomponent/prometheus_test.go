@@ -0,0 +1,61 @@
+package alertcomponent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheus_RuleHealth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "tenant", r.Header.Get("X-Scope-OrgID"))
+		switch r.URL.Path {
+		case "/api/v1/rules":
+			assert.Equal(t, "alert", r.URL.Query().Get("type"))
+			_, _ = w.Write([]byte(`{"status":"success","data":{"groups":[{"name":"default","rules":[
+{"name":"ClickVisual-a_1","state":"firing","health":"ok","lastError":"","evaluationTime":0.25,"lastEvaluation":"2023-11-14T22:13:20Z","type":"alerting"},
+{"name":"ClickVisual-a_2","state":"inactive","health":"err","lastError":"table not found","evaluationTime":0.5,"lastEvaluation":"2023-11-14T22:13:20Z","type":"alerting"}]}]}}`))
+		case "/api/v1/alerts":
+			_, _ = w.Write([]byte(`{"status":"success","data":{"alerts":[
+{"labels":{"alertname":"ClickVisual-a_1"},"state":"firing","activeAt":"2023-11-14T22:10:00Z"},
+{"labels":{"alertname":"ClickVisual-a_1"},"state":"pending","activeAt":"2023-11-14T22:12:00Z"},
+{"labels":{"alertname":"other"},"state":"firing","activeAt":"2023-11-14T22:12:00Z"}]}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	p, err := NewPrometheus(srv.URL, 0)
+	assert.NoError(t, err)
+	res, err := p.RuleHealth("tenant")
+	assert.NoError(t, err)
+	assert.Len(t, res, 2)
+
+	firing := res["ClickVisual-a_1"]
+	assert.True(t, firing.Loaded)
+	assert.Equal(t, "firing", firing.State)
+	assert.Equal(t, 2, firing.ActiveAlerts)
+	assert.Equal(t, int64(1700000000), firing.LastEvaluation)
+	assert.Equal(t, int64(1699999800), firing.ActiveAt)
+
+	broken := res["ClickVisual-a_2"]
+	assert.Equal(t, "err", broken.Health)
+	assert.Equal(t, "table not found", broken.LastError)
+	assert.Equal(t, 0.5, broken.EvaluationTime)
+	assert.Equal(t, 0, broken.ActiveAlerts)
+}
+
+func TestPrometheus_RuleHealthError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	p, err := NewPrometheus(srv.URL, 0)
+	assert.NoError(t, err)
+	_, err = p.RuleHealth("")
+	assert.ErrorIs(t, err, ErrPrometheusApiResponse)
+}
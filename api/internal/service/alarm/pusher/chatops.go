@@ -0,0 +1,206 @@
+package pusher
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/pkg/errors"
+	"github.com/slack-go/slack"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+const (
+	ChatOpsAck    = "ack"
+	ChatOpsSnooze = "snooze"
+
+	// ChatOpsSnoozeDuration silence of the snooze button
+	ChatOpsSnoozeDuration = "1h"
+
+	// chatOpsMaxSkew callbacks older than this are replays
+	chatOpsMaxSkew = 5 * time.Minute
+	// chatOpsLinkTTL the signed links of dingding expire after a day
+	chatOpsLinkTTL = 24 * time.Hour
+
+	feishuURLVerification = "url_verification"
+)
+
+// ChatOpsRequest a button of a firing message clicked in the chat, verified with the callback secret of the channel
+type ChatOpsRequest struct {
+	Action  string
+	AlarmId int
+	User    string // the chat user, not a clickvisual user
+	// Challenge echoed when feishu verifies the request url, there is no action
+	Challenge string
+	// ResponseURL and ThreadTs slack replies in the thread of the message
+	ResponseURL string
+	ThreadTs    string
+	// LinkSign and LinkExp the dingding link, each link is used once
+	LinkSign string
+	LinkExp  int64
+}
+
+type feishuCallback struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Token     string `json:"token"`
+	OpenId    string `json:"open_id"`
+	UserId    string `json:"user_id"`
+	Action    struct {
+		Value map[string]string `json:"value"`
+	} `json:"action"`
+}
+
+// isChatOps the message gets the buttons of the alarm
+func isChatOps(channel *db.AlarmChannel, msg *db.PushMsg) bool {
+	return channel.IsChatOps() && msg.AlarmId != 0
+}
+
+// ChatOpsCallbackURL request url of the callbacks of the channel, set in the feishu or slack app
+func ChatOpsCallbackURL(channelId int) string {
+	return fmt.Sprintf("%s/api/v1/alert/chatops/%d", strings.TrimRight(econf.GetString("app.rootURL"), "/"), channelId)
+}
+
+// ParseFeiShuCallback card callback of feishu, the signature is sha1 of the timestamp, the nonce,
+// the verification token and the body. The encrypt key of the app must not be set.
+func ParseFeiShuCallback(token string, header http.Header, body []byte, now time.Time) (res ChatOpsRequest, err error) {
+	var req feishuCallback
+	if err = json.Unmarshal(body, &req); err != nil {
+		return res, errors.Wrap(err, "invalid feishu callback")
+	}
+	if subtle.ConstantTimeCompare([]byte(req.Token), []byte(token)) != 1 {
+		return res, errors.New("invalid feishu verification token")
+	}
+	if req.Type == feishuURLVerification {
+		res.Challenge = req.Challenge
+		return res, nil
+	}
+	timestamp, nonce := header.Get("X-Lark-Request-Timestamp"), header.Get("X-Lark-Request-Nonce")
+	if err = chatOpsFresh(timestamp, now); err != nil {
+		return res, err
+	}
+	sum := sha1.Sum([]byte(timestamp + nonce + token + string(body)))
+	if !hmac.Equal([]byte(hex.EncodeToString(sum[:])), []byte(header.Get("X-Lark-Signature"))) {
+		return res, errors.New("invalid feishu signature")
+	}
+	res.Action = req.Action.Value["action"]
+	res.AlarmId, _ = strconv.Atoi(req.Action.Value["alarmId"])
+	res.User = req.UserId
+	if res.User == "" {
+		res.User = req.OpenId
+	}
+	return res, nil
+}
+
+// ParseSlackCallback block actions of slack, verified with the signing secret of the app
+func ParseSlackCallback(secret string, header http.Header, body []byte) (res ChatOpsRequest, err error) {
+	sv, err := slack.NewSecretsVerifier(header, secret)
+	if err != nil {
+		return res, errors.Wrap(err, "invalid slack signature")
+	}
+	if _, err = sv.Write(body); err != nil {
+		return res, errors.Wrap(err, "invalid slack signature")
+	}
+	if err = sv.Ensure(); err != nil {
+		return res, errors.Wrap(err, "invalid slack signature")
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return res, errors.Wrap(err, "invalid slack callback")
+	}
+	var req slack.InteractionCallback
+	if err = json.Unmarshal([]byte(form.Get("payload")), &req); err != nil {
+		return res, errors.Wrap(err, "invalid slack callback")
+	}
+	for _, action := range req.ActionCallback.BlockActions {
+		if action.ActionID == ChatOpsAck || action.ActionID == ChatOpsSnooze {
+			res.Action = action.ActionID
+			res.AlarmId, _ = strconv.Atoi(action.Value)
+			break
+		}
+	}
+	res.User = req.User.Name
+	if res.User == "" {
+		res.User = req.User.ID
+	}
+	res.ResponseURL = req.ResponseURL
+	res.ThreadTs = req.Container.MessageTs
+	return res, nil
+}
+
+// ParseDingDingCallback dingding robots have no callbacks, the buttons are links signed with the callback secret
+func ParseDingDingCallback(secret string, channelId int, query url.Values, now time.Time) (res ChatOpsRequest, err error) {
+	res.Action = query.Get("action")
+	res.AlarmId, _ = strconv.Atoi(query.Get("alarmId"))
+	exp, _ := strconv.ParseInt(query.Get("exp"), 10, 64)
+	if now.Unix() > exp {
+		return res, errors.New("the link has expired")
+	}
+	if !hmac.Equal([]byte(dingDingSign(secret, channelId, res.AlarmId, res.Action, exp)), []byte(query.Get("sign"))) {
+		return res, errors.New("invalid dingding signature")
+	}
+	res.User, res.LinkSign, res.LinkExp = "dingding", query.Get("sign"), exp
+	return res, nil
+}
+
+// dingDingActionURL link of a dingding button, valid for chatOpsLinkTTL
+func dingDingActionURL(channel *db.AlarmChannel, alarmId int, action string, now time.Time) string {
+	exp := now.Add(chatOpsLinkTTL).Unix()
+	query := url.Values{}
+	query.Set("alarmId", strconv.Itoa(alarmId))
+	query.Set("action", action)
+	query.Set("exp", strconv.FormatInt(exp, 10))
+	query.Set("sign", dingDingSign(channel.CallbackSecret, channel.ID, alarmId, action, exp))
+	return ChatOpsCallbackURL(channel.ID) + "?" + query.Encode()
+}
+
+func dingDingSign(secret string, channelId, alarmId int, action string, exp int64) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%d\n%d\n%s\n%d", channelId, alarmId, action, exp)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// chatOpsFresh the timestamp in seconds is within chatOpsMaxSkew of now
+func chatOpsFresh(timestamp string, now time.Time) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "invalid callback timestamp")
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > chatOpsMaxSkew || d < -chatOpsMaxSkew {
+		return errors.New("callback timestamp is too old")
+	}
+	return nil
+}
+
+// ChatOpsReply result of the action sent back to the chat
+func ChatOpsReply(alarmName string, req ChatOpsRequest, err error) string {
+	if err != nil {
+		return fmt.Sprintf("【%s】%s: %s (%s)", alarmName, msgLabel("chatOpsFailed"), err.Error(), req.User)
+	}
+	label := "chatOpsAcked"
+	if req.Action == ChatOpsSnooze {
+		label = "chatOpsSnoozed"
+	}
+	return fmt.Sprintf("【%s】%s (%s)", alarmName, msgLabel(label), req.User)
+}
+
+// ReplySlack posts the reply in the thread of the message
+func ReplySlack(req ChatOpsRequest, text string) error {
+	return slack.PostWebhook(req.ResponseURL, &slack.WebhookMessage{
+		Text:            text,
+		ResponseType:    slack.ResponseTypeInChannel,
+		ThreadTimestamp: req.ThreadTs,
+	})
+}
@@ -0,0 +1,149 @@
+package pusher
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+func TestParseFeiShuCallback(t *testing.T) {
+	Convey("ParseFeiShuCallback", t, func() {
+		now := time.Unix(1700000000, 0)
+		body := []byte(`{"open_id":"ou_1","user_id":"u1","token":"token","action":{"tag":"button","value":{"action":"ack","alarmId":"12"}}}`)
+		sign := func(timestamp string, body []byte) http.Header {
+			sum := sha1.Sum([]byte(timestamp + "nonce" + "token" + string(body)))
+			header := http.Header{}
+			header.Set("X-Lark-Request-Timestamp", timestamp)
+			header.Set("X-Lark-Request-Nonce", "nonce")
+			header.Set("X-Lark-Signature", hex.EncodeToString(sum[:]))
+			return header
+		}
+
+		Convey("signed action", func() {
+			res, err := ParseFeiShuCallback("token", sign("1700000000", body), body, now)
+			So(err, ShouldBeNil)
+			So(res, ShouldResemble, ChatOpsRequest{Action: ChatOpsAck, AlarmId: 12, User: "u1"})
+		})
+
+		Convey("url verification", func() {
+			res, err := ParseFeiShuCallback("token", http.Header{}, []byte(`{"challenge":"c1","token":"token","type":"url_verification"}`), now)
+			So(err, ShouldBeNil)
+			So(res.Challenge, ShouldEqual, "c1")
+			_, err = ParseFeiShuCallback("other", http.Header{}, []byte(`{"challenge":"c1","token":"token","type":"url_verification"}`), now)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("tampered body", func() {
+			header := sign("1700000000", body)
+			_, err := ParseFeiShuCallback("token", header, []byte(strings.Replace(string(body), `"12"`, `"13"`, 1)), now)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("replayed callback", func() {
+			_, err := ParseFeiShuCallback("token", sign("1699990000", body), body, now)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestParseSlackCallback(t *testing.T) {
+	Convey("ParseSlackCallback", t, func() {
+		payload := `{"type":"block_actions","user":{"id":"U1","name":"alice"},"response_url":"https://hooks.slack.com/actions/1","container":{"type":"message_attachment","message_ts":"1.2"},"actions":[{"type":"button","block_id":"chatops","action_id":"snooze","value":"7"}]}`
+		body := []byte(url.Values{"payload": {payload}}.Encode())
+		sign := func(secret string, ts int64) http.Header {
+			mac := hmac.New(sha256.New, []byte(secret))
+			mac.Write([]byte(fmt.Sprintf("v0:%d:%s", ts, body)))
+			header := http.Header{}
+			header.Set("X-Slack-Request-Timestamp", strconv.FormatInt(ts, 10))
+			header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+			return header
+		}
+
+		Convey("signed action", func() {
+			res, err := ParseSlackCallback("secret", sign("secret", time.Now().Unix()), body)
+			So(err, ShouldBeNil)
+			So(res, ShouldResemble, ChatOpsRequest{Action: ChatOpsSnooze, AlarmId: 7, User: "alice", ResponseURL: "https://hooks.slack.com/actions/1", ThreadTs: "1.2"})
+		})
+
+		Convey("other secret", func() {
+			_, err := ParseSlackCallback("secret", sign("other", time.Now().Unix()), body)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("replayed callback", func() {
+			_, err := ParseSlackCallback("secret", sign("secret", time.Now().Add(-time.Hour).Unix()), body)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestParseDingDingCallback(t *testing.T) {
+	Convey("ParseDingDingCallback", t, func() {
+		now := time.Unix(1700000000, 0)
+		channel := &db.AlarmChannel{BaseModel: db.BaseModel{ID: 3}, CallbackSecret: "secret"}
+		link, err := url.Parse(dingDingActionURL(channel, 12, ChatOpsAck, now))
+		So(err, ShouldBeNil)
+		So(link.Path, ShouldEndWith, "/api/v1/alert/chatops/3")
+
+		Convey("signed link", func() {
+			res, err := ParseDingDingCallback("secret", 3, link.Query(), now.Add(time.Hour))
+			So(err, ShouldBeNil)
+			So(res, ShouldResemble, ChatOpsRequest{Action: ChatOpsAck, AlarmId: 12, User: "dingding",
+				LinkSign: link.Query().Get("sign"), LinkExp: now.Add(chatOpsLinkTTL).Unix()})
+		})
+
+		Convey("link of another channel or alarm", func() {
+			_, err := ParseDingDingCallback("secret", 4, link.Query(), now)
+			So(err, ShouldNotBeNil)
+			query := link.Query()
+			query.Set("alarmId", "13")
+			_, err = ParseDingDingCallback("secret", 3, query, now)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("expired link", func() {
+			_, err := ParseDingDingCallback("secret", 3, link.Query(), now.Add(chatOpsLinkTTL+time.Second))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestChatOpsButtons(t *testing.T) {
+	Convey("chat-ops buttons", t, func() {
+		channel := &db.AlarmChannel{BaseModel: db.BaseModel{ID: 3}, ChatOps: 1, CallbackSecret: "secret"}
+		msg := &db.PushMsg{AlarmId: 12, LogsURL: "http://logs"}
+		So(isChatOps(channel, msg), ShouldBeTrue)
+		So(isChatOps(channel, &db.PushMsg{}), ShouldBeFalse)
+		So(isChatOps(&db.AlarmChannel{ChatOps: 1}, msg), ShouldBeFalse)
+
+		links := dingDingButtons(channel, msg, time.Unix(1700000000, 0))
+		So(links, ShouldStartWith, "[确认告警](")
+		So(links, ShouldContainSubstring, "action=snooze")
+		So(links, ShouldContainSubstring, "[告警日志](http://logs)")
+
+		buttons := feiShuButtons(msg)
+		So(buttons, ShouldHaveLength, 3)
+		So(buttons[0].Value, ShouldResemble, map[string]string{"action": ChatOpsAck, "alarmId": "12"})
+		So(buttons[2].URL, ShouldEqual, "http://logs")
+		So(slackButtons(&db.PushMsg{AlarmId: 12}), ShouldHaveLength, 2)
+	})
+}
+
+func TestChatOpsReply(t *testing.T) {
+	Convey("ChatOpsReply", t, func() {
+		So(ChatOpsReply("cpu", ChatOpsRequest{Action: ChatOpsSnooze, User: "alice"}, nil), ShouldEqual, "【cpu】已静默一小时 (alice)")
+		So(ChatOpsReply("cpu", ChatOpsRequest{Action: ChatOpsAck, User: "alice"}, fmt.Errorf("alarm is not firing")), ShouldEqual, "【cpu】操作失败: alarm is not firing (alice)")
+	})
+}
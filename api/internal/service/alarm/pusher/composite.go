@@ -0,0 +1,35 @@
+package pusher
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+// CompositeAlarm an alarm of the expression of a composite alarm
+type CompositeAlarm struct {
+	Id     int
+	Name   string
+	Firing bool
+}
+
+// BuildCompositeMsg message of the state change of the composite alarm with the states of its alarms
+func BuildCompositeMsg(composite *db.AlarmComposite, alarms []CompositeAlarm, status int, at time.Time) (msg *db.PushMsg, msgWithAt *db.PushMsg) {
+	m := newStateMsg(status, composite.Name)
+	if composite.Desc != "" {
+		m.field("alarmDesc", composite.Desc)
+	}
+	m.field("startsAt", FormatTime(at))
+	m.statusField()
+	m.field("compositeExpr", composite.Expr)
+	m.lines = append(m.lines, fmt.Sprintf("【%s】:", msgLabel("compositeAlarms")))
+	for _, alarm := range alarms {
+		state := msgLabel("normal")
+		if alarm.Firing {
+			state = msgLabel("firing")
+		}
+		m.lines = append(m.lines, fmt.Sprintf("- %s(%d): %s", alarm.Name, alarm.Id, state))
+	}
+	return m.build(composite.Name, fmt.Sprintf("composite-%d", composite.ID))
+}
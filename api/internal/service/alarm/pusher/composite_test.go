@@ -0,0 +1,29 @@
+package pusher
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+func TestBuildCompositeMsg(t *testing.T) {
+	composite := &db.AlarmComposite{Name: "checkout", Expr: "1 && !2"}
+	composite.ID = 7
+	alarms := []CompositeAlarm{{Id: 1, Name: "errors", Firing: true}, {Id: 2, Name: "deploy", Firing: false}}
+	at := time.Unix(1700000000, 0)
+
+	Convey("firing composite alarm", t, func() {
+		msg, msgWithAt := BuildCompositeMsg(composite, alarms, db.AlarmStatusFiring, at)
+		So(msg.DedupKey, ShouldEqual, "composite-7")
+		So(msg.Status, ShouldEqual, db.AlarmStatusFiring)
+		So(msg.Title, ShouldContainSubstring, "checkout")
+		So(msg.Text, ShouldContainSubstring, "1 && !2")
+		So(msg.Text, ShouldContainSubstring, "errors(1)")
+		So(msg.Text, ShouldContainSubstring, "deploy(2)")
+		So(strings.Contains(msgWithAt.Text, "\n\n"), ShouldBeTrue)
+	})
+}
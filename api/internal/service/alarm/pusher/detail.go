@@ -0,0 +1,88 @@
+package pusher
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// partialLogMaxLen bytes of a sample log kept in the built-in messages
+const partialLogMaxLen = 600
+
+// Detail samples of the alert window added to the messages
+type Detail struct {
+	Logs      []string   // matching logs as json, the first one is the partial log
+	TopField  string     // field of the top values
+	TopValues []TopValue // top values of the field over the alert window
+	Chart     []byte     // png chart of the condition metric
+	ChartURL  string     // link of the chart for the channels which can not upload images
+}
+
+// TopValue a value of the field with its count of logs
+type TopValue struct {
+	Value string `json:"value"`
+	Count uint64 `json:"count"`
+}
+
+// PartialLog first sample log
+func (d *Detail) PartialLog() string {
+	if d == nil || len(d.Logs) == 0 {
+		return ""
+	}
+	return d.Logs[0]
+}
+
+// TopValues the n values with the most logs, by value for equal counts
+func TopValues(counts map[string]uint64, n int) []TopValue {
+	res := make([]TopValue, 0, len(counts))
+	for v, c := range counts {
+		res = append(res, TopValue{Value: v, Count: c})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		return res[i].Value < res[j].Value
+	})
+	if len(res) > n {
+		res = res[:n]
+	}
+	return res
+}
+
+// writeDetail writes the top values and the sample logs, each line ends with sep
+func writeDetail(buffer *bytes.Buffer, detail *Detail, sep string) {
+	if detail == nil {
+		return
+	}
+	if len(detail.TopValues) > 0 {
+		values := make([]string, 0, len(detail.TopValues))
+		for _, v := range detail.TopValues {
+			values = append(values, fmt.Sprintf("%s(%d)", v.Value, v.Count))
+		}
+		buffer.WriteString(fmt.Sprintf("【%s %s】: %s%s", msgLabel("topValues"), detail.TopField, strings.Join(values, " "), sep))
+	}
+	if detail.ChartURL != "" {
+		buffer.WriteString(fmt.Sprintf("【%s】: %s%s", msgLabel("chart"), detail.ChartURL, sep))
+	}
+	switch len(detail.Logs) {
+	case 0:
+	case 1:
+		buffer.WriteString(fmt.Sprintf("【%s】: %s", msgLabel("logs"), truncateLog(detail.Logs[0])))
+	default:
+		buffer.WriteString(fmt.Sprintf("【%s】:%s", msgLabel("logs"), sep))
+		for k, log := range detail.Logs {
+			buffer.WriteString(fmt.Sprintf("%d. %s%s", k+1, truncateLog(log), sep))
+		}
+	}
+}
+
+// truncateLog quotes are removed as before, the log is cut at partialLogMaxLen bytes
+func truncateLog(log string) string {
+	log = strings.Replace(log, "\"", "", -1)
+	if len(log) > partialLogMaxLen {
+		return log[0 : partialLogMaxLen-1]
+	}
+	return log
+}
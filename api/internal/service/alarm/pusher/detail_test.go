@@ -0,0 +1,50 @@
+package pusher
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTopValues(t *testing.T) {
+	Convey("TopValues", t, func() {
+		res := TopValues(map[string]uint64{"a": 1, "b": 5, "c": 5, "d": 3}, 3)
+		So(res, ShouldResemble, []TopValue{{Value: "b", Count: 5}, {Value: "c", Count: 5}, {Value: "d", Count: 3}})
+		So(TopValues(nil, 3), ShouldBeEmpty)
+	})
+}
+
+func TestWriteDetail(t *testing.T) {
+	Convey("writeDetail", t, func() {
+		Convey("one log is the partial log", func() {
+			var buf bytes.Buffer
+			writeDetail(&buf, &Detail{Logs: []string{`{"msg":"a"}`}}, "\n")
+			So(buf.String(), ShouldEqual, "【告警日志】: {msg:a}")
+		})
+
+		Convey("top values, chart and logs", func() {
+			var buf bytes.Buffer
+			writeDetail(&buf, &Detail{
+				Logs:      []string{`{"msg":"a"}`, strings.Repeat("x", 700)},
+				TopField:  "host",
+				TopValues: []TopValue{{Value: "h1", Count: 3}, {Value: "h2", Count: 1}},
+				ChartURL:  "http://localhost/chart",
+			}, "\n")
+			lines := strings.Split(buf.String(), "\n")
+			So(lines[0], ShouldEqual, "【字段分布 host】: h1(3) h2(1)")
+			So(lines[1], ShouldEqual, "【告警图表】: http://localhost/chart")
+			So(lines[2], ShouldEqual, "【告警日志】:")
+			So(lines[3], ShouldEqual, "1. {msg:a}")
+			So(lines[4], ShouldEqual, "2. "+strings.Repeat("x", partialLogMaxLen-1))
+		})
+
+		Convey("nothing without detail", func() {
+			var buf bytes.Buffer
+			writeDetail(&buf, nil, "\n")
+			So(buf.Len(), ShouldEqual, 0)
+			So((*Detail)(nil).PartialLog(), ShouldEqual, "")
+		})
+	})
+}
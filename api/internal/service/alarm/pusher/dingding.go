@@ -3,7 +3,9 @@ package pusher
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -16,11 +18,15 @@ var _ IPusher = (*DingDing)(nil)
 type DingDing struct{}
 
 func (d *DingDing) Send(channel *db.AlarmChannel, msg *db.PushMsg) (err error) {
+	text := msg.Text
+	if isChatOps(channel, msg) {
+		text += dingDingButtons(channel, msg, time.Now())
+	}
 	markdown := &view.DingTalkMarkdown{
 		MsgType: "markdown",
 		Markdown: &view.Markdown{
 			Title: msg.Title,
-			Text:  msg.Text,
+			Text:  text,
 		},
 		At: &view.At{
 			IsAtAll:   false,
@@ -47,3 +53,15 @@ func (d *DingDing) Send(channel *db.AlarmChannel, msg *db.PushMsg) (err error) {
 	defer func() { _ = resp.Body.Close() }()
 	return
 }
+
+// dingDingButtons markdown links as the action cards can not mention the duty officers,
+// acknowledge and snooze are links signed with the callback secret of the channel
+func dingDingButtons(channel *db.AlarmChannel, msg *db.PushMsg, now time.Time) string {
+	res := fmt.Sprintf("[%s](%s) | [%s](%s)",
+		msgLabel("ack"), dingDingActionURL(channel, msg.AlarmId, ChatOpsAck, now),
+		msgLabel("snooze"), dingDingActionURL(channel, msg.AlarmId, ChatOpsSnooze, now))
+	if msg.LogsURL != "" {
+		res += fmt.Sprintf(" | [%s](%s)", msgLabel("logs"), msg.LogsURL)
+	}
+	return res + "\n\n"
+}
@@ -0,0 +1,154 @@
+package pusher
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+const emailDialTimeout = 10 * time.Second
+
+var _ IPusher = (*Email)(nil)
+
+// Email sends html mails through the smtp server of the channel key
+type Email struct{}
+
+func (e *Email) Send(channel *db.AlarmChannel, msg *db.PushMsg) (err error) {
+	conf, err := db.ParseChannelEmailKey(channel.Key)
+	if err != nil {
+		return err
+	}
+	client, err := e.dial(conf)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Close() }()
+	if conf.Username != "" {
+		if err = client.Auth(smtp.PlainAuth("", conf.Username, conf.Password, conf.Host)); err != nil {
+			return errors.Wrap(err, "smtp auth")
+		}
+	}
+	if err = client.Mail(conf.From); err != nil {
+		return errors.Wrap(err, "smtp mail")
+	}
+	for _, to := range conf.To {
+		if err = client.Rcpt(to); err != nil {
+			return errors.Wrapf(err, "smtp rcpt %s", to)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return errors.Wrap(err, "smtp data")
+	}
+	if _, err = w.Write(emailMessage(conf, msg)); err != nil {
+		return errors.Wrap(err, "smtp write")
+	}
+	if err = w.Close(); err != nil {
+		return errors.Wrap(err, "smtp data close")
+	}
+	return client.Quit()
+}
+
+func (e *Email) dial(conf db.ChannelEmailKey) (*smtp.Client, error) {
+	addr := net.JoinHostPort(conf.Host, strconv.Itoa(conf.Port))
+	tlsConf := &tls.Config{ServerName: conf.Host, InsecureSkipVerify: conf.InsecureSkipVerify} // nolint:gosec
+	var (
+		conn net.Conn
+		err  error
+	)
+	dialer := &net.Dialer{Timeout: emailDialTimeout}
+	if conf.TLS == db.EmailTLSImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConf)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "smtp dial %s", addr)
+	}
+	client, err := smtp.NewClient(conn, conf.Host)
+	if err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrapf(err, "smtp client %s", addr)
+	}
+	if conf.TLS == db.EmailTLSStartTLS {
+		if err = client.StartTLS(tlsConf); err != nil {
+			_ = client.Close()
+			return nil, errors.Wrap(err, "smtp starttls")
+		}
+	}
+	return client, nil
+}
+
+// emailChartID content id of the inline chart
+const emailChartID = "chart@clickvisual"
+
+// emailMessage html mail of the message, lines of the text are kept,
+// the mail is multipart/related with the image inline below the text when the message has one
+func emailMessage(conf db.ChannelEmailKey, msg *db.PushMsg) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("From: %s\r\n", conf.From))
+	buffer.WriteString(fmt.Sprintf("To: %s\r\n", strings.Join(conf.To, ", ")))
+	buffer.WriteString(fmt.Sprintf("Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Title)))
+	buffer.WriteString(fmt.Sprintf("Date: %s\r\n", time.Now().Format(time.RFC1123Z)))
+	buffer.WriteString("MIME-Version: 1.0\r\n")
+	if len(msg.Image) == 0 {
+		buffer.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+		buffer.WriteString("\r\n")
+		buffer.Write(emailHTML(msg))
+		return buffer.Bytes()
+	}
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	buffer.WriteString(fmt.Sprintf("Content-Type: multipart/related; boundary=%s\r\n", w.Boundary()))
+	buffer.WriteString("\r\n")
+	part, _ := w.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/html; charset=UTF-8"}})
+	_, _ = part.Write(emailHTML(msg))
+	part, _ = w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"image/png"},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-ID":                {"<" + emailChartID + ">"},
+		"Content-Disposition":       {"inline; filename=chart.png"},
+	})
+	encoded := base64.StdEncoding.EncodeToString(msg.Image)
+	for len(encoded) > 76 {
+		_, _ = part.Write([]byte(encoded[:76] + "\r\n"))
+		encoded = encoded[76:]
+	}
+	_, _ = part.Write([]byte(encoded + "\r\n"))
+	_ = w.Close()
+	buffer.Write(body.Bytes())
+	return buffer.Bytes()
+}
+
+// emailFontTag escaped color tags of the pushers, put back as html
+var emailFontTag = regexp.MustCompile(`&lt;(font color=#?[0-9A-Za-z]+|/font)&gt;`)
+
+func emailHTML(msg *db.PushMsg) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("<html><body><h3>%s</h3>\r\n", html.EscapeString(msg.Title)))
+	// the text is markdown with font tags, it is escaped but for the color tags and the line breaks are converted
+	for _, line := range strings.Split(strings.TrimRight(msg.Text, "\n"), "\n") {
+		buffer.WriteString(emailFontTag.ReplaceAllString(html.EscapeString(line), "<$1>"))
+		buffer.WriteString("<br/>\r\n")
+	}
+	if len(msg.Image) > 0 {
+		buffer.WriteString(fmt.Sprintf("<img src=\"cid:%s\" alt=\"chart\"/>\r\n", emailChartID))
+	}
+	buffer.WriteString("</body></html>\r\n")
+	return buffer.Bytes()
+}
@@ -0,0 +1,145 @@
+package pusher
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+// fakeSMTP accepts one mail without tls and records the commands and the data
+type fakeSMTP struct {
+	ln       net.Listener
+	mu       sync.Mutex
+	commands []string
+	data     string
+	done     chan struct{}
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSMTP{ln: ln, done: make(chan struct{})}
+	go s.serve()
+	return s
+}
+
+func (s *fakeSMTP) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeSMTP) serve() {
+	defer close(s.done)
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	write := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+	write("220 fake smtp")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		s.mu.Lock()
+		s.commands = append(s.commands, line)
+		s.mu.Unlock()
+		switch cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); cmd {
+		case "EHLO":
+			write("250-fake")
+			write("250 AUTH PLAIN")
+		case "AUTH":
+			write("235 ok")
+		case "MAIL", "RCPT":
+			write("250 ok")
+		case "DATA":
+			write("354 go ahead")
+			var data strings.Builder
+			for {
+				l, errData := r.ReadString('\n')
+				if errData != nil || l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.mu.Lock()
+			s.data = data.String()
+			s.mu.Unlock()
+			write("250 queued")
+		case "QUIT":
+			write("221 bye")
+			return
+		default:
+			write("502 unknown")
+		}
+	}
+}
+
+func TestEmail_Send(t *testing.T) {
+	Convey("email is sent to every recipient", t, func() {
+		s := newFakeSMTP(t)
+		defer s.ln.Close()
+		key, _ := json.Marshal(db.ChannelEmailKey{
+			Host:     "127.0.0.1",
+			Port:     s.port(),
+			Username: "alert@example.com",
+			Password: "secret",
+			To:       []string{"a@example.com", "b@example.com"},
+			TLS:      db.EmailTLSNone,
+		})
+		err := (&Email{}).Send(&db.AlarmChannel{Typ: db.ChannelEmail, Key: string(key)}, &db.PushMsg{
+			Title: "【告警中】test",
+			Text:  "<font color=red>告警中</font>\n【告警名称】: test\n",
+		})
+		So(err, ShouldBeNil)
+		<-s.done
+		So(s.commands, ShouldContain, "MAIL FROM:<alert@example.com>")
+		So(s.commands, ShouldContain, "RCPT TO:<a@example.com>")
+		So(s.commands, ShouldContain, "RCPT TO:<b@example.com>")
+		So(s.data, ShouldContainSubstring, "To: a@example.com, b@example.com")
+		So(s.data, ShouldContainSubstring, "Subject: =?utf-8?q?")
+		So(s.data, ShouldContainSubstring, "Content-Type: text/html; charset=UTF-8")
+		So(s.data, ShouldContainSubstring, "【告警名称】: test<br/>")
+	})
+	Convey("the image is inline", t, func() {
+		s := newFakeSMTP(t)
+		defer s.ln.Close()
+		key, _ := json.Marshal(db.ChannelEmailKey{Host: "127.0.0.1", Port: s.port(), From: "alert@example.com", To: []string{"a@example.com"}, TLS: db.EmailTLSNone})
+		err := (&Email{}).Send(&db.AlarmChannel{Typ: db.ChannelEmail, Key: string(key)}, &db.PushMsg{
+			Title: "test",
+			Text:  "text\n",
+			Image: []byte("png"),
+		})
+		So(err, ShouldBeNil)
+		<-s.done
+		So(s.data, ShouldContainSubstring, "Content-Type: multipart/related; boundary=")
+		So(s.data, ShouldContainSubstring, `<img src="cid:chart@clickvisual" alt="chart"/>`)
+		So(s.data, ShouldContainSubstring, "Content-ID: <chart@clickvisual>")
+		So(s.data, ShouldContainSubstring, "cG5n")
+	})
+	Convey("the text is escaped but for the color tags", t, func() {
+		body := string(emailHTML(&db.PushMsg{
+			Title: "<b>test</b>",
+			Text:  "<font color=#FF0000>告警中</font>\n【日志】: <script>alert(1)</script>\n",
+		}))
+		So(body, ShouldContainSubstring, "<h3>&lt;b&gt;test&lt;/b&gt;</h3>")
+		So(body, ShouldContainSubstring, "<font color=#FF0000>告警中</font><br/>")
+		So(body, ShouldContainSubstring, "&lt;script&gt;alert(1)&lt;/script&gt;<br/>")
+		So(body, ShouldNotContainSubstring, "<script>")
+	})
+	Convey("invalid key", t, func() {
+		err := (&Email{}).Send(&db.AlarmChannel{Typ: db.ChannelEmail, Key: `{"host":"127.0.0.1"}`}, &db.PushMsg{})
+		So(err, ShouldNotBeNil)
+	})
+}
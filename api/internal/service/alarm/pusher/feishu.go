@@ -2,6 +2,7 @@ package pusher
 
 import (
 	"errors"
+	"strconv"
 
 	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
 	"github.com/clickvisual/clickvisual/api/internal/service/alarm/pusher/feishu"
@@ -12,7 +13,7 @@ var _ IPusher = (*FeiShu)(nil)
 type FeiShu struct{}
 
 func (s *FeiShu) Send(channel *db.AlarmChannel, msg *db.PushMsg) (err error) {
-	err = s.sendMessage(channel.Key, msg.Title, msg.Text)
+	err = s.sendMessage(channel.Key, feiShuCard(channel, msg))
 	if err != nil {
 		return err
 	}
@@ -24,12 +25,9 @@ func (s *FeiShu) Send(channel *db.AlarmChannel, msg *db.PushMsg) (err error) {
 //	Description: 发送消息至feishu
 //	receiver s
 //	 param url webhook 地址
-//	 param title 标题
-//	 param text md 内容
+//	 param msg 卡片消息
 //	return err 错误
-func (s *FeiShu) sendMessage(url string, title, text string) (err error) {
-	msg := feishu.NewCardMsg(title, feishu.WARNING)
-	msg.AddElement(text)
+func (s *FeiShu) sendMessage(url string, msg *feishu.CardMsg) (err error) {
 	sendMsg, errflag, err := feishu.SendMsg(url, msg)
 	// err 不为空基本为本地问题
 	// err is not empty is basically a local problem
@@ -44,3 +42,33 @@ func (s *FeiShu) sendMessage(url string, title, text string) (err error) {
 	}
 	return
 }
+
+func feiShuCard(channel *db.AlarmChannel, msg *db.PushMsg) *feishu.CardMsg {
+	card := feishu.NewCardMsg(msg.Title, feishu.WARNING)
+	card.AddElement(msg.Text)
+	buttons := make([]feishu.ActionsItem, 0)
+	if isChatOps(channel, msg) {
+		buttons = feiShuButtons(msg)
+	}
+	// images of the cards are uploaded with the credentials of an app, the webhook links the chart
+	if msg.ImageURL != "" {
+		buttons = append(buttons, feishu.NewButton(msgLabel("chart"), "default", msg.ImageURL, nil))
+	}
+	if len(buttons) > 0 {
+		card.AddButtons(buttons...)
+	}
+	return card
+}
+
+// feiShuButtons acknowledge and snooze call back the request url of the card, logs opens the link
+func feiShuButtons(msg *db.PushMsg) []feishu.ActionsItem {
+	alarmId := strconv.Itoa(msg.AlarmId)
+	res := []feishu.ActionsItem{
+		feishu.NewButton(msgLabel("ack"), "primary", "", map[string]string{"action": ChatOpsAck, "alarmId": alarmId}),
+		feishu.NewButton(msgLabel("snooze"), "default", "", map[string]string{"action": ChatOpsSnooze, "alarmId": alarmId}),
+	}
+	if msg.LogsURL != "" {
+		res = append(res, feishu.NewButton(msgLabel("logs"), "default", msg.LogsURL, nil))
+	}
+	return res
+}
@@ -32,15 +32,11 @@ type Actions struct {
 	Actions []ActionsItem `json:"actions"`
 }
 type ActionsItem struct {
-	Tag  string `json:"tag"`
-	Text struct {
-		Content string `json:"content"`
-		Tag     string `json:"tag"`
-	} `json:"text"`
-	URL   string `json:"url"`
-	Type  string `json:"type"`
-	Value struct {
-	} `json:"value"`
+	Tag   string            `json:"tag"`
+	Text  Body              `json:"text"`
+	URL   string            `json:"url,omitempty"`
+	Type  string            `json:"type"`
+	Value map[string]string `json:"value"` // sent to the request url of the card callbacks
 }
 type Header struct {
 	Title    Body   `json:"title,omitempty"`
@@ -113,16 +109,13 @@ func (c *CardMsg) AddUrl(url string) {
 		Actions: &Actions{
 			Actions: []ActionsItem{{
 				Tag: "button",
-				Text: struct {
-					Content string `json:"content"`
-					Tag     string `json:"tag"`
-				}{
+				Text: Body{
 					Content: "**提交结束，注意检查链接**😊",
 					Tag:     "lark_md",
 				},
 				URL:   url,
 				Type:  "primary",
-				Value: struct{}{},
+				Value: map[string]string{},
 			}},
 		},
 	}
@@ -130,6 +123,27 @@ func (c *CardMsg) AddUrl(url string) {
 
 }
 
+// AddButtons 增加一行按钮，带 value 的按钮回调卡片请求网址，带 url 的按钮打开链接
+// Add a row of buttons, the buttons with a value call the request url of the card, the ones with an url open it
+func (c *CardMsg) AddButtons(items ...ActionsItem) {
+	c.Card.Elements = append(c.Card.Elements, Element{Tag: "action", Actions: &Actions{Actions: items}})
+}
+
+// NewButton 按钮，value 与 url 二选一
+// A button with either a value or an url
+func NewButton(text, typ, url string, value map[string]string) ActionsItem {
+	if value == nil {
+		value = map[string]string{}
+	}
+	return ActionsItem{
+		Tag:   "button",
+		Text:  Body{Content: text, Tag: "plain_text"},
+		URL:   url,
+		Type:  typ,
+		Value: value,
+	}
+}
+
 // AddAtAll 增加一个@全体的功能
 // Add an @All function
 func (c *CardMsg) AddAtAll() {
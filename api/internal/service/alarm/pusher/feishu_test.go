@@ -5,13 +5,30 @@ import (
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/service/alarm/pusher/feishu"
 )
 
 func TestFeiShu_sendMessage(t *testing.T) {
 	Convey("测试飞书传输消息", t, func() {
 		url := "YOUR_WEBHOOK_URL_HERE"
 		f := FeiShu{}
-		err := f.sendMessage(url, "测试", "")
+		err := f.sendMessage(url, feishu.NewCardMsg("测试", feishu.WARNING))
 		So(err, ShouldBeNil)
 	})
 }
+
+func TestFeiShuCard(t *testing.T) {
+	Convey("the chart is linked by a button", t, func() {
+		card := feiShuCard(&db.AlarmChannel{}, &db.PushMsg{Title: "cpu", Text: "text", ImageURL: "http://chart"})
+		So(card.Card.Elements, ShouldHaveLength, 2)
+		So(card.Card.Elements[1].Actions.Actions, ShouldHaveLength, 1)
+		So(card.Card.Elements[1].Actions.Actions[0].URL, ShouldEqual, "http://chart")
+
+		channel := &db.AlarmChannel{BaseModel: db.BaseModel{ID: 3}, ChatOps: 1, CallbackSecret: "secret"}
+		card = feiShuCard(channel, &db.PushMsg{AlarmId: 12, ImageURL: "http://chart"})
+		So(card.Card.Elements[1].Actions.Actions, ShouldHaveLength, 3)
+		So(feiShuCard(&db.AlarmChannel{}, &db.PushMsg{}).Card.Elements, ShouldHaveLength, 1)
+	})
+}
@@ -0,0 +1,50 @@
+package pusher
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+)
+
+// BuildIngestionMsg message of a stalled or recovered kafka engine storage
+func BuildIngestionMsg(table *db.BaseTable, health view.IngestionHealth, stalled bool) *db.PushMsg {
+	var buffer bytes.Buffer
+	statusText := msgLabel("resolved")
+	if stalled {
+		statusText = msgLabel("stalled")
+		buffer.WriteString(fmt.Sprintf("<font color=#FF0000>%s</font>\n\n", msgLabel("ingestionStalled")))
+	} else {
+		buffer.WriteString(fmt.Sprintf("<font color=#008000>%s</font>\n\n", msgLabel("ingestionResumed")))
+	}
+	buffer.WriteString(fmt.Sprintf("【%s】: %s.%s %s\n\n", msgLabel("table"), table.Database.Name, table.Name, table.Desc))
+	buffer.WriteString(fmt.Sprintf("【%s】: %d\n\n", msgLabel("consumers"), health.Consumers))
+	if health.Lag >= 0 {
+		buffer.WriteString(fmt.Sprintf("【%s】: %d\n\n", msgLabel("lag"), health.Lag))
+	}
+	if health.LatestTime > 0 {
+		buffer.WriteString(fmt.Sprintf("【%s】: %s (%s)\n\n", msgLabel("latestData"),
+			FormatTime(time.Unix(health.LatestTime, 0)), fmt.Sprintf(msgLabel("secondsAgo"), health.Freshness)))
+	} else {
+		buffer.WriteString(fmt.Sprintf("【%s】: %s\n\n", msgLabel("latestData"), msgLabel("noRecentData")))
+	}
+	if health.RecentErrors > 0 {
+		buffer.WriteString(fmt.Sprintf("【%s】: %d\n\n", msgLabel("consumerErrors"), health.RecentErrors))
+	}
+	if health.BrokenMessages > 0 {
+		buffer.WriteString(fmt.Sprintf("【%s】: %d\n\n", msgLabel("brokenMessages"), health.BrokenMessages))
+	}
+	if health.LastError != "" {
+		lastError := health.LastError
+		if len(lastError) > 600 {
+			lastError = lastError[0:599]
+		}
+		buffer.WriteString(fmt.Sprintf("【%s】: %s\n\n", msgLabel("lastError"), lastError))
+	}
+	return &db.PushMsg{
+		Title: fmt.Sprintf("【%s】%s", statusText, table.Name),
+		Text:  buffer.String(),
+	}
+}
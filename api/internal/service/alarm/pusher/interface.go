@@ -35,6 +35,10 @@ func GetPusher(typ int) (IPusher, error) {
 		return &Webhook{}, nil
 	case db.ChannelTelegram:
 		return &Telegram{}, nil
+	case db.ChannelEmail:
+		return &Email{}, nil
+	case db.ChannelPagerDuty:
+		return &PagerDuty{}, nil
 	default:
 		err = errors.New("undefined channels")
 	}
@@ -45,34 +49,33 @@ func GetPusher(typ int) (IPusher, error) {
 // Description: 提供一个通用的md模式的获取内容的方法
 // param notification  通知的部分方法
 // param alarm 警告的数据库连接
-// param oneTheLogs 日志内容
-func BuildAlarmMsg(notification db.Notification, table *db.BaseTable, alarm *db.Alarm, filter *db.AlarmFilter, partialLog string) (msg *db.PushMsg, err error) {
+// param detail 日志样例、字段分布与图表
+func BuildAlarmMsg(notification db.Notification, table *db.BaseTable, alarm *db.Alarm, filter *db.AlarmFilter, detail *Detail) (msg *db.PushMsg, err error) {
 	// groupKey := notification.GroupKey
 	var buffer bytes.Buffer
 	// base info
 	if notification.GetStatus() == db.AlarmStatusNormal {
-		buffer.WriteString("<font color=#008000>您的告警已恢复</font>\n")
+		buffer.WriteString("<font color=#008000>" + msgLabel("resolvedHeader") + "</font>\n")
 	} else {
-		buffer.WriteString("<font color=#FF0000>您有待处理的告警</font>\n")
+		buffer.WriteString("<font color=#FF0000>" + msgLabel("firingHeader") + "</font>\n")
 	}
-	buffer.WriteString(fmt.Sprintf("【告警名称】: %s\n", alarm.Name))
+	buffer.WriteString(fmt.Sprintf("【%s】: %s\n", msgLabel("alarmName"), alarm.Name))
 	if alarm.Desc != "" {
-		buffer.WriteString(fmt.Sprintf("【告警描述】: %s\n", alarm.Desc))
+		buffer.WriteString(fmt.Sprintf("【%s】: %s\n", msgLabel("alarmDesc"), alarm.Desc))
 	}
 	users, phones := dutyOffices(alarm)
 	instance, _ := db.InstanceInfo(invoker.Db, table.Database.Iid)
-	statusText := "告警中"
+	statusText := msgLabel("firing")
+	logsURL := ""
 	for _, alert := range notification.Alerts {
-		end := alert.StartsAt.Add(time.Minute).Unix()
-		start := alert.StartsAt.Add(-alarm.GetInterval() - time.Minute).Unix()
-		buffer.WriteString(fmt.Sprintf("【触发时间】: %s\n", alert.StartsAt.Add(time.Hour*8).Format("2006-01-02 15:04:05")))
-		buffer.WriteString(fmt.Sprintf("【相关实例】: %s %s\n", instance.Name, instance.Desc))
-		buffer.WriteString(fmt.Sprintf("【日志库表】: %s %s\n", table.Name, table.Desc))
+		buffer.WriteString(fmt.Sprintf("【%s】: %s\n", msgLabel("startsAt"), FormatTime(alert.StartsAt)))
+		buffer.WriteString(fmt.Sprintf("【%s】: %s %s\n", msgLabel("instance"), instance.Name, instance.Desc))
+		buffer.WriteString(fmt.Sprintf("【%s】: %s %s\n", msgLabel("table"), table.Name, table.Desc))
 		if notification.GetStatus() == db.AlarmStatusNormal {
-			statusText = "已恢复"
-			buffer.WriteString("【告警状态】: <font color=#008000>已恢复</font>\n")
+			statusText = msgLabel("resolved")
+			buffer.WriteString(fmt.Sprintf("【%s】: <font color=#008000>%s</font>\n", msgLabel("status"), msgLabel("resolved")))
 		} else {
-			buffer.WriteString("【告警状态】: <font color=red>告警中</font>\n")
+			buffer.WriteString(fmt.Sprintf("【%s】: <font color=red>%s</font>\n", msgLabel("status"), msgLabel("firing")))
 		}
 		dutyOfficesStr := ""
 		for _, u := range users {
@@ -83,37 +86,31 @@ func BuildAlarmMsg(notification db.Notification, table *db.BaseTable, alarm *db.
 			}
 		}
 		if dutyOfficesStr != "" {
-			buffer.WriteString(fmt.Sprintf("【告警责任】: %s\n", dutyOfficesStr))
+			buffer.WriteString(fmt.Sprintf("【%s】: %s\n", msgLabel("dutyOfficers"), dutyOfficesStr))
 		} else {
 			user, _ := db.UserInfo(alarm.Uid)
-			buffer.WriteString(fmt.Sprintf("【告警更新】: %s\n\n", user.Nickname))
+			buffer.WriteString(fmt.Sprintf("【%s】: %s\n\n", msgLabel("updatedBy"), user.Nickname))
 		}
-		mode := 0
-		filterMode := "rawLog"
-		if filter.Mode == db.AlarmModeAggregation {
-			mode = 1
-			filterMode = "statisticalTable"
-		}
-		jumpURL := fmt.Sprintf("%s/share?mode=%d&tab=custom&tid=%d&kw=%s&start=%d&end=%d&queryType=%s", strings.TrimRight(econf.GetString("app.rootURL"), "/"), mode, filter.Tid, url.QueryEscape(filter.When), start, end, filterMode)
-		shortURL, err := shorturl.GenShortURL(jumpURL)
-		if err != nil {
-			elog.Error("shorturl.GenShortURL", elog.FieldErr(err), elog.String("jumpURL", jumpURL))
-			buffer.WriteString(fmt.Sprintf("【链接跳转】: %s\n", jumpURL))
-		} else {
-			buffer.WriteString(fmt.Sprintf("【链接跳转】: %s\n", shortURL))
-		}
-		if partialLog != "" {
-			partialLog = strings.Replace(partialLog, "\"", "", -1)
-			if len(partialLog) > 600 {
-				buffer.WriteString(fmt.Sprintf("【告警日志】: %s", partialLog[0:599]))
-			} else {
-				buffer.WriteString(fmt.Sprintf("【告警日志】: %s", partialLog))
-			}
+		logsURL = shareURL(alarm, filter, alert.StartsAt)
+		buffer.WriteString(fmt.Sprintf("【%s】: %s\n", msgLabel("link"), logsURL))
+		if notification.GetStatus() == db.AlarmStatusFiring {
+			buffer.WriteString(fmt.Sprintf("【%s】: %s\n", msgLabel("ack"), ackURL(alarm)))
+			buffer.WriteString(fmt.Sprintf("【%s】: %s\n", msgLabel("snooze"), snoozeURL(alarm)))
 		}
+		writeDetail(&buffer, detail, "\n")
 	}
 	pushMsg := &db.PushMsg{
-		Title: fmt.Sprintf("【%s】%s", statusText, alarm.Name),
-		Text:  buffer.String(),
+		Title:    fmt.Sprintf("【%s】%s", statusText, alarm.Name),
+		Text:     buffer.String(),
+		DedupKey: DedupKey(alarm, filter),
+		Status:   notification.GetStatus(),
+		LogsURL:  logsURL,
+	}
+	if notification.GetStatus() == db.AlarmStatusFiring {
+		pushMsg.AlarmId = alarm.ID
+	}
+	if detail != nil {
+		pushMsg.Image, pushMsg.ImageURL = detail.Chart, detail.ChartURL
 	}
 	if len(phones) != 0 {
 		pushMsg.Mobiles = phones
@@ -125,34 +122,35 @@ func BuildAlarmMsg(notification db.Notification, table *db.BaseTable, alarm *db.
 // Description: 提供一个通用的md模式的获取内容的方法
 // param notification  通知的部分方法
 // param alarm 警告的数据库连接
-// param oneTheLogs 日志内容
-func BuildAlarmMsgWithAt(notification db.Notification, table *db.BaseTable, alarm *db.Alarm, filter *db.AlarmFilter, partialLog string) (msg *db.PushMsg, err error) {
+// param detail 日志样例、字段分布与图表
+func BuildAlarmMsgWithAt(notification db.Notification, table *db.BaseTable, alarm *db.Alarm, filter *db.AlarmFilter, detail *Detail) (msg *db.PushMsg, err error) {
 	// groupKey := notification.GroupKey
 	var buffer bytes.Buffer
 	// base info
 	if notification.GetStatus() == db.AlarmStatusNormal {
-		buffer.WriteString("<font color=#008000>您的告警已恢复</font>\n\n")
+		buffer.WriteString("<font color=#008000>" + msgLabel("resolvedHeader") + "</font>\n\n")
 	} else {
-		buffer.WriteString("<font color=#FF0000>您有待处理的告警</font>\n\n")
+		buffer.WriteString("<font color=#FF0000>" + msgLabel("firingHeader") + "</font>\n\n")
 	}
-	buffer.WriteString(fmt.Sprintf("【告警名称】: %s\n\n", alarm.Name))
+	buffer.WriteString(fmt.Sprintf("【%s】: %s\n\n", msgLabel("alarmName"), alarm.Name))
 	if alarm.Desc != "" {
-		buffer.WriteString(fmt.Sprintf("【告警描述】: %s\n\n", alarm.Desc))
+		buffer.WriteString(fmt.Sprintf("【%s】: %s\n\n", msgLabel("alarmDesc"), alarm.Desc))
 	}
 	users, phones := dutyOffices(alarm)
 	instance, _ := db.InstanceInfo(invoker.Db, table.Database.Iid)
-	statusText := "告警中"
+	statusText := msgLabel("firing")
+	logsURL := ""
 	for _, alert := range notification.Alerts {
 		end := alert.StartsAt.Add(time.Minute).Unix()
 		start := alert.StartsAt.Add(-alarm.GetInterval() - time.Minute).Unix()
-		buffer.WriteString(fmt.Sprintf("【触发时间】: %s\n\n", alert.StartsAt.Add(time.Hour*8).Format("2006-01-02 15:04:05")))
-		buffer.WriteString(fmt.Sprintf("【相关实例】: %s %s\n\n", instance.Name, instance.Desc))
-		buffer.WriteString(fmt.Sprintf("【日志库表】: %s %s\n\n", table.Name, table.Desc))
+		buffer.WriteString(fmt.Sprintf("【%s】: %s\n\n", msgLabel("startsAt"), FormatTime(alert.StartsAt)))
+		buffer.WriteString(fmt.Sprintf("【%s】: %s %s\n\n", msgLabel("instance"), instance.Name, instance.Desc))
+		buffer.WriteString(fmt.Sprintf("【%s】: %s %s\n\n", msgLabel("table"), table.Name, table.Desc))
 		if notification.GetStatus() == db.AlarmStatusNormal {
-			statusText = "已恢复"
-			buffer.WriteString("【告警状态】: <font color=#008000>已恢复</font>\n\n")
+			statusText = msgLabel("resolved")
+			buffer.WriteString(fmt.Sprintf("【%s】: <font color=#008000>%s</font>\n\n", msgLabel("status"), msgLabel("resolved")))
 		} else {
-			buffer.WriteString("【告警状态】: <font color=red>告警中</font>\n\n")
+			buffer.WriteString(fmt.Sprintf("【%s】: <font color=red>%s</font>\n\n", msgLabel("status"), msgLabel("firing")))
 		}
 		dutyOfficesStr := ""
 		for _, u := range users {
@@ -167,33 +165,39 @@ func BuildAlarmMsgWithAt(notification db.Notification, table *db.BaseTable, alar
 			}
 		}
 		if dutyOfficesStr != "" {
-			buffer.WriteString(fmt.Sprintf("【告警责任】: %s\n\n", dutyOfficesStr))
+			buffer.WriteString(fmt.Sprintf("【%s】: %s\n\n", msgLabel("dutyOfficers"), dutyOfficesStr))
 		} else {
 			user, _ := db.UserInfo(alarm.Uid)
-			buffer.WriteString(fmt.Sprintf("【告警更新】: %s\n\n", user.Nickname))
+			buffer.WriteString(fmt.Sprintf("【%s】: %s\n\n", msgLabel("updatedBy"), user.Nickname))
 		}
 		jumpURL := fmt.Sprintf("%s/share?mode=0&tab=custom&tid=%d&kw=%s&start=%d&end=%d",
 			strings.TrimRight(econf.GetString("app.rootURL"), "/"), filter.Tid, url.QueryEscape(filter.When), start, end,
 		)
 		shortURL, err := shorturl.GenShortURL(jumpURL)
+		logsURL = shortURL
 		if err != nil {
 			elog.Error("shorturl.GenShortURL", elog.FieldErr(err), elog.String("jumpURL", jumpURL))
-			buffer.WriteString(fmt.Sprintf("【链接跳转】: %s\n\n", jumpURL))
-		} else {
-			buffer.WriteString(fmt.Sprintf("【链接跳转】: %s\n\n", shortURL))
+			logsURL = jumpURL
 		}
-		if partialLog != "" {
-			partialLog = strings.Replace(partialLog, "\"", "", -1)
-			if len(partialLog) > 600 {
-				buffer.WriteString(fmt.Sprintf("【告警日志】: %s", partialLog[0:599]))
-			} else {
-				buffer.WriteString(fmt.Sprintf("【告警日志】: %s", partialLog))
-			}
+		buffer.WriteString(fmt.Sprintf("【%s】: %s\n\n", msgLabel("link"), logsURL))
+		if notification.GetStatus() == db.AlarmStatusFiring {
+			buffer.WriteString(fmt.Sprintf("【%s】: %s\n\n", msgLabel("ack"), ackURL(alarm)))
+			buffer.WriteString(fmt.Sprintf("【%s】: %s\n\n", msgLabel("snooze"), snoozeURL(alarm)))
 		}
+		writeDetail(&buffer, detail, "\n\n")
 	}
 	pushMsg := &db.PushMsg{
-		Title: fmt.Sprintf("【%s】%s", statusText, alarm.Name),
-		Text:  buffer.String(),
+		Title:    fmt.Sprintf("【%s】%s", statusText, alarm.Name),
+		Text:     buffer.String(),
+		DedupKey: DedupKey(alarm, filter),
+		Status:   notification.GetStatus(),
+		LogsURL:  logsURL,
+	}
+	if notification.GetStatus() == db.AlarmStatusFiring {
+		pushMsg.AlarmId = alarm.ID
+	}
+	if detail != nil {
+		pushMsg.Image, pushMsg.ImageURL = detail.Chart, detail.ChartURL
 	}
 	if len(phones) != 0 {
 		pushMsg.Mobiles = phones
@@ -201,32 +205,89 @@ func BuildAlarmMsgWithAt(notification db.Notification, table *db.BaseTable, alar
 	return pushMsg, nil
 }
 
-func Execute(channelIds []int, pushMsg *db.PushMsg, pushMsgWithAt *db.PushMsg) error {
-	for _, channelId := range channelIds {
-		channel, err := db.AlarmChannelInfo(invoker.Db, channelId)
-		if err != nil {
-			return err
-		}
-		channelPusher, err := GetPusher(channel.Typ)
+// DedupKey identifies the alert of the alarm filter for incident channels
+func DedupKey(alarm *db.Alarm, filter *db.AlarmFilter) string {
+	return fmt.Sprintf("%s-%d", alarm.Uuid, filter.ID)
+}
+
+// snoozeURL page where a logged in user confirms silencing the alarm for an hour
+func snoozeURL(alarm *db.Alarm) string {
+	return fmt.Sprintf("%s/api/v2/alert/alarms/%d/snooze?duration=1h", strings.TrimRight(econf.GetString("app.rootURL"), "/"), alarm.ID)
+}
+
+// shareURL short link of the logs around the alert, the full link when the short one fails
+func shareURL(alarm *db.Alarm, filter *db.AlarmFilter, startsAt time.Time) string {
+	end := startsAt.Add(time.Minute).Unix()
+	start := startsAt.Add(-alarm.GetInterval() - time.Minute).Unix()
+	mode := 0
+	filterMode := "rawLog"
+	if filter.Mode == db.AlarmModeAggregation || filter.Mode == db.AlarmModeTraceEdge {
+		mode = 1
+		filterMode = "statisticalTable"
+	}
+	jumpURL := fmt.Sprintf("%s/share?mode=%d&tab=custom&tid=%d&kw=%s&start=%d&end=%d&queryType=%s", strings.TrimRight(econf.GetString("app.rootURL"), "/"), mode, filter.Tid, url.QueryEscape(filter.When), start, end, filterMode)
+	shortURL, err := shorturl.GenShortURL(jumpURL)
+	if err != nil {
+		elog.Error("shorturl.GenShortURL", elog.FieldErr(err), elog.String("jumpURL", jumpURL))
+		return jumpURL
+	}
+	return shortURL
+}
+
+// ackURL page where a logged in user confirms acknowledging the firing alarm, which stops its escalation
+func ackURL(alarm *db.Alarm) string {
+	return fmt.Sprintf("%s/api/v2/alert/alarms/%d/ack", strings.TrimRight(econf.GetString("app.rootURL"), "/"), alarm.ID)
+}
+
+// BuildEscalationMsg message of an escalation step, the users are mentioned
+func BuildEscalationMsg(alarm *db.Alarm, filter *db.AlarmFilter, step int, uids []int, firingAt time.Time) (msg *db.PushMsg, msgWithAt *db.PushMsg) {
+	names, ats, phones := make([]string, 0), make([]string, 0), make([]string, 0)
+	for _, uid := range uids {
+		user, err := db.UserInfo(uid)
 		if err != nil {
-			return err
+			continue
 		}
-		if channel.Typ == db.ChannelDingDing {
-			err = channelPusher.Send(&channel, pushMsgWithAt)
+		names = append(names, user.Nickname)
+		at := user.Phone
+		if at == "" {
+			at = user.Nickname
 		} else {
-			err = channelPusher.Send(&channel, pushMsg)
+			phones = append(phones, user.Phone)
 		}
-		if err != nil {
-			return err
+		ats = append(ats, "@"+at)
+	}
+	lines := func(officers string) []string {
+		return []string{
+			"<font color=#FF0000>" + msgLabel("escalationHeader") + "</font>",
+			fmt.Sprintf("【%s】: %s", msgLabel("alarmName"), alarm.Name),
+			fmt.Sprintf("【%s】: %s", msgLabel("startsAt"), FormatTime(firingAt)),
+			fmt.Sprintf("【%s】: %d", msgLabel("escalationStep"), step+1),
+			fmt.Sprintf("【%s】: %s", msgLabel("dutyOfficers"), officers),
+			fmt.Sprintf("【%s】: %s", msgLabel("ack"), ackURL(alarm)),
 		}
 	}
-	return nil
+	title := fmt.Sprintf("【%s】%s", msgLabel("escalation"), alarm.Name)
+	msg = &db.PushMsg{
+		Title:    title,
+		Text:     strings.Join(lines(strings.Join(names, "/")), "\n") + "\n",
+		Mobiles:  phones,
+		DedupKey: DedupKey(alarm, filter),
+		Status:   db.AlarmStatusFiring,
+	}
+	msgWithAt = &db.PushMsg{
+		Title:    title,
+		Text:     strings.Join(lines(strings.Join(ats, "")), "\n\n") + "\n\n",
+		Mobiles:  phones,
+		DedupKey: msg.DedupKey,
+		Status:   db.AlarmStatusFiring,
+	}
+	return msg, msgWithAt
 }
 
 func dutyOffices(alarm *db.Alarm) ([]db.User, []string) {
 	dutyOfficers := make([]db.User, 0)
 	phones := make([]string, 0)
-	for _, dutyOfficer := range alarm.DutyOfficers {
+	for _, dutyOfficer := range dutyOfficerUids(alarm) {
 		user, _ := db.UserInfo(dutyOfficer)
 		if user.Phone != "" {
 			dutyOfficers = append(dutyOfficers, user)
@@ -235,3 +296,35 @@ func dutyOffices(alarm *db.Alarm) ([]db.User, []string) {
 	}
 	return dutyOfficers, phones
 }
+
+// dutyOfficerUids the duty officers of the alarm and the users of the first escalation step
+func dutyOfficerUids(alarm *db.Alarm) []int {
+	res := make([]int, 0, len(alarm.DutyOfficers))
+	res = append(res, alarm.DutyOfficers...)
+	if alarm.EscalationId == 0 {
+		return res
+	}
+	escalation, err := db.AlarmEscalationInfo(invoker.Db, alarm.EscalationId)
+	if err != nil || len(escalation.Steps) == 0 {
+		elog.Error("dutyOfficerUids", elog.FieldErr(err), elog.Int("escalationId", alarm.EscalationId))
+		return res
+	}
+	uids, err := db.EscalationStepUids(invoker.Db, escalation.Steps[0], time.Now().Unix())
+	if err != nil {
+		elog.Error("dutyOfficerUids", elog.FieldErr(err), elog.Int("escalationId", alarm.EscalationId))
+		return res
+	}
+	for _, uid := range uids {
+		exist := false
+		for _, cur := range res {
+			if cur == uid {
+				exist = true
+				break
+			}
+		}
+		if !exist {
+			res = append(res, uid)
+		}
+	}
+	return res
+}
@@ -0,0 +1,74 @@
+package pusher
+
+import (
+	"encoding/json"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/pkg/errors"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/dto"
+)
+
+var _ IPusher = (*PagerDuty)(nil)
+
+// PagerDuty triggers and resolves incidents with the events api v2,
+// the dedup key of the message identifies the incident
+type PagerDuty struct{}
+
+func (p *PagerDuty) Send(channel *db.AlarmChannel, msg *db.PushMsg) (err error) {
+	conf, err := db.ParseChannelPagerDutyKey(channel.Key)
+	if err != nil {
+		return err
+	}
+	req := pagerDutyEvent(conf, msg)
+	if req.EventAction == dto.PagerDutyActionResolve && req.DedupKey == "" {
+		// nothing to resolve without the key of the incident
+		return nil
+	}
+	b, err := json.Marshal(req)
+	if err != nil {
+		return errors.Wrap(err, "pagerduty marshal")
+	}
+	resp, err := resty.New().R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(b).
+		Post(conf.URL)
+	if err != nil {
+		elog.Error("pagerDutySend", elog.String("title", msg.Title), elog.FieldErr(err))
+		return errors.Wrap(err, "pagerduty send")
+	}
+	if resp.StatusCode() != 200 && resp.StatusCode() != 202 {
+		return errors.Errorf("pagerduty send error, status: %d, resp: %s", resp.StatusCode(), string(resp.Body()))
+	}
+	elog.Info("pagerDutySend", elog.String("action", req.EventAction), elog.String("dedupKey", req.DedupKey), elog.String("resp", string(resp.Body())))
+	return nil
+}
+
+func pagerDutyEvent(conf db.ChannelPagerDutyKey, msg *db.PushMsg) dto.PagerDutyEvent {
+	if msg.Status == db.AlarmStatusNormal {
+		return dto.PagerDutyEvent{
+			RoutingKey:  conf.RoutingKey,
+			EventAction: dto.PagerDutyActionResolve,
+			DedupKey:    msg.DedupKey,
+		}
+	}
+	summary := msg.Title
+	// summary is limited to 1024 characters
+	if r := []rune(summary); len(r) > 1024 {
+		summary = string(r[:1024])
+	}
+	return dto.PagerDutyEvent{
+		RoutingKey:  conf.RoutingKey,
+		EventAction: dto.PagerDutyActionTrigger,
+		DedupKey:    msg.DedupKey,
+		Client:      FOOTER,
+		Payload: &dto.PagerDutyPayload{
+			Summary:       summary,
+			Source:        FOOTER,
+			Severity:      conf.Severity,
+			CustomDetails: map[string]string{"text": msg.Text},
+		},
+	}
+}
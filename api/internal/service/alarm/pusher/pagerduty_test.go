@@ -0,0 +1,67 @@
+package pusher
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/dto"
+)
+
+func TestPagerDuty_Send(t *testing.T) {
+	events := make([]dto.PagerDutyEvent, 0)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event dto.PagerDutyEvent
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil || event.RoutingKey != "routing" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		events = append(events, event)
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"status":"success","message":"Event processed","dedup_key":"` + event.DedupKey + `"}`))
+	}))
+	defer srv.Close()
+	key, _ := json.Marshal(db.ChannelPagerDutyKey{URL: srv.URL, RoutingKey: "routing"})
+	channel := &db.AlarmChannel{Typ: db.ChannelPagerDuty, Key: string(key)}
+
+	Convey("trigger and resolve with the dedup key", t, func() {
+		events = events[:0]
+		p := &PagerDuty{}
+		So(p.Send(channel, &db.PushMsg{Title: "【告警中】test", Text: "text", DedupKey: "uuid-1", Status: db.AlarmStatusFiring}), ShouldBeNil)
+		So(p.Send(channel, &db.PushMsg{Title: "【已恢复】test", Text: "text", DedupKey: "uuid-1", Status: db.AlarmStatusNormal}), ShouldBeNil)
+		So(events, ShouldHaveLength, 2)
+		So(events[0].EventAction, ShouldEqual, dto.PagerDutyActionTrigger)
+		So(events[0].DedupKey, ShouldEqual, "uuid-1")
+		So(events[0].Payload.Summary, ShouldEqual, "【告警中】test")
+		So(events[0].Payload.Severity, ShouldEqual, "error")
+		So(events[1].EventAction, ShouldEqual, dto.PagerDutyActionResolve)
+		So(events[1].DedupKey, ShouldEqual, "uuid-1")
+		So(events[1].Payload, ShouldBeNil)
+	})
+	Convey("resolve without dedup key is skipped", t, func() {
+		events = events[:0]
+		So((&PagerDuty{}).Send(channel, &db.PushMsg{Status: db.AlarmStatusNormal}), ShouldBeNil)
+		So(events, ShouldHaveLength, 0)
+	})
+	Convey("escalation adds to the alert of the firing filter", t, func() {
+		events = events[:0]
+		alarm := &db.Alarm{Uuid: "uuid", Name: "test"}
+		filter := &db.AlarmFilter{}
+		filter.ID = 1
+		msg, _ := BuildEscalationMsg(alarm, filter, 1, nil, time.Unix(1000, 0))
+		So((&PagerDuty{}).Send(channel, msg), ShouldBeNil)
+		So(events, ShouldHaveLength, 1)
+		So(events[0].EventAction, ShouldEqual, dto.PagerDutyActionTrigger)
+		So(events[0].DedupKey, ShouldEqual, "uuid-1")
+	})
+	Convey("rejected event", t, func() {
+		bad, _ := json.Marshal(db.ChannelPagerDutyKey{URL: srv.URL, RoutingKey: "other"})
+		err := (&PagerDuty{}).Send(&db.AlarmChannel{Typ: db.ChannelPagerDuty, Key: string(bad)}, &db.PushMsg{Title: "t"})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -23,7 +23,11 @@ var _ IPusher = (*Slack)(nil)
 type Slack struct{}
 
 func (s *Slack) Send(channel *db.AlarmChannel, msg *db.PushMsg) (err error) {
-	err = s.sendMessage(channel.Key, msg.Title, msg.Text)
+	var buttons []slack.BlockElement
+	if isChatOps(channel, msg) {
+		buttons = slackButtons(msg)
+	}
+	err = s.sendMessage(channel.Key, msg.Title, msg.Text, msg.ImageURL, buttons)
 	if err != nil {
 		return err
 	}
@@ -37,8 +41,10 @@ func (s *Slack) Send(channel *db.AlarmChannel, msg *db.PushMsg) (err error) {
 //	 param url webhook 信息
 //	 param title 标题
 //	 param text 内容
+//	 param imageURL 图表链接，webhook 无法上传图片
+//	 param buttons chat-ops 按钮，在第二个附件中
 //	return err
-func (s *Slack) sendMessage(url string, title, text string) (err error) {
+func (s *Slack) sendMessage(url string, title, text, imageURL string, buttons []slack.BlockElement) (err error) {
 	attachment := slack.Attachment{
 		Color:         COLOR,
 		AuthorName:    title,
@@ -48,14 +54,38 @@ func (s *Slack) sendMessage(url string, title, text string) (err error) {
 		Text:          text,
 		Footer:        FOOTER,
 		FooterIcon:    ICON,
+		ImageURL:      imageURL,
 		Ts:            json.Number(strconv.FormatInt(time.Now().Unix(), 10)),
 	}
 	msg := slack.WebhookMessage{
 		Attachments: []slack.Attachment{attachment},
 	}
+	if len(buttons) > 0 {
+		msg.Attachments = append(msg.Attachments, slack.Attachment{
+			Color:  COLOR,
+			Blocks: slack.Blocks{BlockSet: []slack.Block{slack.NewActionBlock("chatops", buttons...)}},
+		})
+	}
 	err = slack.PostWebhook(url, &msg)
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+// slackButtons acknowledge and snooze call back the interactivity request url of the app, logs opens the link
+func slackButtons(msg *db.PushMsg) []slack.BlockElement {
+	alarmId := strconv.Itoa(msg.AlarmId)
+	ack := slack.NewButtonBlockElement(ChatOpsAck, alarmId, slack.NewTextBlockObject(slack.PlainTextType, msgLabel("ack"), false, false))
+	ack.Style = slack.StylePrimary
+	res := []slack.BlockElement{
+		ack,
+		slack.NewButtonBlockElement(ChatOpsSnooze, alarmId, slack.NewTextBlockObject(slack.PlainTextType, msgLabel("snooze"), false, false)),
+	}
+	if msg.LogsURL != "" {
+		logs := slack.NewButtonBlockElement("logs", "", slack.NewTextBlockObject(slack.PlainTextType, msgLabel("logs"), false, false))
+		logs.URL = msg.LogsURL
+		res = append(res, logs)
+	}
+	return res
+}
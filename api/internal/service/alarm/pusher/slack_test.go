@@ -13,7 +13,7 @@ func TestSlack_sendMessage(t *testing.T) {
 		title := "测试"
 		text := "<https://example.com|Overlook Hotel> \\n :star: \\n Doors had too many axe holes, guest in room 237 was far too rowdy, whole place felt stuck in the 1920s."
 		s := Slack{}
-		err := s.sendMessage(url, title, text)
+		err := s.sendMessage(url, title, text, "", nil)
 		So(err, ShouldBeNil)
 	})
 }
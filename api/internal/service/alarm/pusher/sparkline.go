@@ -0,0 +1,91 @@
+package pusher
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	SparklineWidth  = 600
+	SparklineHeight = 120
+
+	sparklinePadding = 4
+)
+
+var (
+	sparklineBackground = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	sparklineArea       = color.RGBA{R: 0xe6, G: 0xf4, B: 0xff, A: 0xff}
+	sparklineLine       = color.RGBA{R: 0x16, G: 0x77, B: 0xff, A: 0xff}
+	sparklineLast       = color.RGBA{R: 0xff, G: 0x4d, B: 0x4f, A: 0xff}
+
+	errSparklinePoints = errors.New("sparkline needs at least two values")
+)
+
+// Sparkline png line chart of the values without axes, rendered in memory so no browser is needed.
+// The area under the line is filled and the last value is marked.
+func Sparkline(values []float64, width, height int) ([]byte, error) {
+	if len(values) < 2 {
+		return nil, errSparklinePoints
+	}
+	if width <= 2*sparklinePadding || height <= 2*sparklinePadding {
+		return nil, errors.Errorf("sparkline size %dx%d is too small", width, height)
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, errors.New("sparkline values must be finite")
+		}
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: sparklineBackground}, image.Point{}, draw.Src)
+	left, right := sparklinePadding, width-1-sparklinePadding
+	top, bottom := sparklinePadding, height-1-sparklinePadding
+	// y of the value, flat values are drawn in the middle
+	y := func(v float64) int {
+		if hi == lo {
+			return (top + bottom) / 2
+		}
+		return bottom - int(math.Round((v-lo)/(hi-lo)*float64(bottom-top)))
+	}
+	// each column interpolates the values around it, the line joins the previous column
+	prev := -1
+	for x := left; x <= right; x++ {
+		pos := float64(x-left) / float64(right-left) * float64(len(values)-1)
+		k := int(pos)
+		v := values[k]
+		if k < len(values)-1 {
+			v += (values[k+1] - values[k]) * (pos - float64(k))
+		}
+		cur := y(v)
+		for py := cur + 1; py <= bottom; py++ {
+			img.Set(x, py, sparklineArea)
+		}
+		from, to := cur, cur
+		if prev >= 0 {
+			from, to = min(prev, cur), max(prev, cur)
+		}
+		for py := from; py <= to; py++ {
+			img.Set(x, py, sparklineLine)
+			img.Set(x, py+1, sparklineLine)
+		}
+		prev = cur
+	}
+	last := y(values[len(values)-1])
+	for dx := -2; dx <= 2; dx++ {
+		for dy := -2; dy <= 2; dy++ {
+			img.Set(right+dx, last+dy, sparklineLast)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, errors.Wrap(err, "png.Encode")
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,52 @@
+package pusher
+
+import (
+	"bytes"
+	"image/png"
+	"math"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSparkline(t *testing.T) {
+	Convey("Sparkline", t, func() {
+		Convey("png of the size with the line, the area and the last point", func() {
+			out, err := Sparkline([]float64{1, 4, 2, 8, 3, 10}, 120, 40)
+			So(err, ShouldBeNil)
+			img, err := png.Decode(bytes.NewReader(out))
+			So(err, ShouldBeNil)
+			So(img.Bounds().Dx(), ShouldEqual, 120)
+			So(img.Bounds().Dy(), ShouldEqual, 40)
+			colors := map[[4]uint32]int{}
+			for x := 0; x < 120; x++ {
+				for y := 0; y < 40; y++ {
+					r, g, b, a := img.At(x, y).RGBA()
+					colors[[4]uint32{r >> 8, g >> 8, b >> 8, a >> 8}]++
+				}
+			}
+			So(colors[[4]uint32{0x16, 0x77, 0xff, 0xff}], ShouldBeGreaterThan, 0)
+			So(colors[[4]uint32{0xe6, 0xf4, 0xff, 0xff}], ShouldBeGreaterThan, 0)
+			So(colors[[4]uint32{0xff, 0x4d, 0x4f, 0xff}], ShouldEqual, 25)
+			// the last value is the highest, marked at the top right
+			r, _, _, _ := img.At(120-1-sparklinePadding, sparklinePadding).RGBA()
+			So(r>>8, ShouldEqual, 0xff)
+		})
+
+		Convey("flat values", func() {
+			out, err := Sparkline([]float64{2, 2, 2}, 60, 20)
+			So(err, ShouldBeNil)
+			_, err = png.Decode(bytes.NewReader(out))
+			So(err, ShouldBeNil)
+		})
+
+		Convey("invalid values and sizes", func() {
+			_, err := Sparkline([]float64{1}, 60, 20)
+			So(err, ShouldNotBeNil)
+			_, err = Sparkline([]float64{1, math.NaN()}, 60, 20)
+			So(err, ShouldNotBeNil)
+			_, err = Sparkline([]float64{1, 2}, 4, 4)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
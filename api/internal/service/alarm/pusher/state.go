@@ -0,0 +1,44 @@
+package pusher
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+// stateMsg lines of the message of a state change, its header and status are colored by the state
+type stateMsg struct {
+	status     int
+	statusText string
+	color      string
+	lines      []string
+}
+
+func newStateMsg(status int, name string) *stateMsg {
+	m := &stateMsg{status: status, statusText: msgLabel("firing"), color: "#FF0000"}
+	header := msgLabel("firingHeader")
+	if status != db.AlarmStatusFiring {
+		header, m.statusText, m.color = msgLabel("resolvedHeader"), msgLabel("resolved"), "#008000"
+	}
+	m.lines = append(m.lines, fmt.Sprintf("<font color=%s>%s</font>", m.color, header))
+	m.field("alarmName", name)
+	return m
+}
+
+// field line of the value with its label
+func (m *stateMsg) field(label, value string) {
+	m.lines = append(m.lines, fmt.Sprintf("【%s】: %s", msgLabel(label), value))
+}
+
+func (m *stateMsg) statusField() {
+	m.field("status", fmt.Sprintf("<font color=%s>%s</font>", m.color, m.statusText))
+}
+
+// build the title is prefixed with the state, dingding receives the message with the blank lines its markdown needs
+func (m *stateMsg) build(title, dedupKey string) (msg *db.PushMsg, msgWithAt *db.PushMsg) {
+	title = fmt.Sprintf("【%s】%s", m.statusText, title)
+	msg = &db.PushMsg{Title: title, Text: strings.Join(m.lines, "\n") + "\n", DedupKey: dedupKey, Status: m.status}
+	msgWithAt = &db.PushMsg{Title: title, Text: strings.Join(m.lines, "\n\n") + "\n\n", DedupKey: dedupKey, Status: m.status}
+	return msg, msgWithAt
+}
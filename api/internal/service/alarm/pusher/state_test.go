@@ -0,0 +1,33 @@
+package pusher
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+func TestStateMsg(t *testing.T) {
+	Convey("firing state", t, func() {
+		m := newStateMsg(db.AlarmStatusFiring, "checkout")
+		m.statusField()
+		msg, msgWithAt := m.build("checkout", "composite-7")
+		So(msg.Title, ShouldEqual, "【"+msgLabel("firing")+"】checkout")
+		So(msg.Text, ShouldStartWith, "<font color=#FF0000>"+msgLabel("firingHeader")+"</font>\n")
+		So(msg.Text, ShouldContainSubstring, "checkout")
+		So(msg.DedupKey, ShouldEqual, "composite-7")
+		So(msgWithAt.Status, ShouldEqual, db.AlarmStatusFiring)
+		So(strings.Contains(msgWithAt.Text, "\n\n"), ShouldBeTrue)
+	})
+
+	Convey("resolved state", t, func() {
+		m := newStateMsg(db.AlarmStatusNormal, "checkout")
+		m.statusField()
+		msg, _ := m.build("checkout", "composite-7")
+		So(msg.Status, ShouldEqual, db.AlarmStatusNormal)
+		So(msg.Title, ShouldStartWith, "【"+msgLabel("resolved")+"】")
+		So(msg.Text, ShouldContainSubstring, "<font color=#008000>"+msgLabel("resolved")+"</font>")
+	})
+}
@@ -2,6 +2,7 @@
 package pusher
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"strconv"
@@ -21,13 +22,13 @@ type Telegram struct{}
 // Occasionally there is a situation where the robot times out, and it will not be used for the time being
 
 func (t *Telegram) Send(channel *db.AlarmChannel, msg *db.PushMsg) (err error) {
-	err = t.sendMessage(channel.Key, msg.Title, msg.Text)
+	err = t.sendMessage(channel.Key, msg.Title, msg.Text, msg.Image)
 	if err != nil {
 		return err
 	}
 	return nil
 }
-func (t *Telegram) sendMessage(url string, title, text string) (err error) {
+func (t *Telegram) sendMessage(url string, title, text string, image []byte) (err error) {
 	tbot, err := NewTelegram(url)
 	if err != nil {
 		return err
@@ -44,6 +45,9 @@ func (t *Telegram) sendMessage(url string, title, text string) (err error) {
 	if err != nil {
 		return err
 	}
+	if len(image) > 0 {
+		return tbot.SendPhoto(image, title, toid)
+	}
 	return nil
 }
 
@@ -83,3 +87,12 @@ func (tg *TelegramBot) SendFile(filePath, fileName, mime, caption string, to int
 	})
 	return err
 }
+
+// SendPhoto sends the png image with the caption
+func (tg *TelegramBot) SendPhoto(image []byte, caption string, to int64) error {
+	_, err := tg.bot.Send(tb.ChatID(to), &tb.Photo{
+		File:    tb.FromReader(bytes.NewReader(image)),
+		Caption: caption,
+	})
+	return err
+}
@@ -23,7 +23,7 @@ func TestTelegram_sendMessage(t1 *testing.T) {
 		tbot := Telegram{}
 		title := ""
 		text := "<https://example.com|Overlook Hotel> \\n :star: \\n Doors had too many axe holes, guest in room 237 was far too rowdy, whole place felt stuck in the 1920s."
-		err := tbot.sendMessage("you token", title, text)
+		err := tbot.sendMessage("you token", title, text, nil)
 
 		So(err, ShouldBeNil)
 	})
@@ -0,0 +1,349 @@
+package pusher
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/pkg/errors"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+const (
+	LocaleZh = "zh"
+	LocaleEn = "en"
+
+	// templateTitle the template named title renders the title of the message
+	templateTitle = "title"
+	msgTimeLayout = "2006-01-02 15:04:05"
+)
+
+var (
+	msgLabels = map[string]map[string]string{
+		LocaleZh: {
+			"firingHeader":     "您有待处理的告警",
+			"resolvedHeader":   "您的告警已恢复",
+			"escalationHeader": "告警未确认，已升级",
+			"firing":           "告警中",
+			"resolved":         "已恢复",
+			"escalation":       "告警升级",
+			"alarmName":        "告警名称",
+			"alarmDesc":        "告警描述",
+			"startsAt":         "触发时间",
+			"instance":         "相关实例",
+			"table":            "日志库表",
+			"status":           "告警状态",
+			"dutyOfficers":     "告警责任",
+			"updatedBy":        "告警更新",
+			"link":             "链接跳转",
+			"ack":              "确认告警",
+			"snooze":           "静默一小时",
+			"logs":             "告警日志",
+			"escalationStep":   "升级步骤",
+			"topValues":        "字段分布",
+			"chart":            "告警图表",
+			"chatOpsAcked":     "已确认",
+			"chatOpsSnoozed":   "已静默一小时",
+			"chatOpsFailed":    "操作失败",
+			"compositeExpr":    "组合条件",
+			"compositeAlarms":  "关联告警",
+			"normal":           "正常",
+			"watchdog":         "日志巡检",
+			"volumeZero":       "日志量跌零",
+			"volumeSpike":      "日志量突增",
+			"errorRatio":       "错误日志占比突增",
+			"newError":         "出现新的错误日志",
+			"window":           "检查窗口",
+			"volume":           "日志数量",
+			"usualVolume":      "同时段常见",
+			"errorRatioValue":  "错误占比",
+			"errorPattern":     "错误模式",
+			"ingestionStalled": "日志接入异常",
+			"ingestionResumed": "日志接入已恢复",
+			"stalled":          "接入异常",
+			"consumers":        "消费者数",
+			"lag":              "消费延迟",
+			"latestData":       "最新数据",
+			"secondsAgo":       "%ds 前",
+			"noRecentData":     "最近 7 天无数据",
+			"consumerErrors":   "消费错误",
+			"brokenMessages":   "解析失败消息",
+			"lastError":        "最近错误",
+			"groupedTitle":     "%s 等%d条告警",
+		},
+		LocaleEn: {
+			"firingHeader":     "You have an alarm to handle",
+			"resolvedHeader":   "Your alarm is resolved",
+			"escalationHeader": "Alarm not acknowledged, escalated",
+			"firing":           "Firing",
+			"resolved":         "Resolved",
+			"escalation":       "Escalated",
+			"alarmName":        "Alarm",
+			"alarmDesc":        "Description",
+			"startsAt":         "Starts at",
+			"instance":         "Instance",
+			"table":            "Table",
+			"status":           "Status",
+			"dutyOfficers":     "Duty officers",
+			"updatedBy":        "Updated by",
+			"link":             "Link",
+			"ack":              "Acknowledge",
+			"snooze":           "Snooze 1h",
+			"logs":             "Logs",
+			"escalationStep":   "Escalation step",
+			"topValues":        "Top values",
+			"chart":            "Chart",
+			"chatOpsAcked":     "Acknowledged",
+			"chatOpsSnoozed":   "Snoozed for 1h",
+			"chatOpsFailed":    "Failed",
+			"compositeExpr":    "Expression",
+			"compositeAlarms":  "Alarms",
+			"normal":           "Normal",
+			"watchdog":         "Watchdog",
+			"volumeZero":       "Log volume dropped to zero",
+			"volumeSpike":      "Log volume spike",
+			"errorRatio":       "Error ratio spike",
+			"newError":         "New error pattern",
+			"window":           "Window",
+			"volume":           "Logs",
+			"usualVolume":      "Usual at this hour",
+			"errorRatioValue":  "Error ratio",
+			"errorPattern":     "Error pattern",
+			"ingestionStalled": "Log ingestion stalled",
+			"ingestionResumed": "Log ingestion resumed",
+			"stalled":          "Stalled",
+			"consumers":        "Consumers",
+			"lag":              "Lag",
+			"latestData":       "Latest data",
+			"secondsAgo":       "%ds ago",
+			"noRecentData":     "No data in the last 7 days",
+			"consumerErrors":   "Consumer errors",
+			"brokenMessages":   "Unparsable messages",
+			"lastError":        "Last error",
+			"groupedTitle":     "%s (%d alarms)",
+		},
+	}
+	msgOffsetRegex = regexp.MustCompile(`^[+-]\d{2}:\d{2}$`)
+	// msgDefaultLocation messages were always in UTC+8 before the timezone setting
+	msgDefaultLocation = time.FixedZone("UTC+8", 8*3600)
+)
+
+// TemplateData fields available in notification templates.
+// Templates are written by every alarm editor, so they only see display values and never the models holding dsn or secrets.
+type TemplateData struct {
+	Status       string // firing or resolved
+	IsFiring     bool
+	StartsAt     time.Time
+	Alarm        TemplateAlarm
+	Filter       TemplateFilter
+	Table        TemplateTable
+	Instance     TemplateInstance
+	PartialLog   string     // first sample log
+	Logs         []string   // sample logs, alarm notifyLogs of them
+	TopField     string     // field of the top values, the notifyTopField of the alarm
+	TopValues    []TopValue // top values of the field over the alert window
+	ChartURL     string     // link of the chart of the condition metric
+	DutyOfficers []TemplateUser
+	ShareURL     string
+	AckURL       string
+	SnoozeURL    string
+	Labels       map[string]string // common labels of the notification
+}
+
+type TemplateAlarm struct {
+	ID   int
+	Uuid string
+	Name string
+	Desc string
+	Tags map[string]string
+}
+
+type TemplateFilter struct {
+	ID   int
+	When string
+	Mode int
+}
+
+type TemplateTable struct {
+	ID       int
+	Name     string
+	Desc     string
+	Database string
+}
+
+type TemplateInstance struct {
+	ID   int
+	Name string
+	Desc string
+}
+
+type TemplateUser struct {
+	Nickname string
+	Phone    string
+	Email    string
+}
+
+func newTemplateAlarm(alarm *db.Alarm) TemplateAlarm {
+	return TemplateAlarm{ID: alarm.ID, Uuid: alarm.Uuid, Name: alarm.Name, Desc: alarm.Desc, Tags: alarm.Tags}
+}
+
+func newTemplateTable(table *db.BaseTable) TemplateTable {
+	res := TemplateTable{ID: table.ID, Name: table.Name, Desc: table.Desc}
+	if table.Database != nil {
+		res.Database = table.Database.Name
+	}
+	return res
+}
+
+func newTemplateUsers(users []db.User) []TemplateUser {
+	res := make([]TemplateUser, 0, len(users))
+	for _, u := range users {
+		res = append(res, TemplateUser{Nickname: u.Nickname, Phone: u.Phone, Email: u.Email})
+	}
+	return res
+}
+
+// msgLabel label of the built-in message in the locale of app.alarmLocale, zh by default
+func msgLabel(key string) string {
+	labels, ok := msgLabels[econf.GetString("app.alarmLocale")]
+	if !ok {
+		labels = msgLabels[LocaleZh]
+	}
+	return labels[key]
+}
+
+// GroupedTitle title of a message grouping count alarms, the title of the first one followed by the count
+func GroupedTitle(title string, count int) string {
+	return fmt.Sprintf(msgLabel("groupedTitle"), title, count)
+}
+
+// MsgLocation time zone of the messages, app.alarmTimezone is an IANA name or an offset like +08:00
+func MsgLocation() *time.Location {
+	return parseLocation(econf.GetString("app.alarmTimezone"))
+}
+
+func parseLocation(name string) *time.Location {
+	if name == "" {
+		return msgDefaultLocation
+	}
+	if msgOffsetRegex.MatchString(name) {
+		t, err := time.Parse("-07:00", name)
+		if err == nil {
+			_, offset := t.Zone()
+			return time.FixedZone("UTC"+name, offset)
+		}
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		elog.Error("parseLocation", elog.FieldErr(err), elog.String("timezone", name))
+		return msgDefaultLocation
+	}
+	return loc
+}
+
+// FormatTime time of the messages in the configured time zone
+func FormatTime(t time.Time) string {
+	return t.In(MsgLocation()).Format(msgTimeLayout)
+}
+
+// NewTemplateData data of the notification for the templates of the alarm and its channels
+func NewTemplateData(notification db.Notification, table *db.BaseTable, alarm *db.Alarm, filter *db.AlarmFilter, detail *Detail) *TemplateData {
+	users, _ := dutyOffices(alarm)
+	instance, _ := db.InstanceInfo(invoker.Db, table.Database.Iid)
+	res := &TemplateData{
+		Status:       notification.Status,
+		IsFiring:     notification.GetStatus() == db.AlarmStatusFiring,
+		StartsAt:     time.Now(),
+		Alarm:        newTemplateAlarm(alarm),
+		Filter:       TemplateFilter{ID: filter.ID, When: filter.When, Mode: filter.Mode},
+		Table:        newTemplateTable(table),
+		Instance:     TemplateInstance{ID: instance.ID, Name: instance.Name, Desc: instance.Desc},
+		PartialLog:   detail.PartialLog(),
+		DutyOfficers: newTemplateUsers(users),
+		AckURL:       ackURL(alarm),
+		SnoozeURL:    snoozeURL(alarm),
+		Labels:       notification.CommonLabels,
+	}
+	if len(notification.Alerts) > 0 {
+		res.StartsAt = notification.Alerts[0].StartsAt
+	}
+	if detail != nil {
+		res.Logs, res.TopField, res.TopValues, res.ChartURL = detail.Logs, detail.TopField, detail.TopValues, detail.ChartURL
+	}
+	res.ShareURL = shareURL(alarm, filter, res.StartsAt)
+	return res
+}
+
+// SampleTemplateData firing notification used to preview templates
+func SampleTemplateData() *TemplateData {
+	alarm := &db.Alarm{Uuid: "00000000-0000-0000-0000-000000000000", Name: "sample-alarm", Desc: "error logs over 10 in the last minute", Interval: 1, Tags: map[string]string{"env": "prod"}}
+	alarm.ID = 1
+	return &TemplateData{
+		Status:       "firing",
+		IsFiring:     true,
+		StartsAt:     time.Now(),
+		Alarm:        newTemplateAlarm(alarm),
+		Filter:       TemplateFilter{ID: 1, When: "level='error'"},
+		Table:        TemplateTable{ID: 1, Name: "app_logs", Desc: "application logs", Database: "default"},
+		Instance:     TemplateInstance{ID: 1, Name: "default", Desc: "clickhouse"},
+		PartialLog:   `{"level":"error","msg":"connection refused"}`,
+		Logs:         []string{`{"level":"error","msg":"connection refused"}`, `{"level":"error","msg":"timeout"}`},
+		TopField:     "host",
+		TopValues:    []TopValue{{Value: "10.0.0.1", Count: 8}, {Value: "10.0.0.2", Count: 3}},
+		DutyOfficers: []TemplateUser{{Nickname: "oncall", Phone: "13800000000"}},
+		ShareURL:     strings.TrimRight(econf.GetString("app.rootURL"), "/") + "/share",
+		AckURL:       ackURL(alarm),
+		SnoozeURL:    snoozeURL(alarm),
+		Labels:       map[string]string{"alertname": "sample-alarm", "severity": "warning"},
+	}
+}
+
+func parseTemplate(tpl string) (*template.Template, error) {
+	return template.New("text").Funcs(template.FuncMap{
+		"formatTime": FormatTime,
+		"join":       strings.Join,
+		"truncate": func(n int, s string) string {
+			if r := []rune(s); len(r) > n {
+				return string(r[:n])
+			}
+			return s
+		},
+		"label": msgLabel,
+	}).Parse(tpl)
+}
+
+// TemplateValidate an empty template keeps the built-in message
+func TemplateValidate(tpl string) error {
+	if tpl == "" {
+		return nil
+	}
+	_, err := parseTemplate(tpl)
+	return errors.Wrap(err, "invalid template")
+}
+
+// RenderTemplate renders the text of the message, and the title when the template defines one named title
+func RenderTemplate(tpl string, data *TemplateData, title string) (*db.PushMsg, error) {
+	t, err := parseTemplate(tpl)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid template")
+	}
+	var text bytes.Buffer
+	if err = t.Execute(&text, data); err != nil {
+		return nil, errors.Wrap(err, "render template")
+	}
+	if t.Lookup(templateTitle) != nil {
+		var buf bytes.Buffer
+		if err = t.ExecuteTemplate(&buf, templateTitle, data); err != nil {
+			return nil, errors.Wrap(err, "render title")
+		}
+		title = strings.TrimSpace(buf.String())
+	}
+	return &db.PushMsg{Title: title, Text: text.String()}, nil
+}
@@ -0,0 +1,75 @@
+package pusher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gotomicro/ego/core/econf"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRenderTemplate(t *testing.T) {
+	Convey("RenderTemplate", t, func() {
+		data := SampleTemplateData()
+		data.StartsAt = time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC)
+
+		Convey("text only keeps the title", func() {
+			msg, err := RenderTemplate(`{{.Alarm.Name}} {{if .IsFiring}}firing{{end}} {{formatTime .StartsAt}}`, data, "default")
+			So(err, ShouldBeNil)
+			So(msg.Title, ShouldEqual, "default")
+			So(msg.Text, ShouldEqual, "sample-alarm firing 2022-01-02 11:04:05")
+		})
+
+		Convey("title template", func() {
+			msg, err := RenderTemplate(`{{define "title"}} [{{.Labels.severity}}] {{.Alarm.Name}} {{end}}{{truncate 6 .PartialLog}}`, data, "default")
+			So(err, ShouldBeNil)
+			So(msg.Title, ShouldEqual, "[warning] sample-alarm")
+			So(msg.Text, ShouldEqual, `{"leve`)
+		})
+
+		Convey("invalid template", func() {
+			_, err := RenderTemplate(`{{.Alarm.Name`, data, "")
+			So(err, ShouldNotBeNil)
+			_, err = RenderTemplate(`{{.Missing}}`, data, "")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("the dsn and secrets of the models are not reachable", func() {
+			for _, tpl := range []string{`{{.Instance.GetDSN}}`, `{{.Instance.Dsn}}`, `{{.Instance.WebhookSecret}}`, `{{.Alarm.ChannelIds}}`, `{{(index .DutyOfficers 0).Password}}`} {
+				_, err := RenderTemplate(tpl, data, "")
+				So(err, ShouldNotBeNil)
+			}
+			msg, err := RenderTemplate(`{{.Instance.Name}} {{.Table.Database}}.{{.Table.Name}}`, data, "")
+			So(err, ShouldBeNil)
+			So(msg.Text, ShouldEqual, "default default.app_logs")
+		})
+	})
+}
+
+func TestTemplateValidate(t *testing.T) {
+	Convey("TemplateValidate", t, func() {
+		So(TemplateValidate(""), ShouldBeNil)
+		So(TemplateValidate(`{{label "alarmName"}}: {{.Alarm.Name}}`), ShouldBeNil)
+		So(TemplateValidate(`{{if .IsFiring}}`), ShouldNotBeNil)
+		So(TemplateValidate(`{{unknown .Alarm}}`), ShouldNotBeNil)
+	})
+}
+
+func TestParseLocation(t *testing.T) {
+	Convey("parseLocation", t, func() {
+		ts := time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC)
+		So(parseLocation(""), ShouldEqual, msgDefaultLocation)
+		So(ts.In(parseLocation("-05:30")).Format(msgTimeLayout), ShouldEqual, "2022-01-01 18:30:00")
+		So(ts.In(parseLocation("UTC")).Format(msgTimeLayout), ShouldEqual, "2022-01-02 00:00:00")
+		So(parseLocation("Not/AZone"), ShouldEqual, msgDefaultLocation)
+	})
+}
+
+func TestGroupedTitle(t *testing.T) {
+	Convey("GroupedTitle", t, func() {
+		So(GroupedTitle("checkout", 3), ShouldEqual, "checkout 等3条告警")
+		econf.Set("app.alarmLocale", LocaleEn)
+		defer econf.Set("app.alarmLocale", "")
+		So(GroupedTitle("checkout", 3), ShouldEqual, "checkout (3 alarms)")
+	})
+}
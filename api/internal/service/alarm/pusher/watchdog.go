@@ -0,0 +1,48 @@
+package pusher
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+// WatchdogAnomaly anomaly of a checked window found by the watchdog of a storage
+type WatchdogAnomaly struct {
+	Kind        string // db.WatchdogVolumeZero, db.WatchdogVolumeSpike, db.WatchdogErrorRatio or db.WatchdogNewError
+	ST          int64
+	ET          int64
+	Count       uint64
+	Usual       float64 // learned logs per window of the hour
+	ErrorRatio  float64
+	UsualRatio  float64
+	Fingerprint string // new error pattern
+	Pattern     string
+	Example     string
+}
+
+// BuildWatchdogMsg message of an anomaly of the storage or of its end
+func BuildWatchdogMsg(table *db.BaseTable, anomaly WatchdogAnomaly, status int) (msg *db.PushMsg, msgWithAt *db.PushMsg) {
+	m := newStateMsg(status, fmt.Sprintf("%s - %s", msgLabel("watchdog"), msgLabel(anomaly.Kind)))
+	m.field("table", fmt.Sprintf("%s.%s %s", table.Database.Name, table.Name, table.Desc))
+	m.field("startsAt", FormatTime(time.Unix(anomaly.ET, 0)))
+	m.statusField()
+	m.field("window", fmt.Sprintf("%s ~ %s", FormatTime(time.Unix(anomaly.ST, 0)), FormatTime(time.Unix(anomaly.ET, 0))))
+	switch anomaly.Kind {
+	case db.WatchdogNewError:
+		// the resolve of a new error pattern has none
+		if anomaly.Pattern != "" {
+			m.field("errorPattern", truncateLog(anomaly.Pattern))
+			m.field("logs", truncateLog(anomaly.Example))
+		}
+	case db.WatchdogErrorRatio:
+		m.field("errorRatioValue", fmt.Sprintf("%.2f%% (%s %.2f%%)", anomaly.ErrorRatio*100, msgLabel("usualVolume"), anomaly.UsualRatio*100))
+	default:
+		m.field("volume", fmt.Sprintf("%d (%s %.0f)", anomaly.Count, msgLabel("usualVolume"), anomaly.Usual))
+	}
+	dedupKey := fmt.Sprintf("watchdog-%d-%s", table.ID, anomaly.Kind)
+	if anomaly.Fingerprint != "" {
+		dedupKey += "-" + anomaly.Fingerprint
+	}
+	return m.build(fmt.Sprintf("%s %s", table.Name, msgLabel(anomaly.Kind)), dedupKey)
+}
@@ -0,0 +1,40 @@
+package pusher
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+func TestBuildWatchdogMsg(t *testing.T) {
+	table := &db.BaseTable{Name: "app", Database: &db.BaseDatabase{Name: "logs"}}
+	table.ID = 3
+
+	Convey("volume spike", t, func() {
+		anomaly := WatchdogAnomaly{Kind: db.WatchdogVolumeSpike, ST: 1700000000, ET: 1700000300, Count: 9000, Usual: 1200}
+		msg, msgWithAt := BuildWatchdogMsg(table, anomaly, db.AlarmStatusFiring)
+		So(msg.DedupKey, ShouldEqual, "watchdog-3-volumeSpike")
+		So(msg.Status, ShouldEqual, db.AlarmStatusFiring)
+		So(msg.Title, ShouldContainSubstring, "app")
+		So(msg.Text, ShouldContainSubstring, "logs.app")
+		So(msg.Text, ShouldContainSubstring, "9000")
+		So(msg.Text, ShouldContainSubstring, "1200")
+		So(strings.Contains(msgWithAt.Text, "\n\n"), ShouldBeTrue)
+	})
+
+	Convey("new error pattern", t, func() {
+		anomaly := WatchdogAnomaly{
+			Kind:        db.WatchdogNewError,
+			Fingerprint: "a1b2",
+			Pattern:     "dial tcp <ip>:<num>: connection refused",
+			Example:     "dial tcp 10.0.0.1:3306: connection refused",
+		}
+		msg, _ := BuildWatchdogMsg(table, anomaly, db.AlarmStatusFiring)
+		So(msg.DedupKey, ShouldEqual, "watchdog-3-newError-a1b2")
+		So(msg.Text, ShouldContainSubstring, "dial tcp <ip>:<num>")
+		So(msg.Text, ShouldContainSubstring, "10.0.0.1:3306")
+	})
+}
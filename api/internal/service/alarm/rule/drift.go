@@ -0,0 +1,69 @@
+package rule
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// clickVisualRulePrefix rule names are cv-<uuid>[-<filter id>].yaml, operator groups cv-<iid>-<uuid>
+const clickVisualRulePrefix = "cv-"
+
+func isClickVisualRule(name string) bool {
+	return strings.HasPrefix(name, clickVisualRulePrefix) && strings.HasSuffix(name, ".yaml")
+}
+
+// RulesEqual the rules of the contents are the same, names of the groups aside
+func RulesEqual(a, b string) bool {
+	ra, errA := flattenRules(a)
+	rb, errB := flattenRules(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return reflect.DeepEqual(normalizeRules(ra), normalizeRules(rb))
+}
+
+// normalizeRules empty labels and annotations are the same as missing ones once stored
+func normalizeRules(rules []OperatorRule) []OperatorRule {
+	for k := range rules {
+		if len(rules[k].Labels) == 0 {
+			rules[k].Labels = nil
+		}
+		if len(rules[k].Annotations) == 0 {
+			rules[k].Annotations = nil
+		}
+		rules[k].Expr = strings.TrimSpace(rules[k].Expr)
+	}
+	return rules
+}
+
+// MergeRules the rules of the contents as one group, how the prometheus operator stores the rules of an alarm
+func MergeRules(groupName string, contents []string) (string, error) {
+	group := OperatorRuleGroup{Name: groupName, Rules: make([]OperatorRule, 0)}
+	for _, content := range contents {
+		rules, err := flattenRules(content)
+		if err != nil {
+			return "", err
+		}
+		group.Rules = append(group.Rules, rules...)
+	}
+	res, err := yaml.Marshal(OperatorRuleGroups{Groups: []OperatorRuleGroup{group}})
+	if err != nil {
+		return "", errors.Wrap(err, "yaml.Marshal")
+	}
+	return string(res), nil
+}
+
+func flattenRules(content string) ([]OperatorRule, error) {
+	ruleGroups := OperatorRuleGroups{}
+	if err := yaml.Unmarshal([]byte(content), &ruleGroups); err != nil {
+		return nil, errors.Wrapf(err, "rule: %s", content)
+	}
+	res := make([]OperatorRule, 0)
+	for _, group := range ruleGroups.Groups {
+		res = append(res, group.Rules...)
+	}
+	return res, nil
+}
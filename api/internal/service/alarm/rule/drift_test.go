@@ -0,0 +1,45 @@
+package rule
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRulesEqual(t *testing.T) {
+	Convey("RulesEqual", t, func() {
+		renamed := "groups:\n- name: cv-uuid-1\n  rules:\n  - alert: ClickVisual-test\n    expr: max_over_time(up[1m])>0\n    labels:\n      severity: warning\n"
+		So(RulesEqual(rulerTestContent, renamed), ShouldBeTrue)
+		So(RulesEqual(rulerTestContent, "groups:\n- name: default\n  rules:\n  - alert: ClickVisual-test\n    expr: up"), ShouldBeFalse)
+		So(RulesEqual("{", "{"), ShouldBeTrue)
+		emptyLabels := "groups:\n- name: default\n  rules:\n  - alert: ClickVisual-test\n    expr: up\n    labels: {}\n    annotations: {}\n"
+		So(RulesEqual(emptyLabels, "groups:\n- name: default\n  rules:\n  - alert: ClickVisual-test\n    expr: |\n      up\n"), ShouldBeTrue)
+	})
+	Convey("MergeRules", t, func() {
+		merged, err := MergeRules("cv-1-uuid", []string{rulerTestContent, rulerTestContent})
+		So(err, ShouldBeNil)
+		rules, err := flattenRules(merged)
+		So(err, ShouldBeNil)
+		So(rules, ShouldHaveLength, 2)
+		_, err = MergeRules("cv-1-uuid", []string{"{"})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestFileSystem_List(t *testing.T) {
+	Convey("rules of clickvisual in the path", t, func() {
+		dir := t.TempDir()
+		So(os.WriteFile(dir+"/other.yaml", []byte("groups: []"), 0644), ShouldBeNil)
+		r, err := NewFileSystem(&Params{InstanceID: 100, RulePath: dir})
+		So(err, ShouldBeNil)
+		So(r.CreateOrUpdate("", "cv-uuid-1.yaml", rulerTestContent), ShouldBeNil)
+		rules, err := r.List()
+		So(err, ShouldBeNil)
+		So(rules, ShouldResemble, map[string]string{"cv-uuid-1.yaml": rulerTestContent})
+		So(r.Delete("", "cv-uuid-1.yaml"), ShouldBeNil)
+		rules, err = r.List()
+		So(err, ShouldBeNil)
+		So(rules, ShouldBeEmpty)
+	})
+}
@@ -69,3 +69,26 @@ func (r *fileSystem) BatchSet(groupName string, rules []db.ClusterRuleItem) erro
 func (r *fileSystem) BatchRemove(groupName string) error {
 	return ErrNotYetSupported
 }
+
+func (r *fileSystem) List() (map[string]string, error) {
+	if r.rulePath == "" {
+		return nil, errors.Wrapf(ErrParameter, "rule: %v", r)
+	}
+	path := strings.TrimSuffix(r.rulePath, "/")
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "file path is %s", r.rulePath)
+	}
+	res := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() || !isClickVisualRule(entry.Name()) {
+			continue
+		}
+		content, errRead := os.ReadFile(path + "/" + entry.Name())
+		if errRead != nil {
+			return nil, errors.Wrapf(errRead, "rule name %s", entry.Name())
+		}
+		res[entry.Name()] = string(content)
+	}
+	return res, nil
+}
@@ -22,6 +22,8 @@ type Component interface {
 	// BatchRemove v2 ...
 	BatchRemove(groupName string) error
 	BatchSet(groupName string, rules []db.ClusterRuleItem) error
+	// List rules of clickvisual in the store by rule name, by group name for the prometheus operator
+	List() (map[string]string, error)
 }
 
 type Params struct {
@@ -34,17 +36,24 @@ type Params struct {
 	Configmap string
 	// k8s operator
 	PrometheusOperator string
+	// ruler api
+	RulerUrl       string
+	RulerNamespace string
+	RulerTenant    string
 }
 
 func (p *Params) md5() string {
 	has := md5.New() // 创建md5算法
-	has.Write([]byte(fmt.Sprintf("%d_%s_%d_%s_%s_%s",
+	has.Write([]byte(fmt.Sprintf("%d_%s_%d_%s_%s_%s_%s_%s_%s",
 		p.InstanceID,
 		p.RulePath,
 		p.ClusterId,
 		p.Namespace,
 		p.Configmap,
 		p.PrometheusOperator,
+		p.RulerUrl,
+		p.RulerNamespace,
+		p.RulerTenant,
 	))) // 写入需要加密的数据
 	b := has.Sum(nil) // 获取hash值字符切片；Sum函数接受一个字符切片，这个切片的内容会原样的追加到abc123加密
 	return string(b)
@@ -61,6 +70,8 @@ func GetComponent(storeType int, params *Params) (Component, error) {
 		return NewK8sConfigMap(params)
 	case db.RuleStoreTypeK8sOperator:
 		return NewK8sOperator(params)
+	case db.RuleStoreTypeRuler:
+		return NewRuler(params)
 	}
 	return nil, errors.Wrapf(ErrParameter, "storeType: %d", storeType)
 }
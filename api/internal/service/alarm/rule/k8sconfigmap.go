@@ -82,3 +82,20 @@ func (r *k8sConfigMap) BatchSet(groupName string, rules []db.ClusterRuleItem) er
 func (r *k8sConfigMap) BatchRemove(groupName string) error {
 	return ErrNotYetSupported
 }
+
+func (r *k8sConfigMap) List() (map[string]string, error) {
+	if r.clusterId == 0 || r.namespace == "" || r.configmap == "" {
+		return nil, errors.Wrapf(ErrParameter, "rule: %v", r)
+	}
+	data, err := resource.ListConfigmap(r.clusterId, r.namespace, r.configmap)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]string)
+	for k, v := range data {
+		if isClickVisualRule(k) {
+			res[k] = v
+		}
+	}
+	return res, nil
+}
@@ -2,6 +2,7 @@ package rule
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 
 	"github.com/pkg/errors"
@@ -103,6 +104,41 @@ func (r *k8sOperator) BatchRemove(groupName string) error {
 	return nil
 }
 
+func (r *k8sOperator) List() (map[string]string, error) {
+	if r.clusterId == 0 ||
+		r.prometheusOperator.MetaData.Namespace == "" ||
+		r.prometheusOperator.MetaData.Name == "" {
+		return nil, errors.Wrapf(ErrParameter, "rule: %v", r)
+	}
+	groups, err := resource.ListPrometheusRuleGroups(r.clusterId, r.prometheusOperator.MetaData.Namespace, r.prometheusOperator.MetaData.Name)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]string)
+	for _, group := range groups {
+		if !strings.HasPrefix(group.Name, clickVisualRulePrefix) {
+			continue
+		}
+		ruleGroup := OperatorRuleGroup{Name: group.Name, Rules: make([]OperatorRule, 0, len(group.Rules))}
+		for _, rule := range group.Rules {
+			ruleGroup.Rules = append(ruleGroup.Rules, OperatorRule{
+				Record:      rule.Record,
+				Alert:       rule.Alert,
+				Expr:        rule.Expr.String(),
+				For:         string(rule.For),
+				Labels:      rule.Labels,
+				Annotations: rule.Annotations,
+			})
+		}
+		content, errMarshal := yaml.Marshal(OperatorRuleGroups{Groups: []OperatorRuleGroup{ruleGroup}})
+		if errMarshal != nil {
+			return nil, errors.Wrap(errMarshal, "yaml.Marshal")
+		}
+		res[group.Name] = string(content)
+	}
+	return res, nil
+}
+
 type OperatorRuleGroups struct {
 	Groups []OperatorRuleGroup `yaml:"groups,omitempty"`
 }
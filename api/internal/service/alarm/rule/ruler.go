@@ -0,0 +1,230 @@
+package rule
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+var _ Component = (*ruler)(nil)
+
+var errRulerNotFound = errors.New("ruler namespace or group not found")
+
+// ruler Object resource pool
+var resourcePoolRuler sync.Map
+
+// ruler rule groups of a namespace managed through the prometheus compatible ruler api of mimir, cortex or vmalert proxies:
+// POST <url>/<namespace> sets a group, GET and DELETE <url>/<namespace>/<group> read and delete it
+type ruler struct {
+	md5       string
+	iid       int
+	url       string
+	namespace string
+	tenant    string
+	client    *http.Client
+}
+
+func NewRuler(params *Params) (*ruler, error) {
+	nmd5 := params.md5()
+	if v, ok := resourcePoolRuler.Load(params.InstanceID); ok {
+		if v == nil {
+			return nil, errors.Wrap(ErrNilObject, "new")
+		}
+		obj, typeOk := v.(*ruler)
+		if !typeOk {
+			return nil, errors.Wrap(ErrNilObject, "type")
+		}
+		if obj.md5 == nmd5 {
+			return obj, nil
+		}
+	}
+	p := &ruler{
+		iid:       params.InstanceID,
+		md5:       nmd5,
+		url:       strings.TrimSuffix(params.RulerUrl, "/"),
+		namespace: params.RulerNamespace,
+		tenant:    params.RulerTenant,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+	resourcePoolRuler.Store(params.InstanceID, p)
+	return p, nil
+}
+
+// CreateOrUpdate the rule is stored as a group named after the rule, the groups of the content are all named default
+func (r *ruler) CreateOrUpdate(groupName, ruleName, content string) error {
+	if r.url == "" || r.namespace == "" {
+		return errors.Wrapf(ErrParameter, "rule: %v", r)
+	}
+	ruleGroups := OperatorRuleGroups{}
+	if err := yaml.Unmarshal([]byte(content), &ruleGroups); err != nil {
+		return errors.Wrapf(err, "rule: %s", content)
+	}
+	if len(ruleGroups.Groups) != 1 {
+		return errors.Errorf("format error and rule is: %s", content)
+	}
+	group := ruleGroups.Groups[0]
+	group.Name = rulerGroupName(ruleName)
+	return r.setGroup(group)
+}
+
+func (r *ruler) Delete(groupName, ruleName string) error {
+	if r.url == "" || r.namespace == "" {
+		return errors.Wrapf(ErrParameter, "rule: %v", r)
+	}
+	return r.deleteGroup(rulerGroupName(ruleName))
+}
+
+// BatchSet the rules are stored as one group
+func (r *ruler) BatchSet(groupName string, rules []db.ClusterRuleItem) error {
+	if r.url == "" || r.namespace == "" {
+		return errors.Wrapf(ErrParameter, "rule: %v", r)
+	}
+	group := OperatorRuleGroup{Name: groupName, Rules: make([]OperatorRule, 0)}
+	for _, rule := range rules {
+		ruleGroups := OperatorRuleGroups{}
+		if err := yaml.Unmarshal([]byte(rule.Content), &ruleGroups); err != nil {
+			return errors.Wrapf(err, "rule: %s", rule.Content)
+		}
+		for _, g := range ruleGroups.Groups {
+			group.Rules = append(group.Rules, g.Rules...)
+		}
+	}
+	return r.setGroup(group)
+}
+
+func (r *ruler) BatchRemove(groupName string) error {
+	if r.url == "" || r.namespace == "" {
+		return errors.Wrapf(ErrParameter, "rule: %v", r)
+	}
+	return r.deleteGroup(groupName)
+}
+
+// List groups of the namespace named after the rules, as rules
+func (r *ruler) List() (map[string]string, error) {
+	if r.url == "" || r.namespace == "" {
+		return nil, errors.Wrapf(ErrParameter, "rule: %v", r)
+	}
+	body, err := r.do(http.MethodGet, "/"+url.PathEscape(r.namespace), nil)
+	if errors.Is(err, errRulerNotFound) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	namespaces := make(map[string][]OperatorRuleGroup)
+	if err = yaml.Unmarshal(body, &namespaces); err != nil {
+		return nil, errors.Wrap(err, "yaml.Unmarshal")
+	}
+	res := make(map[string]string)
+	for _, group := range namespaces[r.namespace] {
+		if !strings.HasPrefix(group.Name, clickVisualRulePrefix) {
+			continue
+		}
+		content, errMarshal := yaml.Marshal(OperatorRuleGroups{Groups: []OperatorRuleGroup{group}})
+		if errMarshal != nil {
+			return nil, errors.Wrap(errMarshal, "yaml.Marshal")
+		}
+		res[group.Name+".yaml"] = string(content)
+	}
+	return res, nil
+}
+
+// Check the ruler answers for the namespace, a namespace without groups is not an error
+func (r *ruler) Check() error {
+	if r.url == "" || r.namespace == "" {
+		return errors.Wrapf(ErrParameter, "ruler url and namespace are required")
+	}
+	_, err := r.do(http.MethodGet, "/"+url.PathEscape(r.namespace), nil)
+	if errors.Is(err, errRulerNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Group the group of the namespace, nil when it does not exist
+func (r *ruler) Group(groupName string) (*OperatorRuleGroup, error) {
+	body, err := r.do(http.MethodGet, r.groupPath(groupName), nil)
+	if errors.Is(err, errRulerNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var res OperatorRuleGroup
+	if err = yaml.Unmarshal(body, &res); err != nil {
+		return nil, errors.Wrap(err, "yaml.Unmarshal")
+	}
+	return &res, nil
+}
+
+func (r *ruler) setGroup(group OperatorRuleGroup) error {
+	body, err := yaml.Marshal(group)
+	if err != nil {
+		return errors.Wrap(err, "yaml.Marshal")
+	}
+	_, err = r.do(http.MethodPost, "/"+url.PathEscape(r.namespace), body)
+	return err
+}
+
+func (r *ruler) deleteGroup(groupName string) error {
+	if _, err := r.do(http.MethodDelete, r.groupPath(groupName), nil); err != nil && !errors.Is(err, errRulerNotFound) {
+		return err
+	}
+	return nil
+}
+
+func (r *ruler) groupPath(groupName string) string {
+	return "/" + url.PathEscape(r.namespace) + "/" + url.PathEscape(groupName)
+}
+
+func (r *ruler) do(method, path string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, r.url+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "http.NewRequest")
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/yaml")
+	}
+	if r.tenant != "" {
+		req.Header.Set("X-Scope-OrgID", r.tenant)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "ruler %s %s", method, path)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	res, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "io.ReadAll")
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errors.Wrapf(errRulerNotFound, "%s %s", method, path)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, errors.Errorf("ruler %s %s: %d %s", method, path, resp.StatusCode, strings.TrimSpace(string(res)))
+	}
+	return res, nil
+}
+
+// rulerGroupName cv-<uuid>-<filter id>.yaml is stored as the group cv-<uuid>-<filter id>
+func rulerGroupName(ruleName string) string {
+	return strings.TrimSuffix(ruleName, ".yaml")
+}
+
+// RulerCheck the ruler of the settings is reachable
+func RulerCheck(params *Params) error {
+	r, err := NewRuler(params)
+	if err != nil {
+		return err
+	}
+	return r.Check()
+}
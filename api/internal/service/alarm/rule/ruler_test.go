@@ -0,0 +1,129 @@
+package rule
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"gopkg.in/yaml.v3"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+const rulerTestContent = `groups:
+- name: default
+  rules:
+  - alert: ClickVisual-test
+    expr: max_over_time(up[1m])>0
+    labels:
+      severity: warning`
+
+// fakeRuler in memory ruler api, the groups of a tenant are keyed by namespace/group
+func fakeRuler(tenant string) (*httptest.Server, map[string]OperatorRuleGroup) {
+	var mu sync.Mutex
+	groups := make(map[string]OperatorRuleGroup)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("X-Scope-OrgID") != tenant {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		path := strings.Split(strings.TrimPrefix(r.URL.Path, "/rules/"), "/")
+		switch {
+		case r.Method == http.MethodPost && len(path) == 1:
+			body, _ := io.ReadAll(r.Body)
+			var group OperatorRuleGroup
+			if err := yaml.Unmarshal(body, &group); err != nil || group.Name == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			groups[path[0]+"/"+group.Name] = group
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == http.MethodGet && len(path) == 1:
+			res := make([]OperatorRuleGroup, 0)
+			for k, g := range groups {
+				if strings.HasPrefix(k, path[0]+"/") {
+					res = append(res, g)
+				}
+			}
+			if len(res) == 0 {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			out, _ := yaml.Marshal(map[string][]OperatorRuleGroup{path[0]: res})
+			_, _ = w.Write(out)
+		case len(path) == 2:
+			g, ok := groups[path[0]+"/"+path[1]]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if r.Method == http.MethodDelete {
+				delete(groups, path[0]+"/"+path[1])
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			out, _ := yaml.Marshal(g)
+			_, _ = w.Write(out)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	return srv, groups
+}
+
+func TestRuler(t *testing.T) {
+	srv, groups := fakeRuler("tenant")
+	defer srv.Close()
+
+	Convey("rules are groups of the namespace", t, func() {
+		r, err := NewRuler(&Params{InstanceID: 1, RulerUrl: srv.URL + "/rules/", RulerNamespace: "clickvisual", RulerTenant: "tenant"})
+		So(err, ShouldBeNil)
+		So(r.Check(), ShouldBeNil)
+
+		So(r.CreateOrUpdate("", "cv-uuid-1.yaml", rulerTestContent), ShouldBeNil)
+		So(r.CreateOrUpdate("", "cv-uuid-2.yaml", rulerTestContent), ShouldBeNil)
+		So(groups, ShouldHaveLength, 2)
+		group, err := r.Group("cv-uuid-1")
+		So(err, ShouldBeNil)
+		So(group.Name, ShouldEqual, "cv-uuid-1")
+		So(group.Rules, ShouldHaveLength, 1)
+		So(group.Rules[0].Alert, ShouldEqual, "ClickVisual-test")
+		So(r.Check(), ShouldBeNil)
+		rules, err := r.List()
+		So(err, ShouldBeNil)
+		So(rules, ShouldHaveLength, 2)
+		So(RulesEqual(rules["cv-uuid-1.yaml"], rulerTestContent), ShouldBeTrue)
+
+		So(r.Delete("", "cv-uuid-1.yaml"), ShouldBeNil)
+		So(r.Delete("", "cv-uuid-1.yaml"), ShouldBeNil)
+		group, err = r.Group("cv-uuid-1")
+		So(err, ShouldBeNil)
+		So(group, ShouldBeNil)
+		So(groups, ShouldHaveLength, 1)
+
+		So(r.BatchSet("cv-1-uuid", []db.ClusterRuleItem{{Content: rulerTestContent}, {Content: rulerTestContent}}), ShouldBeNil)
+		group, err = r.Group("cv-1-uuid")
+		So(err, ShouldBeNil)
+		So(group.Rules, ShouldHaveLength, 2)
+		So(r.BatchRemove("cv-1-uuid"), ShouldBeNil)
+		So(groups, ShouldHaveLength, 1)
+	})
+
+	Convey("invalid rules and settings", t, func() {
+		r, err := NewRuler(&Params{InstanceID: 2, RulerUrl: srv.URL + "/rules", RulerNamespace: "clickvisual", RulerTenant: "tenant"})
+		So(err, ShouldBeNil)
+		So(r.CreateOrUpdate("", "cv-uuid.yaml", "groups: []"), ShouldNotBeNil)
+		So(r.CreateOrUpdate("", "cv-uuid.yaml", "{"), ShouldNotBeNil)
+
+		So(RulerCheck(&Params{InstanceID: 3, RulerUrl: srv.URL + "/rules", RulerNamespace: "clickvisual", RulerTenant: "other"}), ShouldNotBeNil)
+		So(RulerCheck(&Params{InstanceID: 4, RulerUrl: srv.URL + "/rules"}), ShouldNotBeNil)
+		_, err = GetComponent(db.RuleStoreTypeRuler, &Params{InstanceID: 5, RulerUrl: srv.URL + "/rules", RulerNamespace: "clickvisual", RulerTenant: "tenant"})
+		So(err, ShouldBeNil)
+	})
+}
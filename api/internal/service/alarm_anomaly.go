@@ -0,0 +1,100 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	db2 "github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+// anomalyExp prometheus expression of the anomaly conditions on the metric selector.
+// The alert view only writes the log count per time point, the previous windows are read by prometheus
+// with offsets instead of being queried by the view on every insert.
+// A window without logs has no sample, the conditions comparing it do not match.
+func anomalyExp(selector string, interval int64, conditions []*db2.AlarmCondition) string {
+	if interval <= 0 {
+		interval = 60
+	}
+	// window of interval seconds ending offset seconds before the current one, with the offset of the rules
+	window := func(exp int, offset int64) string {
+		return fmt.Sprintf("%s(%s[%ds] offset %ds)", overTimeFunc(exp), selector, interval, offset+10)
+	}
+	var res string
+	for k, condition := range conditions {
+		cur := window(condition.SetOperatorExp, 0)
+		var exp string
+		switch condition.CondTyp {
+		case db2.ConditionTypChange:
+			exp = promCond(condition, promChange(cur, window(condition.SetOperatorExp, int64(condition.Offset))))
+		case db2.ConditionTypBaseline:
+			periods := fmt.Sprintf("%s(%s[%ds])[%ds:%ds] offset %ds", overTimeFunc(condition.SetOperatorExp), selector, interval,
+				int64(condition.Periods)*interval, interval, interval+10)
+			factor := strconv.FormatFloat(condition.Factor, 'f', -1, 64)
+			lower := fmt.Sprintf("(avg_over_time(%[1]s) - %[2]s * stddev_over_time(%[1]s))", periods, factor)
+			upper := fmt.Sprintf("(avg_over_time(%[1]s) + %[2]s * stddev_over_time(%[1]s))", periods, factor)
+			switch condition.Cond {
+			case 0:
+				exp = promRange(0, cur, upper, "")
+			case 1:
+				exp = promRange(1, cur, lower, "")
+			default:
+				exp = promRange(condition.Cond, cur, lower, upper)
+			}
+		case db2.ConditionTypSeasonal:
+			seasons := make([]string, 0, condition.Periods)
+			for p := 1; p <= condition.Periods; p++ {
+				seasons = append(seasons, window(condition.SetOperatorExp, int64(p*condition.Offset)))
+			}
+			exp = promCond(condition, promChange(cur, fmt.Sprintf("((%s) / %d)", strings.Join(seasons, " + "), condition.Periods)))
+		default:
+			exp = promCond(condition, cur)
+		}
+		switch {
+		case k == 0:
+			res = exp
+		case condition.SetOperatorTyp == 1:
+			res = fmt.Sprintf("(%s and %s)", res, exp)
+		case condition.SetOperatorTyp == 2:
+			res = fmt.Sprintf("(%s or %s)", res, exp)
+		}
+	}
+	return res
+}
+
+// overTimeFunc 0 avg 1 min 2 max 3 sum 4 count
+func overTimeFunc(exp int) string {
+	switch exp {
+	case 1:
+		return "min_over_time"
+	case 2:
+		return "max_over_time"
+	case 3:
+		return "sum_over_time"
+	case 4:
+		return "count_over_time"
+	}
+	return "avg_over_time"
+}
+
+// promChange percentage change of cur versus prev, +Inf when logs appear after none
+func promChange(cur, prev string) string {
+	return fmt.Sprintf("((%[1]s - %[2]s) / %[2]s * 100)", cur, prev)
+}
+
+// promCond 0 above 1 below 2 outside range 3 within range
+func promCond(condition *db2.AlarmCondition, val string) string {
+	return promRange(condition.Cond, val, strconv.Itoa(condition.Val1), strconv.Itoa(condition.Val2))
+}
+
+func promRange(cond int, val, val1, val2 string) string {
+	switch cond {
+	case 1:
+		return fmt.Sprintf("%s<%s", val, val1)
+	case 2:
+		return fmt.Sprintf("(%s<%s or %s>%s)", val, val1, val, val2)
+	case 3:
+		return fmt.Sprintf("(%s>=%s and %s<=%s)", val, val1, val, val2)
+	}
+	return fmt.Sprintf("%s>%s", val, val1)
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+func Test_anomalyExp(t *testing.T) {
+	const sel = `m{uuid="u"}`
+	tests := []struct {
+		name       string
+		conditions []*db.AlarmCondition
+		want       string
+	}{
+		{
+			name:       "change",
+			conditions: []*db.AlarmCondition{{SetOperatorExp: 3, CondTyp: db.ConditionTypChange, Offset: 3600, Val1: 100}},
+			want:       `((sum_over_time(m{uuid="u"}[60s] offset 10s) - sum_over_time(m{uuid="u"}[60s] offset 3610s)) / sum_over_time(m{uuid="u"}[60s] offset 3610s) * 100)>100`,
+		},
+		{
+			name: "baseline and threshold",
+			conditions: []*db.AlarmCondition{
+				{SetOperatorExp: 3, CondTyp: db.ConditionTypBaseline, Periods: 2, Factor: 2.5},
+				{SetOperatorTyp: 1, SetOperatorExp: 3, Val1: 10},
+			},
+			want: `(sum_over_time(m{uuid="u"}[60s] offset 10s)>(avg_over_time(sum_over_time(m{uuid="u"}[60s])[120s:60s] offset 70s) + 2.5 * stddev_over_time(sum_over_time(m{uuid="u"}[60s])[120s:60s] offset 70s))` +
+				` and sum_over_time(m{uuid="u"}[60s] offset 10s)>10)`,
+		},
+		{
+			name:       "seasonal outside range",
+			conditions: []*db.AlarmCondition{{SetOperatorExp: 4, CondTyp: db.ConditionTypSeasonal, Offset: 86400, Periods: 2, Cond: 2, Val1: -50, Val2: 50}},
+			want: `(((count_over_time(m{uuid="u"}[60s] offset 10s) - ((count_over_time(m{uuid="u"}[60s] offset 86410s) + count_over_time(m{uuid="u"}[60s] offset 172810s)) / 2)) / ((count_over_time(m{uuid="u"}[60s] offset 86410s) + count_over_time(m{uuid="u"}[60s] offset 172810s)) / 2) * 100)<-50` +
+				` or ((count_over_time(m{uuid="u"}[60s] offset 10s) - ((count_over_time(m{uuid="u"}[60s] offset 86410s) + count_over_time(m{uuid="u"}[60s] offset 172810s)) / 2)) / ((count_over_time(m{uuid="u"}[60s] offset 86410s) + count_over_time(m{uuid="u"}[60s] offset 172810s)) / 2) * 100)>50)`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, anomalyExp(sel, 60, tt.conditions))
+		})
+	}
+}
@@ -0,0 +1,915 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ego-component/egorm"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service/alarm/pusher"
+)
+
+// alarmCodeStep change of the plan with the object of the file
+type alarmCodeStep struct {
+	view.AlarmCodeChange
+	channel *view.AlarmCodeChannel
+	alarm   *view.AlarmCodeAlarm
+	silence *view.AlarmCodeSilence
+}
+
+// alarmCodeState objects of the database and the names of their references
+type alarmCodeState struct {
+	channels    []*db.AlarmChannel
+	alarms      []*db.Alarm
+	silences    []*db.AlarmSilence
+	users       map[int]string
+	escalations map[int]string
+	instances   map[int]string
+	tables      map[int]view.AlarmCodeFilter // names of the table
+	tableIds    map[string]int
+}
+
+// ParseAlarmCode the yaml of the alarms, unknown fields are rejected
+func ParseAlarmCode(content string) (res view.AlarmCode, err error) {
+	dec := yaml.NewDecoder(strings.NewReader(content))
+	dec.KnownFields(true)
+	if err = dec.Decode(&res); err != nil && !errors.Is(err, io.EOF) {
+		return res, errors.Wrap(err, "invalid yaml")
+	}
+	return res, nil
+}
+
+// AlarmCodeExport channels, alarms and the silences not ended, the keys and callback secrets of the channels are redacted
+func (i *alert) AlarmCodeExport() (res view.AlarmCode, err error) {
+	s, err := loadAlarmCodeState()
+	if err != nil {
+		return res, err
+	}
+	res.Channels = make([]view.AlarmCodeChannel, 0, len(s.channels))
+	for _, channel := range s.channels {
+		res.Channels = append(res.Channels, redactChannelCode(channelCode(channel)))
+	}
+	res.Alarms = make([]view.AlarmCodeAlarm, 0, len(s.alarms))
+	for _, alarm := range s.alarms {
+		code, errAlarm := s.alarmCode(alarm)
+		if errAlarm != nil {
+			return res, errors.Wrapf(errAlarm, "alarm %s", alarm.Name)
+		}
+		res.Alarms = append(res.Alarms, code)
+	}
+	res.Silences = make([]view.AlarmCodeSilence, 0, len(s.silences))
+	for _, silence := range s.silences {
+		code, errSilence := s.silenceCode(silence)
+		if errSilence != nil {
+			return res, errors.Wrapf(errSilence, "silence %d", silence.ID)
+		}
+		res.Silences = append(res.Silences, code)
+	}
+	return res, nil
+}
+
+// AlarmCodePlan changes which make the database match the file, objects missing from the file are deleted with prune
+func (i *alert) AlarmCodePlan(code view.AlarmCode, prune bool) ([]*view.AlarmCodeChange, error) {
+	steps, _, err := alarmCodePlan(code, prune)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*view.AlarmCodeChange, 0, len(steps))
+	for _, step := range steps {
+		res = append(res, &step.AlarmCodeChange)
+	}
+	return res, nil
+}
+
+// AlarmCodeApply applies the plan in a transaction, the views and rules of the alarms are synced once it is committed.
+// A failed sync is resumed by applying the same file again.
+func (i *alert) AlarmCodeApply(uid int, code view.AlarmCode, prune bool) ([]*view.AlarmCodeChange, error) {
+	steps, s, err := alarmCodePlan(code, prune)
+	if err != nil {
+		return nil, err
+	}
+	return i.alarmCodeApplySteps(uid, s, steps)
+}
+
+func (i *alert) alarmCodeApplySteps(uid int, s *alarmCodeState, steps []*alarmCodeStep) ([]*view.AlarmCodeChange, error) {
+	res := make([]*view.AlarmCodeChange, 0, len(steps))
+	syncs := make([]func() error, 0)
+	tx := invoker.Db.Begin()
+	for _, step := range steps {
+		sync, err := i.alarmCodeApply(tx, uid, s, step)
+		if err != nil {
+			tx.Rollback()
+			return nil, errors.Wrapf(err, "%s %s %s", step.Action, step.Kind, step.Name)
+		}
+		if sync != nil {
+			syncs = append(syncs, sync)
+		}
+		res = append(res, &step.AlarmCodeChange)
+	}
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	for _, sync := range syncs {
+		if err := sync(); err != nil {
+			return res, errors.Wrap(err, "sync of the views and rules")
+		}
+	}
+	return res, nil
+}
+
+// alarmCodeApply writes the step in the transaction, sync updates the views and rules of the alarm after the commit
+func (i *alert) alarmCodeApply(tx *gorm.DB, uid int, s *alarmCodeState, step *alarmCodeStep) (sync func() error, err error) {
+	switch step.Kind {
+	case view.AlarmCodeKindChannel:
+		switch step.Action {
+		case view.AlarmCodeActionCreate:
+			obj := channelFromCode(step.channel)
+			obj.Uid = uid
+			if err = db.AlarmChannelCreate(tx, obj); err != nil {
+				return nil, err
+			}
+			s.channels = append(s.channels, obj)
+		case view.AlarmCodeActionUpdate:
+			obj := channelFromCode(step.channel)
+			ups := make(map[string]interface{}, 0)
+			ups["name"] = obj.Name
+			ups["typ"] = obj.Typ
+			ups["key"] = obj.Key
+			ups["group_by"] = obj.GroupBy
+			ups["group_wait"] = obj.GroupWait
+			ups["group_interval"] = obj.GroupInterval
+			ups["repeat_interval"] = obj.RepeatInterval
+			ups["rate_limit"] = obj.RateLimit
+			ups["template"] = obj.Template
+			ups["chat_ops"] = obj.ChatOps
+			ups["callback_secret"] = obj.CallbackSecret
+			ups["uid"] = uid
+			return nil, db.AlarmChannelUpdate(tx, step.Id, ups)
+		case view.AlarmCodeActionDelete:
+			return nil, db.AlarmChannelDelete(tx, step.Id)
+		}
+	case view.AlarmCodeKindAlarm:
+		switch step.Action {
+		case view.AlarmCodeActionCreate:
+			req, errReq := s.alarmReq(step.alarm)
+			if errReq != nil {
+				return nil, errReq
+			}
+			obj, errCreate := alarmCreateRow(tx, uid, req)
+			if errCreate != nil {
+				return nil, errCreate
+			}
+			s.alarms = append(s.alarms, obj)
+			return func() error { return i.CreateOrUpdate(obj, req) }, nil
+		case view.AlarmCodeActionUpdate:
+			req, errReq := s.alarmReq(step.alarm)
+			if errReq != nil {
+				return nil, errReq
+			}
+			obj, errUpdate := alarmUpdateRows(tx, uid, step.Id, req)
+			if errUpdate != nil {
+				return nil, errUpdate
+			}
+			return func() error { return i.CreateOrUpdate(&obj, req) }, nil
+		case view.AlarmCodeActionDelete:
+			alarmInfo, relatedList, errInfo := db.GetAlarmTableInstanceInfo(step.Id)
+			if errInfo != nil {
+				return nil, errInfo
+			}
+			if err = alarmDeleteRows(tx, step.Id); err != nil {
+				return nil, err
+			}
+			return func() error { return i.deleteViewsAndRules(&alarmInfo, relatedList) }, nil
+		}
+	case view.AlarmCodeKindSilence:
+		switch step.Action {
+		case view.AlarmCodeActionCreate:
+			obj, errSilence := s.silenceObj(step.silence)
+			if errSilence != nil {
+				return nil, errSilence
+			}
+			obj.Uid = uid
+			return nil, db.AlarmSilenceCreate(tx, obj)
+		case view.AlarmCodeActionDelete:
+			return nil, db.AlarmSilenceDelete(tx, step.Id)
+		}
+	}
+	return nil, nil
+}
+
+// alarmCodePlan channels are created first and deleted last, as they are referenced by alarms and alarms by silences
+func alarmCodePlan(code view.AlarmCode, prune bool) (res []*alarmCodeStep, s *alarmCodeState, err error) {
+	s, err = loadAlarmCodeState()
+	if err != nil {
+		return nil, nil, err
+	}
+	deletes := make([]*alarmCodeStep, 0)
+
+	// channels
+	fileChannels := make(map[string]struct{}, len(code.Channels))
+	for k := range code.Channels {
+		channel := &code.Channels[k]
+		if _, ok := fileChannels[channel.Name]; ok || channel.Name == "" {
+			return nil, nil, errors.Errorf("channel name %q is empty or duplicated", channel.Name)
+		}
+		fileChannels[channel.Name] = struct{}{}
+		cur, errCur := s.channel(channel.Name)
+		if errCur != nil {
+			return nil, nil, errCur
+		}
+		if err = unredactChannelCode(channel, cur); err != nil {
+			return nil, nil, errors.Wrapf(err, "channel %s", channel.Name)
+		}
+		obj := channelFromCode(channel)
+		if err = obj.JudgmentType(); err != nil {
+			return nil, nil, errors.Wrapf(err, "channel %s", channel.Name)
+		}
+		if err = pusher.TemplateValidate(obj.Template); err != nil {
+			return nil, nil, errors.Wrapf(err, "channel %s", channel.Name)
+		}
+		step := &alarmCodeStep{AlarmCodeChange: view.AlarmCodeChange{Kind: view.AlarmCodeKindChannel, Name: channel.Name}, channel: channel}
+		if cur == nil {
+			step.Action = view.AlarmCodeActionCreate
+			res = append(res, step)
+			continue
+		}
+		if step.Fields, err = alarmCodeDiff(channelCode(cur), channel); err != nil {
+			return nil, nil, err
+		}
+		if len(step.Fields) > 0 {
+			step.Action, step.Id = view.AlarmCodeActionUpdate, cur.ID
+			res = append(res, step)
+		}
+	}
+	if prune {
+		for _, channel := range s.channels {
+			if _, ok := fileChannels[channel.Name]; !ok {
+				deletes = append(deletes, &alarmCodeStep{AlarmCodeChange: view.AlarmCodeChange{Kind: view.AlarmCodeKindChannel, Name: channel.Name, Action: view.AlarmCodeActionDelete, Id: channel.ID}})
+			}
+		}
+	}
+
+	// alarms
+	fileAlarms := make(map[string]struct{}, len(code.Alarms))
+	for k := range code.Alarms {
+		alarm := &code.Alarms[k]
+		if _, ok := fileAlarms[alarm.Name]; ok || alarm.Name == "" {
+			return nil, nil, errors.Errorf("alarm name %q is empty or duplicated", alarm.Name)
+		}
+		fileAlarms[alarm.Name] = struct{}{}
+		normalizeAlarmCode(alarm)
+		if err = s.alarmValidate(alarm, fileChannels, prune); err != nil {
+			return nil, nil, errors.Wrapf(err, "alarm %s", alarm.Name)
+		}
+		cur, errCur := s.alarm(alarm.Name)
+		if errCur != nil {
+			return nil, nil, errCur
+		}
+		step := &alarmCodeStep{AlarmCodeChange: view.AlarmCodeChange{Kind: view.AlarmCodeKindAlarm, Name: alarm.Name}, alarm: alarm}
+		if cur == nil {
+			step.Action = view.AlarmCodeActionCreate
+			res = append(res, step)
+			continue
+		}
+		curCode, errCode := s.alarmCode(cur)
+		if errCode != nil {
+			return nil, nil, errors.Wrapf(errCode, "alarm %s", alarm.Name)
+		}
+		if step.Fields, err = alarmCodeDiff(curCode, alarm); err != nil {
+			return nil, nil, err
+		}
+		if len(step.Fields) > 0 {
+			step.Action, step.Id = view.AlarmCodeActionUpdate, cur.ID
+			res = append(res, step)
+		}
+	}
+	if prune {
+		alarmDeletes := make([]*alarmCodeStep, 0)
+		for _, alarm := range s.alarms {
+			if _, ok := fileAlarms[alarm.Name]; !ok {
+				alarmDeletes = append(alarmDeletes, &alarmCodeStep{AlarmCodeChange: view.AlarmCodeChange{Kind: view.AlarmCodeKindAlarm, Name: alarm.Name, Action: view.AlarmCodeActionDelete, Id: alarm.ID}})
+			}
+		}
+		deletes = append(alarmDeletes, deletes...)
+	}
+
+	// silences
+	existing := make(map[string][]*db.AlarmSilence)
+	for _, silence := range s.silences {
+		silenceCode, errCode := s.silenceCode(silence)
+		if errCode != nil {
+			return nil, nil, errors.Wrapf(errCode, "silence %d", silence.ID)
+		}
+		key, errKey := alarmCodeKey(silenceCode)
+		if errKey != nil {
+			return nil, nil, errKey
+		}
+		existing[key] = append(existing[key], silence)
+	}
+	for k := range code.Silences {
+		silence := &code.Silences[k]
+		if err = s.silenceValidate(silence, fileAlarms, prune); err != nil {
+			return nil, nil, errors.Wrapf(err, "silence %s", silenceCodeName(silence))
+		}
+		key, errKey := alarmCodeKey(silence)
+		if errKey != nil {
+			return nil, nil, errKey
+		}
+		if len(existing[key]) > 0 {
+			existing[key] = existing[key][1:]
+			continue
+		}
+		res = append(res, &alarmCodeStep{AlarmCodeChange: view.AlarmCodeChange{Kind: view.AlarmCodeKindSilence, Name: silenceCodeName(silence), Action: view.AlarmCodeActionCreate}, silence: silence})
+	}
+	if prune {
+		left := make(map[int]struct{})
+		for _, silences := range existing {
+			for _, silence := range silences {
+				left[silence.ID] = struct{}{}
+			}
+		}
+		silenceDeletes := make([]*alarmCodeStep, 0)
+		for _, silence := range s.silences {
+			if _, ok := left[silence.ID]; ok {
+				silenceDeletes = append(silenceDeletes, &alarmCodeStep{AlarmCodeChange: view.AlarmCodeChange{Kind: view.AlarmCodeKindSilence, Name: silenceName(silence), Action: view.AlarmCodeActionDelete, Id: silence.ID}})
+			}
+		}
+		deletes = append(silenceDeletes, deletes...)
+	}
+	return append(res, deletes...), s, nil
+}
+
+func loadAlarmCodeState() (s *alarmCodeState, err error) {
+	s = &alarmCodeState{
+		users:       make(map[int]string),
+		escalations: make(map[int]string),
+		instances:   make(map[int]string),
+		tables:      make(map[int]view.AlarmCodeFilter),
+		tableIds:    make(map[string]int),
+	}
+	if s.channels, err = db.AlarmChannelList(egorm.Conds{}); err != nil {
+		return nil, err
+	}
+	if s.alarms, err = db.AlarmList(egorm.Conds{}); err != nil {
+		return nil, err
+	}
+	silences, err := db.AlarmSilenceList(invoker.Db, egorm.Conds{})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	for _, silence := range silences {
+		if silence.EndTime == 0 || silence.EndTime > now {
+			s.silences = append(s.silences, silence)
+		}
+	}
+	users, err := db.UserList(egorm.Conds{})
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		s.users[user.ID] = user.Username
+	}
+	escalations, err := db.AlarmEscalationList(invoker.Db, egorm.Conds{})
+	if err != nil {
+		return nil, err
+	}
+	for _, escalation := range escalations {
+		s.escalations[escalation.ID] = escalation.Name
+	}
+	instances, err := db.InstanceList(egorm.Conds{})
+	if err != nil {
+		return nil, err
+	}
+	for _, instance := range instances {
+		s.instances[instance.ID] = instance.Name
+	}
+	sort.Slice(s.channels, func(i, j int) bool { return s.channels[i].Name < s.channels[j].Name })
+	sort.Slice(s.alarms, func(i, j int) bool { return s.alarms[i].Name < s.alarms[j].Name })
+	return s, nil
+}
+
+func (s *alarmCodeState) channel(name string) (res *db.AlarmChannel, err error) {
+	for _, channel := range s.channels {
+		if channel.Name != name {
+			continue
+		}
+		if res != nil {
+			return nil, errors.Errorf("several channels are named %s", name)
+		}
+		res = channel
+	}
+	return res, nil
+}
+
+func (s *alarmCodeState) alarm(name string) (res *db.Alarm, err error) {
+	for _, alarm := range s.alarms {
+		if alarm.Name != name {
+			continue
+		}
+		if res != nil {
+			return nil, errors.Errorf("several alarms are named %s", name)
+		}
+		res = alarm
+	}
+	return res, nil
+}
+
+// table names of the table, the instance name is required as database names are unique per instance
+func (s *alarmCodeState) table(tid int) (view.AlarmCodeFilter, error) {
+	if res, ok := s.tables[tid]; ok {
+		return res, nil
+	}
+	table, err := db.TableInfo(invoker.Db, tid)
+	if err != nil {
+		return view.AlarmCodeFilter{}, err
+	}
+	if table.Database == nil {
+		return view.AlarmCodeFilter{}, errors.Errorf("database of table %d not found", tid)
+	}
+	res := view.AlarmCodeFilter{Instance: s.instances[table.Database.Iid], Database: table.Database.Name, Table: table.Name}
+	s.tables[tid] = res
+	return res, nil
+}
+
+func (s *alarmCodeState) tableId(instance, database, table string) (int, error) {
+	key := instance + "|" + database + "|" + table
+	if tid, ok := s.tableIds[key]; ok {
+		return tid, nil
+	}
+	iid := 0
+	for id, name := range s.instances {
+		if name == instance {
+			iid = id
+		}
+	}
+	if iid == 0 {
+		return 0, errors.Errorf("instance %s not found", instance)
+	}
+	conds := egorm.Conds{}
+	conds["iid"] = iid
+	conds["name"] = database
+	databaseInfo, err := db.DatabaseInfoX(invoker.Db, conds)
+	if err != nil {
+		return 0, err
+	}
+	if databaseInfo.ID == 0 {
+		return 0, errors.Errorf("database %s of instance %s not found", database, instance)
+	}
+	conds = egorm.Conds{}
+	conds["did"] = databaseInfo.ID
+	conds["name"] = table
+	tableInfo, err := db.TableInfoX(invoker.Db, conds)
+	if err != nil {
+		return 0, err
+	}
+	if tableInfo.ID == 0 {
+		return 0, errors.Errorf("table %s.%s of instance %s not found", database, table, instance)
+	}
+	s.tableIds[key] = tableInfo.ID
+	return tableInfo.ID, nil
+}
+
+func (s *alarmCodeState) alarmCode(alarm *db.Alarm) (res view.AlarmCodeAlarm, err error) {
+	res = view.AlarmCodeAlarm{
+		Name:             alarm.Name,
+		Desc:             alarm.Desc,
+		Interval:         alarm.Interval,
+		Unit:             alarm.Unit,
+		Tags:             alarm.Tags,
+		NoDataOp:         alarm.NoDataOp,
+		Level:            alarm.Level,
+		IsDisableResolve: alarm.IsDisableResolve,
+		Channels:         make([]string, 0, len(alarm.ChannelIds)),
+		Escalation:       s.escalations[alarm.EscalationId],
+		Template:         alarm.Template,
+		NotifyLogs:       alarm.NotifyLogs,
+		NotifyTopField:   alarm.NotifyTopField,
+		NotifyChart:      alarm.NotifyChart,
+	}
+	for _, id := range alarm.ChannelIds {
+		for _, channel := range s.channels {
+			if channel.ID == id {
+				res.Channels = append(res.Channels, channel.Name)
+			}
+		}
+	}
+	for _, uid := range alarm.DutyOfficers {
+		if name, ok := s.users[uid]; ok {
+			res.DutyOfficers = append(res.DutyOfficers, name)
+		}
+	}
+	conds := egorm.Conds{}
+	conds["alarm_id"] = alarm.ID
+	filters, err := db.AlarmFilterList(invoker.Db, conds)
+	if err != nil {
+		return res, err
+	}
+	conditions, err := db.AlarmConditionList(conds)
+	if err != nil {
+		return res, err
+	}
+	for _, filter := range filters {
+		code, errTable := s.table(filter.Tid)
+		if errTable != nil {
+			return res, errTable
+		}
+		code.When, code.Typ, code.Exp, code.Mode = filter.When, filter.SetOperatorTyp, filter.SetOperatorExp, filter.Mode
+		if filter.Mode == db.AlarmModeTraceEdge {
+			code.When, code.TraceParent, code.TraceChild, code.TraceMetric = "", filter.TraceParent, filter.TraceChild, filter.TraceMetric
+		}
+		for _, condition := range conditions {
+			if condition.FilterId != filter.ID {
+				continue
+			}
+			code.Conditions = append(code.Conditions, view.AlarmCodeCondition{
+				Typ:     condition.SetOperatorTyp,
+				Exp:     condition.SetOperatorExp,
+				Cond:    condition.Cond,
+				Val1:    condition.Val1,
+				Val2:    condition.Val2,
+				CondTyp: condition.CondTyp,
+				Offset:  condition.Offset,
+				Periods: condition.Periods,
+				Factor:  condition.Factor,
+			})
+		}
+		res.Filters = append(res.Filters, code)
+	}
+	return res, nil
+}
+
+// alarmValidate references of the alarm must exist, with prune its channels must be in the file
+func (s *alarmCodeState) alarmValidate(alarm *view.AlarmCodeAlarm, fileChannels map[string]struct{}, prune bool) error {
+	if alarm.Interval <= 0 || len(alarm.Channels) == 0 || len(alarm.Filters) == 0 {
+		return errors.New("interval, channels and filters are required")
+	}
+	if err := pusher.TemplateValidate(alarm.Template); err != nil {
+		return err
+	}
+	if err := alarmNotifyValidate(view.ReqAlarmCreate{NotifyLogs: alarm.NotifyLogs, NotifyTopField: alarm.NotifyTopField}); err != nil {
+		return err
+	}
+	for _, name := range alarm.Channels {
+		if _, ok := fileChannels[name]; ok {
+			continue
+		}
+		cur, err := s.channel(name)
+		if err != nil {
+			return err
+		}
+		if cur == nil || prune {
+			return errors.Errorf("channel %s is not in the file", name)
+		}
+	}
+	_, err := s.alarmReq(alarm)
+	if err != nil && !errors.Is(err, errAlarmCodeChannel) {
+		return err
+	}
+	for k, f := range alarm.Filters {
+		conditions := make([]view.ReqAlarmConditionCreate, 0, len(f.Conditions))
+		for _, condition := range f.Conditions {
+			conditions = append(conditions, alarmConditionReq(condition))
+		}
+		if _, err = newAlarmConditions(0, 0, conditions); err != nil {
+			return errors.Wrapf(err, "filter %d", k)
+		}
+	}
+	return nil
+}
+
+var errAlarmCodeChannel = errors.New("channel not found")
+
+// alarmReq request of the alarm with the ids of its references, the channels created by the plan included
+func (s *alarmCodeState) alarmReq(alarm *view.AlarmCodeAlarm) (res view.ReqAlarmCreate, err error) {
+	res = view.ReqAlarmCreate{
+		Name:             alarm.Name,
+		Desc:             alarm.Desc,
+		Interval:         alarm.Interval,
+		Unit:             alarm.Unit,
+		Tags:             alarm.Tags,
+		NoDataOp:         alarm.NoDataOp,
+		Level:            alarm.Level,
+		IsDisableResolve: alarm.IsDisableResolve,
+		Template:         alarm.Template,
+		NotifyLogs:       alarm.NotifyLogs,
+		NotifyTopField:   alarm.NotifyTopField,
+		NotifyChart:      alarm.NotifyChart,
+	}
+	for _, name := range alarm.DutyOfficers {
+		uid := 0
+		for id, username := range s.users {
+			if username == name {
+				uid = id
+			}
+		}
+		if uid == 0 {
+			return res, errors.Errorf("user %s not found", name)
+		}
+		res.DutyOfficers = append(res.DutyOfficers, uid)
+	}
+	if alarm.Escalation != "" {
+		for id, name := range s.escalations {
+			if name == alarm.Escalation {
+				res.EscalationId = id
+			}
+		}
+		if res.EscalationId == 0 {
+			return res, errors.Errorf("escalation policy %s not found", alarm.Escalation)
+		}
+	}
+	for _, f := range alarm.Filters {
+		tid, errTable := s.tableId(f.Instance, f.Database, f.Table)
+		if errTable != nil {
+			return res, errTable
+		}
+		filter := view.ReqAlarmFilterCreate{Tid: tid, When: f.When, SetOperatorTyp: f.Typ, SetOperatorExp: f.Exp, Mode: f.Mode,
+			TraceParent: f.TraceParent, TraceChild: f.TraceChild, TraceMetric: f.TraceMetric}
+		for _, condition := range f.Conditions {
+			filter.Conditions = append(filter.Conditions, alarmConditionReq(condition))
+		}
+		res.Filters = append(res.Filters, filter)
+	}
+	for _, name := range alarm.Channels {
+		channel, errChannel := s.channel(name)
+		if errChannel != nil {
+			return res, errChannel
+		}
+		if channel == nil {
+			return res, errors.Wrap(errAlarmCodeChannel, name)
+		}
+		res.ChannelIds = append(res.ChannelIds, channel.ID)
+	}
+	return res, nil
+}
+
+func (s *alarmCodeState) silenceCode(silence *db.AlarmSilence) (res view.AlarmCodeSilence, err error) {
+	res = view.AlarmCodeSilence{
+		Matchers:  silence.Matchers,
+		StartTime: silence.StartTime,
+		EndTime:   silence.EndTime,
+		Cron:      silence.Cron,
+		Duration:  silence.Duration,
+		Reason:    silence.Reason,
+	}
+	if silence.AlarmId != 0 {
+		for _, alarm := range s.alarms {
+			if alarm.ID == silence.AlarmId {
+				res.Alarm = alarm.Name
+			}
+		}
+		if res.Alarm == "" {
+			return res, errors.Errorf("alarm %d not found", silence.AlarmId)
+		}
+	}
+	if silence.Tid != 0 {
+		table, errTable := s.table(silence.Tid)
+		if errTable != nil {
+			return res, errTable
+		}
+		res.Instance, res.Database, res.Table = table.Instance, table.Database, table.Table
+	} else if silence.Iid != 0 {
+		res.Instance = s.instances[silence.Iid]
+	}
+	return res, nil
+}
+
+// silenceValidate references of the silence must exist, with prune its alarm must be in the file
+func (s *alarmCodeState) silenceValidate(silence *view.AlarmCodeSilence, fileAlarms map[string]struct{}, prune bool) error {
+	if silence.StartTime == 0 {
+		return errors.New("start time is required")
+	}
+	if silence.Alarm != "" {
+		if _, ok := fileAlarms[silence.Alarm]; !ok {
+			cur, err := s.alarm(silence.Alarm)
+			if err != nil {
+				return err
+			}
+			if cur == nil || prune {
+				return errors.Errorf("alarm %s is not in the file", silence.Alarm)
+			}
+		}
+	}
+	req := view.ReqAlarmSilenceCreate{
+		Matchers:  silence.Matchers,
+		StartTime: silence.StartTime,
+		EndTime:   silence.EndTime,
+		Cron:      silence.Cron,
+		Duration:  silence.Duration,
+		Reason:    silence.Reason,
+	}
+	if silence.Alarm != "" {
+		// the alarm may be created by the plan
+		req.AlarmId = -1
+	}
+	if silence.Table != "" {
+		tid, err := s.tableId(silence.Instance, silence.Database, silence.Table)
+		if err != nil {
+			return err
+		}
+		req.Tid = tid
+	} else if silence.Instance != "" {
+		iid, err := s.instanceId(silence.Instance)
+		if err != nil {
+			return err
+		}
+		req.Iid = iid
+	}
+	return SilenceValidate(req)
+}
+
+func (s *alarmCodeState) silenceObj(silence *view.AlarmCodeSilence) (res *db.AlarmSilence, err error) {
+	res = &db.AlarmSilence{
+		Matchers:  silence.Matchers,
+		StartTime: silence.StartTime,
+		EndTime:   silence.EndTime,
+		Cron:      silence.Cron,
+		Duration:  silence.Duration,
+		Reason:    silence.Reason,
+	}
+	if silence.Alarm != "" {
+		alarm, errAlarm := s.alarm(silence.Alarm)
+		if errAlarm != nil {
+			return nil, errAlarm
+		}
+		if alarm == nil {
+			return nil, errors.Errorf("alarm %s not found", silence.Alarm)
+		}
+		res.AlarmId = alarm.ID
+	}
+	if silence.Table != "" {
+		if res.Tid, err = s.tableId(silence.Instance, silence.Database, silence.Table); err != nil {
+			return nil, err
+		}
+	} else if silence.Instance != "" {
+		if res.Iid, err = s.instanceId(silence.Instance); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (s *alarmCodeState) instanceId(name string) (int, error) {
+	for id, instance := range s.instances {
+		if instance == name {
+			return id, nil
+		}
+	}
+	return 0, errors.Errorf("instance %s not found", name)
+}
+
+func channelCode(channel *db.AlarmChannel) view.AlarmCodeChannel {
+	return view.AlarmCodeChannel{
+		Name:           channel.Name,
+		Typ:            channel.Typ,
+		Key:            channel.Key,
+		GroupBy:        channel.GroupBy,
+		GroupWait:      channel.GroupWait,
+		GroupInterval:  channel.GroupInterval,
+		RepeatInterval: channel.RepeatInterval,
+		RateLimit:      channel.RateLimit,
+		Template:       channel.Template,
+		ChatOps:        channel.ChatOps,
+		CallbackSecret: channel.CallbackSecret,
+	}
+}
+
+// redactChannelCode the key holds the webhook token or the credentials of the channel
+func redactChannelCode(channel view.AlarmCodeChannel) view.AlarmCodeChannel {
+	if channel.Key != "" {
+		channel.Key = view.AlarmCodeRedacted
+	}
+	if channel.CallbackSecret != "" {
+		channel.CallbackSecret = view.AlarmCodeRedacted
+	}
+	return channel
+}
+
+// unredactChannelCode redacted values of the file are the ones of the current channel
+func unredactChannelCode(channel *view.AlarmCodeChannel, cur *db.AlarmChannel) error {
+	if channel.Key != view.AlarmCodeRedacted && channel.CallbackSecret != view.AlarmCodeRedacted {
+		return nil
+	}
+	if cur == nil {
+		return errors.New("the key and callback secret of a new channel can not be redacted")
+	}
+	if channel.Key == view.AlarmCodeRedacted {
+		channel.Key = cur.Key
+	}
+	if channel.CallbackSecret == view.AlarmCodeRedacted {
+		channel.CallbackSecret = cur.CallbackSecret
+	}
+	return nil
+}
+
+func channelFromCode(channel *view.AlarmCodeChannel) *db.AlarmChannel {
+	return &db.AlarmChannel{
+		Name:           channel.Name,
+		Typ:            channel.Typ,
+		Key:            channel.Key,
+		GroupBy:        channel.GroupBy,
+		GroupWait:      channel.GroupWait,
+		GroupInterval:  channel.GroupInterval,
+		RepeatInterval: channel.RepeatInterval,
+		RateLimit:      channel.RateLimit,
+		Template:       channel.Template,
+		ChatOps:        channel.ChatOps,
+		CallbackSecret: channel.CallbackSecret,
+	}
+}
+
+func alarmConditionReq(condition view.AlarmCodeCondition) view.ReqAlarmConditionCreate {
+	return view.ReqAlarmConditionCreate{
+		SetOperatorTyp: condition.Typ,
+		SetOperatorExp: condition.Exp,
+		Cond:           condition.Cond,
+		Val1:           condition.Val1,
+		Val2:           condition.Val2,
+		CondTyp:        condition.CondTyp,
+		Offset:         condition.Offset,
+		Periods:        condition.Periods,
+		Factor:         condition.Factor,
+	}
+}
+
+// normalizeAlarmCode defaults applied when the alarm is stored, so that an applied file has no diff
+func normalizeAlarmCode(alarm *view.AlarmCodeAlarm) {
+	for k := range alarm.Filters {
+		if alarm.Filters[k].Mode == db.AlarmModeTraceEdge {
+			// generated from the edge
+			alarm.Filters[k].When = ""
+		} else if alarm.Filters[k].When == "" {
+			alarm.Filters[k].When = "1=1"
+		}
+		conditions := alarm.Filters[k].Conditions
+		sort.SliceStable(conditions, func(i, j int) bool {
+			return conditions[i].Typ < conditions[j].Typ
+		})
+	}
+}
+
+func silenceCodeName(silence *view.AlarmCodeSilence) string {
+	for _, name := range []string{silence.Reason, silence.Alarm, silence.Table, silence.Instance} {
+		if name != "" {
+			return name
+		}
+	}
+	return "silence"
+}
+
+func silenceName(silence *db.AlarmSilence) string {
+	if silence.Reason != "" {
+		return silence.Reason
+	}
+	return fmt.Sprintf("silence %d", silence.ID)
+}
+
+// alarmCodeKey yaml of the object, equal objects have the same key
+func alarmCodeKey(obj interface{}) (string, error) {
+	out, err := yaml.Marshal(obj)
+	return string(out), err
+}
+
+// alarmCodeDiff top level fields of the yaml which differ
+func alarmCodeDiff(cur, desired interface{}) ([]string, error) {
+	toMap := func(obj interface{}) (map[string]interface{}, error) {
+		res := make(map[string]interface{})
+		out, err := yaml.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		return res, yaml.Unmarshal(out, &res)
+	}
+	a, err := toMap(cur)
+	if err != nil {
+		return nil, err
+	}
+	b, err := toMap(desired)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0)
+	for k, v := range a {
+		if !reflect.DeepEqual(v, b[k]) {
+			res = append(res, k)
+		}
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			res = append(res, k)
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
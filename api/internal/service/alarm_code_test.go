@@ -0,0 +1,152 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+)
+
+func TestParseAlarmCode(t *testing.T) {
+	code, err := ParseAlarmCode(`
+channels:
+  - name: ops
+    typ: 1
+    key: https://example.com/hook
+alarms:
+  - name: errors
+    interval: 1
+    channels: [ops]
+    filters:
+      - instance: default
+        database: logs
+        table: app
+        when: level='error'
+        conditions:
+          - exp: 4
+            val1: 10
+`)
+	assert.NoError(t, err)
+	assert.Equal(t, "ops", code.Channels[0].Name)
+	assert.Equal(t, "app", code.Alarms[0].Filters[0].Table)
+	assert.Equal(t, 10, code.Alarms[0].Filters[0].Conditions[0].Val1)
+
+	_, err = ParseAlarmCode("alarms:\n  - name: errors\n    unknown: 1\n")
+	assert.Error(t, err)
+
+	code, err = ParseAlarmCode("")
+	assert.NoError(t, err)
+	assert.Empty(t, code.Alarms)
+}
+
+func Test_alarmCodeDiff(t *testing.T) {
+	cur := view.AlarmCodeAlarm{Name: "errors", Interval: 1, Channels: []string{"ops"}, Filters: []view.AlarmCodeFilter{{Table: "app", When: "1=1"}}}
+	desired := view.AlarmCodeAlarm{Name: "errors", Interval: 1, Channels: []string{"ops"}, Filters: []view.AlarmCodeFilter{{Table: "app"}}}
+	normalizeAlarmCode(&desired)
+	fields, err := alarmCodeDiff(cur, desired)
+	assert.NoError(t, err)
+	assert.Empty(t, fields)
+
+	desired.Interval = 5
+	desired.Desc = "error logs"
+	desired.Filters[0].Conditions = []view.AlarmCodeCondition{{Typ: 1}, {Typ: 0, Val1: 3}}
+	normalizeAlarmCode(&desired)
+	assert.Equal(t, 3, desired.Filters[0].Conditions[0].Val1)
+	fields, err = alarmCodeDiff(cur, desired)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"desc", "filters", "interval"}, fields)
+}
+
+func Test_alarmCodeKey(t *testing.T) {
+	a, err := alarmCodeKey(view.AlarmCodeSilence{Alarm: "errors", StartTime: 1, EndTime: 2, Matchers: map[string]string{"a": "1", "b": "2"}})
+	assert.NoError(t, err)
+	b, err := alarmCodeKey(view.AlarmCodeSilence{Matchers: map[string]string{"b": "2", "a": "1"}, Alarm: "errors", StartTime: 1, EndTime: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, a, b)
+	c, err := alarmCodeKey(view.AlarmCodeSilence{Alarm: "errors", StartTime: 1, EndTime: 3})
+	assert.NoError(t, err)
+	assert.NotEqual(t, a, c)
+}
+
+func Test_redactChannelCode(t *testing.T) {
+	cur := &db.AlarmChannel{Name: "ops", Key: "https://example.com/hook?token=1", CallbackSecret: "secret"}
+	code := redactChannelCode(channelCode(cur))
+	assert.Equal(t, view.AlarmCodeRedacted, code.Key)
+	assert.Equal(t, view.AlarmCodeRedacted, code.CallbackSecret)
+	assert.Empty(t, redactChannelCode(view.AlarmCodeChannel{Name: "ops"}).CallbackSecret)
+
+	assert.NoError(t, unredactChannelCode(&code, cur))
+	assert.Equal(t, cur.Key, code.Key)
+	assert.Equal(t, cur.CallbackSecret, code.CallbackSecret)
+
+	code = view.AlarmCodeChannel{Name: "ops", Key: "https://example.com/hook?token=2", CallbackSecret: view.AlarmCodeRedacted}
+	assert.NoError(t, unredactChannelCode(&code, cur))
+	assert.Equal(t, "https://example.com/hook?token=2", code.Key)
+	assert.Equal(t, "secret", code.CallbackSecret)
+
+	code = view.AlarmCodeChannel{Name: "new", Key: view.AlarmCodeRedacted}
+	assert.Error(t, unredactChannelCode(&code, nil))
+}
+
+func Test_alarmCodeApplySteps(t *testing.T) {
+	gdb, err := gorm.Open(sqlite.Open("file:alarmcode?mode=memory&cache=shared"), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, gdb.AutoMigrate(&db.AlarmChannel{}, &db.AlarmSilence{}))
+	dbBefore := invoker.Db
+	invoker.Db = gdb
+	defer func() { invoker.Db = dbBefore }()
+	i := &alert{}
+	newState := func() *alarmCodeState {
+		return &alarmCodeState{instances: map[int]string{}, tables: map[int]view.AlarmCodeFilter{}, tableIds: map[string]int{}}
+	}
+	channelStep := func(action, name string, id int) *alarmCodeStep {
+		return &alarmCodeStep{
+			AlarmCodeChange: view.AlarmCodeChange{Kind: view.AlarmCodeKindChannel, Action: action, Name: name, Id: id},
+			channel:         &view.AlarmCodeChannel{Name: name, Typ: db.ChannelDingDing, Key: "https://example.com/" + name},
+		}
+	}
+	silenceStep := func(alarm string) *alarmCodeStep {
+		silence := &view.AlarmCodeSilence{Alarm: alarm, StartTime: 1, Reason: "upgrade"}
+		return &alarmCodeStep{AlarmCodeChange: view.AlarmCodeChange{Kind: view.AlarmCodeKindSilence, Action: view.AlarmCodeActionCreate, Name: silenceCodeName(silence)}, silence: silence}
+	}
+
+	res, err := i.alarmCodeApplySteps(1, newState(), []*alarmCodeStep{channelStep(view.AlarmCodeActionCreate, "ops", 0), silenceStep("")})
+	assert.NoError(t, err)
+	assert.Len(t, res, 2)
+	channels, err := db.AlarmChannelList(nil)
+	assert.NoError(t, err)
+	assert.Len(t, channels, 1)
+	assert.Equal(t, 1, channels[0].Uid)
+	silence := db.AlarmSilence{}
+	assert.NoError(t, gdb.Select("id").First(&silence).Error)
+
+	// the channel created before the failed step is rolled back
+	res, err = i.alarmCodeApplySteps(1, newState(), []*alarmCodeStep{
+		channelStep(view.AlarmCodeActionUpdate, "ops-renamed", channels[0].ID),
+		channelStep(view.AlarmCodeActionCreate, "dev", 0),
+		silenceStep("missing"),
+	})
+	assert.Error(t, err)
+	assert.Nil(t, res)
+	channels, err = db.AlarmChannelList(nil)
+	assert.NoError(t, err)
+	assert.Len(t, channels, 1)
+	assert.Equal(t, "ops", channels[0].Name)
+
+	_, err = i.alarmCodeApplySteps(1, newState(), []*alarmCodeStep{
+		{AlarmCodeChange: view.AlarmCodeChange{Kind: view.AlarmCodeKindSilence, Action: view.AlarmCodeActionDelete, Id: silence.ID}},
+		channelStep(view.AlarmCodeActionDelete, "ops", channels[0].ID),
+	})
+	assert.NoError(t, err)
+	channels, err = db.AlarmChannelList(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, channels)
+	var silences int64
+	assert.NoError(t, gdb.Model(&db.AlarmSilence{}).Count(&silences).Error)
+	assert.Zero(t, silences)
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gotomicro/cetus/pkg/xgo"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/pkg/errors"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service/alarm/pusher"
+	"github.com/clickvisual/clickvisual/api/internal/service/inquiry/clickhouse"
+	"github.com/clickvisual/clickvisual/api/internal/service/inquiry/factory"
+)
+
+const (
+	// alarmNotifyTopValues top values of the field in the messages
+	alarmNotifyTopValues = 5
+	// alarmNotifyChartIntervals alarm intervals spanned by the chart, so the alert is seen against what came before
+	alarmNotifyChartIntervals = 30
+	alarmNotifyChartMinRange  = 30 * time.Minute
+	alarmNotifyChartMaxRange  = 24 * time.Hour
+	// alarmNotifyDetailTimeout queries of the detail run while the alert webhook waits
+	alarmNotifyDetailTimeout = 5 * time.Second
+)
+
+// alarmNotifyValidate the top field is quoted in the sql of the top values
+func alarmNotifyValidate(req view.ReqAlarmCreate) error {
+	if req.NotifyLogs < 0 || req.NotifyLogs > db.AlarmNotifyLogsMax {
+		return errors.Errorf("notifyLogs must be between 0 and %d", db.AlarmNotifyLogsMax)
+	}
+	if strings.ContainsAny(req.NotifyTopField, "`\\") {
+		return errors.Errorf("invalid notifyTopField: %s", req.NotifyTopField)
+	}
+	return nil
+}
+
+// notifyDetailTimeout the detail of the notification, the notification is sent without it when its queries
+// take longer than app.alarmNotifyDetailTimeout
+func (i *alert) notifyDetailTimeout(op factory.Operator, table *db.BaseTable, alarm *db.Alarm, filter *db.AlarmFilter) *pusher.Detail {
+	timeout := econf.GetDuration("app.alarmNotifyDetailTimeout")
+	if timeout <= 0 {
+		timeout = alarmNotifyDetailTimeout
+	}
+	resC := make(chan *pusher.Detail, 1)
+	xgo.Go(func() {
+		resC <- i.notifyDetail(op, table, alarm, filter)
+	})
+	select {
+	case res := <-resC:
+		return res
+	case <-time.After(timeout):
+		elog.Warn("notifyDetail", elog.String("step", "timeout"), elog.Int("alarmId", alarm.ID), elog.Duration("timeout", timeout))
+		return &pusher.Detail{}
+	}
+}
+
+// notifyDetail samples of the alert window added to the messages: the matching logs,
+// the top values of the notifyTopField and the chart of the matching logs when notifyChart is on.
+// Aggregation filters are sql queries, only their rows are sampled.
+func (i *alert) notifyDetail(op factory.Operator, table *db.BaseTable, alarm *db.Alarm, filter *db.AlarmFilter) *pusher.Detail {
+	res := &pusher.Detail{}
+	now := time.Now()
+	base := view.ReqQuery{
+		Tid:           table.ID,
+		Database:      table.Database.Name,
+		Table:         table.Name,
+		Query:         filter.When,
+		AlarmMode:     filter.Mode,
+		TimeField:     table.TimeField,
+		TimeFieldType: table.TimeFieldType,
+		ST:            now.Add(-alarm.GetInterval() - time.Minute).Unix(),
+		ET:            now.Add(time.Minute).Unix(),
+		Page:          1,
+		PageSize:      uint32(alarm.GetNotifyLogs()),
+	}
+	param, err := op.Prepare(base, table, false)
+	if err != nil {
+		elog.Error("notifyDetail", elog.String("step", "prepare"), elog.Int("alarmId", alarm.ID), elog.FieldErr(err))
+		return res
+	}
+	resp, _ := op.GetLogs(param, table.ID)
+	for _, log := range resp.Logs {
+		l, _ := json.Marshal(log)
+		res.Logs = append(res.Logs, string(l))
+	}
+	if filter.IsAggregation() {
+		return res
+	}
+	if alarm.NotifyTopField != "" {
+		param.Field = alarm.NotifyTopField
+		res.TopField = alarm.NotifyTopField
+		res.TopValues = pusher.TopValues(op.GroupBy(param), alarmNotifyTopValues)
+	}
+	if alarm.NotifyChart == 1 {
+		if err = i.notifyChart(op, table, alarm, base, now, res); err != nil {
+			elog.Error("notifyDetail", elog.String("step", "chart"), elog.Int("alarmId", alarm.ID), elog.FieldErr(err))
+		}
+	}
+	return res
+}
+
+// notifyChart renders the counts of the matching logs before the alert and stores the image for its link
+func (i *alert) notifyChart(op factory.Operator, table *db.BaseTable, alarm *db.Alarm, param view.ReqQuery, now time.Time, res *pusher.Detail) (err error) {
+	param.ST, param.ET = notifyChartRange(alarm, now)
+	if param, err = op.Prepare(param, table, false); err != nil {
+		return err
+	}
+	param.GroupByCond, param.Interval = op.CalculateInterval(param.ET-param.ST, clickhouse.TransferGroupTimeField(param.TimeField, table.TimeFieldType))
+	charts, _, err := op.Chart(param)
+	if err != nil {
+		return err
+	}
+	image, err := pusher.Sparkline(notifyChartValues(charts, param.ST, param.ET, param.Interval), pusher.SparklineWidth, pusher.SparklineHeight)
+	if err != nil {
+		return err
+	}
+	res.Chart = image
+	res.ChartURL, err = alarmChartSave(alarm.ID, image)
+	return err
+}
+
+// notifyChartRange alarmNotifyChartIntervals intervals of the alarm before now, within the min and max ranges
+func notifyChartRange(alarm *db.Alarm, now time.Time) (st, et int64) {
+	window := alarm.GetInterval() * alarmNotifyChartIntervals
+	if window < alarmNotifyChartMinRange {
+		window = alarmNotifyChartMinRange
+	}
+	if window > alarmNotifyChartMaxRange {
+		window = alarmNotifyChartMaxRange
+	}
+	return now.Add(-window).Unix(), now.Unix()
+}
+
+// notifyChartValues counts per bucket of step seconds between st and et, the buckets without logs are 0
+func notifyChartValues(charts []*view.HighChart, st, et, step int64) []float64 {
+	if step <= 0 || et < st {
+		return nil
+	}
+	start := st - st%step
+	res := make([]float64, (et-start)/step+1)
+	for _, chart := range charts {
+		k := (chart.From - start) / step
+		if chart.From < start || k >= int64(len(res)) {
+			continue
+		}
+		res[k] += float64(chart.Count)
+	}
+	return res
+}
+
+// alarmChartSave stores the chart for the channels which reference images by url, the expired charts are removed
+func alarmChartSave(alarmId int, image []byte) (string, error) {
+	chart := &db.AlarmChart{Token: strings.ReplaceAll(uuid.NewString(), "-", ""), AlarmId: alarmId, Image: image}
+	if err := db.AlarmChartCreate(invoker.Db, chart); err != nil {
+		return "", err
+	}
+	retention := econf.GetDuration("app.alarmChartRetention")
+	if retention <= 0 {
+		retention = 7 * 24 * time.Hour
+	}
+	if err := db.AlarmChartDeleteBefore(invoker.Db, time.Now().Add(-retention).Unix()); err != nil {
+		elog.Error("alarmChartSave", elog.FieldErr(err))
+	}
+	return fmt.Sprintf("%s/api/v1/alert/charts/%s", strings.TrimRight(econf.GetString("app.rootURL"), "/"), chart.Token), nil
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service/inquiry/factory"
+)
+
+func Test_alarmNotifyValidate(t *testing.T) {
+	assert.NoError(t, alarmNotifyValidate(view.ReqAlarmCreate{NotifyLogs: 5, NotifyTopField: "_raw_log_.host"}))
+	assert.Error(t, alarmNotifyValidate(view.ReqAlarmCreate{NotifyLogs: -1}))
+	assert.Error(t, alarmNotifyValidate(view.ReqAlarmCreate{NotifyLogs: db.AlarmNotifyLogsMax + 1}))
+	assert.Error(t, alarmNotifyValidate(view.ReqAlarmCreate{NotifyTopField: "host` FROM x --"}))
+}
+
+func Test_notifyChartRange(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	st, et := notifyChartRange(&db.Alarm{Interval: 1, Unit: 0}, now)
+	assert.Equal(t, now.Unix(), et)
+	assert.Equal(t, int64(1800), et-st)
+	st, _ = notifyChartRange(&db.Alarm{Interval: 5, Unit: 0}, now)
+	assert.Equal(t, int64(9000), et-st)
+	st, _ = notifyChartRange(&db.Alarm{Interval: 1, Unit: 3}, now)
+	assert.Equal(t, int64(86400), et-st)
+}
+
+func Test_notifyChartValues(t *testing.T) {
+	charts := []*view.HighChart{{From: 60, Count: 3}, {From: 180, Count: 5}, {From: 600, Count: 9}, {From: 0, Count: 1}}
+	assert.Equal(t, []float64{3, 0, 5, 0}, notifyChartValues(charts, 90, 240, 60))
+	assert.Nil(t, notifyChartValues(charts, 0, 240, 0))
+	assert.Equal(t, []float64{1, 3}, notifyChartValues(charts, 0, 60, 60))
+}
+
+// notifyTestOp fails or blocks Prepare, the other queries of the detail panic on the nil operator
+type notifyTestOp struct {
+	factory.Operator
+	wait time.Duration
+}
+
+func (o notifyTestOp) Prepare(view.ReqQuery, *db.BaseTable, bool) (view.ReqQuery, error) {
+	time.Sleep(o.wait)
+	return view.ReqQuery{}, errors.New("prepare failed")
+}
+
+func Test_notifyDetailTimeout(t *testing.T) {
+	table := &db.BaseTable{Database: &db.BaseDatabase{}}
+	alarm := &db.Alarm{Interval: 1, NotifyTopField: "host", NotifyChart: 1}
+	res := (&alert{}).notifyDetailTimeout(notifyTestOp{}, table, alarm, &db.AlarmFilter{})
+	assert.Empty(t, res.Logs)
+	assert.Empty(t, res.TopValues)
+
+	econf.Set("app.alarmNotifyDetailTimeout", "10ms")
+	defer econf.Set("app.alarmNotifyDetailTimeout", "")
+	start := time.Now()
+	res = (&alert{}).notifyDetailTimeout(notifyTestOp{wait: time.Second}, table, alarm, &db.AlarmFilter{})
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+	assert.Empty(t, res.Logs)
+}
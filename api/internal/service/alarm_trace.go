@@ -0,0 +1,62 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service/storage/storageworker"
+)
+
+// traceEdgeMinWindow the dependencies are written by each run of the trace worker,
+// the window spans two runs so that it always holds the rows of a complete run
+const traceEdgeMinWindow = 2 * storageworker.TraceInterval
+
+var traceEdgeEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// traceEdgeFilter the when of the trace edge filter is the aggregation sql of the metric of the edge,
+// in the jaeger dependencies of the table, over the interval of the alarm
+func traceEdgeFilter(alarm *db.Alarm, obj *db.AlarmFilter, req view.ReqAlarmFilterCreate) error {
+	if req.TraceParent == "" || req.TraceChild == "" {
+		return errors.New("traceParent and traceChild are required by trace edge filters")
+	}
+	table, err := db.TableInfo(invoker.Db, req.Tid)
+	if err != nil {
+		return err
+	}
+	if table.V3TableType != db.V3TableTypeJaegerJSON {
+		return errors.Errorf("table %s is not a jaeger json table and has no dependencies", table.Name)
+	}
+	when, err := traceEdgeSQL(table.Database.Name, table.Name+db.SuffixJaegerJSON, req.TraceParent, req.TraceChild, req.TraceMetric, alarm.GetInterval())
+	if err != nil {
+		return err
+	}
+	obj.When, obj.TraceParent, obj.TraceChild, obj.TraceMetric = when, req.TraceParent, req.TraceChild, req.TraceMetric
+	return nil
+}
+
+// traceEdgeSQL val is the metric of the edge over the window, no row without calls except for the call count
+func traceEdgeSQL(database, table, parent, child, metric string, window time.Duration) (string, error) {
+	var val, having string
+	switch metric {
+	case db.TraceMetricCallCount:
+		val = "toFloat64(sum(call_count))"
+	case db.TraceMetricP99:
+		// durations are in nanoseconds
+		val, having = "max(server_duration_p99) / 1000000", " HAVING count() > 0"
+	case db.TraceMetricSuccessRate:
+		val, having = "sum(server_success_rate * call_count) / sum(call_count) * 100", " HAVING sum(call_count) > 0"
+	default:
+		return "", errors.Errorf("invalid traceMetric %q, one of %s, %s or %s", metric, db.TraceMetricCallCount, db.TraceMetricP99, db.TraceMetricSuccessRate)
+	}
+	if window < traceEdgeMinWindow {
+		window = traceEdgeMinWindow
+	}
+	return fmt.Sprintf("SELECT %s AS val FROM `%s`.`%s` WHERE parent = '%s' AND child = '%s' AND timestamp >= now() - INTERVAL %d SECOND%s",
+		val, database, table, traceEdgeEscaper.Replace(parent), traceEdgeEscaper.Replace(child), int64(window.Seconds()), having), nil
+}
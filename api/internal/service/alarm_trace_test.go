@@ -0,0 +1,62 @@
+package service
+
+import (
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/service/storage/storageworker"
+)
+
+var traceEdgeWindowReg = regexp.MustCompile(`timestamp >= now\(\) - INTERVAL (\d+) SECOND`)
+
+// traceEdgeWindow seconds of the window of the sql
+func traceEdgeWindow(t *testing.T, sql string) time.Duration {
+	match := traceEdgeWindowReg.FindStringSubmatch(sql)
+	if !assert.Len(t, match, 2, sql) {
+		return 0
+	}
+	seconds, err := strconv.Atoi(match[1])
+	assert.NoError(t, err)
+	return time.Duration(seconds) * time.Second
+}
+
+func Test_traceEdgeSQL(t *testing.T) {
+	for _, tt := range []struct {
+		interval time.Duration
+		window   time.Duration
+	}{
+		{interval: 10 * time.Second, window: 20 * time.Minute},
+		{interval: 5 * time.Minute, window: 20 * time.Minute},
+		{interval: time.Hour, window: time.Hour},
+	} {
+		sql, err := traceEdgeSQL("trace", "spans_jaeger_dependencies", "gateway", "user", db.TraceMetricCallCount, tt.interval)
+		assert.NoError(t, err)
+		window := traceEdgeWindow(t, sql)
+		assert.Equal(t, tt.window, window)
+		// a window always holds the rows of a complete run of the trace worker
+		assert.GreaterOrEqual(t, window, 2*storageworker.TraceInterval)
+	}
+
+	sql, err := traceEdgeSQL("trace", "spans_jaeger_dependencies", "gateway", "user", db.TraceMetricCallCount, time.Hour)
+	assert.NoError(t, err)
+	assert.Contains(t, sql, "SELECT toFloat64(sum(call_count)) AS val FROM `trace`.`spans_jaeger_dependencies`")
+	assert.NotContains(t, sql, "HAVING")
+
+	sql, err = traceEdgeSQL("trace", "spans_jaeger_dependencies", "gateway", "user", db.TraceMetricP99, time.Hour)
+	assert.NoError(t, err)
+	assert.Contains(t, sql, "max(server_duration_p99) / 1000000 AS val")
+	assert.Contains(t, sql, "HAVING count() > 0")
+
+	sql, err = traceEdgeSQL("trace", "spans_jaeger_dependencies", "a'b", `c\`, db.TraceMetricSuccessRate, time.Minute)
+	assert.NoError(t, err)
+	assert.Contains(t, sql, `WHERE parent = 'a\'b' AND child = 'c\\'`)
+	assert.Contains(t, sql, "HAVING sum(call_count) > 0")
+
+	_, err = traceEdgeSQL("trace", "spans_jaeger_dependencies", "a", "b", "p50", time.Minute)
+	assert.Error(t, err)
+}
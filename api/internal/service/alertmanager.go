@@ -1,10 +1,10 @@
 package service
 
 import (
-	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ego-component/egorm"
@@ -16,7 +16,6 @@ import (
 	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
 	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
 	"github.com/clickvisual/clickvisual/api/internal/service/alarm/pusher"
-	"github.com/clickvisual/clickvisual/api/internal/service/inquiry/factory"
 )
 
 // HandlerAlertManager Processing Alarms
@@ -39,23 +38,21 @@ func (i *alert) HandlerAlertManager(alarmUUID string, filterIdStr string, notifi
 		return
 	}
 	notificationStatus := notification.GetStatus() // 当前需要推送的状态
-	if alarm.IsDisableResolve == 1 && notificationStatus == db.AlarmStatusNormal {
-		log.Warn("AlarmIsDisableResolve", l.A("alarm", alarm))
-		tx.Commit()
-		return
-	}
 	// create history
 	filterId, err := strconv.Atoi(filterIdStr)
 	if err != nil {
 		tx.Rollback()
 		return fmt.Errorf("strconv.Atoi %s, error: %w", filterIdStr, err)
 	}
+	currentFiltersStatus := alarm.GetStatus(tx)
 	alarmHistory := db.AlarmHistory{AlarmId: alarm.ID, FilterId: filterId, FilterStatus: notificationStatus, IsPushed: db.PushedStatusRepeat}
+	if notificationStatus == db.AlarmStatusFiring && currentFiltersStatus != db.AlarmStatusFiring {
+		alarmHistory.IsIncidentStart = 1
+	}
 	if err = db.AlarmHistoryCreate(tx, &alarmHistory); err != nil {
 		tx.Rollback()
 		return fmt.Errorf("AlarmHistoryCreate %s, error: %w", alarmUUID, err)
 	}
-	currentFiltersStatus := alarm.GetStatus(tx)
 	// update filter
 	af := db.AlarmFilter{}
 	af.ID = filterId
@@ -72,10 +69,17 @@ func (i *alert) HandlerAlertManager(alarmUUID string, filterIdStr string, notifi
 		// 此时有正在进行中的告警
 		log.Info("PushAlertManagerRepeat", l.I("filterId", filterId))
 		tx.Commit()
+		Compositor.Trigger()
 		return
 	}
 	// 完成告警状态更新
 	tx.Commit()
+	Compositor.Trigger()
+	// the filter is resolved all the same, only its message is not sent
+	if alarm.IsDisableResolve == 1 && notificationStatus == db.AlarmStatusNormal {
+		log.Warn("AlarmIsDisableResolve", l.A("alarm", alarm))
+		return
+	}
 	// get alarm filter info
 	filter, err := i.compatibleFilter(alarm.ID, filterId)
 	if err != nil {
@@ -89,31 +93,52 @@ func (i *alert) HandlerAlertManager(alarmUUID string, filterIdStr string, notifi
 	if tableInfo.TimeField == "" {
 		tableInfo.TimeField = db.TimeFieldSecond
 	}
+	// silenced notifications are only recorded
+	silence, err := i.Silenced(&alarm, &tableInfo, time.Now())
+	if err != nil {
+		return fmt.Errorf("Silenced %s, error: %w", alarmUUID, err)
+	}
+	if silence != nil {
+		log.Info("AlarmSilenced", l.I("silenceId", silence.ID))
+		return db.AlarmHistoryUpdate(invoker.Db, alarmHistory.ID, map[string]interface{}{"is_pushed": db.PushedStatusSilenced, "silence_id": silence.ID})
+	}
 	// get op
 	op, err := InstanceManager.Load(tableInfo.Database.Iid)
 	if err != nil {
 		return fmt.Errorf("InstanceManager.Load %s, error: %w", alarmUUID, err)
 	}
-	// get partial log
-	partialLog := i.getPartialLog(op, &tableInfo, &alarm, filter)
+	// get sample logs, top values and chart
+	detail := i.notifyDetailTimeout(op, &tableInfo, &alarm, filter)
 
-	pushMsg, err := pusher.BuildAlarmMsg(notification, &tableInfo, &alarm, filter, partialLog)
+	pushMsg, err := pusher.BuildAlarmMsg(notification, &tableInfo, &alarm, filter, detail)
 	if err != nil {
 		return fmt.Errorf("BuildAlarmMsg %s, error: %w", alarmUUID, err)
 	}
 
-	pushMsgWithAt, err := pusher.BuildAlarmMsgWithAt(notification, &tableInfo, &alarm, filter, partialLog)
+	pushMsgWithAt, err := pusher.BuildAlarmMsgWithAt(notification, &tableInfo, &alarm, filter, detail)
 	if err != nil {
 		return fmt.Errorf("BuildAlarmMsgWithAt %s, error: %w", alarmUUID, err)
 	}
 
-	if err = pusher.Execute(alarm.ChannelIds, pushMsg, pushMsgWithAt); err != nil {
-		_ = db.AlarmHistoryUpdate(invoker.Db, alarmHistory.ID, map[string]interface{}{"is_pushed": db.PushedStatusFail})
-		return fmt.Errorf("execute %s, error: %w", alarmUUID, err)
-	}
-
-	if err = db.AlarmHistoryUpdate(invoker.Db, alarmHistory.ID, map[string]interface{}{"is_pushed": db.PushedStatusSuccess}); err != nil {
-		return fmt.Errorf("AlarmHistoryUpdate %s, error: %w", alarmUUID, err)
+	// grouped, deduplicated and rate limited per channel
+	item := &notifyItem{
+		alertKey:  fmt.Sprintf("%d|%d", alarm.ID, filterId),
+		status:    notificationStatus,
+		historyId: alarmHistory.ID,
+		msg:       pushMsg,
+		msgWithAt: pushMsgWithAt,
+		template:  alarm.Template,
+	}
+	var once sync.Once
+	var data *pusher.TemplateData
+	item.data = func() *pusher.TemplateData {
+		once.Do(func() {
+			data = pusher.NewTemplateData(notification, &tableInfo, &alarm, filter, detail)
+		})
+		return data
+	}
+	if err = Notifier.Notify(alarm.ChannelIds, notifyLabels(&alarm, filter, &tableInfo), item); err != nil {
+		return fmt.Errorf("notify %s, error: %w", alarmUUID, err)
 	}
 	return nil
 }
@@ -140,28 +165,33 @@ func (i *alert) compatibleFilter(alarmId int, filterId int) (res *db.AlarmFilter
 	return
 }
 
-func (i *alert) getPartialLog(op factory.Operator, table *db.BaseTable, alarm *db.Alarm, filter *db.AlarmFilter) (partialLog string) {
-	param := view.ReqQuery{
-		Tid:           table.ID,
-		Database:      table.Database.Name,
-		Table:         table.Name,
-		Query:         filter.When,
-		AlarmMode:     filter.Mode,
-		TimeField:     table.TimeField,
-		TimeFieldType: table.TimeFieldType,
-		ST:            time.Now().Add(-alarm.GetInterval() - time.Minute).Unix(),
-		ET:            time.Now().Add(time.Minute).Unix(),
-		Page:          1,
-		PageSize:      1,
-	}
-	param, _ = op.Prepare(param, table, false)
-	resp, _ := op.GetLogs(param, table.ID)
-	if table.V3TableType == db.V3TableTypeJaegerJSON {
-		resp.IsTrace = 1
-	}
-	if len(resp.Logs) > 0 {
-		l, _ := json.Marshal(resp.Logs[0])
-		partialLog = string(l)
-	}
-	return partialLog
+// TemplatePreview renders the template with a firing notification of the alarm, or with the sample data
+func (i *alert) TemplatePreview(req view.ReqAlarmTemplatePreview) (res view.RespAlarmTemplatePreview, err error) {
+	data := pusher.SampleTemplateData()
+	if req.AlarmId != 0 {
+		alarm, errAlarm := db.AlarmInfo(invoker.Db, req.AlarmId)
+		if errAlarm != nil {
+			return res, errAlarm
+		}
+		filter, errFilter := i.compatibleFilter(alarm.ID, 0)
+		if errFilter != nil {
+			return res, errFilter
+		}
+		table, errTable := db.TableInfo(invoker.Db, filter.Tid)
+		if errTable != nil {
+			return res, errTable
+		}
+		notification := db.Notification{
+			Status:       "firing",
+			CommonLabels: map[string]string{"uuid": alarm.Uuid, "filterId": strconv.Itoa(filter.ID)},
+			Alerts:       []db.Alert{{StartsAt: time.Now()}},
+		}
+		data = pusher.NewTemplateData(notification, &table, &alarm, filter, &pusher.Detail{Logs: data.Logs, TopField: data.TopField, TopValues: data.TopValues})
+	}
+	msg, err := pusher.RenderTemplate(req.Template, data, fmt.Sprintf("【%s】%s", "preview", data.Alarm.Name))
+	if err != nil {
+		return res, err
+	}
+	res.Title, res.Text = msg.Title, msg.Text
+	return res, nil
 }
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"sort"
+
+	"github.com/ego-component/egorm"
+	"github.com/pkg/errors"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service/permission"
+)
+
+const (
+	analyticsDefaultLimit      = 10
+	analyticsDefaultFlapWindow = 600
+	analyticsDefaultTeamTag    = "team"
+)
+
+// alarmStat sums of the incidents, the means are computed once they are merged
+type alarmStat struct {
+	firing        int
+	resolved      int
+	acked         int
+	notifications int
+	flapping      int
+	tta           int64
+	ttr           int64
+}
+
+func (s *alarmStat) add(o alarmStat) {
+	s.firing += o.firing
+	s.resolved += o.resolved
+	s.acked += o.acked
+	s.notifications += o.notifications
+	s.flapping += o.flapping
+	s.tta += o.tta
+	s.ttr += o.ttr
+}
+
+func (s alarmStat) view() (res view.AlarmAnalyticsStat) {
+	res = view.AlarmAnalyticsStat{Firing: s.firing, Resolved: s.resolved, Acked: s.acked, Notifications: s.notifications}
+	if s.acked > 0 {
+		res.Mtta = float64(s.tta) / float64(s.acked)
+	}
+	if s.resolved > 0 {
+		res.Mttr = float64(s.ttr) / float64(s.resolved)
+	}
+	if s.firing > 0 {
+		res.FlappingScore = float64(s.flapping) / float64(s.firing)
+	}
+	return res
+}
+
+// alarmStatFromHistories histories of one alarm in id order, the alarm fires while one of its filters fires
+func alarmStatFromHistories(histories []*db.AlarmHistory, flapWindow int64) (res alarmStat) {
+	var (
+		firingAt   int64
+		resolvedAt int64
+		acked      bool
+		filters    = make(map[int]struct{})
+	)
+	for _, h := range histories {
+		switch h.Typ {
+		case db.HistoryTypNotification:
+			if h.IsPushed == db.PushedStatusSuccess {
+				res.notifications++
+			}
+			switch h.FilterStatus {
+			case db.AlarmStatusFiring:
+				filters[h.FilterId] = struct{}{}
+				if firingAt != 0 {
+					continue
+				}
+				res.firing++
+				if resolvedAt != 0 && h.Ctime-resolvedAt < flapWindow {
+					res.flapping++
+				}
+				firingAt, acked = h.Ctime, false
+			case db.AlarmStatusNormal:
+				delete(filters, h.FilterId)
+				if firingAt == 0 || len(filters) > 0 {
+					continue
+				}
+				res.resolved++
+				res.ttr += h.Ctime - firingAt
+				firingAt, resolvedAt = 0, h.Ctime
+			}
+		case db.HistoryTypEscalation:
+			if h.IsPushed == db.PushedStatusSuccess {
+				res.notifications++
+			}
+		case db.HistoryTypAck:
+			if firingAt != 0 && !acked {
+				acked = true
+				res.acked++
+				res.tta += h.Ctime - firingAt
+			}
+		}
+	}
+	return res
+}
+
+// Analytics firing counts, mean time to acknowledge and resolve, flapping and noisiest alarms between st and et
+func (i *alert) Analytics(uid int, req view.ReqAlarmAnalytics) (res view.RespAlarmAnalytics, err error) {
+	if req.Et <= req.St {
+		return res, errors.New("end time must be after start time")
+	}
+	if req.Limit <= 0 {
+		req.Limit = analyticsDefaultLimit
+	}
+	if req.FlapWindow <= 0 {
+		req.FlapWindow = analyticsDefaultFlapWindow
+	}
+	if req.TeamTag == "" {
+		req.TeamTag = analyticsDefaultTeamTag
+	}
+	alarms, err := db.AlarmList(egorm.Conds{})
+	if err != nil {
+		return res, err
+	}
+	if permission.Manager.IsRootUser(uid) != nil {
+		alarms = alarmsOfTables(alarms, ReadAllPermissionTable(uid))
+	}
+	conds := egorm.Conds{}
+	conds["ctime"] = egorm.Cond{Op: "between", Val: []int64{req.St, req.Et}}
+	histories, err := db.AlarmHistoryList(invoker.Db, conds)
+	if err != nil {
+		return res, err
+	}
+	byAlarm := make(map[int][]*db.AlarmHistory)
+	for _, h := range histories {
+		byAlarm[h.AlarmId] = append(byAlarm[h.AlarmId], h)
+	}
+	var (
+		total     alarmStat
+		teams     = make(map[string]*alarmStat)
+		teamSizes = make(map[string]int)
+		databases = make(map[int]string)
+	)
+	res.Alarms = make([]view.AlarmAnalyticsAlarm, 0)
+	for _, alarm := range alarms {
+		stat := alarmStatFromHistories(byAlarm[alarm.ID], req.FlapWindow)
+		if stat.firing == 0 && stat.notifications == 0 {
+			continue
+		}
+		team := alarmTeam(alarm, req.TeamTag, databases)
+		if teams[team] == nil {
+			teams[team] = &alarmStat{}
+		}
+		teams[team].add(stat)
+		teamSizes[team]++
+		total.add(stat)
+		res.Alarms = append(res.Alarms, view.AlarmAnalyticsAlarm{AlarmId: alarm.ID, Name: alarm.Name, Team: team, AlarmAnalyticsStat: stat.view()})
+	}
+	sort.SliceStable(res.Alarms, func(i, j int) bool {
+		a, b := res.Alarms[i], res.Alarms[j]
+		if a.Firing != b.Firing {
+			return a.Firing > b.Firing
+		}
+		return a.Notifications > b.Notifications
+	})
+	res.Noisiest = res.Alarms
+	if len(res.Noisiest) > req.Limit {
+		res.Noisiest = res.Noisiest[:req.Limit]
+	}
+	res.Teams = make([]view.AlarmAnalyticsTeam, 0, len(teams))
+	for team, stat := range teams {
+		res.Teams = append(res.Teams, view.AlarmAnalyticsTeam{Team: team, Alarms: teamSizes[team], AlarmAnalyticsStat: stat.view()})
+	}
+	sort.Slice(res.Teams, func(i, j int) bool {
+		if res.Teams[i].Firing != res.Teams[j].Firing {
+			return res.Teams[i].Firing > res.Teams[j].Firing
+		}
+		return res.Teams[i].Team < res.Teams[j].Team
+	})
+	res.Total = total.view()
+	return res, nil
+}
+
+func alarmsOfTables(alarms []*db.Alarm, tids []int) []*db.Alarm {
+	set := make(map[int]struct{}, len(tids))
+	for _, tid := range tids {
+		set[tid] = struct{}{}
+	}
+	res := make([]*db.Alarm, 0)
+	for _, alarm := range alarms {
+		for _, tid := range alarm.TableIds {
+			if _, ok := set[tid]; ok {
+				res = append(res, alarm)
+				break
+			}
+		}
+	}
+	return res
+}
+
+// alarmTeam tag of the alarm, the database of its first table without it
+func alarmTeam(alarm *db.Alarm, tag string, databases map[int]string) string {
+	if team := alarm.Tags[tag]; team != "" {
+		return team
+	}
+	if len(alarm.TableIds) == 0 {
+		return ""
+	}
+	tid := alarm.TableIds[0]
+	if name, ok := databases[tid]; ok {
+		return name
+	}
+	table, err := db.TableInfo(invoker.Db, tid)
+	if err == nil && table.Database != nil {
+		databases[tid] = table.Database.Name
+	} else {
+		databases[tid] = ""
+	}
+	return databases[tid]
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+func Test_alarmStatFromHistories(t *testing.T) {
+	history := func(typ, filterId, status, pushed int, ctime int64) *db.AlarmHistory {
+		h := &db.AlarmHistory{Typ: typ, FilterId: filterId, FilterStatus: status, IsPushed: pushed}
+		h.Ctime = ctime
+		return h
+	}
+	histories := []*db.AlarmHistory{
+		// resolution of an incident fired before the range
+		history(db.HistoryTypNotification, 1, db.AlarmStatusNormal, db.PushedStatusSuccess, 50),
+		history(db.HistoryTypNotification, 1, db.AlarmStatusFiring, db.PushedStatusSuccess, 100),
+		history(db.HistoryTypNotification, 2, db.AlarmStatusFiring, db.PushedStatusRepeat, 120),
+		history(db.HistoryTypEscalation, 0, db.AlarmStatusFiring, db.PushedStatusSuccess, 150),
+		history(db.HistoryTypAck, 0, db.AlarmStatusFiring, db.PushedStatusRepeat, 160),
+		history(db.HistoryTypAck, 0, db.AlarmStatusFiring, db.PushedStatusRepeat, 170),
+		history(db.HistoryTypNotification, 1, db.AlarmStatusNormal, db.PushedStatusSuccess, 200),
+		history(db.HistoryTypNotification, 2, db.AlarmStatusNormal, db.PushedStatusSuccess, 400),
+		// fires again 100s after the resolution
+		history(db.HistoryTypNotification, 1, db.AlarmStatusFiring, db.PushedStatusSilenced, 500),
+		history(db.HistoryTypNotification, 1, db.AlarmStatusNormal, db.PushedStatusFail, 700),
+		history(db.HistoryTypNotification, 1, db.AlarmStatusFiring, db.PushedStatusSuccess, 2000),
+	}
+	stat := alarmStatFromHistories(histories, 600)
+	assert.Equal(t, alarmStat{firing: 3, resolved: 2, acked: 1, notifications: 6, flapping: 1, tta: 60, ttr: 500}, stat)
+
+	res := stat.view()
+	assert.Equal(t, 60.0, res.Mtta)
+	assert.Equal(t, 250.0, res.Mttr)
+	assert.InDelta(t, 1.0/3, res.FlappingScore, 1e-9)
+	assert.Equal(t, 0.0, alarmStat{}.view().Mttr)
+}
+
+func Test_alarmsOfTables(t *testing.T) {
+	alarms := []*db.Alarm{{TableIds: db.Ints{1, 2}}, {TableIds: db.Ints{3}}, {}}
+	assert.Equal(t, alarms[:1], alarmsOfTables(alarms, []int{2, 4}))
+	assert.Empty(t, alarmsOfTables(alarms, nil))
+}
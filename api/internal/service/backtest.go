@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ego-component/eredis"
+	"github.com/google/uuid"
+	"github.com/gotomicro/cetus/pkg/xgo"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/pkg/errors"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+)
+
+const (
+	// backtestMaxPoints each point is one query of each filter
+	backtestMaxPoints = 2000
+	// backtestJobTTL jobs are kept an hour after their last update
+	backtestJobTTL = time.Hour
+	// backtestSaveEvery the progress of a running job is saved every 50 evaluations
+	backtestSaveEvery = 50
+
+	BacktestStatusRunning = "running"
+	BacktestStatusDone    = "done"
+	BacktestStatusFailed  = "failed"
+)
+
+var (
+	backtestOnce  sync.Once
+	backtestStore backtestJobStore
+)
+
+// backtestJobStore jobs of the copy running them, in redis when the copies share them
+type backtestJobStore interface {
+	save(job *view.RespAlarmBacktestJob) error
+	load(id string) (*view.RespAlarmBacktestJob, error)
+}
+
+func backtestJobs() backtestJobStore {
+	backtestOnce.Do(func() {
+		if econf.GetBool("app.isMultiCopy") {
+			backtestStore = &redisBacktestStore{redis: invoker.Redis}
+		} else {
+			backtestStore = newMemoryBacktestStore()
+		}
+	})
+	return backtestStore
+}
+
+// backtestFilterJob filter of the request with what is needed to query its samples
+type backtestFilterJob struct {
+	filter     *db.AlarmFilter
+	conditions []*db.AlarmCondition
+	samples    func(st, et int64) ([]float64, error)
+}
+
+// BacktestStart checks the request and evaluates the filters of the alarm at its interval between st and et
+// in the background, as the native evaluator does. Nothing is stored but the job and no message is sent.
+func (i *alert) BacktestStart(uid int, req view.ReqAlarmBacktest) (res view.RespAlarmBacktestJob, err error) {
+	req.ConvertV2()
+	alarm := &db.Alarm{Interval: req.Interval, Unit: req.Unit, NoDataOp: req.NoDataOp, IsDisableResolve: req.IsDisableResolve}
+	points, err := backtestPoints(req.St, req.Et, alarm.GetInterval())
+	if err != nil {
+		return res, err
+	}
+	filters := make([]backtestFilterJob, 0, len(req.Filters))
+	for k, f := range req.Filters {
+		filter := &db.AlarmFilter{Tid: f.Tid, When: f.When, SetOperatorTyp: f.SetOperatorTyp, SetOperatorExp: f.SetOperatorExp, Mode: f.Mode}
+		conditions, errCond := newAlarmConditions(0, 0, f.Conditions)
+		if errCond != nil {
+			return res, errors.Wrapf(errCond, "filter %d", k)
+		}
+		table, errTable := db.TableInfo(invoker.Db, f.Tid)
+		if errTable != nil {
+			return res, errTable
+		}
+		op, errOp := InstanceManager.Load(table.Database.Iid)
+		if errOp != nil {
+			return res, errOp
+		}
+		filters = append(filters, backtestFilterJob{filter: filter, conditions: conditions, samples: func(st, et int64) ([]float64, error) {
+			return op.GetAlertSamples(&table, filter, conditions, st, et)
+		}})
+	}
+	res = view.RespAlarmBacktestJob{Id: uuid.NewString(), Uid: uid, Status: BacktestStatusRunning, Total: len(points) * len(filters)}
+	store := backtestJobs()
+	if err = store.save(&res); err != nil {
+		return res, err
+	}
+	job := res
+	xgo.Go(func() {
+		core.LoggerError("alert", "backtest", backtestRun(store, &job, alarm, filters, points))
+	})
+	return res, nil
+}
+
+// BacktestJob the job started by the user
+func (i *alert) BacktestJob(uid int, id string) (view.RespAlarmBacktestJob, error) {
+	job, err := backtestJobs().load(id)
+	if err != nil {
+		return view.RespAlarmBacktestJob{}, err
+	}
+	if job.Uid != uid {
+		return view.RespAlarmBacktestJob{}, errors.New("backtest not found")
+	}
+	return *job, nil
+}
+
+// backtestRun evaluates the filters one after the other and saves the progress of the job
+func backtestRun(store backtestJobStore, job *view.RespAlarmBacktestJob, alarm *db.Alarm, filters []backtestFilterJob, points []int64) error {
+	res := &view.RespAlarmBacktest{Points: len(points), Timeline: make([]view.AlarmBacktestEvent, 0)}
+	for k, f := range filters {
+		samples := func(st, et int64) ([]float64, error) {
+			job.Done++
+			if job.Done%backtestSaveEvery == 0 {
+				core.LoggerError("alert", "backtestProgress", store.save(job))
+			}
+			return f.samples(st, et)
+		}
+		events, err := backtestFilter(alarm, f.filter, f.conditions, points, samples)
+		if err != nil {
+			job.Status, job.Error = BacktestStatusFailed, fmt.Sprintf("filter %d: %s", k, err.Error())
+			core.LoggerError("alert", "backtestFailed", store.save(job))
+			return err
+		}
+		for _, event := range events {
+			event.FilterIdx = k
+			res.Timeline = append(res.Timeline, event)
+		}
+	}
+	sort.SliceStable(res.Timeline, func(i, j int) bool {
+		return res.Timeline[i].Time < res.Timeline[j].Time
+	})
+	for _, event := range res.Timeline {
+		if event.Status == db.AlarmStatusFiring {
+			res.Firing++
+		} else {
+			res.Resolved++
+		}
+		if event.IsNotified {
+			res.Notifications++
+		}
+	}
+	job.Status, job.Result = BacktestStatusDone, res
+	return store.save(job)
+}
+
+type memoryBacktestStore struct {
+	mu   sync.Mutex
+	jobs map[string]memoryBacktestJob
+}
+
+type memoryBacktestJob struct {
+	job   view.RespAlarmBacktestJob
+	utime time.Time
+}
+
+func newMemoryBacktestStore() *memoryBacktestStore {
+	return &memoryBacktestStore{jobs: make(map[string]memoryBacktestJob)}
+}
+
+func (s *memoryBacktestStore) save(job *view.RespAlarmBacktestJob) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, item := range s.jobs {
+		if now.Sub(item.utime) > backtestJobTTL {
+			delete(s.jobs, id)
+		}
+	}
+	s.jobs[job.Id] = memoryBacktestJob{job: *job, utime: now}
+	return nil
+}
+
+func (s *memoryBacktestStore) load(id string) (*view.RespAlarmBacktestJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	item, ok := s.jobs[id]
+	if !ok {
+		return nil, errors.New("backtest not found")
+	}
+	return &item.job, nil
+}
+
+// redisBacktestStore jobs are json in redis, the job is polled from any copy
+type redisBacktestStore struct {
+	redis *eredis.Component
+}
+
+func redisBacktestKey(id string) string {
+	return "clickvisual:backtest:" + id
+}
+
+func (s *redisBacktestStore) save(job *view.RespAlarmBacktestJob) error {
+	raw, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return s.redis.Set(context.Background(), redisBacktestKey(job.Id), raw, backtestJobTTL)
+}
+
+func (s *redisBacktestStore) load(id string) (*view.RespAlarmBacktestJob, error) {
+	raw, err := s.redis.GetBytes(context.Background(), redisBacktestKey(id))
+	if errors.Is(err, eredis.Nil) {
+		return nil, errors.New("backtest not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	res := &view.RespAlarmBacktestJob{}
+	return res, json.Unmarshal(raw, res)
+}
+
+// backtestPoints ends of the evaluated windows, one interval after st until et
+func backtestPoints(st, et int64, interval time.Duration) ([]int64, error) {
+	step := int64(interval.Seconds())
+	if step <= 0 {
+		return nil, errors.New("invalid alarm interval")
+	}
+	if et <= st {
+		return nil, errors.New("et must be after st")
+	}
+	if (et-st)/step > backtestMaxPoints {
+		return nil, errors.Errorf("more than %d evaluations, shorten the range or raise the interval", backtestMaxPoints)
+	}
+	res := make([]int64, 0, (et-st)/step)
+	for t := st + step; t <= et; t += step {
+		res = append(res, t)
+	}
+	return res, nil
+}
+
+// backtestFilter status transitions of the filter, notified as the evaluator would
+func backtestFilter(alarm *db.Alarm, filter *db.AlarmFilter, conditions []*db.AlarmCondition, points []int64, samples func(st, et int64) ([]float64, error)) ([]view.AlarmBacktestEvent, error) {
+	step := int64(alarm.GetInterval().Seconds())
+	res := make([]view.AlarmBacktestEvent, 0)
+	status := db.AlarmStatusUnknown
+	for _, t := range points {
+		values, err := samples(t-step, t)
+		if err != nil {
+			return nil, err
+		}
+		next := evaluatorStatus(filter, conditions, values, alarm.NoDataOp)
+		if next == status {
+			continue
+		}
+		isResolved := status == db.AlarmStatusFiring
+		status = next
+		if next == db.AlarmStatusNormal && !isResolved {
+			// first evaluation
+			continue
+		}
+		res = append(res, view.AlarmBacktestEvent{
+			Time:       t,
+			Status:     next,
+			IsNotified: next == db.AlarmStatusFiring || alarm.IsDisableResolve != 1,
+		})
+	}
+	return res, nil
+}
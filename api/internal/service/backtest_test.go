@@ -0,0 +1,106 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+)
+
+func Test_backtestPoints(t *testing.T) {
+	points, err := backtestPoints(0, 300, time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{60, 120, 180, 240, 300}, points)
+	_, err = backtestPoints(300, 0, time.Minute)
+	assert.Error(t, err)
+	_, err = backtestPoints(0, 86400*7, time.Minute)
+	assert.Error(t, err)
+}
+
+func Test_backtestFilter(t *testing.T) {
+	alarm := &db.Alarm{Interval: 1, Unit: 0}
+	conditions := []*db.AlarmCondition{{SetOperatorExp: 3, Cond: 0, Val1: 10}}
+	values := map[int64][]float64{
+		60:  {1},
+		120: {20},
+		180: {30},
+		240: {1},
+		300: {},
+		360: {11},
+	}
+	samples := func(st, et int64) ([]float64, error) {
+		assert.Equal(t, int64(60), et-st)
+		return values[et], nil
+	}
+	points := []int64{60, 120, 180, 240, 300, 360}
+
+	events, err := backtestFilter(alarm, &db.AlarmFilter{}, conditions, points, samples)
+	assert.NoError(t, err)
+	assert.Len(t, events, 3)
+	assert.Equal(t, int64(120), events[0].Time)
+	assert.Equal(t, db.AlarmStatusFiring, events[0].Status)
+	assert.Equal(t, db.AlarmStatusNormal, events[1].Status)
+	assert.True(t, events[1].IsNotified)
+	assert.Equal(t, int64(360), events[2].Time)
+
+	alarm.IsDisableResolve = 1
+	events, err = backtestFilter(alarm, &db.AlarmFilter{}, conditions, points, samples)
+	assert.NoError(t, err)
+	assert.False(t, events[1].IsNotified)
+
+	// a firing count filter resolves when no log matches anymore
+	alarm.IsDisableResolve = 0
+	values[240] = nil
+	events, err = backtestFilter(alarm, &db.AlarmFilter{}, conditions, points, samples)
+	assert.NoError(t, err)
+	assert.Len(t, events, 3)
+	assert.Equal(t, int64(240), events[1].Time)
+	assert.Equal(t, db.AlarmStatusNormal, events[1].Status)
+}
+
+func Test_backtestRun(t *testing.T) {
+	alarm := &db.Alarm{Interval: 1, Unit: 0}
+	conditions := []*db.AlarmCondition{{SetOperatorExp: 3, Cond: 0, Val1: 10}}
+	points := []int64{60, 120, 180}
+	filters := []backtestFilterJob{
+		{filter: &db.AlarmFilter{}, conditions: conditions, samples: func(st, et int64) ([]float64, error) {
+			if et == 120 {
+				return []float64{20}, nil
+			}
+			return []float64{1}, nil
+		}},
+		{filter: &db.AlarmFilter{}, conditions: conditions, samples: func(st, et int64) ([]float64, error) {
+			return []float64{20}, nil
+		}},
+	}
+	store := newMemoryBacktestStore()
+	job := &view.RespAlarmBacktestJob{Id: "job", Uid: 1, Status: BacktestStatusRunning, Total: 6}
+	assert.NoError(t, backtestRun(store, job, alarm, filters, points))
+
+	res, err := store.load("job")
+	assert.NoError(t, err)
+	assert.Equal(t, BacktestStatusDone, res.Status)
+	assert.Equal(t, 6, res.Done)
+	assert.Equal(t, 2, res.Result.Firing)
+	assert.Equal(t, 1, res.Result.Resolved)
+	assert.Equal(t, []int64{60, 120, 180}, []int64{res.Result.Timeline[0].Time, res.Result.Timeline[1].Time, res.Result.Timeline[2].Time})
+	assert.Equal(t, 1, res.Result.Timeline[0].FilterIdx)
+
+	filters[1].samples = func(st, et int64) ([]float64, error) {
+		return nil, errors.New("query failed")
+	}
+	job = &view.RespAlarmBacktestJob{Id: "job", Uid: 1, Status: BacktestStatusRunning, Total: 6}
+	assert.Error(t, backtestRun(store, job, alarm, filters, points))
+	res, err = store.load("job")
+	assert.NoError(t, err)
+	assert.Equal(t, BacktestStatusFailed, res.Status)
+	assert.Equal(t, "filter 1: query failed", res.Error)
+	assert.Nil(t, res.Result)
+
+	_, err = store.load("unknown")
+	assert.Error(t, err)
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/pkg/errors"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service/alarm/pusher"
+)
+
+// ChatOps applies the button clicked in the channel on the alarm and returns the reply for the chat,
+// the reply tells the error of the action too. The chat users are not clickvisual users, the actions are made as the owner of the channel
+// and only on the alarms notifying the channel.
+func (i *alert) ChatOps(channel *db.AlarmChannel, req pusher.ChatOpsRequest) (reply string, err error) {
+	alarm, err := db.AlarmInfo(invoker.Db, req.AlarmId)
+	if err != nil {
+		return "", err
+	}
+	if req.LinkSign != "" {
+		if err = chatOpsLinkUse(req.LinkSign, req.LinkExp, time.Now()); err != nil {
+			return pusher.ChatOpsReply(alarm.Name, req, err), err
+		}
+	}
+	err = i.chatOpsApply(channel, &alarm, req)
+	return pusher.ChatOpsReply(alarm.Name, req, err), err
+}
+
+func (i *alert) chatOpsApply(channel *db.AlarmChannel, alarm *db.Alarm, req pusher.ChatOpsRequest) (err error) {
+	if !chatOpsNotifies(channel, alarm) {
+		return errors.New("the alarm does not notify this channel")
+	}
+	switch req.Action {
+	case pusher.ChatOpsAck:
+		_, err = i.Ack(channel.Uid, alarm.ID)
+	case pusher.ChatOpsSnooze:
+		_, err = i.Snooze(channel.Uid, alarm.ID, view.ReqAlarmSnooze{Duration: pusher.ChatOpsSnoozeDuration, Reason: "chat-ops " + req.User})
+	default:
+		err = errors.Errorf("unknown action %s", req.Action)
+	}
+	return err
+}
+
+func chatOpsNotifies(channel *db.AlarmChannel, alarm *db.Alarm) bool {
+	for _, id := range alarm.ChannelIds {
+		if id == channel.ID {
+			return true
+		}
+	}
+	return false
+}
+
+var chatOpsLinks = struct {
+	sync.Mutex
+	used map[string]int64
+}{used: make(map[string]int64)}
+
+// chatOpsLinkUse marks the dingding link used until it expires, a used link is rejected
+func chatOpsLinkUse(sign string, exp int64, now time.Time) error {
+	if econf.GetBool("app.isMultiCopy") {
+		ok, err := invoker.Redis.SetNx(context.Background(), "clickvisual:chatops:link:"+sign, 1, time.Unix(exp, 0).Sub(now)+time.Minute)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errChatOpsLinkUsed
+		}
+		return nil
+	}
+	chatOpsLinks.Lock()
+	defer chatOpsLinks.Unlock()
+	for k, linkExp := range chatOpsLinks.used {
+		if linkExp < now.Unix() {
+			delete(chatOpsLinks.used, k)
+		}
+	}
+	if _, ok := chatOpsLinks.used[sign]; ok {
+		return errChatOpsLinkUsed
+	}
+	chatOpsLinks.used[sign] = exp
+	return nil
+}
+
+var errChatOpsLinkUsed = errors.New("the link has already been used")
@@ -0,0 +1,21 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_chatOpsLinkUse(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	assert.NoError(t, chatOpsLinkUse("link-a", now.Add(time.Hour).Unix(), now))
+	assert.Error(t, chatOpsLinkUse("link-a", now.Add(time.Hour).Unix(), now))
+	assert.NoError(t, chatOpsLinkUse("link-b", now.Add(time.Hour).Unix(), now))
+
+	// the expired links are dropped, they are rejected by their signature anyway
+	later := now.Add(2 * time.Hour)
+	assert.NoError(t, chatOpsLinkUse("link-c", later.Add(time.Hour).Unix(), later))
+	_, ok := chatOpsLinks.used["link-a"]
+	assert.False(t, ok)
+}
@@ -0,0 +1,393 @@
+package service
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ego-component/egorm"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service/alarm/pusher"
+)
+
+// compositeMaxFor longest for-duration of a composite alarm
+const compositeMaxFor = 24 * 3600
+
+// compositor evaluates the composite alarms when the state of an alarm changes,
+// and on each tick for the for-durations and the state changes received by the other copies
+type compositor struct {
+	ticker   workerTicker
+	triggerC chan struct{}
+}
+
+func NewCompositor() *compositor {
+	return &compositor{triggerC: make(chan struct{}, 1)}
+}
+
+func (c *compositor) tickerCheck() {
+	interval := econf.GetDuration("app.compositeEvaluateInterval")
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	c.ticker.start("compositor", interval, c.triggerC, func() error { return c.check(time.Now()) })
+}
+
+func (c *compositor) stop() {
+	c.ticker.stop()
+}
+
+// Trigger the state of an alarm changed, the composite alarms are evaluated without waiting for the tick
+func (c *compositor) Trigger() {
+	if c == nil {
+		return
+	}
+	select {
+	case c.triggerC <- struct{}{}:
+	default:
+	}
+}
+
+// check evaluates the opened composite alarms
+func (c *compositor) check(now time.Time) (err error) {
+	conds := egorm.Conds{}
+	conds["status"] = egorm.Cond{
+		Op:  ">",
+		Val: db.AlarmStatusClose,
+	}
+	composites, err := db.AlarmCompositeList(invoker.Db, conds)
+	if err != nil || len(composites) == 0 {
+		return err
+	}
+	alarms, err := compositeAlarmMap()
+	if err != nil {
+		return err
+	}
+	for _, composite := range composites {
+		err = multierr.Append(err, c.evaluate(composite, alarms, now))
+	}
+	return err
+}
+
+// evaluate updates the status of the composite alarm, its channels are notified of the changes
+func (c *compositor) evaluate(composite *db.AlarmComposite, alarms map[int]*db.Alarm, now time.Time) error {
+	expr, ids, err := parseCompositeExpr(composite.Expr)
+	if err != nil {
+		return errors.Wrapf(err, "composite alarm %d", composite.ID)
+	}
+	holds := expr.eval(func(id int) bool {
+		alarm, ok := alarms[id]
+		return ok && alarm.Status == db.AlarmStatusFiring
+	})
+	status, pendingAt := compositeNext(composite, holds, now)
+	if status == composite.Status {
+		if pendingAt == composite.PendingAt {
+			return nil
+		}
+		_, err = db.AlarmCompositeUpdateFrom(invoker.Db, composite.ID, composite.Status, map[string]interface{}{"pending_at": pendingAt})
+		return err
+	}
+	ups := make(map[string]interface{}, 0)
+	ups["status"] = status
+	ups["pending_at"] = pendingAt
+	updated, err := db.AlarmCompositeUpdateFrom(invoker.Db, composite.ID, composite.Status, ups)
+	if err != nil || !updated {
+		// closed or evaluated by another copy since it was listed
+		return err
+	}
+	history := db.AlarmHistory{CompositeId: composite.ID, FilterStatus: status, IsPushed: db.PushedStatusRepeat}
+	if err = db.AlarmHistoryCreate(invoker.Db, &history); err != nil {
+		return err
+	}
+	msg, msgWithAt := pusher.BuildCompositeMsg(composite, compositeAlarms(ids, alarms), status, now)
+	item := &notifyItem{
+		alertKey:  "composite|" + strconv.Itoa(composite.ID),
+		status:    status,
+		historyId: history.ID,
+		msg:       msg,
+		msgWithAt: msgWithAt,
+	}
+	// grouped apart from the alarms by default
+	labels := map[string]string{
+		"alarmId":     "composite-" + strconv.Itoa(composite.ID),
+		"alarmName":   composite.Name,
+		"compositeId": strconv.Itoa(composite.ID),
+	}
+	return Notifier.Notify(composite.ChannelIds, labels, item)
+}
+
+// compositeNext status and pending time after the evaluation, the composite alarm fires
+// once the expression has held for its for-duration and is resolved as soon as it does not
+func compositeNext(composite *db.AlarmComposite, holds bool, now time.Time) (int, int64) {
+	if !holds {
+		return db.AlarmStatusNormal, 0
+	}
+	pendingAt := composite.PendingAt
+	if pendingAt == 0 {
+		pendingAt = now.Unix()
+	}
+	if now.Unix()-pendingAt >= int64(composite.For) {
+		return db.AlarmStatusFiring, pendingAt
+	}
+	return composite.Status, pendingAt
+}
+
+// CompositeValidate checks the expression, its alarms and the channels, the alarm ids of the expression are returned
+func CompositeValidate(req view.ReqAlarmCompositeCreate) ([]int, error) {
+	if req.For < 0 || req.For > compositeMaxFor {
+		return nil, errors.Errorf("for must be between 0 and %d seconds", compositeMaxFor)
+	}
+	if len(req.ChannelIds) == 0 {
+		return nil, errors.New("channels are required")
+	}
+	for _, id := range req.ChannelIds {
+		if _, err := db.AlarmChannelInfo(invoker.Db, id); err != nil {
+			return nil, err
+		}
+	}
+	_, ids, err := parseCompositeExpr(req.Expr)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if _, err = db.AlarmInfo(invoker.Db, id); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// CompositeAlarmIds alarm ids of the expression of the composite alarm
+func CompositeAlarmIds(expr string) ([]int, error) {
+	_, ids, err := parseCompositeExpr(expr)
+	return ids, err
+}
+
+// CompositeInfo the composite alarm with the current states of its alarms
+func CompositeInfo(id int) (res view.RespAlarmCompositeInfo, err error) {
+	composite, err := db.AlarmCompositeInfo(invoker.Db, id)
+	if err != nil {
+		return res, err
+	}
+	res.AlarmComposite = &composite
+	res.Alarms = make([]view.RespAlarmCompositeAlarm, 0)
+	_, ids, err := parseCompositeExpr(composite.Expr)
+	if err != nil {
+		return res, nil
+	}
+	for _, alarmId := range ids {
+		item := view.RespAlarmCompositeAlarm{Id: alarmId}
+		if alarm, errAlarm := db.AlarmInfo(invoker.Db, alarmId); errAlarm == nil {
+			item.Name, item.Status = alarm.Name, alarm.Status
+		}
+		res.Alarms = append(res.Alarms, item)
+	}
+	return res, nil
+}
+
+// CompositesOfAlarm composite alarms whose expression uses the alarm
+func CompositesOfAlarm(alarmId int) (res []*db.AlarmComposite, err error) {
+	composites, err := db.AlarmCompositeList(invoker.Db, egorm.Conds{})
+	if err != nil {
+		return nil, err
+	}
+	for _, composite := range composites {
+		_, ids, errExpr := parseCompositeExpr(composite.Expr)
+		if errExpr != nil {
+			continue
+		}
+		for _, id := range ids {
+			if id == alarmId {
+				res = append(res, composite)
+				break
+			}
+		}
+	}
+	return res, nil
+}
+
+func compositeAlarmMap() (map[int]*db.Alarm, error) {
+	alarms, err := db.AlarmList(egorm.Conds{})
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int]*db.Alarm, len(alarms))
+	for _, alarm := range alarms {
+		res[alarm.ID] = alarm
+	}
+	return res, nil
+}
+
+func compositeAlarms(ids []int, alarms map[int]*db.Alarm) []pusher.CompositeAlarm {
+	res := make([]pusher.CompositeAlarm, 0, len(ids))
+	for _, id := range ids {
+		item := pusher.CompositeAlarm{Id: id}
+		if alarm, ok := alarms[id]; ok {
+			item.Name, item.Firing = alarm.Name, alarm.Status == db.AlarmStatusFiring
+		}
+		res = append(res, item)
+	}
+	return res
+}
+
+// compositeExpr boolean expression over the firing states of alarms
+type compositeExpr interface {
+	eval(firing func(alarmId int) bool) bool
+}
+
+type (
+	compositeAlarm int
+	compositeNot   struct{ x compositeExpr }
+	compositeAnd   struct{ l, r compositeExpr }
+	compositeOr    struct{ l, r compositeExpr }
+)
+
+func (e compositeAlarm) eval(firing func(int) bool) bool { return firing(int(e)) }
+func (e compositeNot) eval(firing func(int) bool) bool   { return !e.x.eval(firing) }
+func (e compositeAnd) eval(firing func(int) bool) bool   { return e.l.eval(firing) && e.r.eval(firing) }
+func (e compositeOr) eval(firing func(int) bool) bool    { return e.l.eval(firing) || e.r.eval(firing) }
+
+// parseCompositeExpr parses alarm ids joined by && (AND), || (OR), ! (NOT) and parentheses,
+// NOT binds tighter than AND which binds tighter than OR. The ids are returned in order of appearance.
+func parseCompositeExpr(s string) (compositeExpr, []int, error) {
+	tokens, err := compositeTokens(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := &compositeParser{tokens: tokens, seen: make(map[int]struct{})}
+	res, err := p.or()
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, nil, errors.Errorf("unexpected %s in expression", p.tokens[p.pos])
+	}
+	return res, p.ids, nil
+}
+
+func compositeTokens(s string) ([]string, error) {
+	res := make([]string, 0)
+	for i := 0; i < len(s); {
+		r := rune(s[i])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')' || r == '!':
+			res = append(res, string(r))
+			i++
+		case strings.HasPrefix(s[i:], "&&") || strings.HasPrefix(s[i:], "||"):
+			res = append(res, s[i:i+2])
+			i += 2
+		case unicode.IsDigit(r) || unicode.IsLetter(r):
+			j := i
+			for j < len(s) && (unicode.IsDigit(rune(s[j])) || unicode.IsLetter(rune(s[j]))) {
+				j++
+			}
+			switch word := strings.ToUpper(s[i:j]); word {
+			case "AND":
+				res = append(res, "&&")
+			case "OR":
+				res = append(res, "||")
+			case "NOT":
+				res = append(res, "!")
+			default:
+				if _, err := strconv.Atoi(word); err != nil {
+					return nil, errors.Errorf("invalid alarm id %s in expression", s[i:j])
+				}
+				res = append(res, word)
+			}
+			i = j
+		default:
+			return nil, errors.Errorf("unexpected %q in expression", r)
+		}
+	}
+	return res, nil
+}
+
+type compositeParser struct {
+	tokens []string
+	pos    int
+	ids    []int
+	seen   map[int]struct{}
+}
+
+func (p *compositeParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *compositeParser) or() (compositeExpr, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.pos++
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l = compositeOr{l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *compositeParser) and() (compositeExpr, error) {
+	l, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.pos++
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l = compositeAnd{l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *compositeParser) unary() (compositeExpr, error) {
+	token := p.peek()
+	p.pos++
+	switch token {
+	case "":
+		return nil, errors.New("unexpected end of expression")
+	case "!":
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return compositeNot{x: x}, nil
+	case "(":
+		x, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, errors.New("missing ) in expression")
+		}
+		p.pos++
+		return x, nil
+	case ")", "&&", "||":
+		return nil, errors.Errorf("unexpected %s in expression", token)
+	}
+	id, _ := strconv.Atoi(token)
+	if id <= 0 {
+		return nil, errors.Errorf("invalid alarm id %s in expression", token)
+	}
+	if _, ok := p.seen[id]; !ok {
+		p.seen[id] = struct{}{}
+		p.ids = append(p.ids, id)
+	}
+	return compositeAlarm(id), nil
+}
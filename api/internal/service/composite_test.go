@@ -0,0 +1,71 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+func Test_parseCompositeExpr(t *testing.T) {
+	firing := map[int]bool{1: true, 2: false, 3: true}
+	isFiring := func(id int) bool { return firing[id] }
+	tests := []struct {
+		expr string
+		want bool
+		ids  []int
+	}{
+		{expr: "1", want: true, ids: []int{1}},
+		{expr: "1 && 2", want: false, ids: []int{1, 2}},
+		{expr: "1 || 2", want: true, ids: []int{1, 2}},
+		{expr: "!2", want: true, ids: []int{2}},
+		{expr: "2 && 1 || 3", want: true, ids: []int{2, 1, 3}},
+		{expr: "2 && (1 || 3)", want: false, ids: []int{2, 1, 3}},
+		{expr: "!1 || 3", want: true, ids: []int{1, 3}},
+		{expr: "!(1 || 3)", want: false, ids: []int{1, 3}},
+		{expr: "1 and not 2", want: true, ids: []int{1, 2}},
+		{expr: "2 OR 3 AND 1", want: true, ids: []int{2, 3, 1}},
+		{expr: "1&&(3||1)", want: true, ids: []int{1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, ids, err := parseCompositeExpr(tt.expr)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, expr.eval(isFiring))
+			assert.Equal(t, tt.ids, ids)
+		})
+	}
+}
+
+func Test_parseCompositeExprError(t *testing.T) {
+	for _, expr := range []string{"", "1 &&", "(1 || 2", "1 || 2)", "1 & 2", "0 || 1", "a1 && 2", "1 2", "!", "&& 1"} {
+		t.Run(expr, func(t *testing.T) {
+			_, _, err := parseCompositeExpr(expr)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func Test_compositeNext(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	composite := &db.AlarmComposite{For: 60, Status: db.AlarmStatusNormal}
+
+	status, pendingAt := compositeNext(composite, true, now)
+	assert.Equal(t, db.AlarmStatusNormal, status)
+	assert.Equal(t, now.Unix(), pendingAt)
+
+	composite.PendingAt = pendingAt
+	status, pendingAt = compositeNext(composite, true, now.Add(time.Minute))
+	assert.Equal(t, db.AlarmStatusFiring, status)
+	assert.Equal(t, now.Unix(), pendingAt)
+
+	composite.Status = status
+	status, pendingAt = compositeNext(composite, false, now.Add(2*time.Minute))
+	assert.Equal(t, db.AlarmStatusNormal, status)
+	assert.Equal(t, int64(0), pendingAt)
+
+	status, _ = compositeNext(&db.AlarmComposite{Status: db.AlarmStatusNormal}, true, now)
+	assert.Equal(t, db.AlarmStatusFiring, status)
+}
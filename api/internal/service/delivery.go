@@ -0,0 +1,377 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ego-component/eredis"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/service/alarm/pusher"
+)
+
+const (
+	deliveryBatch = 100
+	// deliveryLease an attempt holds its delivery for so long, the other copies do not send it meanwhile
+	deliveryLease      = time.Minute
+	deliveryMaxBackoff = time.Hour
+)
+
+// deliverer sends the messages to the channels, the failed ones are stored and retried with an exponential backoff.
+// A channel failing deliveryBreakerThreshold times in a row is not called for deliveryBreakerCooldown,
+// its messages are queued meanwhile.
+//
+// The queue is the mysql table in multi-copy mode too: the copies share it, and AlarmDeliveryClaim moving next_at
+// lets a single copy attempt a delivery. Only the breakers are in redis then, a queue in redis would neither
+// outlive a flush of redis nor back the view and the resend of the deliveries.
+type deliverer struct {
+	ticker workerTicker
+
+	breaker     deliveryBreaker
+	maxAttempts int
+	backoff     time.Duration
+
+	send          func(channel *db.AlarmChannel, msg *db.PushMsg) error
+	updateHistory func(historyIds []int, isPushed int)
+}
+
+// deliveryMsg the image of the message is not in its json
+type deliveryMsg struct {
+	*db.PushMsg
+	Image []byte `json:"image,omitempty"`
+}
+
+func NewDeliverer() *deliverer {
+	d := &deliverer{
+		maxAttempts: econf.GetInt("app.deliveryMaxAttempts"),
+		backoff:     econf.GetDuration("app.deliveryBackoff"),
+		send: func(channel *db.AlarmChannel, msg *db.PushMsg) error {
+			channelPusher, err := pusher.GetPusher(channel.Typ)
+			if err != nil {
+				return err
+			}
+			return channelPusher.Send(channel, msg)
+		},
+		updateHistory: func(historyIds []int, isPushed int) {
+			for _, id := range historyIds {
+				core.LoggerError("deliverer", "updateHistory", db.AlarmHistoryUpdate(invoker.Db, id, map[string]interface{}{"is_pushed": isPushed}))
+			}
+		},
+	}
+	if d.maxAttempts <= 0 {
+		d.maxAttempts = 8
+	}
+	if d.backoff <= 0 {
+		d.backoff = 30 * time.Second
+	}
+	threshold, cooldown := econf.GetInt("app.deliveryBreakerThreshold"), econf.GetDuration("app.deliveryBreakerCooldown")
+	if threshold <= 0 {
+		threshold = 5
+	}
+	if cooldown <= 0 {
+		cooldown = time.Minute
+	}
+	// the copies share the breakers when they all push
+	if econf.GetBool("app.isMultiCopy") {
+		d.breaker = &redisBreaker{redis: invoker.Redis, threshold: threshold, cooldown: cooldown}
+	} else {
+		d.breaker = newMemoryBreaker(threshold, cooldown)
+	}
+	return d
+}
+
+func (d *deliverer) tickerCheck() {
+	interval := econf.GetDuration("app.deliveryRetryInterval")
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	d.ticker.start("deliverer", interval, nil, func() error { return d.retry(time.Now()) })
+}
+
+func (d *deliverer) stop() {
+	d.ticker.stop()
+}
+
+// Execute sends the message to each channel, dingding receives msgWithAt.
+// The histories are updated by the deliverer once a queued message is delivered.
+func (d *deliverer) Execute(channelIds []int, msg, msgWithAt *db.PushMsg, historyIds []int) (queued bool, err error) {
+	for _, channelId := range channelIds {
+		channel, errChannel := db.AlarmChannelInfo(invoker.Db, channelId)
+		if errChannel != nil {
+			err = multierr.Append(err, errChannel)
+			continue
+		}
+		channelMsg := msg
+		if channel.Typ == db.ChannelDingDing {
+			channelMsg = msgWithAt
+		}
+		channelQueued, errSend := d.Send(&channel, channelMsg, historyIds)
+		queued = queued || channelQueued
+		err = multierr.Append(err, errSend)
+	}
+	return queued, err
+}
+
+// Send pushes the message, it is queued for retry when the push fails or the breaker of the channel is open.
+// The pending deliveries of its dedup key to the channel are superseded, the newer message replaces them.
+// An error is only returned when the message could not be queued.
+func (d *deliverer) Send(channel *db.AlarmChannel, msg *db.PushMsg, historyIds []int) (queued bool, err error) {
+	now := time.Now()
+	d.supersede(channel.ID, msg.DedupKey)
+	if d.breaker.open(channel.ID, now) {
+		return true, d.enqueue(channel, msg, historyIds, 0, "circuit breaker open", now)
+	}
+	errSend := d.send(channel, msg)
+	if errSend == nil {
+		d.breaker.success(channel.ID)
+		return false, nil
+	}
+	d.breaker.failure(channel.ID, now)
+	elog.Warn("deliverer", elog.Int("channelId", channel.ID), elog.String("step", "queued"), elog.FieldErr(errSend))
+	if err = d.enqueue(channel, msg, historyIds, 1, errSend.Error(), now); err != nil {
+		return false, multierr.Append(errSend, err)
+	}
+	return true, nil
+}
+
+func (d *deliverer) enqueue(channel *db.AlarmChannel, msg *db.PushMsg, historyIds []int, attempts int, reason string, now time.Time) error {
+	raw, err := json.Marshal(deliveryMsg{PushMsg: msg, Image: msg.Image})
+	if err != nil {
+		return errors.Wrap(err, "delivery message")
+	}
+	return db.AlarmDeliveryCreate(invoker.Db, &db.AlarmDelivery{
+		ChannelId:  channel.ID,
+		DedupKey:   deliveryTruncate(msg.DedupKey, 255),
+		HistoryIds: historyIds,
+		Title:      deliveryTruncate(msg.Title, 255),
+		Msg:        string(raw),
+		Status:     db.DeliveryStatusPending,
+		Attempts:   attempts,
+		NextAt:     now.Add(deliveryBackoff(d.backoff, attempts)).Unix(),
+		LastError:  deliveryTruncate(reason, 1024),
+	})
+}
+
+// supersede the histories of the superseded deliveries are not pushed, as repeats
+func (d *deliverer) supersede(channelId int, dedupKey string) {
+	if dedupKey == "" {
+		return
+	}
+	deliveries, err := db.AlarmDeliverySupersede(invoker.Db, channelId, deliveryTruncate(dedupKey, 255))
+	if err != nil {
+		core.LoggerError("deliverer", "supersede", err)
+		return
+	}
+	for _, delivery := range deliveries {
+		d.updateHistory(delivery.HistoryIds, db.PushedStatusRepeat)
+	}
+}
+
+// retry attempts the due deliveries whose channel breaker is closed
+func (d *deliverer) retry(now time.Time) (err error) {
+	deliveries, err := db.AlarmDeliveryDue(invoker.Db, now.Unix(), deliveryBatch)
+	if err != nil {
+		return err
+	}
+	for _, delivery := range deliveries {
+		if d.breaker.open(delivery.ChannelId, now) {
+			continue
+		}
+		err = multierr.Append(err, d.attempt(delivery, now))
+	}
+	return err
+}
+
+// Resend attempts the pending or failed delivery at once, the breaker of the channel is ignored
+func (d *deliverer) Resend(id int) error {
+	delivery, err := db.AlarmDeliveryInfo(invoker.Db, id)
+	if err != nil {
+		return err
+	}
+	switch delivery.Status {
+	case db.DeliveryStatusSuccess:
+		return errors.Errorf("delivery %d was already delivered", id)
+	case db.DeliveryStatusSuperseded:
+		return errors.Errorf("delivery %d was superseded by a newer message", id)
+	}
+	return d.attempt(&delivery, time.Now())
+}
+
+// attempt claims the delivery so that a single copy sends it, then records the result of the push
+func (d *deliverer) attempt(delivery *db.AlarmDelivery, now time.Time) error {
+	ok, err := db.AlarmDeliveryClaim(invoker.Db, delivery.ID, delivery.NextAt, now.Add(deliveryLease).Unix())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.Errorf("delivery %d is being sent", delivery.ID)
+	}
+	ups, errSend := d.deliver(delivery, now)
+	if err = db.AlarmDeliveryUpdate(invoker.Db, delivery.ID, ups); err != nil {
+		return err
+	}
+	switch ups["status"] {
+	case db.DeliveryStatusSuccess:
+		d.updateHistory(delivery.HistoryIds, db.PushedStatusSuccess)
+	case db.DeliveryStatusFailed:
+		d.updateHistory(delivery.HistoryIds, db.PushedStatusFail)
+	}
+	return errSend
+}
+
+// deliver pushes the stored message and returns the updates of the delivery
+func (d *deliverer) deliver(delivery *db.AlarmDelivery, now time.Time) (map[string]interface{}, error) {
+	attempts := delivery.Attempts + 1
+	ups := map[string]interface{}{"attempts": attempts}
+	channel, err := db.AlarmChannelInfo(invoker.Db, delivery.ChannelId)
+	if err == nil {
+		msg := deliveryMsg{PushMsg: &db.PushMsg{}}
+		if err = json.Unmarshal([]byte(delivery.Msg), &msg); err == nil {
+			msg.PushMsg.Image = msg.Image
+			err = d.send(&channel, msg.PushMsg)
+			if err == nil {
+				d.breaker.success(channel.ID)
+			} else {
+				d.breaker.failure(channel.ID, now)
+			}
+		}
+	}
+	status, nextAt := deliveryNext(attempts, d.maxAttempts, d.backoff, err == nil, now)
+	ups["status"], ups["next_at"] = status, nextAt
+	if err != nil {
+		ups["last_error"] = deliveryTruncate(err.Error(), 1024)
+		return ups, errors.Wrapf(err, "delivery %d attempt %d", delivery.ID, attempts)
+	}
+	ups["last_error"] = ""
+	return ups, nil
+}
+
+// deliveryNext status and time of the next attempt, a failed delivery is given up after maxAttempts
+func deliveryNext(attempts, maxAttempts int, backoff time.Duration, delivered bool, now time.Time) (int, int64) {
+	switch {
+	case delivered:
+		return db.DeliveryStatusSuccess, now.Unix()
+	case attempts >= maxAttempts:
+		return db.DeliveryStatusFailed, now.Unix()
+	}
+	return db.DeliveryStatusPending, now.Add(deliveryBackoff(backoff, attempts)).Unix()
+}
+
+// deliveryBackoff wait before the attempt following the given number of attempts, doubled each time up to an hour
+func deliveryBackoff(backoff time.Duration, attempts int) time.Duration {
+	if attempts <= 0 {
+		return 0
+	}
+	res := backoff
+	for i := 1; i < attempts && res < deliveryMaxBackoff; i++ {
+		res *= 2
+	}
+	if res > deliveryMaxBackoff {
+		return deliveryMaxBackoff
+	}
+	return res
+}
+
+func deliveryTruncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
+
+// deliveryBreaker per-channel circuit breaker, opened by consecutive failures for a cooldown.
+// Once the cooldown is over the next push is let through, a failure opens it again.
+type deliveryBreaker interface {
+	open(channelId int, now time.Time) bool
+	success(channelId int)
+	failure(channelId int, now time.Time)
+}
+
+type memoryBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  map[int]int
+	openUntil map[int]time.Time
+}
+
+func newMemoryBreaker(threshold int, cooldown time.Duration) *memoryBreaker {
+	return &memoryBreaker{threshold: threshold, cooldown: cooldown, failures: make(map[int]int), openUntil: make(map[int]time.Time)}
+}
+
+func (b *memoryBreaker) open(channelId int, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return now.Before(b.openUntil[channelId])
+}
+
+func (b *memoryBreaker) success(channelId int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.failures, channelId)
+	delete(b.openUntil, channelId)
+}
+
+func (b *memoryBreaker) failure(channelId int, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures[channelId]++
+	if b.failures[channelId] >= b.threshold {
+		b.openUntil[channelId] = now.Add(b.cooldown)
+	}
+}
+
+// redisBreaker the failures and the open state are redis keys, every copy sees the same breaker
+type redisBreaker struct {
+	redis     *eredis.Component
+	threshold int
+	cooldown  time.Duration
+}
+
+// redisBreakerKeys the key of the failures then the one of the open state
+func redisBreakerKeys(channelId int) [2]string {
+	return [2]string{fmt.Sprintf("clickvisual:delivery:failures:%d", channelId), fmt.Sprintf("clickvisual:delivery:open:%d", channelId)}
+}
+
+func (b *redisBreaker) open(channelId int, _ time.Time) bool {
+	res, err := b.redis.Exists(context.Background(), redisBreakerKeys(channelId)[1])
+	if err != nil {
+		// the channel is tried when redis is down
+		core.LoggerError("deliverer", "breakerOpen", err)
+		return false
+	}
+	return res
+}
+
+func (b *redisBreaker) success(channelId int) {
+	for _, key := range redisBreakerKeys(channelId) {
+		_, err := b.redis.Del(context.Background(), key)
+		core.LoggerError("deliverer", "breakerSuccess", err)
+	}
+}
+
+func (b *redisBreaker) failure(channelId int, _ time.Time) {
+	ctx := context.Background()
+	keys := redisBreakerKeys(channelId)
+	failures, err := b.redis.Incr(ctx, keys[0])
+	if err != nil {
+		core.LoggerError("deliverer", "breakerFailure", err)
+		return
+	}
+	// failures are consecutive within a day
+	_, err = b.redis.Expire(ctx, keys[0], 24*time.Hour)
+	core.LoggerError("deliverer", "breakerFailure", err)
+	if failures >= int64(b.threshold) {
+		core.LoggerError("deliverer", "breakerOpen", b.redis.Set(ctx, keys[1], 1, b.cooldown))
+	}
+}
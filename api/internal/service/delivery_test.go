@@ -0,0 +1,66 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+func Test_deliveryBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: 0},
+		{attempts: 1, want: 30 * time.Second},
+		{attempts: 2, want: time.Minute},
+		{attempts: 4, want: 4 * time.Minute},
+		{attempts: 8, want: time.Hour},
+		{attempts: 100, want: time.Hour},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, deliveryBackoff(30*time.Second, tt.attempts), "attempts %d", tt.attempts)
+	}
+}
+
+func Test_deliveryNext(t *testing.T) {
+	now := time.Unix(1000, 0)
+	status, nextAt := deliveryNext(3, 8, 30*time.Second, true, now)
+	assert.Equal(t, db.DeliveryStatusSuccess, status)
+	assert.Equal(t, int64(1000), nextAt)
+
+	status, nextAt = deliveryNext(3, 8, 30*time.Second, false, now)
+	assert.Equal(t, db.DeliveryStatusPending, status)
+	assert.Equal(t, int64(1120), nextAt)
+
+	status, _ = deliveryNext(8, 8, 30*time.Second, false, now)
+	assert.Equal(t, db.DeliveryStatusFailed, status)
+	// a manual resend of a failed delivery
+	status, _ = deliveryNext(9, 8, 30*time.Second, false, now)
+	assert.Equal(t, db.DeliveryStatusFailed, status)
+}
+
+func Test_memoryBreaker(t *testing.T) {
+	b := newMemoryBreaker(3, time.Minute)
+	now := time.Unix(1000, 0)
+
+	b.failure(1, now)
+	b.failure(1, now)
+	assert.False(t, b.open(1, now))
+	b.failure(1, now)
+	assert.True(t, b.open(1, now.Add(59*time.Second)))
+	assert.False(t, b.open(2, now))
+
+	// half open after the cooldown, a failure opens it again
+	assert.False(t, b.open(1, now.Add(time.Minute)))
+	b.failure(1, now.Add(time.Minute))
+	assert.True(t, b.open(1, now.Add(90*time.Second)))
+
+	b.success(1)
+	assert.False(t, b.open(1, now.Add(90*time.Second)))
+	b.failure(1, now)
+	assert.False(t, b.open(1, now))
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"time"
+
+	"github.com/ego-component/egorm"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"gorm.io/gorm"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/service/alarm/pusher"
+)
+
+// escalator notifies the next steps of the escalation policy while a firing alarm is not acknowledged
+type escalator struct {
+	ticker workerTicker
+}
+
+// incident firing period of an alarm, from the notification which fired it until all its filters are resolved
+type incident struct {
+	FiringAt int64 `json:"firingAt"` // 0 when the alarm is not firing
+	AckUid   int   `json:"ackUid"`
+	AckTime  int64 `json:"ackTime"`
+	Step     int   `json:"step"` // latest escalation step notified, the first step is notified with the alarm message
+}
+
+func NewEscalator() *escalator {
+	return &escalator{}
+}
+
+func (e *escalator) tickerCheck() {
+	interval := econf.GetDuration("app.alertEscalateInterval")
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	e.ticker.start("escalator", interval, nil, func() error { return e.check(time.Now()) })
+}
+
+func (e *escalator) stop() {
+	e.ticker.stop()
+}
+
+func (e *escalator) check(now time.Time) (err error) {
+	conds := egorm.Conds{}
+	conds["status"] = db.AlarmStatusFiring
+	conds["escalation_id"] = egorm.Cond{
+		Op:  "!=",
+		Val: 0,
+	}
+	alarms, err := db.AlarmList(conds)
+	if err != nil {
+		return err
+	}
+	for _, alarm := range alarms {
+		err = multierr.Append(err, e.escalate(alarm, now))
+	}
+	return err
+}
+
+// escalate notifies the next step when its delay is over
+func (e *escalator) escalate(alarm *db.Alarm, now time.Time) error {
+	policy, err := db.AlarmEscalationInfo(invoker.Db, alarm.EscalationId)
+	if err != nil {
+		return err
+	}
+	cur, err := Alert.Incident(alarm.ID)
+	if err != nil {
+		return err
+	}
+	next, ok := escalationNext(policy.Steps, cur, now.Unix())
+	if !ok {
+		return nil
+	}
+	step := policy.Steps[next]
+	uids, err := db.EscalationStepUids(invoker.Db, step, now.Unix())
+	if err != nil {
+		return err
+	}
+	channelIds := step.ChannelIds
+	if len(channelIds) == 0 {
+		channelIds = alarm.ChannelIds
+	}
+	history := db.AlarmHistory{AlarmId: alarm.ID, FilterStatus: db.AlarmStatusFiring, IsPushed: db.PushedStatusPending, Typ: db.HistoryTypEscalation, Step: next}
+	if err = db.AlarmHistoryCreate(invoker.Db, &history); err != nil {
+		return err
+	}
+	conds := egorm.Conds{}
+	conds["alarm_id"] = alarm.ID
+	conds["status"] = db.AlarmStatusFiring
+	filters, err := db.AlarmFilterList(invoker.Db, conds)
+	if err != nil {
+		return err
+	}
+	if len(filters) == 0 {
+		return nil
+	}
+	// incident channels add the step to the alert of a firing filter, which is resolved with it
+	msg, msgWithAt := pusher.BuildEscalationMsg(alarm, filters[0], next, uids, time.Unix(cur.FiringAt, 0))
+	queued, err := Deliverer.Execute(channelIds, msg, msgWithAt, []int{history.ID})
+	if err != nil {
+		_ = db.AlarmHistoryUpdate(invoker.Db, history.ID, map[string]interface{}{"is_pushed": db.PushedStatusFail})
+		return errors.Wrapf(err, "alarm %d escalation step %d", alarm.ID, next)
+	}
+	if queued {
+		// pending until the deliverer is done with it
+		return nil
+	}
+	return db.AlarmHistoryUpdate(invoker.Db, history.ID, map[string]interface{}{"is_pushed": db.PushedStatusSuccess})
+}
+
+// escalationNext the step to notify now, one step each time
+func escalationNext(steps db.EscalationSteps, cur incident, now int64) (int, bool) {
+	if cur.FiringAt == 0 || cur.AckUid != 0 {
+		return 0, false
+	}
+	next := cur.Step + 1
+	if next >= len(steps) || now < cur.FiringAt+int64(steps[next].Delay)*60 {
+		return 0, false
+	}
+	return next, true
+}
+
+// Incident current firing period of the alarm, from the notification which fired it while none of its filters was firing
+func (i *alert) Incident(alarmId int) (res incident, err error) {
+	alarm, err := db.AlarmInfo(invoker.Db, alarmId)
+	if err != nil {
+		return res, err
+	}
+	if alarm.Status != db.AlarmStatusFiring {
+		return res, nil
+	}
+	conds := egorm.Conds{}
+	conds["alarm_id"] = alarmId
+	conds["typ"] = db.HistoryTypNotification
+	conds["is_incident_start"] = 1
+	start, err := db.AlarmHistoryLatest(invoker.Db, conds)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return res, nil
+		}
+		return res, err
+	}
+	conds = egorm.Conds{}
+	conds["alarm_id"] = alarmId
+	conds["id"] = egorm.Cond{
+		Op:  ">",
+		Val: start.ID,
+	}
+	histories, err := db.AlarmHistoryList(invoker.Db, conds)
+	if err != nil {
+		return res, err
+	}
+	return incidentFromHistories(&start, histories), nil
+}
+
+// incidentFromHistories the escalations and acknowledgement following the start of the incident
+func incidentFromHistories(start *db.AlarmHistory, histories []*db.AlarmHistory) (res incident) {
+	res.FiringAt = start.Ctime
+	for _, h := range histories {
+		switch h.Typ {
+		case db.HistoryTypEscalation:
+			if h.Step > res.Step {
+				res.Step = h.Step
+			}
+		case db.HistoryTypAck:
+			if res.AckUid == 0 {
+				res.AckUid, res.AckTime = h.Uid, h.Ctime
+			}
+		}
+	}
+	return res
+}
+
+// Ack acknowledges the firing alarm, its escalation stops until it fires again
+func (i *alert) Ack(uid, alarmId int) (res incident, err error) {
+	res, err = i.Incident(alarmId)
+	if err != nil {
+		return res, err
+	}
+	if res.FiringAt == 0 {
+		return res, errors.New("alarm is not firing")
+	}
+	if res.AckUid != 0 {
+		return res, errors.Errorf("alarm is already acknowledged by user %d", res.AckUid)
+	}
+	history := db.AlarmHistory{AlarmId: alarmId, FilterStatus: db.AlarmStatusFiring, Typ: db.HistoryTypAck, Step: res.Step, Uid: uid}
+	if err = db.AlarmHistoryCreate(invoker.Db, &history); err != nil {
+		return res, err
+	}
+	res.AckUid, res.AckTime = uid, history.Ctime
+	return res, nil
+}
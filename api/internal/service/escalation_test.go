@@ -0,0 +1,54 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+func Test_incidentFromHistories(t *testing.T) {
+	history := func(typ, status, step, uid int, ctime int64) *db.AlarmHistory {
+		h := &db.AlarmHistory{Typ: typ, FilterStatus: status, Step: step, Uid: uid}
+		h.Ctime = ctime
+		return h
+	}
+	start := history(db.HistoryTypNotification, db.AlarmStatusFiring, 0, 0, 100)
+	assert.Equal(t, incident{FiringAt: 100}, incidentFromHistories(start, nil))
+	assert.Equal(t, incident{FiringAt: 100, Step: 2, AckUid: 7, AckTime: 400}, incidentFromHistories(start, []*db.AlarmHistory{
+		// another filter firing and one resolving do not start a new incident
+		history(db.HistoryTypNotification, db.AlarmStatusFiring, 0, 0, 150),
+		history(db.HistoryTypNotification, db.AlarmStatusNormal, 0, 0, 160),
+		history(db.HistoryTypEscalation, db.AlarmStatusFiring, 1, 0, 200),
+		history(db.HistoryTypEscalation, db.AlarmStatusFiring, 2, 0, 300),
+		history(db.HistoryTypAck, db.AlarmStatusFiring, 2, 7, 400),
+		history(db.HistoryTypAck, db.AlarmStatusFiring, 2, 8, 500),
+	}))
+}
+
+func Test_escalationNext(t *testing.T) {
+	steps := db.EscalationSteps{{Delay: 0}, {Delay: 5}, {Delay: 15}}
+	tests := []struct {
+		name     string
+		cur      incident
+		now      int64
+		want     int
+		wantNext bool
+	}{
+		{name: "not firing", cur: incident{}, now: 10000},
+		{name: "acknowledged", cur: incident{FiringAt: 1000, AckUid: 1}, now: 10000},
+		{name: "before delay", cur: incident{FiringAt: 1000}, now: 1000 + 5*60 - 1},
+		{name: "second step", cur: incident{FiringAt: 1000}, now: 1000 + 5*60, want: 1, wantNext: true},
+		{name: "one step at a time", cur: incident{FiringAt: 1000}, now: 1000 + 60*60, want: 1, wantNext: true},
+		{name: "third step", cur: incident{FiringAt: 1000, Step: 1}, now: 1000 + 15*60, want: 2, wantNext: true},
+		{name: "last step notified", cur: incident{FiringAt: 1000, Step: 2}, now: 10000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := escalationNext(steps, tt.cur, tt.now)
+			assert.Equal(t, tt.wantNext, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ego-component/egorm"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+// evaluatorOffset same as the offset of the prometheus rule, waits for the latest logs to be written
+const evaluatorOffset = 10 * time.Second
+
+// evaluator evaluates the alarms of native evaluator instances without prometheus
+type evaluator struct {
+	mu       sync.Mutex
+	lastEval map[int]time.Time
+	ticker   workerTicker
+}
+
+func NewEvaluator() *evaluator {
+	return &evaluator{
+		lastEval: make(map[int]time.Time),
+	}
+}
+
+func (e *evaluator) tickerCheck() {
+	interval := econf.GetDuration("app.alertEvaluateInterval")
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	e.ticker.start("evaluator", interval, nil, func() error { return e.check(time.Now()) })
+}
+
+func (e *evaluator) stop() {
+	e.ticker.stop()
+}
+
+// check evaluates the opened alarms whose interval is due
+func (e *evaluator) check(now time.Time) (err error) {
+	conds := egorm.Conds{}
+	conds["status"] = egorm.Cond{
+		Op:  ">",
+		Val: db.AlarmStatusClose,
+	}
+	alarms, err := db.AlarmList(conds)
+	if err != nil {
+		return err
+	}
+	instances := make(map[int]db.BaseInstance)
+	for _, alarm := range alarms {
+		if !e.isDue(alarm, now) {
+			continue
+		}
+		err = multierr.Append(err, e.evaluate(alarm, instances, now))
+	}
+	return err
+}
+
+func (e *evaluator) isDue(alarm *db.Alarm, now time.Time) bool {
+	interval := alarm.GetInterval()
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if last, ok := e.lastEval[alarm.ID]; ok && now.Sub(last) < interval {
+		return false
+	}
+	e.lastEval[alarm.ID] = now
+	return true
+}
+
+// evaluate runs the filters of the alarm that belong to native evaluator instances
+func (e *evaluator) evaluate(alarm *db.Alarm, instances map[int]db.BaseInstance, now time.Time) (err error) {
+	conds := egorm.Conds{}
+	conds["alarm_id"] = alarm.ID
+	filters, err := db.AlarmFilterList(invoker.Db, conds)
+	if err != nil {
+		return err
+	}
+	for _, filter := range filters {
+		table, errTable := db.TableInfo(invoker.Db, filter.Tid)
+		if errTable != nil {
+			err = multierr.Append(err, errTable)
+			continue
+		}
+		instance, ok := instances[table.Database.Iid]
+		if !ok {
+			instance, errTable = db.InstanceInfo(invoker.Db, table.Database.Iid)
+			if errTable != nil {
+				err = multierr.Append(err, errTable)
+				continue
+			}
+			instances[table.Database.Iid] = instance
+		}
+		if instance.AlertEvaluator != db.AlertEvaluatorNative {
+			continue
+		}
+		err = multierr.Append(err, e.evaluateFilter(alarm, &table, filter, now))
+	}
+	return err
+}
+
+func (e *evaluator) evaluateFilter(alarm *db.Alarm, table *db.BaseTable, filter *db.AlarmFilter, now time.Time) error {
+	op, err := InstanceManager.Load(table.Database.Iid)
+	if err != nil {
+		return err
+	}
+	conds := egorm.Conds{}
+	conds["alarm_id"] = alarm.ID
+	conds["filter_id"] = filter.ID
+	conditions, err := db.AlarmConditionList(conds)
+	if err != nil {
+		return err
+	}
+	et := now.Add(-evaluatorOffset)
+	samples, err := op.GetAlertSamples(table, filter, conditions, et.Add(-alarm.GetInterval()).Unix(), et.Unix())
+	if err != nil {
+		return errors.Wrapf(err, "alarm %d filter %d", alarm.ID, filter.ID)
+	}
+	status := evaluatorStatus(filter, conditions, samples, alarm.NoDataOp)
+	if status == filter.Status {
+		return nil
+	}
+	isResolved := filter.Status == db.AlarmStatusFiring
+	if status == db.AlarmStatusFiring || (isResolved && alarm.IsDisableResolve != 1) {
+		// the state is updated with the alarm history as alertmanager does
+		return Alert.HandlerAlertManager(alarm.Uuid, strconv.Itoa(filter.ID), evaluatorNotification(alarm, filter, status, now))
+	}
+	// first evaluation of an opened filter, or a resolve without message
+	if isResolved {
+		// the firing period ends in the history all the same
+		if err = db.AlarmHistoryCreate(invoker.Db, &db.AlarmHistory{AlarmId: alarm.ID, FilterId: filter.ID, FilterStatus: status, IsPushed: db.PushedStatusRepeat}); err != nil {
+			return err
+		}
+	}
+	filter.Status = status
+	if err = filter.UpdateStatus(invoker.Db); err != nil {
+		return err
+	}
+	if err = alarm.UpdateStatus(invoker.Db, alarm.GetStatus(invoker.Db)); err != nil {
+		return err
+	}
+	Compositor.Trigger()
+	return nil
+}
+
+// evaluatorStatus the filter status computed from the samples.
+// Count filters have no row when no log matches, which is a count of 0; other filters are resolved on no data
+// unless NoDataOp says otherwise, as prometheus does when the series is absent.
+func evaluatorStatus(filter *db.AlarmFilter, conditions []*db.AlarmCondition, samples []float64, noDataOp int) int {
+	if len(samples) == 0 {
+		switch noDataOp {
+		case NoDataOpOK:
+			return db.AlarmStatusNormal
+		case NoDataOpAlert:
+			return db.AlarmStatusFiring
+		}
+		if filter.IsAggregation() || db.HasAnomaly(conditions) {
+			return db.AlarmStatusNormal
+		}
+		// the aggregations of no sample are 0
+		if evaluatorMatch(conditions, nil) {
+			return db.AlarmStatusFiring
+		}
+		return db.AlarmStatusNormal
+	}
+	if db.HasAnomaly(conditions) {
+		// the sample is the val of the anomaly sql
+		if samples[0] > 0 {
+			return db.AlarmStatusFiring
+		}
+		return db.AlarmStatusNormal
+	}
+	if evaluatorMatch(conditions, samples) {
+		return db.AlarmStatusFiring
+	}
+	return db.AlarmStatusNormal
+}
+
+// evaluatorMatch conditions are sorted like the prometheus expression, WHEN first, then AND, then OR
+func evaluatorMatch(conditions []*db.AlarmCondition, samples []float64) bool {
+	sorted := make([]*db.AlarmCondition, len(conditions))
+	copy(sorted, conditions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].SetOperatorTyp < sorted[j].SetOperatorTyp
+	})
+	res := false
+	for k, condition := range sorted {
+		cur := evaluatorCond(condition, evaluatorAggregate(condition.SetOperatorExp, samples))
+		switch {
+		case k == 0:
+			res = cur
+		case condition.SetOperatorTyp == 1:
+			res = res && cur
+		case condition.SetOperatorTyp == 2:
+			res = res || cur
+		}
+	}
+	return res
+}
+
+// evaluatorAggregate 0 avg 1 min 2 max 3 sum 4 count
+func evaluatorAggregate(exp int, samples []float64) float64 {
+	if len(samples) == 0 {
+		return 0
+	}
+	var (
+		sum float64
+		min = math.Inf(1)
+		max = math.Inf(-1)
+	)
+	for _, v := range samples {
+		sum += v
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	switch exp {
+	case 0:
+		return sum / float64(len(samples))
+	case 1:
+		return min
+	case 2:
+		return max
+	case 3:
+		return sum
+	case 4:
+		return float64(len(samples))
+	}
+	return 0
+}
+
+// evaluatorCond 0 above 1 below 2 outside range 3 within range
+func evaluatorCond(condition *db.AlarmCondition, val float64) bool {
+	val1, val2 := float64(condition.Val1), float64(condition.Val2)
+	switch condition.Cond {
+	case 0:
+		return val > val1
+	case 1:
+		return val < val1
+	case 2:
+		return val < val1 || val > val2
+	case 3:
+		return val >= val1 && val <= val2
+	}
+	return false
+}
+
+func evaluatorNotification(alarm *db.Alarm, filter *db.AlarmFilter, status int, now time.Time) db.Notification {
+	notificationStatus := "resolved"
+	if status == db.AlarmStatusFiring {
+		notificationStatus = "firing"
+	}
+	labels := map[string]string{
+		"uuid":     alarm.Uuid,
+		"alarmId":  strconv.Itoa(alarm.ID),
+		"filterId": strconv.Itoa(filter.ID),
+	}
+	return db.Notification{
+		Status:       notificationStatus,
+		Receiver:     "clickvisual",
+		CommonLabels: labels,
+		Alerts: []db.Alert{{
+			Labels:   labels,
+			StartsAt: now,
+		}},
+	}
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+func Test_evaluatorStatus(t *testing.T) {
+	above10 := &db.AlarmCondition{SetOperatorTyp: 0, SetOperatorExp: 3, Cond: 0, Val1: 10}
+	tests := []struct {
+		name       string
+		conditions []*db.AlarmCondition
+		samples    []float64
+		filter     db.AlarmFilter
+		noDataOp   int
+		want       int
+	}{
+		{
+			name:       "sum above",
+			conditions: []*db.AlarmCondition{above10},
+			samples:    []float64{4, 5, 6},
+			want:       db.AlarmStatusFiring,
+		},
+		{
+			name:       "sum not above",
+			conditions: []*db.AlarmCondition{above10},
+			samples:    []float64{4, 5},
+			want:       db.AlarmStatusNormal,
+		},
+		{
+			name: "when and",
+			conditions: []*db.AlarmCondition{
+				{SetOperatorTyp: 1, SetOperatorExp: 2, Cond: 1, Val1: 5},
+				above10,
+			},
+			samples: []float64{4, 5, 6},
+			want:    db.AlarmStatusNormal,
+		},
+		{
+			name: "when or",
+			conditions: []*db.AlarmCondition{
+				{SetOperatorTyp: 2, SetOperatorExp: 4, Cond: 3, Val1: 1, Val2: 3},
+				above10,
+			},
+			samples: []float64{1, 2},
+			want:    db.AlarmStatusFiring,
+		},
+		{
+			name:       "avg outside range",
+			conditions: []*db.AlarmCondition{{SetOperatorExp: 0, Cond: 2, Val1: 2, Val2: 4}},
+			samples:    []float64{5, 7},
+			want:       db.AlarmStatusFiring,
+		},
+		{
+			name:       "min within range",
+			conditions: []*db.AlarmCondition{{SetOperatorExp: 1, Cond: 3, Val1: 2, Val2: 4}},
+			samples:    []float64{1, 7},
+			want:       db.AlarmStatusNormal,
+		},
+		{
+			name:       "no log of a count filter is a count of 0",
+			conditions: []*db.AlarmCondition{above10},
+			want:       db.AlarmStatusNormal,
+		},
+		{
+			name:       "no log of a count filter below",
+			conditions: []*db.AlarmCondition{{SetOperatorExp: 3, Cond: 1, Val1: 1}},
+			want:       db.AlarmStatusFiring,
+		},
+		{
+			name:       "no data of an aggregation filter resolves",
+			conditions: []*db.AlarmCondition{{SetOperatorExp: 3, Cond: 1, Val1: 1}},
+			filter:     db.AlarmFilter{Mode: db.AlarmModeAggregation},
+			want:       db.AlarmStatusNormal,
+		},
+		{
+			name:       "no data ok",
+			conditions: []*db.AlarmCondition{above10},
+			noDataOp:   NoDataOpOK,
+			want:       db.AlarmStatusNormal,
+		},
+		{
+			name:       "no data alert",
+			conditions: []*db.AlarmCondition{above10},
+			noDataOp:   NoDataOpAlert,
+			want:       db.AlarmStatusFiring,
+		},
+		{
+			name:       "anomaly matched",
+			conditions: []*db.AlarmCondition{{CondTyp: db.ConditionTypChange, Offset: 3600, Val1: 100}},
+			samples:    []float64{1},
+			want:       db.AlarmStatusFiring,
+		},
+		{
+			name:       "anomaly not matched",
+			conditions: []*db.AlarmCondition{above10, {SetOperatorTyp: 1, CondTyp: db.ConditionTypBaseline, Periods: 5, Factor: 3}},
+			samples:    []float64{0},
+			want:       db.AlarmStatusNormal,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := evaluatorStatus(&tt.filter, tt.conditions, tt.samples, tt.noDataOp); got != tt.want {
+				t.Errorf("evaluatorStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
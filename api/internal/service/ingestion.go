@@ -0,0 +1,180 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ego-component/egorm"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+	"go.uber.org/multierr"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service/alarm/pusher"
+)
+
+const defaultIngestionStallSeconds = 600
+
+// ingestion monitors the consuming state of kafka engine storages
+type ingestion struct {
+	mu        sync.RWMutex
+	health    map[int]view.IngestionHealth
+	lastCheck int64
+	ticker    workerTicker
+}
+
+func NewIngestion() *ingestion {
+	return &ingestion{
+		health: make(map[int]view.IngestionHealth),
+	}
+}
+
+// Health latest health of the storage, false before the first check
+func (i *ingestion) Health(tid int) (view.IngestionHealth, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	res, ok := i.health[tid]
+	return res, ok
+}
+
+// tickerCheck starts checking, the stop channel is set before it returns so a following stop always ends it
+func (i *ingestion) tickerCheck() {
+	interval := econf.GetDuration("app.ingestionCheckInterval")
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	i.ticker.start("ingestion", interval, nil, i.check)
+}
+
+func (i *ingestion) stop() {
+	i.ticker.stop()
+}
+
+func (i *ingestion) check() (err error) {
+	conds := egorm.Conds{}
+	conds["sql_stream"] = egorm.Cond{
+		Op:  "!=",
+		Val: "",
+	}
+	tables, err := db.TableList(invoker.Db, conds)
+	if err != nil {
+		return err
+	}
+	monitors, err := db.IngestionList(invoker.Db, egorm.Conds{})
+	if err != nil {
+		return err
+	}
+	monitorMap := make(map[int]*db.BaseIngestion, len(monitors))
+	for _, m := range monitors {
+		monitorMap[m.Tid] = m
+	}
+	now := time.Now().Unix()
+	since := i.lastCheck
+	if since == 0 {
+		since = now - int64(time.Minute.Seconds())
+	}
+	// only clickhouse storages consume kafka
+	instances, err := db.InstanceList(egorm.Conds{"datasource": db.DatasourceClickHouse})
+	if err != nil {
+		return err
+	}
+	supported := make(map[int]struct{}, len(instances))
+	for _, instance := range instances {
+		supported[instance.ID] = struct{}{}
+	}
+	health := make(map[int]view.IngestionHealth, len(tables))
+	for _, table := range tables {
+		if table.Database == nil {
+			continue
+		}
+		if _, ok := supported[table.Database.Iid]; !ok {
+			continue
+		}
+		op, errLoad := InstanceManager.Load(table.Database.Iid)
+		if errLoad != nil {
+			err = multierr.Append(err, errLoad)
+			continue
+		}
+		res, errHealth := op.IngestionHealth(table, since)
+		if errHealth != nil {
+			err = multierr.Append(err, errHealth)
+			continue
+		}
+		monitor, ok := monitorMap[table.ID]
+		if !ok {
+			monitor = &db.BaseIngestion{Tid: table.ID, StallSeconds: defaultIngestionStallSeconds}
+		}
+		res.Stalled = ingestionStalled(monitor, res)
+		health[table.ID] = res
+		if ok {
+			err = multierr.Append(err, i.alarm(table, monitor, res))
+		}
+	}
+	i.mu.Lock()
+	i.health = health
+	i.lastCheck = now
+	i.mu.Unlock()
+	return err
+}
+
+// ingestionStalled no data for the stall seconds or consumer errors reach the threshold
+func ingestionStalled(monitor *db.BaseIngestion, health view.IngestionHealth) bool {
+	stallSeconds := int64(monitor.StallSeconds)
+	if stallSeconds <= 0 {
+		stallSeconds = defaultIngestionStallSeconds
+	}
+	if health.LatestTime == 0 || health.Freshness > stallSeconds {
+		return true
+	}
+	errs := health.RecentErrors
+	if health.BrokenMessages > 0 {
+		errs += uint64(health.BrokenMessages)
+	}
+	return monitor.ErrorThreshold > 0 && errs >= uint64(monitor.ErrorThreshold)
+}
+
+// alarm pushes when the status of the storage changes
+func (i *ingestion) alarm(table *db.BaseTable, monitor *db.BaseIngestion, health view.IngestionHealth) error {
+	status := db.IngestionStatusNormal
+	if health.Stalled {
+		status = db.IngestionStatusStalled
+	}
+	if status == monitor.Status {
+		return nil
+	}
+	if err := db.IngestionUpdate(invoker.Db, monitor.ID, map[string]interface{}{"status": status}); err != nil {
+		return err
+	}
+	if len(monitor.ChannelIds) == 0 {
+		return nil
+	}
+	elog.Info("ingestion", elog.String("step", "alarm"), elog.Int("tid", table.ID), elog.Any("health", health))
+	msg := pusher.BuildIngestionMsg(table, health, health.Stalled)
+	_, err := Deliverer.Execute(monitor.ChannelIds, msg, msg, nil)
+	return err
+}
+
+// UpdateMonitor creates or updates the ingestion monitor settings of the storage
+func (i *ingestion) UpdateMonitor(tid int, req view.ReqStorageUpdateIngestion) error {
+	conds := egorm.Conds{}
+	conds["tid"] = tid
+	monitor, err := db.IngestionInfoX(invoker.Db, conds)
+	if err != nil {
+		return err
+	}
+	if monitor.ID == 0 {
+		return db.IngestionCreate(invoker.Db, &db.BaseIngestion{
+			Tid:            tid,
+			StallSeconds:   req.StallSeconds,
+			ErrorThreshold: req.ErrorThreshold,
+			ChannelIds:     req.ChannelIds,
+		})
+	}
+	ups := make(map[string]interface{})
+	ups["stall_seconds"] = req.StallSeconds
+	ups["error_threshold"] = req.ErrorThreshold
+	ups["channel_ids"] = db.Ints(req.ChannelIds)
+	return db.IngestionUpdate(invoker.Db, monitor.ID, ups)
+}
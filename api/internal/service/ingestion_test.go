@@ -0,0 +1,82 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+)
+
+func Test_ingestionStalled(t *testing.T) {
+	tests := []struct {
+		name    string
+		monitor db.BaseIngestion
+		health  view.IngestionHealth
+		want    bool
+	}{
+		{
+			name:    "fresh",
+			monitor: db.BaseIngestion{StallSeconds: 600},
+			health:  view.IngestionHealth{LatestTime: 1, Freshness: 30},
+			want:    false,
+		},
+		{
+			name:    "no data",
+			monitor: db.BaseIngestion{StallSeconds: 600},
+			health:  view.IngestionHealth{},
+			want:    true,
+		},
+		{
+			name:    "default stall seconds",
+			monitor: db.BaseIngestion{},
+			health:  view.IngestionHealth{LatestTime: 1, Freshness: 601},
+			want:    true,
+		},
+		{
+			name:    "errors reach threshold",
+			monitor: db.BaseIngestion{StallSeconds: 600, ErrorThreshold: 3},
+			health:  view.IngestionHealth{LatestTime: 1, Freshness: 30, RecentErrors: 3},
+			want:    true,
+		},
+		{
+			name:    "dead-letter messages count as errors",
+			monitor: db.BaseIngestion{StallSeconds: 600, ErrorThreshold: 3},
+			health:  view.IngestionHealth{LatestTime: 1, Freshness: 30, RecentErrors: 1, BrokenMessages: 2},
+			want:    true,
+		},
+		{
+			name:    "unknown dead-letter messages",
+			monitor: db.BaseIngestion{StallSeconds: 600, ErrorThreshold: 3},
+			health:  view.IngestionHealth{LatestTime: 1, Freshness: 30, RecentErrors: 2, BrokenMessages: -1},
+			want:    false,
+		},
+		{
+			name:    "errors ignored without threshold",
+			monitor: db.BaseIngestion{StallSeconds: 600},
+			health:  view.IngestionHealth{LatestTime: 1, Freshness: 30, RecentErrors: 3},
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ingestionStalled(&tt.monitor, tt.health); got != tt.want {
+				t.Errorf("ingestionStalled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ingestion_stop(t *testing.T) {
+	i := NewIngestion()
+	i.tickerCheck()
+	i.ticker.mu.Lock()
+	stopC := i.ticker.stopC
+	i.ticker.mu.Unlock()
+	i.stop()
+	select {
+	case <-stopC:
+	default:
+		t.Fatal("stop right after tickerCheck did not close the stop channel")
+	}
+	i.stop()
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/gotomicro/ego/core/elog"
 
 	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
 	"github.com/clickvisual/clickvisual/api/internal/pkg/kube"
 	"github.com/clickvisual/clickvisual/api/internal/pkg/preempt"
 	"github.com/clickvisual/clickvisual/api/internal/service/configure"
@@ -24,6 +25,14 @@ var (
 	Alert           *alert
 	Node            *node
 	Storage         *srvStorage
+	Ingestion       *ingestion
+	Watchdog        *watchdog
+	Evaluator       *evaluator
+	Notifier        *notifier
+	Escalator       *escalator
+	RuleReconciler  *ruleReconciler
+	Compositor      *compositor
+	Deliverer       *deliverer
 	ppt             *preempt.Preempt
 )
 
@@ -54,24 +63,69 @@ func Init() error {
 
 	// Storage service start
 	Storage = NewSrvStorage()
+	Ingestion = NewIngestion()
+	Watchdog = NewWatchdog()
+	Evaluator = NewEvaluator()
+	Escalator = NewEscalator()
+	RuleReconciler = NewRuleReconciler()
+	Compositor = NewCompositor()
+	// failed pushes are retried by the worker copy, every copy pushes through the deliverer
+	Deliverer = NewDeliverer()
+	// notifications are grouped in redis in multi-copy mode, every copy flushes the due groups
+	Notifier = NewNotifier()
+	core.LoggerError("notifier", "recoverGroups", Notifier.recoverGroups())
+	Notifier.tickerCheck()
 	// Support for multiple copies mode
 	if econf.GetBool("app.isMultiCopy") {
-		sf := func() { Storage.tickerTraceWorker() }
-		ef := func() { Storage.stop() }
+		sf := func() {
+			Ingestion.tickerCheck()
+			Watchdog.tickerCheck()
+			Evaluator.tickerCheck()
+			Escalator.tickerCheck()
+			RuleReconciler.tickerCheck()
+			Compositor.tickerCheck()
+			Deliverer.tickerCheck()
+			Storage.tickerTraceWorker()
+		}
+		ef := func() {
+			Deliverer.stop()
+			Compositor.stop()
+			RuleReconciler.stop()
+			Escalator.stop()
+			Evaluator.stop()
+			Watchdog.stop()
+			Ingestion.stop()
+			Storage.stop()
+		}
 		elog.Debug("crontabRules", elog.String("step", "isMultiCopy"))
 		ppt = preempt.NewPreempt(context.Background(), invoker.Redis, "clickvisual:trace", sf, ef)
 		return nil
 	}
 	xgo.Go(func() { Storage.tickerTraceWorker() })
+	Ingestion.tickerCheck()
+	Watchdog.tickerCheck()
+	Evaluator.tickerCheck()
+	Escalator.tickerCheck()
+	RuleReconciler.tickerCheck()
+	Compositor.tickerCheck()
+	Deliverer.tickerCheck()
 	// Storage service start end
 	return nil
 }
 
 func Close() error {
+	Notifier.stop()
 	// Storage service stop
 	if econf.GetBool("app.isMultiCopy") {
 		ppt.Close()
 	} else {
+		Deliverer.stop()
+		Compositor.stop()
+		RuleReconciler.stop()
+		Escalator.stop()
+		Evaluator.stop()
+		Watchdog.stop()
+		Ingestion.stop()
 		Storage.stop()
 	}
 	// Storage service stop end
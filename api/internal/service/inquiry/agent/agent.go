@@ -352,6 +352,35 @@ func (a *Agent) UpdateMergeTreeTable(table *db2.BaseTable, update view.ReqStorag
 	panic("implement me")
 }
 
+func (a *Agent) UpdateRawLogIndex(table *db2.BaseTable, rawLogIndex int) error {
+	// TODO implement me
+	panic("implement me")
+}
+
+func (a *Agent) KafkaSample(database db2.BaseDatabase, brokers, topic string, limit int) ([]string, error) {
+	// TODO implement me
+	panic("implement me")
+}
+
+func (a *Agent) IngestionHealth(table *db2.BaseTable, since int64) (view.IngestionHealth, error) {
+	// TODO implement me
+	panic("implement me")
+}
+
+func (a *Agent) ListDeadLetter(table *db2.BaseTable, req view.ReqStorageDeadLetterList) (view.RespStorageDeadLetterList, error) {
+	// TODO implement me
+	panic("implement me")
+}
+
+func (a *Agent) ReplayDeadLetter(table *db2.BaseTable, req view.ReqStorageDeadLetterReplay) (uint64, error) {
+	// TODO implement me
+	panic("implement me")
+}
+
+func (a *Agent) ErrorPatterns(param view.ReqQuery, limit int) ([]view.ErrorPattern, error) {
+	return nil, errors.New("error patterns are not supported by the agent")
+}
+
 func (a *Agent) GetCreateSQL(database, table string) (string, error) {
 	// TODO implement me
 	panic("implement me")
@@ -362,6 +391,11 @@ func (a *Agent) GetAlertViewSQL(alarm *db2.Alarm, table db2.BaseTable, i int, it
 	panic("implement me")
 }
 
+func (a *Agent) GetAlertSamples(table *db2.BaseTable, filter *db2.AlarmFilter, conditions []*db2.AlarmCondition, st, et int64) ([]float64, error) {
+	// TODO implement me
+	panic("implement me")
+}
+
 func (a *Agent) GetTraceGraph(ctx context.Context) ([]view.RespJaegerDependencyDataModel, error) {
 	// TODO implement me
 	panic("implement me")
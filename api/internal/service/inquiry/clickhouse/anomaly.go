@@ -0,0 +1,131 @@
+package clickhouse
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+)
+
+// alarmAnomalySQL compiles the conditions of the filter to a query of one row whose val is 1 when they match,
+// run once per interval by the native evaluator. The alert view of prometheus rules only writes the log count.
+// The value of a window is the aggregation of the log count per time point, as the prometheus expression does,
+// end is the sql expression of the unix time where the current window of interval seconds ends.
+func alarmAnomalySQL(table *db.BaseTable, filter *db.AlarmFilter, conditions []*db.AlarmCondition, interval int64, end string) string {
+	when := filter.When
+	if when == "" {
+		when = "1=1"
+	}
+	if interval <= 0 {
+		interval = 60
+	}
+	sorted := make([]*db.AlarmCondition, len(conditions))
+	copy(sorted, conditions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].SetOperatorTyp < sorted[j].SetOperatorTyp
+	})
+	var (
+		withs   = make([]string, 0)
+		aliases = make(map[string]string)
+		param   = view.ReqQuery{TimeField: table.GetTimeField(), TimeFieldType: table.TimeFieldType}
+	)
+	// window alias of the window ending offset seconds before end, each window is queried once
+	window := func(exp int, offset int64) string {
+		key := fmt.Sprintf("%d|%d", exp, offset)
+		if alias, ok := aliases[key]; ok {
+			return alias
+		}
+		alias := fmt.Sprintf("w%d", len(withs))
+		timeCondition := genTimeConditionExpr(param, fmt.Sprintf("%s - %d", end, offset+interval), fmt.Sprintf("%s - %d", end, offset))
+		withs = append(withs, fmt.Sprintf("(SELECT if(count() = 0, 0, toFloat64(%s)) FROM (SELECT count() AS c FROM %s WHERE %s AND (%s) GROUP BY %s)) AS %s",
+			anomalyAggregate(exp), genName(table.Database.Name, table.Name), timeCondition, when, param.TimeField, alias))
+		aliases[key] = alias
+		return alias
+	}
+	var res string
+	for k, condition := range sorted {
+		cur := window(condition.SetOperatorExp, 0)
+		var exp string
+		switch condition.CondTyp {
+		case db.ConditionTypChange:
+			exp = anomalyCond(condition, anomalyChange(cur, window(condition.SetOperatorExp, int64(condition.Offset))))
+		case db.ConditionTypBaseline:
+			periods := make([]string, 0, condition.Periods)
+			for p := 1; p <= condition.Periods; p++ {
+				periods = append(periods, window(condition.SetOperatorExp, int64(p)*interval))
+			}
+			arr := "[" + strings.Join(periods, ", ") + "]"
+			factor := strconv.FormatFloat(condition.Factor, 'f', -1, 64)
+			lower := fmt.Sprintf("arrayAvg(%s) - %s * arrayReduce('stddevPop', %s)", arr, factor, arr)
+			upper := fmt.Sprintf("arrayAvg(%s) + %s * arrayReduce('stddevPop', %s)", arr, factor, arr)
+			switch condition.Cond {
+			case 0:
+				exp = anomalyRange(0, cur, upper, "")
+			case 1:
+				exp = anomalyRange(1, cur, lower, "")
+			default:
+				exp = anomalyRange(condition.Cond, cur, lower, upper)
+			}
+		case db.ConditionTypSeasonal:
+			seasons := make([]string, 0, condition.Periods)
+			for p := 1; p <= condition.Periods; p++ {
+				seasons = append(seasons, window(condition.SetOperatorExp, int64(p*condition.Offset)))
+			}
+			exp = anomalyCond(condition, anomalyChange(cur, "arrayAvg(["+strings.Join(seasons, ", ")+"])"))
+		default:
+			exp = anomalyCond(condition, cur)
+		}
+		switch {
+		case k == 0:
+			res = exp
+		case condition.SetOperatorTyp == 1:
+			res = fmt.Sprintf("(%s AND %s)", res, exp)
+		case condition.SetOperatorTyp == 2:
+			res = fmt.Sprintf("(%s OR %s)", res, exp)
+		}
+	}
+	if res == "" {
+		res = "0"
+	}
+	return fmt.Sprintf("WITH\n  %s\nSELECT toFloat64(%s) AS val", strings.Join(withs, ",\n  "), res)
+}
+
+// anomalyAggregate 0 avg 1 min 2 max 3 sum 4 count
+func anomalyAggregate(exp int) string {
+	switch exp {
+	case 1:
+		return "min(c)"
+	case 2:
+		return "max(c)"
+	case 3:
+		return "sum(c)"
+	case 4:
+		return "count()"
+	}
+	return "avg(c)"
+}
+
+// anomalyChange percentage change of cur versus prev, infinite when logs appear after none
+func anomalyChange(cur, prev string) string {
+	return fmt.Sprintf("if(%[2]s = 0, if(%[1]s = 0, 0, inf), (%[1]s - %[2]s) / %[2]s * 100)", cur, prev)
+}
+
+// anomalyCond 0 above 1 below 2 outside range 3 within range
+func anomalyCond(condition *db.AlarmCondition, val string) string {
+	return anomalyRange(condition.Cond, val, strconv.Itoa(condition.Val1), strconv.Itoa(condition.Val2))
+}
+
+func anomalyRange(cond int, val, val1, val2 string) string {
+	switch cond {
+	case 1:
+		return fmt.Sprintf("%s < %s", val, val1)
+	case 2:
+		return fmt.Sprintf("(%s < %s OR %s > %s)", val, val1, val, val2)
+	case 3:
+		return fmt.Sprintf("(%s >= %s AND %s <= %s)", val, val1, val, val2)
+	}
+	return fmt.Sprintf("%s > %s", val, val1)
+}
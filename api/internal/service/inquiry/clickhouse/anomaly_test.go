@@ -0,0 +1,66 @@
+package clickhouse
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+func Test_alarmAnomalySQL(t *testing.T) {
+	table := &db.BaseTable{Name: "logs", TimeField: "_time_second_", TimeFieldType: db.TimeFieldTypeDT, Database: &db.BaseDatabase{Name: "app"}}
+	filter := &db.AlarmFilter{When: "level='error'"}
+	tests := []struct {
+		name       string
+		conditions []*db.AlarmCondition
+		want       []string
+	}{
+		{
+			name:       "change",
+			conditions: []*db.AlarmCondition{{SetOperatorExp: 3, CondTyp: db.ConditionTypChange, Offset: 3600, Val1: 100}},
+			want: []string{
+				"(SELECT if(count() = 0, 0, toFloat64(sum(c))) FROM (SELECT count() AS c FROM `app`.`logs` WHERE _time_second_ >= toDateTime(1000 - 60) AND _time_second_ < toDateTime(1000 - 0) AND (level='error') GROUP BY _time_second_)) AS w0",
+				"_time_second_ >= toDateTime(1000 - 3660) AND _time_second_ < toDateTime(1000 - 3600)",
+				"SELECT toFloat64(if(w1 = 0, if(w0 = 0, 0, inf), (w0 - w1) / w1 * 100) > 100) AS val",
+			},
+		},
+		{
+			name: "baseline and threshold",
+			conditions: []*db.AlarmCondition{
+				{SetOperatorTyp: 1, CondTyp: db.ConditionTypBaseline, Periods: 2, Factor: 2.5},
+				{SetOperatorExp: 0, Cond: 0, Val1: 10},
+			},
+			want: []string{
+				"AS w2\n",
+				"(w0 > 10 AND w0 > arrayAvg([w1, w2]) + 2.5 * arrayReduce('stddevPop', [w1, w2]))",
+			},
+		},
+		{
+			name:       "seasonal outside range",
+			conditions: []*db.AlarmCondition{{SetOperatorExp: 4, CondTyp: db.ConditionTypSeasonal, Offset: 86400, Periods: 2, Cond: 2, Val1: -50, Val2: 50}},
+			want: []string{
+				"toFloat64(count())",
+				"toDateTime(1000 - 172860)",
+				"(if(arrayAvg([w1, w2]) = 0, if(w0 = 0, 0, inf), (w0 - arrayAvg([w1, w2])) / arrayAvg([w1, w2]) * 100) < -50 OR",
+			},
+		},
+	}
+	// the whole query of one condition, the windows of the others are checked below
+	want := "WITH\n" +
+		"  (SELECT if(count() = 0, 0, toFloat64(sum(c))) FROM (SELECT count() AS c FROM `app`.`logs` WHERE _time_second_ >= toDateTime(1000 - 60) AND _time_second_ < toDateTime(1000 - 0) AND (level='error') GROUP BY _time_second_)) AS w0,\n" +
+		"  (SELECT if(count() = 0, 0, toFloat64(sum(c))) FROM (SELECT count() AS c FROM `app`.`logs` WHERE _time_second_ >= toDateTime(1000 - 3660) AND _time_second_ < toDateTime(1000 - 3600) AND (level='error') GROUP BY _time_second_)) AS w1\n" +
+		"SELECT toFloat64(if(w1 = 0, if(w0 = 0, 0, inf), (w0 - w1) / w1 * 100) > 100) AS val"
+	if got := alarmAnomalySQL(table, filter, tests[0].conditions, 60, "1000"); got != want {
+		t.Errorf("alarmAnomalySQL() = %v, want %v", got, want)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := alarmAnomalySQL(table, filter, tt.conditions, 60, "1000")
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("alarmAnomalySQL() = %v, want contains %v", got, want)
+				}
+			}
+		})
+	}
+}
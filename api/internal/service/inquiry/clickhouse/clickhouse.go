@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	clickhousev2 "github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/ego-component/egorm"
 	"github.com/gotomicro/cetus/l"
 	"github.com/gotomicro/ego/core/econf"
@@ -25,6 +26,7 @@ import (
 	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
 	"github.com/clickvisual/clickvisual/api/internal/pkg/model/dto"
 	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/utils/mapping"
 	"github.com/clickvisual/clickvisual/api/internal/service/inquiry/factory"
 	"github.com/clickvisual/clickvisual/api/internal/service/inquiry/factory/builder"
 	"github.com/clickvisual/clickvisual/api/internal/service/inquiry/factory/builder/bumo"
@@ -545,7 +547,7 @@ func (c *ClickHouseX) GetAlertViewSQL(alarm *db.Alarm, tableInfo db.BaseTable, f
 		SourceTable:  sourceTableName,
 		Where:        filter.When,
 	}
-	if filter.Mode == db.AlarmModeAggregation || filter.Mode == db.AlarmModeAggregationCheck {
+	if filter.IsAggregation() {
 		vp.ViewType = bumo.ViewTypePrometheusMetricAggregation
 		// vp.WithSQL = adaSelectPart(filter.When)
 		vp.WithSQL = filter.When
@@ -604,6 +606,42 @@ func (c *ClickHouseX) DeleteAlertView(viewTableName, cluster string) (err error)
 	return nil
 }
 
+// GetAlertSamples values of the alarm filter between st and et used by the native alert evaluator,
+// the count of logs per time point, the val column of the aggregation sql at et, or the val of the anomaly conditions
+func (c *ClickHouseX) GetAlertSamples(tableInfo *db.BaseTable, filter *db.AlarmFilter, conditions []*db.AlarmCondition, st, et int64) (res []float64, err error) {
+	when := filter.When
+	if when == "" {
+		when = "1=1"
+	}
+	var sql string
+	if db.HasAnomaly(conditions) {
+		sql = alarmAnomalySQL(tableInfo, filter, conditions, et-st, strconv.FormatInt(et, 10))
+	} else if filter.IsAggregation() {
+		sql = fmt.Sprintf("SELECT toFloat64(val) FROM (%s) LIMIT 1", alertAggregationAt(when, et))
+	} else {
+		timeCondition := fmt.Sprintf(genTimeCondition(view.ReqQuery{
+			TimeField:     tableInfo.GetTimeField(),
+			TimeFieldType: tableInfo.TimeFieldType,
+		}), st, et)
+		sql = fmt.Sprintf("SELECT toFloat64(count(*)) FROM %s WHERE %s AND (%s) GROUP BY %s",
+			genName(tableInfo.Database.Name, tableInfo.Name), timeCondition, when, tableInfo.GetTimeField())
+	}
+	rows, err := c.db.Query(sql)
+	if err != nil {
+		return nil, errors.Wrapf(err, "sql: %s", sql)
+	}
+	defer func() { _ = rows.Close() }()
+	res = make([]float64, 0)
+	for rows.Next() {
+		var val float64
+		if err = rows.Scan(&val); err != nil {
+			return nil, errors.Wrapf(err, "sql: %s", sql)
+		}
+		res = append(res, val)
+	}
+	return res, rows.Err()
+}
+
 // DeleteTableListByNames data view stream
 func (c *ClickHouseX) DeleteTableListByNames(names []string, cluster string) (err error) {
 	isCluster, err := c.isCluster(cluster)
@@ -634,6 +672,9 @@ func (c *ClickHouseX) DeleteTable(database, table, cluster string, tid int) (err
 	if err != nil {
 		return errors.Wrap(err, "isCluster get failed")
 	}
+	if err = c.deleteDeadLetter(database, table, cluster, isCluster); err != nil {
+		return err
+	}
 	if isCluster == ModeCluster {
 		if cluster == "" {
 			err = constx.ErrClusterNameEmpty
@@ -686,6 +727,27 @@ func (c *ClickHouseX) DeleteTable(database, table, cluster string, tid int) (err
 	return nil
 }
 
+// deleteDeadLetter drops the dead-letter view and tables, they exist only when dead letter is enabled
+func (c *ClickHouseX) deleteDeadLetter(database, table, cluster string, isCluster int) error {
+	sqls := []string{
+		fmt.Sprintf("DROP TABLE IF EXISTS `%s`.`%s_dlq_view`", database, table),
+		fmt.Sprintf("DROP TABLE IF EXISTS `%s`.`%s_dlq`", database, table),
+	}
+	if isCluster == ModeCluster {
+		sqls = []string{
+			fmt.Sprintf("DROP TABLE IF EXISTS `%s`.`%s_dlq_local_view` ON CLUSTER '%s'", database, table, cluster),
+			fmt.Sprintf("DROP TABLE IF EXISTS `%s`.`%s_dlq` ON CLUSTER '%s'", database, table, cluster),
+			fmt.Sprintf("DROP TABLE IF EXISTS `%s`.`%s_dlq_local` ON CLUSTER '%s'", database, table, cluster),
+		}
+	}
+	for _, s := range sqls {
+		if _, err := c.db.Exec(s); err != nil {
+			return errors.Wrapf(err, "sql: %s", s)
+		}
+	}
+	return nil
+}
+
 func (c *ClickHouseX) DeleteDatabase(name string, cluster string) (err error) {
 	if cluster == "" {
 		_, err = c.db.Exec(fmt.Sprintf("DROP DATABASE IF EXISTS %s;", name))
@@ -715,7 +777,7 @@ func (c *ClickHouseX) GetLogs(param view.ReqQuery, tid int) (res view.RespQuery,
 		optimizeSQL   string
 	)
 	switch param.AlarmMode {
-	case db.AlarmModeAggregation:
+	case db.AlarmModeAggregation, db.AlarmModeTraceEdge:
 		defaultSQL = param.Query
 	case db.AlarmModeAggregationCheck:
 		defaultSQL = alarmAggregationSQLWith(param)
@@ -1146,26 +1208,49 @@ func (c *ClickHouseX) CreateStorageJSONAsString(database db.BaseDatabase, ct vie
 	var storeSQLs []string
 	var readerSQLs []string
 	var switcherSQLs []string
+	storerParams, readerParams, switcherParams := c.storageJSONAsStringParams(database, ct, c.isShard(database.Cluster), c.isReplica(database.Cluster))
 	// storer
-	_, storeSQLs, err = storer.New(db.DatasourceClickHouse, i.StorerParams{
+	_, storeSQLs, err = storer.New(db.DatasourceClickHouse, storerParams).Create()
+	if err != nil {
+		return
+	}
+	// reader
+	_, readerSQLs, err = reader.New(db.DatasourceClickHouse, readerParams).Create()
+	if err != nil {
+		return
+	}
+	// switcher
+	_, switcherSQLs, err = switcher.New(db.DatasourceClickHouse, switcherParams).Create()
+	if err != nil {
+		return
+	}
+	dDataSQL = storeSQLs[0]
+	if len(storeSQLs[0]) == 2 {
+		dDistributedSQL = storeSQLs[1]
+	}
+	dStreamSQL = readerSQLs[0]
+	dViewSQL = switcherSQLs[0]
+	return
+}
+
+// storageJSONAsStringParams params of storer, reader and switcher for a JSONAsString storage
+func (c *ClickHouseX) storageJSONAsStringParams(database db.BaseDatabase, ct view.ReqStorageCreate, isShard, isReplica bool) (storerParams i.StorerParams, readerParams i.ReaderParams, switcherParams i.SwitcherParams) {
+	storerParams = i.StorerParams{
 		CreateType: ct.CreateType,
-		IsShard:    c.isShard(database.Cluster),
-		IsReplica:  c.isReplica(database.Cluster),
+		IsShard:    isShard,
+		IsReplica:  isReplica,
 		Cluster:    database.Cluster,
 		Database:   database.Name,
 		Table:      ct.TableName,
 		Conn:       c.Conn(),
 		Fields:     ct.Mapping2String(true, ct.RawLogFieldParent),
 		TTL:        ct.Days,
-	}).Create()
-	if err != nil {
-		return
+		DeadLetter: ct.DeadLetter == 1,
 	}
-	// reader
-	_, readerSQLs, err = reader.New(db.DatasourceClickHouse, i.ReaderParams{
+	readerParams = i.ReaderParams{
 		CreateType:              ct.CreateType,
-		IsShard:                 c.isShard(database.Cluster),
-		IsReplica:               c.isReplica(database.Cluster),
+		IsShard:                 isShard,
+		IsReplica:               isReplica,
 		Cluster:                 database.Cluster,
 		Database:                database.Name,
 		Table:                   ct.TableName,
@@ -1175,15 +1260,11 @@ func (c *ClickHouseX) CreateStorageJSONAsString(database db.BaseDatabase, ct vie
 		GroupName:               database.Name + "_" + ct.TableName,
 		KafkaNumConsumers:       ct.Consumers,
 		KafkaSkipBrokenMessages: ct.KafkaSkipBrokenMessages,
-	}).Create()
-	if err != nil {
-		return
 	}
-	// switcher
-	_, switcherSQLs, err = switcher.New(db.DatasourceClickHouse, i.SwitcherParams{
+	switcherParams = i.SwitcherParams{
 		CreateType:          ct.CreateType,
-		IsShard:             c.isShard(database.Cluster),
-		IsReplica:           c.isReplica(database.Cluster),
+		IsShard:             isShard,
+		IsReplica:           isReplica,
 		Cluster:             database.Cluster,
 		Database:            database.Name,
 		Table:               ct.TableName,
@@ -1195,16 +1276,10 @@ func (c *ClickHouseX) CreateStorageJSONAsString(database db.BaseDatabase, ct vie
 		ParseTime:           c.timeParseJSONAsString(ct.Typ, nil, ct.TimeField, ct.TimeFieldParent, ct.GetRawLogField()),
 		ParseWhere:          c.whereConditionSQLDefault(nil, ct.GetRawLogField()),
 		IsRawLogFieldString: ct.IsRawLogFieldString(),
-	}).Create()
-	if err != nil {
-		return
+		DeadLetter:          ct.DeadLetter == 1,
+		ParseTimeCheck:      c.timeCheckJSONAsString(ct.Typ, ct.TimeField, ct.TimeFieldParent),
+		SourceFormat:        ct.Format,
 	}
-	dDataSQL = storeSQLs[0]
-	if len(storeSQLs[0]) == 2 {
-		dDistributedSQL = storeSQLs[1]
-	}
-	dStreamSQL = readerSQLs[0]
-	dViewSQL = switcherSQLs[0]
 	return
 }
 
@@ -1324,6 +1399,206 @@ func (c *ClickHouseX) UpdateMergeTreeTable(tableInfo *db.BaseTable, params view.
 	return
 }
 
+// UpdateRawLogIndex rebuilds the full-text index of the raw log field, db.RawLogIndexNone drops it
+// ALTER TABLE dev.test ADD INDEX idx_raw_log _raw_log_ TYPE tokenbf_v1(30720, 2, 0) GRANULARITY 1
+func (c *ClickHouseX) UpdateRawLogIndex(tableInfo *db.BaseTable, rawLogIndex int) (err error) {
+	isCluster, err := c.isCluster(tableInfo.Database.Cluster)
+	if err != nil {
+		return errors.Wrap(err, "get isCluster error")
+	}
+	field := "_raw_log_"
+	dataName := genNameWithMode(isCluster, tableInfo.Database.Name, tableInfo.Name)
+	if tableInfo.CreateType == constx.TableCreateTypeExist {
+		if tableInfo.RawLogField == "" {
+			return errors.New("raw log field is empty")
+		}
+		field = tableInfo.RawLogField
+		dataName = genName(tableInfo.Database.Name, tableInfo.Name)
+		if isCluster == ModeCluster {
+			createSQL, errCreateSQL := c.GetCreateSQL(tableInfo.Database.Name, tableInfo.Name)
+			if errCreateSQL != nil {
+				return errCreateSQL
+			}
+			subTableName, errSubTable := getDistributedSubTableName(createSQL)
+			if errSubTable != nil {
+				return errSubTable
+			}
+			dataName = genName(tableInfo.Database.Name, subTableName)
+		}
+	}
+	alterTable := fmt.Sprintf("ALTER TABLE %s%s", dataName, genSQLClusterInfo(isCluster, tableInfo.Database.Cluster))
+	sqls := []string{fmt.Sprintf("%s DROP INDEX IF EXISTS %s", alterTable, rawLogIndexName)}
+	ctx := context.Background()
+	if rawLogIndex != db.RawLogIndexNone {
+		indexType, errIndexType := rawLogIndexType(rawLogIndex)
+		if errIndexType != nil {
+			return errIndexType
+		}
+		sqls = append(sqls,
+			fmt.Sprintf("%s ADD INDEX %s `%s` TYPE %s GRANULARITY 1", alterTable, rawLogIndexName, field, indexType),
+			fmt.Sprintf("%s MATERIALIZE INDEX %s", alterTable, rawLogIndexName),
+		)
+		if rawLogIndex == db.RawLogIndexInverted {
+			ctx = clickhousev2.Context(ctx, clickhousev2.WithSettings(clickhousev2.Settings{
+				"allow_experimental_inverted_index": 1,
+			}))
+		}
+	}
+	for _, s := range sqls {
+		if _, err = c.db.ExecContext(ctx, s); err != nil {
+			elog.Error("UpdateRawLogIndex", elog.Any("sql", s), elog.Any("err", err.Error()))
+			return errors.Wrapf(err, "sql: %s", s)
+		}
+	}
+	return
+}
+
+// KafkaSample reads messages of the topic through a temporary kafka engine table with its own consumer group
+func (c *ClickHouseX) KafkaSample(database db.BaseDatabase, brokers, topic string, limit int) (res []string, err error) {
+	suffix := time.Now().UnixNano()
+	name := genName(database.Name, fmt.Sprintf("_clickvisual_sample_%d", suffix))
+	createSQL := fmt.Sprintf("CREATE TABLE %s (_log String) ENGINE = Kafka SETTINGS kafka_broker_list = %s, kafka_topic_list = %s, "+
+		"kafka_group_name = 'clickvisual_sample_%d', kafka_format = 'JSONAsString', kafka_skip_broken_messages = %d",
+		name, quoteString(brokers), quoteString(topic), suffix, kafkaSampleSkipBrokenMessages)
+	if _, err = c.db.Exec(createSQL); err != nil {
+		return nil, errors.Wrapf(err, "sql: %s", createSQL)
+	}
+	defer func() {
+		if _, errDrop := c.db.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", name)); errDrop != nil {
+			elog.Error("KafkaSample", elog.String("table", name), elog.Any("err", errDrop.Error()))
+		}
+	}()
+	ctx := clickhousev2.Context(context.Background(), clickhousev2.WithSettings(clickhousev2.Settings{
+		"stream_like_engine_allow_direct_select": 1,
+	}))
+	rows, err := c.db.QueryContext(ctx, fmt.Sprintf("SELECT _log FROM %s LIMIT %d", name, limit))
+	if err != nil {
+		return nil, errors.Wrap(err, "select kafka sample")
+	}
+	defer func() { _ = rows.Close() }()
+	res = make([]string, 0, limit)
+	for rows.Next() {
+		var msg string
+		if err = rows.Scan(&msg); err != nil {
+			return nil, err
+		}
+		res = append(res, msg)
+	}
+	return res, rows.Err()
+}
+
+// IngestionHealth reads the kafka consumers of the storage and its latest data time,
+// errors are the consumer exceptions after since
+func (c *ClickHouseX) IngestionHealth(table *db.BaseTable, since int64) (res view.IngestionHealth, err error) {
+	isCluster, err := c.isCluster(table.Database.Cluster)
+	if err != nil {
+		return res, errors.Wrap(err, "get isCluster error")
+	}
+	consumers := "system.kafka_consumers"
+	streamTable := table.Name + "_stream"
+	if isCluster == ModeCluster {
+		consumers = fmt.Sprintf("clusterAllReplicas('%s', system.kafka_consumers)", table.Database.Cluster)
+		streamTable = table.Name + "_local_stream"
+	}
+	consumerSQL := fmt.Sprintf(ingestionConsumerSQL, since, consumers, table.Database.Name, streamTable)
+	var stats []string
+	if err = c.db.QueryRow(consumerSQL).Scan(&res.Consumers, &res.MessagesRead, &res.LastPollTime, &res.LastCommitTime,
+		&res.RecentErrors, &res.LastError, &stats); err != nil {
+		return res, errors.Wrapf(err, "sql: %s", consumerSQL)
+	}
+	res.Lag = rdkafkaLag(stats)
+	latestSQL := fmt.Sprintf(ingestionLatestSQL, genName(table.Database.Name, table.Name))
+	if err = c.db.QueryRow(latestSQL).Scan(&res.LatestTime); err != nil {
+		return res, errors.Wrapf(err, "sql: %s", latestSQL)
+	}
+	res.BrokenMessages = -1
+	if table.DeadLetter == 1 {
+		brokenSQL := fmt.Sprintf(ingestionBrokenSQL, genName(table.Database.Name, table.Name+"_dlq"), since)
+		if err = c.db.QueryRow(brokenSQL).Scan(&res.BrokenMessages); err != nil {
+			return res, errors.Wrapf(err, "sql: %s", brokenSQL)
+		}
+	}
+	res.CheckTime = time.Now().Unix()
+	if res.LatestTime > 0 {
+		res.Freshness = res.CheckTime - res.LatestTime
+	}
+	return res, nil
+}
+
+// ListDeadLetter messages of the dead-letter table, newest first
+func (c *ClickHouseX) ListDeadLetter(table *db.BaseTable, req view.ReqStorageDeadLetterList) (res view.RespStorageDeadLetterList, err error) {
+	if table.DeadLetter != 1 {
+		return res, errors.New("dead letter is not enabled")
+	}
+	if req.PageSize == 0 {
+		req.PageSize = 20
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	dlqName := genName(table.Database.Name, table.Name+"_dlq")
+	where, args := deadLetterCondition(req.ST, req.ET, req.Error)
+	countSQL := fmt.Sprintf("SELECT count() FROM %s WHERE %s", dlqName, where)
+	if err = c.db.QueryRow(countSQL, args...).Scan(&res.Total); err != nil {
+		return res, errors.Wrapf(err, "sql: %s", countSQL)
+	}
+	listSQL := fmt.Sprintf("SELECT toInt64(toUnixTimestamp(_time_second_)), _topic_, _partition_, _offset_, _error_, _raw_message_ FROM %s WHERE %s ORDER BY _time_second_ DESC LIMIT %d OFFSET %d",
+		dlqName, where, req.PageSize, (req.Page-1)*req.PageSize)
+	rows, err := c.db.Query(listSQL, args...)
+	if err != nil {
+		return res, errors.Wrapf(err, "sql: %s", listSQL)
+	}
+	defer func() { _ = rows.Close() }()
+	res.List = make([]*view.DeadLetter, 0)
+	for rows.Next() {
+		row := view.DeadLetter{}
+		if err = rows.Scan(&row.Time, &row.Topic, &row.Partition, &row.Offset, &row.Error, &row.RawMessage); err != nil {
+			return res, err
+		}
+		res.List = append(res.List, &row)
+	}
+	return res, rows.Err()
+}
+
+// ReplayDeadLetter writes the messages back to kafka through the stream table and removes them from the dead-letter table,
+// messages still unparsable return to the dead-letter table
+func (c *ClickHouseX) ReplayDeadLetter(table *db.BaseTable, req view.ReqStorageDeadLetterReplay) (count uint64, err error) {
+	if table.DeadLetter != 1 {
+		return 0, errors.New("dead letter is not enabled")
+	}
+	isCluster, err := c.isCluster(table.Database.Cluster)
+	if err != nil {
+		return 0, errors.Wrap(err, "get isCluster error")
+	}
+	// replayed messages that fail again get a new time, keep them out of this replay
+	if now := time.Now().Unix(); req.ET > now {
+		req.ET = now
+	}
+	dlqName := genName(table.Database.Name, table.Name+"_dlq")
+	dlqDataName := dlqName
+	if isCluster == ModeCluster {
+		dlqDataName = genName(table.Database.Name, table.Name+"_dlq_local")
+	}
+	where, args := deadLetterCondition(req.ST, req.ET, req.Error)
+	countSQL := fmt.Sprintf("SELECT count() FROM %s WHERE %s", dlqName, where)
+	if err = c.db.QueryRow(countSQL, args...).Scan(&count); err != nil {
+		return 0, errors.Wrapf(err, "sql: %s", countSQL)
+	}
+	if count == 0 {
+		return 0, nil
+	}
+	insertSQL := fmt.Sprintf("INSERT INTO %s (_log) SELECT _raw_message_ FROM %s WHERE %s",
+		genStreamNameWithMode(isCluster, table.Database.Name, table.Name), dlqName, where)
+	if _, err = c.db.Exec(insertSQL, args...); err != nil {
+		return 0, errors.Wrapf(err, "sql: %s", insertSQL)
+	}
+	deleteSQL := fmt.Sprintf("ALTER TABLE %s%s DELETE WHERE %s", dlqDataName, genSQLClusterInfo(isCluster, table.Database.Cluster), where)
+	if _, err = c.db.Exec(deleteSQL, args...); err != nil {
+		return 0, errors.Wrapf(err, "sql: %s", deleteSQL)
+	}
+	return count, nil
+}
+
 // CreateKafkaTable Drop and Create
 func (c *ClickHouseX) CreateKafkaTable(tableInfo *db.BaseTable, params view.ReqStorageUpdate) (streamSQL string, err error) {
 	currentKafkaSQL := tableInfo.SqlStream
@@ -1603,7 +1878,18 @@ func (c *ClickHouseX) updateReaderJSONAsString(tableInfo *db.BaseTable, params v
 	return readerSQLs[0], nil
 }
 
-func (c *ClickHouseX) updateSwitcherJSONAsString(ct view.ReqStorageCreate, timeView *db.BaseView, timeViewList []*db.BaseView, database *db.BaseDatabase, tid int, customTimeField string, indexes map[string]*db.BaseIndex) (res string, err error) {
+// rebuildSwitcherJSONAsStringParams switcher params of an existing storage, Delete drops the dead-letter view as well
+// so the dead-letter settings are passed through to create it again
+func (c *ClickHouseX) rebuildSwitcherJSONAsStringParams(ct view.ReqStorageCreate, timeView *db.BaseView, timeViewList []*db.BaseView, database *db.BaseDatabase, customTimeField string, indexes map[string]*db.BaseIndex, isShard, isReplica bool) i.SwitcherParams {
+	// the mapping is not stored with the create params
+	if len(ct.SourceMapping.Data) == 0 && ct.Source != "" {
+		sourceMapping, err := mapping.Handle(ct.Source, true)
+		if err != nil {
+			elog.Error("rebuildSwitcherJSONAsStringParams", elog.String("table", ct.TableName), l.E(err))
+		}
+		ct.SourceMapping = sourceMapping
+		ct.SourceMapping.SetAlias(ct.FieldAlias)
+	}
 	var parseTime string
 	var parseWhere string
 	if customTimeField == "" {
@@ -1616,8 +1902,8 @@ func (c *ClickHouseX) updateSwitcherJSONAsString(ct view.ReqStorageCreate, timeV
 
 	params := i.SwitcherParams{
 		CreateType:          constx.TableCreateTypeJSONAsString,
-		IsShard:             c.isShard(database.Cluster),
-		IsReplica:           c.isReplica(database.Cluster),
+		IsShard:             isShard,
+		IsReplica:           isReplica,
 		Cluster:             database.Cluster,
 		Database:            database.Name,
 		Table:               ct.TableName,
@@ -1629,10 +1915,18 @@ func (c *ClickHouseX) updateSwitcherJSONAsString(ct view.ReqStorageCreate, timeV
 		ParseTime:           parseTime,
 		ParseWhere:          parseWhere,
 		IsRawLogFieldString: ct.IsRawLogFieldString(),
+		DeadLetter:          ct.DeadLetter == 1,
+		ParseTimeCheck:      c.timeCheckJSONAsString(ct.Typ, ct.TimeField, ct.TimeFieldParent),
+		SourceFormat:        ct.Format,
 	}
 	if customTimeField != "" {
 		params.CustomTimeField = timeView.Key
 	}
+	return params
+}
+
+func (c *ClickHouseX) updateSwitcherJSONAsString(ct view.ReqStorageCreate, timeView *db.BaseView, timeViewList []*db.BaseView, database *db.BaseDatabase, tid int, customTimeField string, indexes map[string]*db.BaseIndex) (res string, err error) {
+	params := c.rebuildSwitcherJSONAsStringParams(ct, timeView, timeViewList, database, customTimeField, indexes, c.isShard(database.Cluster), c.isReplica(database.Cluster))
 	// 初始化 switcher
 	sw := switcher.New(db.DatasourceClickHouse, params)
 	// 删除
@@ -1862,7 +2156,7 @@ func (c *ClickHouseX) timeParseJSONAsString(typ int, v *db.BaseView, timeField,
 	}
 	if v != nil && v.Format == "fromUnixTimestamp64Micro" && v.IsUseDefaultTime == 0 {
 		timeField = fmt.Sprintf("JSONExtractInt(%s, '%s')", l, timeField)
-	} else if typ == factory.TableTypeFloat {
+	} else if typ == factory.TableTypeFloat || typ == factory.TableTypeUnixMilli {
 		timeField = fmt.Sprintf("JSONExtractFloat(%s, '%s')", l, timeField)
 	} else {
 		timeField = fmt.Sprintf("JSONExtractString(%s, '%s')", l, timeField)
@@ -1870,6 +2164,32 @@ func (c *ClickHouseX) timeParseJSONAsString(typ int, v *db.BaseView, timeField,
 	return c.timeParseSQL(typ, v, timeField, rawLogField)
 }
 
+// timeCheckJSONAsString condition that is true when the time field of the message can be parsed
+func (c *ClickHouseX) timeCheckJSONAsString(typ int, timeField, timeFieldParent string) string {
+	l := "_log"
+	if timeFieldParent != "" {
+		l = fmt.Sprintf("JSONExtractRaw(_log, '%s')", timeFieldParent)
+	}
+	switch typ {
+	case factory.TableTypeFloat, factory.TableTypeUnixMilli:
+		return fmt.Sprintf("JSONExtractFloat(%s, '%s') > 0", l, timeField)
+	case factory.TableTypeUnixNano:
+		return fmt.Sprintf("toInt64OrZero(JSONExtractString(%s, '%s')) > 0", l, timeField)
+	}
+	return fmt.Sprintf("isNotNull(parseDateTimeBestEffortOrNull(%s))", timeStringRewrite(typ, fmt.Sprintf("JSONExtractString(%s, '%s')", l, timeField)))
+}
+
+// timeStringRewrite rewrites the time string of layouts parseDateTimeBestEffort can not parse
+func timeStringRewrite(typ int, timeField string) string {
+	switch typ {
+	case factory.TableTypeCLF:
+		return fmt.Sprintf(clfTimeRewrite, timeField)
+	case factory.TableTypeANSIC:
+		return fmt.Sprintf(ansicTimeRewrite, timeField)
+	}
+	return timeField
+}
+
 func (c *ClickHouseX) timeParseSQL(typ int, v *db.BaseView, timeField, rawLogField string) string {
 	if timeField == "" {
 		timeField = "_time_"
@@ -1883,6 +2203,16 @@ func (c *ClickHouseX) timeParseSQL(typ int, v *db.BaseView, timeField, rawLogFie
 	if typ == factory.TableTypeString {
 		return fmt.Sprintf(defaultStringTimeParse, timeField, timeField)
 	}
+	if typ == factory.TableTypeCLF || typ == factory.TableTypeANSIC {
+		timeField = timeStringRewrite(typ, timeField)
+		return fmt.Sprintf(defaultStringTimeParse, timeField, timeField)
+	}
+	if typ == factory.TableTypeUnixNano {
+		return fmt.Sprintf(defaultUnixNanoTimeParse, timeField, timeField)
+	}
+	if typ == factory.TableTypeUnixMilli {
+		return fmt.Sprintf(defaultUnixMilliTimeParse, timeField, timeField)
+	}
 	return fmt.Sprintf(defaultFloatTimeParse, timeField, timeField)
 }
 
@@ -2000,6 +2330,7 @@ func (c *ClickHouseX) updateSwitcher(typ, tid int, did int, table, customTimeFie
 			Database:         tableInfo.Database,
 		})
 	case constx.TableCreateTypeJSONAsString:
+		rsc.DeadLetter = tableInfo.DeadLetter
 		return c.updateSwitcherJSONAsString(rsc, current, list, tableInfo.Database, tid, customTimeField, indexes)
 	default:
 		// 默认执行 JSONAsEachRow 模式
@@ -2102,7 +2433,7 @@ func (c *ClickHouseX) queryTransform(params view.ReqQuery, isOptimized bool) str
 		params.Query = queryTransformHash(params) // hash transform
 	}
 	table, _ := db.TableInfo(invoker.Db, params.Tid)
-	query := queryTransformLike(table.CreateType, table.RawLogField, params.Query) // _raw_log_ like
+	query := queryTransformLike(table.CreateType, table.RawLogField, table.GetRawLogIndex(), params.Query) // _raw_log_ like
 	if query == "" {
 		return query
 	}
@@ -1,9 +1,24 @@
 package clickhouse
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
+	_ "github.com/ClickHouse/clickhouse-go/v2"
+
+	"github.com/clickvisual/clickvisual/api/core/common"
+	"github.com/clickvisual/clickvisual/api/core/storer"
+	"github.com/clickvisual/clickvisual/api/core/switcher"
 	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/utils/mapping"
+	"github.com/clickvisual/clickvisual/api/internal/service/inquiry/factory"
+	"github.com/clickvisual/clickvisual/api/internal/service/storage/storagetemplate"
 )
 
 func Test_hashTransform(t *testing.T) {
@@ -219,6 +234,7 @@ func Test_queryTransformLike(t *testing.T) {
 	type args struct {
 		createType  int
 		rawLogField string
+		rawLogIndex int
 		query       string
 	}
 	tests := []struct {
@@ -280,10 +296,50 @@ func Test_queryTransformLike(t *testing.T) {
 			},
 			want: "_raw_log_ LIKE '%测试%' AND _container_name_='svc-task'",
 		},
+		{
+			name: "test-8",
+			args: args{
+				createType:  2,
+				rawLogField: "_raw_log_",
+				rawLogIndex: db.RawLogIndexTokenBF,
+				query:       "\"handleCreated\" and _container_name_='svc-task'",
+			},
+			want: "hasToken(_raw_log_, 'handleCreated') AND _container_name_='svc-task'",
+		},
+		{
+			name: "test-9",
+			args: args{
+				createType:  2,
+				rawLogField: "_raw_log_",
+				rawLogIndex: db.RawLogIndexInverted,
+				query:       "\"handle-created\"",
+			},
+			want: "_raw_log_ LIKE '%\"handle-created\"%'",
+		},
+		{
+			name: "test-11",
+			args: args{
+				createType:  2,
+				rawLogField: "_raw_log_",
+				rawLogIndex: db.RawLogIndexTokenBF,
+				query:       "handle",
+			},
+			want: "_raw_log_ LIKE '%handle%'",
+		},
+		{
+			name: "test-10",
+			args: args{
+				createType:  1,
+				rawLogField: "body",
+				rawLogIndex: db.RawLogIndexTokenBF,
+				query:       "测试 and _container_name_='svc-task'",
+			},
+			want: "body LIKE '%测试%' AND _container_name_='svc-task'",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := queryTransformLike(tt.args.createType, tt.args.rawLogField, tt.args.query); got != tt.want {
+			if got := queryTransformLike(tt.args.createType, tt.args.rawLogField, tt.args.rawLogIndex, tt.args.query); got != tt.want {
 				t.Errorf("queryTransformLike() = %v, want %v", got, tt.want)
 			}
 		})
@@ -326,3 +382,429 @@ func Test_clickhouseVersionCompare(t *testing.T) {
 		})
 	}
 }
+
+// BenchmarkRawLogIndexScanBytes compares the bytes read by a keyword search compiled to LIKE
+// with the same search compiled to token functions. It needs a table with the raw log index, e.g.
+// CLICKVISUAL_BENCH_DSN=clickhouse://127.0.0.1:9000 CLICKVISUAL_BENCH_TABLE=logs.app CLICKVISUAL_BENCH_KEYWORD=timeout
+func BenchmarkRawLogIndexScanBytes(b *testing.B) {
+	dsn, table, keyword := os.Getenv("CLICKVISUAL_BENCH_DSN"), os.Getenv("CLICKVISUAL_BENCH_TABLE"), os.Getenv("CLICKVISUAL_BENCH_KEYWORD")
+	if dsn == "" || table == "" || keyword == "" {
+		b.Skip("CLICKVISUAL_BENCH_DSN, CLICKVISUAL_BENCH_TABLE and CLICKVISUAL_BENCH_KEYWORD are required")
+	}
+	conn, err := sql.Open("clickhouse", dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+	for _, bm := range []struct {
+		name        string
+		rawLogIndex int
+	}{
+		{name: "like", rawLogIndex: db.RawLogIndexNone},
+		{name: "token", rawLogIndex: db.RawLogIndexTokenBF},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			where := queryTransformLike(2, "_raw_log_", bm.rawLogIndex, `"`+keyword+`"`)
+			var readBytes uint64
+			for n := 0; n < b.N; n++ {
+				comment := fmt.Sprintf("bench_%s_%d_%d", bm.name, time.Now().UnixNano(), n)
+				q := fmt.Sprintf("SELECT count(*) FROM %s WHERE %s SETTINGS log_comment = '%s'", table, where, comment)
+				if _, err = conn.Exec(q); err != nil {
+					b.Fatal(err)
+				}
+				b.StopTimer()
+				if _, err = conn.Exec("SYSTEM FLUSH LOGS"); err != nil {
+					b.Fatal(err)
+				}
+				var bytes uint64
+				if err = conn.QueryRow("SELECT read_bytes FROM system.query_log WHERE log_comment = ? AND type = 'QueryFinish' LIMIT 1", comment).Scan(&bytes); err != nil {
+					b.Fatal(err)
+				}
+				readBytes += bytes
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(readBytes)/float64(b.N), "scan-bytes/op")
+		})
+	}
+}
+
+func Test_storageTemplateSQL(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		wantData string
+		wantView string
+	}{
+		{
+			name:     "test-fluentbit",
+			template: storagetemplate.NameFluentBit,
+			wantData: `CREATE TABLE IF NOT EXISTS ` + "`logs`.`fluentbit`" + `
+(
+  ` + "`_source_`" + ` String,
+` + "`container_image`" + ` String,
+` + "`_container_name_`" + ` String,
+` + "`_node_name_`" + ` String,
+` + "`_namespace_`" + ` String,
+` + "`pod_id`" + ` String,
+` + "`_pod_name_`" + ` String,
+  _time_second_ DateTime,
+  _time_nanosecond_ DateTime64(9),
+  _raw_log_ String CODEC(ZSTD(1)),
+  INDEX idx_raw_log _raw_log_ TYPE tokenbf_v1(30720, 2, 0) GRANULARITY 1
+)
+ENGINE = MergeTree
+PARTITION BY toYYYYMMDD(_time_second_)
+ORDER BY _time_second_
+TTL toDateTime(_time_second_) + INTERVAL 3 DAY
+SETTINGS index_granularity = 8192;
+`,
+			wantView: "CREATE MATERIALIZED VIEW IF NOT EXISTS `logs`.`fluentbit_view` TO `logs`.`fluentbit` AS" + `
+SELECT
+JSONExtractString(_log, 'stream') AS ` + "`_source_`" + `,
+JSONExtractString(JSONExtractRaw(_log, 'kubernetes'), 'container_image') AS ` + "`container_image`" + `,
+JSONExtractString(JSONExtractRaw(_log, 'kubernetes'), 'container_name') AS ` + "`_container_name_`" + `,
+JSONExtractString(JSONExtractRaw(_log, 'kubernetes'), 'host') AS ` + "`_node_name_`" + `,
+JSONExtractString(JSONExtractRaw(_log, 'kubernetes'), 'namespace_name') AS ` + "`_namespace_`" + `,
+JSONExtractString(JSONExtractRaw(_log, 'kubernetes'), 'pod_id') AS ` + "`pod_id`" + `,
+JSONExtractString(JSONExtractRaw(_log, 'kubernetes'), 'pod_name') AS ` + "`_pod_name_`" + `,
+toDateTime(toInt64(JSONExtractFloat(_log, '_time_'))) AS _time_second_,
+fromUnixTimestamp64Nano(toInt64(JSONExtractFloat(_log, '_time_')*1000000000)) AS _time_nanosecond_,
+JSONExtractString(_log, '_log_') AS _raw_log_
+FROM ` + "`logs`.`fluentbit_stream`" + ` WHERE 1=1;
+`,
+		},
+		{
+			name:     "test-vector",
+			template: storagetemplate.NameVector,
+			wantData: `CREATE TABLE IF NOT EXISTS ` + "`logs`.`vector`" + `
+(
+  ` + "`file`" + ` String,
+` + "`_log_agent_`" + ` String,
+` + "`_source_`" + ` String,
+` + "`container_image`" + ` String,
+` + "`_container_name_`" + ` String,
+` + "`pod_ip`" + ` String,
+` + "`_pod_name_`" + ` String,
+` + "`_namespace_`" + ` String,
+` + "`_node_name_`" + ` String,
+` + "`pod_uid`" + ` String,
+  _time_second_ DateTime,
+  _time_nanosecond_ DateTime64(9),
+  _raw_log_ String CODEC(ZSTD(1)),
+  INDEX idx_raw_log _raw_log_ TYPE tokenbf_v1(30720, 2, 0) GRANULARITY 1
+)
+ENGINE = MergeTree
+PARTITION BY toYYYYMMDD(_time_second_)
+ORDER BY _time_second_
+TTL toDateTime(_time_second_) + INTERVAL 3 DAY
+SETTINGS index_granularity = 8192;
+`,
+			wantView: "CREATE MATERIALIZED VIEW IF NOT EXISTS `logs`.`vector_view` TO `logs`.`vector` AS" + `
+SELECT
+JSONExtractString(_log, 'file') AS ` + "`file`" + `,
+JSONExtractString(_log, 'source_type') AS ` + "`_log_agent_`" + `,
+JSONExtractString(_log, 'stream') AS ` + "`_source_`" + `,
+JSONExtractString(JSONExtractRaw(_log, 'kubernetes'), 'container_image') AS ` + "`container_image`" + `,
+JSONExtractString(JSONExtractRaw(_log, 'kubernetes'), 'container_name') AS ` + "`_container_name_`" + `,
+JSONExtractString(JSONExtractRaw(_log, 'kubernetes'), 'pod_ip') AS ` + "`pod_ip`" + `,
+JSONExtractString(JSONExtractRaw(_log, 'kubernetes'), 'pod_name') AS ` + "`_pod_name_`" + `,
+JSONExtractString(JSONExtractRaw(_log, 'kubernetes'), 'pod_namespace') AS ` + "`_namespace_`" + `,
+JSONExtractString(JSONExtractRaw(_log, 'kubernetes'), 'pod_node_name') AS ` + "`_node_name_`" + `,
+JSONExtractString(JSONExtractRaw(_log, 'kubernetes'), 'pod_uid') AS ` + "`pod_uid`" + `,
+parseDateTimeBestEffort(JSONExtractString(_log, 'timestamp')) AS _time_second_,
+toDateTime64(parseDateTimeBestEffort(JSONExtractString(_log, 'timestamp')), 9) AS _time_nanosecond_,
+JSONExtractString(_log, 'message') AS _raw_log_
+FROM ` + "`logs`.`vector_stream`" + ` WHERE 1=1;
+`,
+		},
+		{
+			name:     "test-otlp",
+			template: storagetemplate.NameOTLP,
+			wantData: `CREATE TABLE IF NOT EXISTS ` + "`logs`.`otlp`" + `
+(
+  ` + "`severityNumber`" + ` Float64,
+` + "`severityText`" + ` String,
+` + "`spanId`" + ` String,
+` + "`traceId`" + ` String,
+` + "`_container_name_`" + ` String,
+` + "`_namespace_`" + ` String,
+` + "`_node_name_`" + ` String,
+` + "`_pod_name_`" + ` String,
+` + "`service.name`" + ` String,
+  _time_second_ DateTime,
+  _time_nanosecond_ DateTime64(9),
+  _raw_log_ String CODEC(ZSTD(1)),
+  INDEX idx_raw_log _raw_log_ TYPE tokenbf_v1(30720, 2, 0) GRANULARITY 1
+)
+ENGINE = MergeTree
+PARTITION BY toYYYYMMDD(_time_second_)
+ORDER BY _time_second_
+TTL toDateTime(_time_second_) + INTERVAL 3 DAY
+SETTINGS index_granularity = 8192;
+`,
+			wantView: "CREATE MATERIALIZED VIEW IF NOT EXISTS `logs`.`otlp_view` TO `logs`.`otlp` AS" + `
+SELECT
+JSONExtractFloat(_log, 'severityNumber') AS ` + "`severityNumber`" + `,
+JSONExtractString(_log, 'severityText') AS ` + "`severityText`" + `,
+JSONExtractString(_log, 'spanId') AS ` + "`spanId`" + `,
+JSONExtractString(_log, 'traceId') AS ` + "`traceId`" + `,
+JSONExtractString(JSONExtractRaw(_log, 'resource'), 'k8s.container.name') AS ` + "`_container_name_`" + `,
+JSONExtractString(JSONExtractRaw(_log, 'resource'), 'k8s.namespace.name') AS ` + "`_namespace_`" + `,
+JSONExtractString(JSONExtractRaw(_log, 'resource'), 'k8s.node.name') AS ` + "`_node_name_`" + `,
+JSONExtractString(JSONExtractRaw(_log, 'resource'), 'k8s.pod.name') AS ` + "`_pod_name_`" + `,
+JSONExtractString(JSONExtractRaw(_log, 'resource'), 'service.name') AS ` + "`service.name`" + `,
+toDateTime(intDiv(toInt64(JSONExtractString(_log, 'timeUnixNano')), 1000000000)) AS _time_second_,
+fromUnixTimestamp64Nano(toInt64(JSONExtractString(_log, 'timeUnixNano'))) AS _time_nanosecond_,
+JSONExtractString(JSONExtractRaw(_log, 'body'), 'stringValue') AS _raw_log_
+FROM (
+  SELECT
+    _topic,
+    _partition,
+    _offset,
+    arrayJoin(if(empty(_records), [_message], _records)) AS _log
+  FROM
+  (
+    SELECT
+      _topic,
+      _partition,
+      _offset,
+      _log AS _message,
+      arrayFlatten(arrayMap(r -> arrayMap(l -> concat('{"resource":', toJSONString(mapFromArrays(arrayMap(a -> JSONExtractString(a, 'key'), JSONExtractArrayRaw(r, 'resource', 'attributes')), arrayMap(a -> JSONExtractString(a, 'value', 'stringValue'), JSONExtractArrayRaw(r, 'resource', 'attributes')))), if(length(l) > 2, ',', ''), substring(l, 2)), arrayFlatten(arrayMap(s -> JSONExtractArrayRaw(s, 'logRecords'), JSONExtractArrayRaw(r, 'scopeLogs')))), JSONExtractArrayRaw(_log, 'resourceLogs'))) AS _records
+    FROM ` + "`logs`.`otlp_stream`" + `
+  )
+) WHERE 1=1;
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tpl, err := storagetemplate.Get(tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ct := tpl.StorageCreate(view.ReqCreateStorageByTemplate{
+				Brokers:    "kafka:9092",
+				DatabaseId: 1,
+				Days:       3,
+				Name:       tt.template,
+				Topic:      tt.template,
+			})
+			if ct.SourceMapping, err = mapping.Handle(ct.Source, true); err != nil {
+				t.Fatal(err)
+			}
+			ct.SourceMapping.SetAlias(ct.FieldAlias)
+			c := &ClickHouseX{}
+			storerParams, _, switcherParams := c.storageJSONAsStringParams(db.BaseDatabase{Name: "logs"}, ct, false, false)
+			_, dataSQLs, err := storer.New(db.DatasourceClickHouse, storerParams).Create()
+			if !errors.Is(err, common.ErrNoConn) {
+				t.Fatal(err)
+			}
+			if dataSQLs[0] != tt.wantData {
+				t.Errorf("data sql = %v, want %v", dataSQLs[0], tt.wantData)
+			}
+			_, viewSQLs, err := switcher.New(db.DatasourceClickHouse, switcherParams).Create()
+			if !errors.Is(err, common.ErrNoConn) {
+				t.Fatal(err)
+			}
+			if viewSQLs[0] != tt.wantView {
+				t.Errorf("view sql = %v, want %v", viewSQLs[0], tt.wantView)
+			}
+		})
+	}
+}
+
+func Test_rdkafkaLag(t *testing.T) {
+	tests := []struct {
+		name  string
+		stats []string
+		want  int64
+	}{
+		{
+			name:  "no statistics",
+			stats: []string{""},
+			want:  -1,
+		},
+		{
+			name: "skip internal partition and unknown lag",
+			stats: []string{
+				`{"topics":{"app":{"partitions":{"0":{"consumer_lag":10},"1":{"consumer_lag":-1},"-1":{"consumer_lag":100}}}}}`,
+				`{"topics":{"app":{"partitions":{"2":{"consumer_lag":5}}}}}`,
+			},
+			want: 15,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rdkafkaLag(tt.stats); got != tt.want {
+				t.Errorf("rdkafkaLag() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_deadLetterSQL(t *testing.T) {
+	tpl, err := storagetemplate.Get(storagetemplate.NameVector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := tpl.StorageCreate(view.ReqCreateStorageByTemplate{
+		Brokers:    "kafka:9092",
+		DatabaseId: 1,
+		Days:       3,
+		Name:       "app",
+		Topic:      "app",
+		DeadLetter: 1,
+	})
+	if ct.SourceMapping, err = mapping.Handle(ct.Source, true); err != nil {
+		t.Fatal(err)
+	}
+	c := &ClickHouseX{}
+	storerParams, _, switcherParams := c.storageJSONAsStringParams(db.BaseDatabase{Name: "logs"}, ct, false, false)
+	_, dataSQLs, err := storer.New(db.DatasourceClickHouse, storerParams).Create()
+	if !errors.Is(err, common.ErrNoConn) {
+		t.Fatal(err)
+	}
+	wantDLQ := "CREATE TABLE IF NOT EXISTS `logs`.`app_dlq`" + `
+(
+  _time_second_ DateTime,
+  _topic_ String,
+  _partition_ UInt64,
+  _offset_ UInt64,
+  _error_ String,
+  _raw_message_ String CODEC(ZSTD(1))
+)
+ENGINE = MergeTree
+PARTITION BY toYYYYMMDD(_time_second_)
+ORDER BY _time_second_
+TTL toDateTime(_time_second_) + INTERVAL 3 DAY
+SETTINGS index_granularity = 8192;
+`
+	if len(dataSQLs) != 2 || dataSQLs[1] != wantDLQ {
+		t.Errorf("dead-letter table sql = %v, want %v", dataSQLs, wantDLQ)
+	}
+	_, viewSQLs, err := switcher.New(db.DatasourceClickHouse, switcherParams).Create()
+	if !errors.Is(err, common.ErrNoConn) {
+		t.Fatal(err)
+	}
+	parsable := "isValidJSON(_log) AND isNotNull(parseDateTimeBestEffortOrNull(JSONExtractString(_log, 'timestamp')))"
+	if len(viewSQLs) != 2 {
+		t.Fatalf("view sqls = %v, want main and dead-letter views", viewSQLs)
+	}
+	if !strings.HasSuffix(viewSQLs[0], "1=1 AND "+parsable+";\n") {
+		t.Errorf("main view sql = %v, want parsable condition", viewSQLs[0])
+	}
+	wantDLQView := "CREATE MATERIALIZED VIEW IF NOT EXISTS `logs`.`app_dlq_view` TO `logs`.`app_dlq` AS" + `
+SELECT
+now() AS _time_second_,
+_topic AS _topic_,
+_partition AS _partition_,
+_offset AS _offset_,
+multiIf(NOT isValidJSON(_log), 'invalid json', 'invalid time field') AS _error_,
+_log AS _raw_message_
+FROM ` + "`logs`.`app_stream`" + ` WHERE NOT (` + parsable + `);
+`
+	if viewSQLs[1] != wantDLQView {
+		t.Errorf("dead-letter view sql = %v, want %v", viewSQLs[1], wantDLQView)
+	}
+}
+
+func Test_rebuildSwitcherJSONAsStringParams(t *testing.T) {
+	tpl, err := storagetemplate.Get(storagetemplate.NameVector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ct := tpl.StorageCreate(view.ReqCreateStorageByTemplate{
+		Brokers:    "kafka:9092",
+		DatabaseId: 1,
+		Days:       3,
+		Name:       "app",
+		Topic:      "app",
+		DeadLetter: 1,
+	})
+	if ct.SourceMapping, err = mapping.Handle(ct.Source, true); err != nil {
+		t.Fatal(err)
+	}
+	ct.SourceMapping.SetAlias(ct.FieldAlias)
+	c := &ClickHouseX{}
+	database := db.BaseDatabase{Name: "logs"}
+	_, _, createParams := c.storageJSONAsStringParams(database, ct, false, false)
+	_, createSQLs, err := switcher.New(db.DatasourceClickHouse, createParams).Create()
+	if !errors.Is(err, common.ErrNoConn) {
+		t.Fatal(err)
+	}
+	// rebuilt from the stored create params, as after an analysis field update
+	stored := view.ReqStorageCreateUnmarshal(ct.JSON())
+	rebuildParams := c.rebuildSwitcherJSONAsStringParams(stored, nil, nil, &database, "", nil, false, false)
+	_, rebuildSQLs, err := switcher.New(db.DatasourceClickHouse, rebuildParams).Create()
+	if !errors.Is(err, common.ErrNoConn) {
+		t.Fatal(err)
+	}
+	if len(rebuildSQLs) != 2 {
+		t.Fatalf("rebuilt view sqls = %v, want main and dead-letter views", rebuildSQLs)
+	}
+	for k := range createSQLs {
+		if rebuildSQLs[k] != createSQLs[k] {
+			t.Errorf("rebuilt view sql = %v, want %v", rebuildSQLs[k], createSQLs[k])
+		}
+	}
+}
+
+func Test_deadLetterCondition(t *testing.T) {
+	where, args := deadLetterCondition(1, 2, "")
+	if where != "_time_second_ >= toDateTime(1) AND _time_second_ < toDateTime(2)" || len(args) != 0 {
+		t.Errorf("deadLetterCondition() = %v %v", where, args)
+	}
+	where, args = deadLetterCondition(1, 2, "invalid json")
+	if where != "_time_second_ >= toDateTime(1) AND _time_second_ < toDateTime(2) AND _error_ = ?" || len(args) != 1 {
+		t.Errorf("deadLetterCondition() = %v %v", where, args)
+	}
+}
+
+func Test_timeParseJSONAsString(t *testing.T) {
+	c := &ClickHouseX{}
+	tests := []struct {
+		typ       int
+		wantParse string
+		wantCheck string
+	}{
+		{
+			typ: factory.TableTypeCLF,
+			wantParse: `parseDateTimeBestEffort(replaceRegexpOne(JSONExtractString(_log, 'time_local'), '^(\\d+)/(\\w+)/(\\d+):', '\\1 \\2 \\3 ')) AS _time_second_,
+toDateTime64(parseDateTimeBestEffort(replaceRegexpOne(JSONExtractString(_log, 'time_local'), '^(\\d+)/(\\w+)/(\\d+):', '\\1 \\2 \\3 ')), 9) AS _time_nanosecond_`,
+			wantCheck: `isNotNull(parseDateTimeBestEffortOrNull(replaceRegexpOne(JSONExtractString(_log, 'time_local'), '^(\\d+)/(\\w+)/(\\d+):', '\\1 \\2 \\3 ')))`,
+		},
+		{
+			typ: factory.TableTypeANSIC,
+			wantParse: `parseDateTimeBestEffort(replaceRegexpOne(JSONExtractString(_log, 'time_local'), '^\\w+ +(\\w+) +(\\d+) (\\S+) (\\d+)$', '\\2 \\1 \\4 \\3')) AS _time_second_,
+toDateTime64(parseDateTimeBestEffort(replaceRegexpOne(JSONExtractString(_log, 'time_local'), '^\\w+ +(\\w+) +(\\d+) (\\S+) (\\d+)$', '\\2 \\1 \\4 \\3')), 9) AS _time_nanosecond_`,
+			wantCheck: `isNotNull(parseDateTimeBestEffortOrNull(replaceRegexpOne(JSONExtractString(_log, 'time_local'), '^\\w+ +(\\w+) +(\\d+) (\\S+) (\\d+)$', '\\2 \\1 \\4 \\3')))`,
+		},
+		{
+			typ: factory.TableTypeString,
+			wantParse: `parseDateTimeBestEffort(JSONExtractString(_log, 'time_local')) AS _time_second_,
+toDateTime64(parseDateTimeBestEffort(JSONExtractString(_log, 'time_local')), 9) AS _time_nanosecond_`,
+			wantCheck: `isNotNull(parseDateTimeBestEffortOrNull(JSONExtractString(_log, 'time_local')))`,
+		},
+	}
+	for _, tt := range tests {
+		if got := c.timeParseJSONAsString(tt.typ, nil, "time_local", "", "_log"); got != tt.wantParse {
+			t.Errorf("timeParseJSONAsString(%d) = %v, want %v", tt.typ, got, tt.wantParse)
+		}
+		if got := c.timeCheckJSONAsString(tt.typ, "time_local", ""); got != tt.wantCheck {
+			t.Errorf("timeCheckJSONAsString(%d) = %v, want %v", tt.typ, got, tt.wantCheck)
+		}
+	}
+}
+
+func Test_quoteString(t *testing.T) {
+	tests := map[string]string{
+		"127.0.0.1:9092":              "'127.0.0.1:9092'",
+		"logs', kafka_format = 'CSV":  `'logs\', kafka_format = \'CSV'`,
+		`logs\', kafka_format = 'CSV`: `'logs\\\', kafka_format = \'CSV'`,
+	}
+	for val, want := range tests {
+		if got := quoteString(val); got != want {
+			t.Errorf("quoteString(%v) = %v, want %v", val, got, want)
+		}
+	}
+}
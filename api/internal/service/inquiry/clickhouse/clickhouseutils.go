@@ -1,6 +1,7 @@
 package clickhouse
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
@@ -23,6 +24,12 @@ var regSingleWord = regexp.MustCompile(`([a-z]|[A-Z]|[0-9]|_|-|')+`)
 var regChinese = regexp.MustCompile("^[\u4e00-\u9fa5]")
 var regDistributedSubTable = regexp.MustCompile(`ENGINE = Distributed\([^,]+,[^,]+,([\S\s]+),`)
 
+// regNow now() of the aggregation sql of an alarm filter
+var regNow = regexp.MustCompile(`(?i)\bnow\(\s*\)`)
+
+// regToken matches a keyword that is exactly one token for the full-text index tokenizer
+var regToken = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+
 type JaegerJsonOriginal struct {
 	TraceId  string `json:"trace_id"`
 	SpanId   string `json:"span_id"`
@@ -107,6 +114,23 @@ func genTimeCondition(param view2.ReqQuery) string {
 	return param.TimeField + " >= %d AND " + param.TimeField + " < %d"
 }
 
+// genTimeConditionExpr time condition between the sql expressions of unix seconds st and et
+func genTimeConditionExpr(param view2.ReqQuery, st, et string) string {
+	switch param.TimeFieldType {
+	case db2.TimeFieldTypeDT:
+		return fmt.Sprintf("%s >= toDateTime(%s) AND %s < toDateTime(%s)", param.TimeField, st, param.TimeField, et)
+	case db2.TimeFieldTypeDT3:
+		return fmt.Sprintf("%s >= toDateTime64(%s, 3) AND %s < toDateTime64(%s, 3)", param.TimeField, st, param.TimeField, et)
+	case db2.TimeFieldTypeDT6:
+		return fmt.Sprintf("%s >= toDateTime64(%s, 6) AND %s < toDateTime64(%s, 6)", param.TimeField, st, param.TimeField, et)
+	case db2.TimeFieldTypeDT9:
+		return fmt.Sprintf("%s >= toDateTime64(%s, 9) AND %s < toDateTime64(%s, 9)", param.TimeField, st, param.TimeField, et)
+	case db2.TimeFieldTypeTsMs:
+		return fmt.Sprintf("intDiv(%s,1000) >= %s AND intDiv(%s,1000) < %s", param.TimeField, st, param.TimeField, et)
+	}
+	return fmt.Sprintf("%s >= %s AND %s < %s", param.TimeField, st, param.TimeField, et)
+}
+
 func TransferGroupTimeField(timeField string, timeFieldTyp int) string {
 	switch timeFieldTyp {
 	case db2.TimeFieldTypeDT:
@@ -190,7 +214,7 @@ func genSelectFields(tid int) string {
 	return "*"
 }
 
-func queryTransformLike(createType int, rawLogField, query string) string {
+func queryTransformLike(createType int, rawLogField string, rawLogIndex int, query string) string {
 	if query == "" || rawLogField == "" {
 		return query
 	}
@@ -200,14 +224,14 @@ func queryTransformLike(createType int, rawLogField, query string) string {
 		for k, item := range andArr {
 			item = strings.TrimSpace(item)
 			if k == 0 {
-				res = likeTransform(createType, rawLogField, item)
+				res = likeTransform(createType, rawLogField, rawLogIndex, item)
 				continue
 			}
-			res = fmt.Sprintf("%s AND %s", res, likeTransform(createType, rawLogField, item))
+			res = fmt.Sprintf("%s AND %s", res, likeTransform(createType, rawLogField, rawLogIndex, item))
 		}
 		return res
 	}
-	return likeTransform(createType, rawLogField, query)
+	return likeTransform(createType, rawLogField, rawLogIndex, query)
 }
 
 func likeTransformAndArr(query string) []string {
@@ -240,7 +264,7 @@ func queryTransformHash(params view2.ReqQuery) string {
 	return query
 }
 
-func likeTransform(createType int, rawLogField, query string) string {
+func likeTransform(createType int, rawLogField string, rawLogIndex int, query string) string {
 	// 判断是否可以进行转换
 	matches := regSingleWord.FindAllString(strings.TrimSpace(query), -1)
 
@@ -254,9 +278,27 @@ func likeTransform(createType int, rawLogField, query string) string {
 	if createType == constx2.TableCreateTypeExist && rawLogField != "" {
 		field = rawLogField
 	}
+	// a keyword in double quotes is a whole word, hasToken skips the granules by the full-text index.
+	// Other keywords keep matching substrings with LIKE, the index only skips by the complete tokens inside them.
+	if rawLogIndex == db2.RawLogIndexTokenBF || rawLogIndex == db2.RawLogIndexInverted {
+		if token := strings.Trim(query, `"`); len(token)+2 == len(query) && regToken.MatchString(token) {
+			return "hasToken(" + field + ", '" + token + "')"
+		}
+	}
 	return field + " LIKE '%" + query + "%'"
 }
 
+// rawLogIndexType returns the index declaration of the raw log full-text index
+func rawLogIndexType(rawLogIndex int) (string, error) {
+	switch rawLogIndex {
+	case db2.RawLogIndexTokenBF:
+		return "tokenbf_v1(30720, 2, 0)", nil
+	case db2.RawLogIndexInverted:
+		return "inverted(0)", nil
+	}
+	return "", errors.New("invalid raw log index type")
+}
+
 func hashTransform(query string, index *db2.BaseIndex) string {
 	var (
 		key              = index.GetFieldName()
@@ -320,14 +362,19 @@ func isEmpty(input interface{}) bool {
 }
 
 func tableTypStr(typ int) string {
-	if typ == factory.TableTypeString {
+	if typ == factory.TableTypeString || typ == factory.TableTypeUnixNano || typ == factory.TableTypeCLF || typ == factory.TableTypeANSIC {
 		return "String"
-	} else if typ == factory.TableTypeFloat {
+	} else if typ == factory.TableTypeFloat || typ == factory.TableTypeUnixMilli {
 		return "Float64"
 	}
 	return ""
 }
 
+// quoteString string literal of the value
+func quoteString(val string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(val) + "'"
+}
+
 func genName(database, tableName string) string {
 	return fmt.Sprintf("`%s`.`%s`", database, tableName)
 }
@@ -437,3 +484,51 @@ func getDistributedSubTableName(sql string) (string, error) {
 	}
 	return "", errors.New("cannot find distributed sub table")
 }
+
+// rdkafkaLag sums consumer_lag of the librdkafka statistics, -1 means no statistics
+func rdkafkaLag(stats []string) int64 {
+	var (
+		lag   int64
+		found bool
+	)
+	for _, stat := range stats {
+		var s struct {
+			Topics map[string]struct {
+				Partitions map[string]struct {
+					ConsumerLag int64 `json:"consumer_lag"`
+				} `json:"partitions"`
+			} `json:"topics"`
+		}
+		if stat == "" || json.Unmarshal([]byte(stat), &s) != nil {
+			continue
+		}
+		for _, topic := range s.Topics {
+			for id, partition := range topic.Partitions {
+				// -1 is the internal UA partition
+				if id == "-1" || partition.ConsumerLag < 0 {
+					continue
+				}
+				lag += partition.ConsumerLag
+				found = true
+			}
+		}
+	}
+	if !found {
+		return -1
+	}
+	return lag
+}
+
+// deadLetterCondition time range [st, et) and optional error reason of the dead-letter table
+func deadLetterCondition(st, et int64, reason string) (string, []interface{}) {
+	where := fmt.Sprintf("_time_second_ >= toDateTime(%d) AND _time_second_ < toDateTime(%d)", st, et)
+	if reason == "" {
+		return where, nil
+	}
+	return where + " AND _error_ = ?", []interface{}{reason}
+}
+
+// alertAggregationAt the aggregation sql of an alarm filter evaluated at et instead of the current time
+func alertAggregationAt(when string, et int64) string {
+	return regNow.ReplaceAllString(when, fmt.Sprintf("toDateTime(%d)", et))
+}
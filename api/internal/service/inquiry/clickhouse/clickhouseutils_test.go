@@ -49,3 +49,34 @@ ENGINE = Distributed('shard2-repl1', 'mogo_shimo_dev', 'shimodev_svc_front_track
 		})
 	}
 }
+
+func Test_alertAggregationAt(t *testing.T) {
+	tests := []struct {
+		name string
+		when string
+		want string
+	}{
+		{
+			name: "now",
+			when: "SELECT count() as val FROM logs WHERE _time_second_ > now() - 60",
+			want: "SELECT count() as val FROM logs WHERE _time_second_ > toDateTime(1681704437) - 60",
+		},
+		{
+			name: "case and spaces",
+			when: "SELECT count() as val FROM logs WHERE _time_second_ BETWEEN NOW( ) - 60 AND now()",
+			want: "SELECT count() as val FROM logs WHERE _time_second_ BETWEEN toDateTime(1681704437) - 60 AND toDateTime(1681704437)",
+		},
+		{
+			name: "other functions",
+			when: "SELECT count() as val FROM logs WHERE _time_second_ > now64() - 60 AND msg != 'knownow()'",
+			want: "SELECT count() as val FROM logs WHERE _time_second_ > now64() - 60 AND msg != 'knownow()'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := alertAggregationAt(tt.when, 1681704437); got != tt.want {
+				t.Errorf("alertAggregationAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
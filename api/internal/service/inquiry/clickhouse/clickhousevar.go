@@ -10,7 +10,18 @@ const (
 toDateTime64(parseDateTimeBestEffort(%s), 9) AS _time_nanosecond_`
 	defaultFloatTimeParse = `toDateTime(toInt64(%s)) AS _time_second_,
 fromUnixTimestamp64Nano(toInt64(%s*1000000000)) AS _time_nanosecond_`
+	defaultUnixNanoTimeParse = `toDateTime(intDiv(toInt64(%s), 1000000000)) AS _time_second_,
+fromUnixTimestamp64Nano(toInt64(%s)) AS _time_nanosecond_`
+	defaultUnixMilliTimeParse = `toDateTime(intDiv(toInt64(%s), 1000)) AS _time_second_,
+toDateTime64(fromUnixTimestamp64Milli(toInt64(%s)), 9) AS _time_nanosecond_`
+	// clfTimeRewrite 02/Jan/2006:15:04:05 -0700 -> 02 Jan 2006 15:04:05 -0700 which parseDateTimeBestEffort accepts
+	clfTimeRewrite = `replaceRegexpOne(%s, '^(\\d+)/(\\w+)/(\\d+):', '\\1 \\2 \\3 ')`
+	// ansicTimeRewrite Mon Jan  2 15:04:05 2006 -> 2 Jan 2006 15:04:05
+	ansicTimeRewrite = `replaceRegexpOne(%s, '^\\w+ +(\\w+) +(\\d+) (\\S+) (\\d+)$', '\\2 \\1 \\4 \\3')`
 	defaultCondition = "1='1'"
+	// kafkaSampleSkipBrokenMessages broken messages of a block skipped while sampling
+	kafkaSampleSkipBrokenMessages = 10
+	rawLogIndexName               = "idx_raw_log"
 )
 
 const (
@@ -98,3 +109,16 @@ var jsonExtractORM = map[int]string{
 	1: "toInt64OrNull",
 	2: "toFloat64OrNull",
 }
+
+const (
+	ingestionConsumerSQL = `SELECT toInt64(count()),
+toUInt64(sum(num_messages_read)),
+toInt64(toUnixTimestamp(max(last_poll_time))),
+toInt64(toUnixTimestamp(max(last_commit_time))),
+toUInt64(sum(arrayCount(t -> toUnixTimestamp(t) > %d, exceptions.time))),
+argMax(exceptions.text[-1], exceptions.time[-1]),
+groupArray(rdkafka_stat)
+FROM %s WHERE database = '%s' AND table = '%s'`
+	ingestionLatestSQL = `SELECT toInt64(toUnixTimestamp(max(_time_second_))) FROM %s WHERE _time_second_ > now() - INTERVAL 7 DAY`
+	ingestionBrokenSQL = `SELECT toInt64(count()) FROM %s WHERE _time_second_ > toDateTime(%d)`
+)
@@ -0,0 +1,62 @@
+package clickhouse
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+)
+
+// errorPatternMaxLen patterns are cut to it
+const errorPatternMaxLen = 200
+
+// errorPatternRegs placeholders of the variable parts of the messages, in re2 syntax for replaceRegexpAll
+var errorPatternRegs = []struct {
+	reg  string
+	repl string
+}{
+	{`"[^"]*"|'[^']*'`, "<str>"},
+	{`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`, "<uuid>"},
+	{`\b\d{1,3}(\.\d{1,3}){3}\b`, "<ip>"},
+	{`(?i)\b0x[0-9a-f]+\b|\b[0-9a-f]{8,}\b`, "<hex>"},
+	{`\d+(\.\d+)?`, "<num>"},
+	{`\s+`, " "},
+}
+
+var errorPatternEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// errorPatternExpr pattern of the message field, the messages are grouped by it in clickhouse
+func errorPatternExpr(field string) string {
+	expr := fmt.Sprintf("toString(`%s`)", field)
+	for _, r := range errorPatternRegs {
+		expr = fmt.Sprintf("replaceRegexpAll(%s, '%s', '%s')", expr, errorPatternEscaper.Replace(r.reg), errorPatternEscaper.Replace(r.repl))
+	}
+	return fmt.Sprintf("substring(trimBoth(%s), 1, %d)", expr, errorPatternMaxLen)
+}
+
+// ErrorPatterns the most frequent patterns of the param.Field messages matching the query, with an example of each
+func (c *ClickHouseX) ErrorPatterns(param view.ReqQuery, limit int) (res []view.ErrorPattern, err error) {
+	q := fmt.Sprintf("SELECT count() AS count, %s AS pattern, any(toString(`%s`)) AS example FROM %s WHERE "+genTimeCondition(param)+" %s GROUP BY pattern HAVING pattern != '' ORDER BY count DESC LIMIT %d",
+		errorPatternExpr(param.Field),
+		param.Field,
+		param.DatabaseTable,
+		param.ST, param.ET,
+		c.queryTransform(param, true),
+		limit)
+	rows, err := c.db.Query(q)
+	if err != nil {
+		return nil, errors.Wrapf(err, "sql: %s", q)
+	}
+	defer func() { _ = rows.Close() }()
+	res = make([]view.ErrorPattern, 0)
+	for rows.Next() {
+		row := view.ErrorPattern{}
+		if err = rows.Scan(&row.Count, &row.Pattern, &row.Example); err != nil {
+			return nil, err
+		}
+		res = append(res, row)
+	}
+	return res, rows.Err()
+}
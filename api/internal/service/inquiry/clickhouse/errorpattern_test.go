@@ -0,0 +1,46 @@
+package clickhouse
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// errorPattern the regexps applied as replaceRegexpAll does, re2 is the syntax of both
+func errorPattern(message string) string {
+	for _, r := range errorPatternRegs {
+		message = regexp.MustCompile(r.reg).ReplaceAllString(message, r.repl)
+	}
+	return strings.TrimSpace(message)
+}
+
+func Test_errorPatternRegs(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"dial tcp 10.0.0.1:3306: connection refused", "dial tcp <ip>:<num>: connection refused"},
+		{"user 42 not found  in 1.5s", "user <num> not found in <num>s"},
+		{`order "A-1" of 7f3c2a1b9e0d failed`, "order <str> of <hex> failed"},
+		{"trace 123e4567-e89b-12d3-a456-426614174000 ptr 0xc000123", "trace <uuid> ptr <hex>"},
+	}
+	for _, tt := range tests {
+		if got := errorPattern(tt.message); got != tt.want {
+			t.Errorf("errorPattern(%q) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+}
+
+func Test_errorPatternExpr(t *testing.T) {
+	expr := errorPatternExpr("msg")
+	for _, want := range []string{
+		"substring(trimBoth(replaceRegexpAll(",
+		"replaceRegexpAll(toString(`msg`), '\"[^\"]*\"|\\'[^\\']*\\'', '<str>')",
+		`'\\d+(\\.\\d+)?', '<num>'`,
+		"), 1, 200)",
+	} {
+		if !strings.Contains(expr, want) {
+			t.Errorf("errorPatternExpr() = %s, want %s in it", expr, want)
+		}
+	}
+}
@@ -369,6 +369,41 @@ func (c *Databend) UpdateMergeTreeTable(tableInfo *db2.BaseTable, params view2.R
 	return
 }
 
+// UpdateRawLogIndex databend has no skipping index for the raw log field
+func (c *Databend) UpdateRawLogIndex(tableInfo *db2.BaseTable, rawLogIndex int) error {
+	return errors.New("raw log index is not supported by databend")
+}
+
+// KafkaSample databend has no kafka engine
+func (c *Databend) KafkaSample(database db2.BaseDatabase, brokers, topic string, limit int) ([]string, error) {
+	return nil, errors.New("kafka sample is not supported by databend")
+}
+
+// IngestionHealth databend has no kafka engine
+func (c *Databend) IngestionHealth(table *db2.BaseTable, since int64) (view2.IngestionHealth, error) {
+	return view2.IngestionHealth{}, errors.New("ingestion health is not supported by databend")
+}
+
+// GetAlertSamples native alert evaluation is not supported by databend yet
+func (c *Databend) GetAlertSamples(table *db2.BaseTable, filter *db2.AlarmFilter, conditions []*db2.AlarmCondition, st, et int64) ([]float64, error) {
+	return nil, errors.New("native alert evaluation is not supported by databend")
+}
+
+// ListDeadLetter databend has no kafka engine
+func (c *Databend) ListDeadLetter(table *db2.BaseTable, req view2.ReqStorageDeadLetterList) (view2.RespStorageDeadLetterList, error) {
+	return view2.RespStorageDeadLetterList{}, errors.New("dead letter is not supported by databend")
+}
+
+// ErrorPatterns databend has no regexp replacement yet
+func (c *Databend) ErrorPatterns(param view2.ReqQuery, limit int) ([]view2.ErrorPattern, error) {
+	return nil, errors.New("error patterns are not supported by databend")
+}
+
+// ReplayDeadLetter databend has no kafka engine
+func (c *Databend) ReplayDeadLetter(table *db2.BaseTable, req view2.ReqStorageDeadLetterReplay) (uint64, error) {
+	return 0, errors.New("dead letter is not supported by databend")
+}
+
 func (c *Databend) GetLogs(param view2.ReqQuery, tid int) (res view2.RespQuery, err error) {
 	res.Logs = make([]map[string]interface{}, 0)
 	res.Keys = make([]*db2.BaseIndex, 0)
@@ -379,7 +414,7 @@ func (c *Databend) GetLogs(param view2.ReqQuery, tid int) (res view2.RespQuery,
 		optimizeSQL   string
 	)
 	switch param.AlarmMode {
-	case db2.AlarmModeAggregation:
+	case db2.AlarmModeAggregation, db2.AlarmModeTraceEdge:
 		defaultSQL = param.Query
 	case db2.AlarmModeAggregationCheck:
 		defaultSQL = alarmAggregationSQLWith(param)
@@ -837,11 +872,14 @@ func (c *Databend) GetAlertViewSQL(alarm *db2.Alarm, tableInfo db2.BaseTable, fi
 		SourceTable:  sourceTableName,
 		Where:        filter.When,
 	}
-	if filter.Mode == db2.AlarmModeAggregation || filter.Mode == db2.AlarmModeAggregationCheck {
+	if filter.IsAggregation() {
 		vp.ViewType = bumo.ViewTypePrometheusMetricAggregation
 		// vp.WithSQL = adaSelectPart(filter.When)
 		vp.WithSQL = filter.When
 	}
+	if db2.HasAnomaly(filter.Conditions) {
+		return "", "", errors.New("anomaly conditions are not supported by databend")
+	}
 	viewSQL = c.execView(bumo.Params{
 		Cluster:       tableInfo.Database.Cluster,
 		ReplicaStatus: c.rs,
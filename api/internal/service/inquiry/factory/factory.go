@@ -17,6 +17,14 @@ import (
 const (
 	TableTypeString = 1
 	TableTypeFloat  = 2
+	// TableTypeUnixNano unix nanoseconds encoded as a string, e.g. timeUnixNano of OTLP JSON
+	TableTypeUnixNano = 3
+	// TableTypeUnixMilli unix milliseconds encoded as a number
+	TableTypeUnixMilli = 4
+	// TableTypeCLF common log format string, e.g. 02/Jan/2006:15:04:05 -0700 of nginx time_local
+	TableTypeCLF = 5
+	// TableTypeANSIC ANSI C asctime string, e.g. Mon Jan  2 15:04:05 2006
+	TableTypeANSIC = 6
 )
 
 var (
@@ -44,10 +52,12 @@ type Operator interface {
 
 	UpdateLogAnalysisFields(db.BaseDatabase, db.BaseTable, map[string]*db.BaseIndex, map[string]*db.BaseIndex, map[string]*db.BaseIndex) error
 	UpdateMergeTreeTable(*db.BaseTable, view.ReqStorageUpdate) error
+	UpdateRawLogIndex(*db.BaseTable, int) error
 
 	GetLogs(view.ReqQuery, int) (view.RespQuery, error)
 	GetCreateSQL(database, table string) (string, error)
 	GetAlertViewSQL(*db.Alarm, db.BaseTable, int, *view.AlarmFilterItem) (string, string, error)
+	GetAlertSamples(*db.BaseTable, *db.AlarmFilter, []*db.AlarmCondition, int64, int64) ([]float64, error)
 	GetTraceGraph(ctx context.Context) ([]view.RespJaegerDependencyDataModel, error)
 	GetMetricsSamples() error
 	ClusterInfo() (clusters map[string]dto.ClusterInfo, err error)
@@ -63,6 +73,11 @@ type Operator interface {
 	DeleteTableListByNames([]string, string) error
 	DeleteTraceJaegerDependencies(database, cluster, table string) (err error)
 	CalculateInterval(interval int64, timeField string) (string, int64)
+	KafkaSample(database db.BaseDatabase, brokers, topic string, limit int) ([]string, error)
+	IngestionHealth(table *db.BaseTable, since int64) (view.IngestionHealth, error)
+	ListDeadLetter(table *db.BaseTable, req view.ReqStorageDeadLetterList) (view.RespStorageDeadLetterList, error)
+	ReplayDeadLetter(table *db.BaseTable, req view.ReqStorageDeadLetterReplay) (uint64, error)
+	ErrorPatterns(param view.ReqQuery, limit int) ([]view.ErrorPattern, error)
 }
 
 func TagsToString(alarm *db.Alarm, isMV bool, filterId int) string {
@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/gotomicro/ego/core/elog"
+	"github.com/pkg/errors"
 
 	"github.com/clickvisual/clickvisual/api/internal/pkg/agent/search"
 	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
@@ -179,6 +180,35 @@ func (l Local) UpdateMergeTreeTable(table *db.BaseTable, update view.ReqStorageU
 	panic("implement me")
 }
 
+func (l Local) UpdateRawLogIndex(table *db.BaseTable, rawLogIndex int) error {
+	// TODO implement me
+	panic("implement me")
+}
+
+func (l Local) KafkaSample(database db.BaseDatabase, brokers, topic string, limit int) ([]string, error) {
+	// TODO implement me
+	panic("implement me")
+}
+
+func (l Local) IngestionHealth(table *db.BaseTable, since int64) (view.IngestionHealth, error) {
+	// TODO implement me
+	panic("implement me")
+}
+
+func (l Local) ListDeadLetter(table *db.BaseTable, req view.ReqStorageDeadLetterList) (view.RespStorageDeadLetterList, error) {
+	// TODO implement me
+	panic("implement me")
+}
+
+func (l Local) ReplayDeadLetter(table *db.BaseTable, req view.ReqStorageDeadLetterReplay) (uint64, error) {
+	// TODO implement me
+	panic("implement me")
+}
+
+func (l Local) ErrorPatterns(param view.ReqQuery, limit int) ([]view.ErrorPattern, error) {
+	return nil, errors.New("error patterns are not supported by local storages")
+}
+
 func (l Local) GetLogs(query view.ReqQuery, i int) (resp view.RespQuery, err error) {
 	data := search.Request{
 		StartTime: query.ST,
@@ -279,6 +309,11 @@ func (l Local) GetAlertViewSQL(alarm *db.Alarm, table db.BaseTable, i int, item
 	panic("implement me")
 }
 
+func (l Local) GetAlertSamples(table *db.BaseTable, filter *db.AlarmFilter, conditions []*db.AlarmCondition, st, et int64) ([]float64, error) {
+	// TODO implement me
+	panic("implement me")
+}
+
 func (l Local) GetTraceGraph(ctx context.Context) ([]view.RespJaegerDependencyDataModel, error) {
 	// TODO implement me
 	panic("implement me")
@@ -35,9 +35,17 @@ var models = []interface{}{
 	db.BaseShortURL{},
 	db.BaseDatabase{},
 	db.BaseHiddenField{},
+	db.BaseIngestion{},
+	db.BaseWatchdog{},
 
 	db.Alarm{},
 	db.AlarmCondition{},
+	db.AlarmSilence{},
+	db.AlarmOncall{},
+	db.AlarmEscalation{},
+	db.AlarmChart{},
+	db.AlarmComposite{},
+	db.AlarmDelivery{},
 	db.AlarmChannel{},
 
 	db.User{},
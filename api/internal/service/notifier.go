@@ -0,0 +1,363 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+	"go.uber.org/multierr"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/service/alarm/pusher"
+)
+
+const (
+	notifierTick = time.Second
+	// notifierDefaultGroupBy notifications of the same alarm are grouped when the channel sets no labels
+	notifierDefaultGroupBy = "alarmId"
+)
+
+// notifier groups, deduplicates and rate limits the alarm notifications of each channel.
+// The state is in redis in multi-copy mode, so that the limits are the same whichever copy receives the webhook
+// and the groups outlive the restart of a copy.
+type notifier struct {
+	state  notifyState
+	ticker workerTicker
+
+	// send pushes the message or queues it for retry, the deliverer updates the histories of a queued message
+	send          func(channel *db.AlarmChannel, msg *db.PushMsg, historyIds []int) (queued bool, err error)
+	updateHistory func(historyId int, isPushed int)
+}
+
+// notifyItem notification of one alarm filter
+type notifyItem struct {
+	alertKey  string
+	status    int
+	historyId int
+	msg       *db.PushMsg
+	msgWithAt *db.PushMsg
+	// template of the alarm, the one of the channel is used when empty
+	template string
+	data     func() *pusher.TemplateData
+}
+
+// notifyQueued item waiting in a group, its message is rendered for the channel when it is queued
+type notifyQueued struct {
+	AlertKey  string      `json:"alertKey"`
+	Status    int         `json:"status"`
+	HistoryId int         `json:"historyId"`
+	Msg       *db.PushMsg `json:"msg"`
+	Image     []byte      `json:"image,omitempty"` // image of the message, which is not part of its json
+}
+
+type notifyGroup struct {
+	Channel   db.AlarmChannel `json:"channel"`
+	Items     []*notifyQueued `json:"items"`
+	FlushAt   time.Time       `json:"flushAt"`
+	LastFlush time.Time       `json:"lastFlush"`
+}
+
+// notifyBatch items of a due group, taken out of the state so that they are sent without holding its lock
+type notifyBatch struct {
+	channel db.AlarmChannel
+	items   []*notifyQueued
+}
+
+func NewNotifier() *notifier {
+	n := &notifier{
+		state: newMemoryNotifyState(),
+		send: func(channel *db.AlarmChannel, msg *db.PushMsg, historyIds []int) (bool, error) {
+			return Deliverer.Send(channel, msg, historyIds)
+		},
+		updateHistory: func(historyId int, isPushed int) {
+			if historyId == 0 {
+				return
+			}
+			core.LoggerError("notifier", "updateHistory", db.AlarmHistoryUpdate(invoker.Db, historyId, map[string]interface{}{"is_pushed": isPushed}))
+		},
+	}
+	if econf.GetBool("app.isMultiCopy") {
+		n.state = &redisNotifyState{redis: invoker.Redis}
+	}
+	return n
+}
+
+// recoverGroups fails the histories left grouped by the previous run of a single copy, whose groups were in its memory
+func (n *notifier) recoverGroups() error {
+	if _, ok := n.state.(*memoryNotifyState); !ok {
+		return nil
+	}
+	return db.AlarmHistoryUpdatePushed(invoker.Db, db.PushedStatusGrouped, db.PushedStatusFail)
+}
+
+func (n *notifier) tickerCheck() {
+	n.ticker.start("notifier", notifierTick, nil, func() error { return n.flush(time.Now()) })
+}
+
+func (n *notifier) stop() {
+	n.ticker.stop()
+	for _, item := range n.state.drain() {
+		n.updateHistory(item.HistoryId, db.PushedStatusFail)
+	}
+}
+
+// Notify sends the item at once to the channels without grouping, the others receive it on flush
+func (n *notifier) Notify(channelIds []int, labels map[string]string, item *notifyItem) error {
+	now := time.Now()
+	isSent, isQueued := false, false
+	for _, channelId := range channelIds {
+		channel, err := db.AlarmChannelInfo(invoker.Db, channelId)
+		if err != nil {
+			n.updateHistory(item.historyId, db.PushedStatusFail)
+			return err
+		}
+		if !channel.IsGrouped() {
+			queued, errSend := n.send(&channel, notifyMsg(&channel, item), notifyHistoryIds([]*notifyQueued{{HistoryId: item.historyId}}))
+			if errSend != nil {
+				n.updateHistory(item.historyId, db.PushedStatusFail)
+				return errSend
+			}
+			if queued {
+				n.updateHistory(item.historyId, db.PushedStatusPending)
+				isQueued = true
+			} else {
+				isSent = true
+			}
+			continue
+		}
+		queued, err := n.enqueue(&channel, labels, item, now)
+		if err != nil {
+			n.updateHistory(item.historyId, db.PushedStatusFail)
+			return err
+		}
+		if queued {
+			isQueued = true
+		}
+	}
+	switch {
+	case isQueued:
+		// updated on flush or by the deliverer
+	case isSent || len(channelIds) == 0:
+		n.updateHistory(item.historyId, db.PushedStatusSuccess)
+	default:
+		n.updateHistory(item.historyId, db.PushedStatusRepeat)
+	}
+	return nil
+}
+
+// enqueue adds the item to its group, false when the same status was pushed within the repeat interval
+func (n *notifier) enqueue(channel *db.AlarmChannel, labels map[string]string, item *notifyItem, now time.Time) (bool, error) {
+	groupKey := fmt.Sprintf("%d|%s", channel.ID, notifyGroupKey(channel.GroupBy, labels))
+	queued := &notifyQueued{AlertKey: item.alertKey, Status: item.status, HistoryId: item.historyId, Msg: notifyMsg(channel, item)}
+	ok, replaced, err := n.state.enqueue(channel, groupKey, queued, now)
+	if err != nil || !ok {
+		return false, err
+	}
+	// only the latest status of an alert is pushed
+	if replaced != nil {
+		n.updateHistory(replaced.HistoryId, db.PushedStatusRepeat)
+	}
+	n.updateHistory(item.historyId, db.PushedStatusGrouped)
+	return true, nil
+}
+
+// flush pushes the due groups whose channel is under its rate limit
+func (n *notifier) flush(now time.Time) (err error) {
+	batches, err := n.state.due(now)
+	for _, b := range batches {
+		isPushed := make([]int, len(b.items))
+		msgs := notifyMessages(&b.channel, b.items)
+		for k, msg := range msgs {
+			// incident channels receive a message per item
+			items := b.items
+			if len(msgs) > 1 {
+				items = b.items[k : k+1]
+			}
+			status := db.PushedStatusSuccess
+			queued, errSend := n.send(&b.channel, msg, notifyHistoryIds(items))
+			switch {
+			case errSend != nil:
+				elog.Error("notifier", elog.FieldErr(errSend), elog.Int("channelId", b.channel.ID))
+				err = multierr.Append(err, errSend)
+				status = db.PushedStatusFail
+			case queued:
+				status = db.PushedStatusPending
+			}
+			for i := range b.items {
+				if len(msgs) == 1 || i == k {
+					isPushed[i] = status
+				}
+			}
+		}
+		for i, item := range b.items {
+			// the queued ones are pending until the deliverer is done with them
+			n.updateHistory(item.HistoryId, isPushed[i])
+		}
+		err = multierr.Append(err, n.state.markSent(&b.channel, b.items, isPushed, now))
+	}
+	return err
+}
+
+func notifySentKey(channel *db.AlarmChannel, alertKey string) string {
+	return fmt.Sprintf("%d|%s", channel.ID, alertKey)
+}
+
+func newNotifyGroup(channel *db.AlarmChannel, now time.Time) *notifyGroup {
+	return &notifyGroup{FlushAt: now.Add(time.Duration(channel.GroupWait) * time.Second)}
+}
+
+// add adds the item to the group and returns the queued item of the same alert it replaced
+func (g *notifyGroup) add(channel *db.AlarmChannel, item *notifyQueued, now time.Time) *notifyQueued {
+	g.Channel = *channel
+	if len(g.Items) == 0 && !g.LastFlush.IsZero() {
+		g.FlushAt = g.LastFlush.Add(time.Duration(channel.GroupInterval) * time.Second)
+	}
+	for k, cur := range g.Items {
+		if cur.AlertKey == item.AlertKey {
+			g.Items[k] = item
+			return cur
+		}
+	}
+	g.Items = append(g.Items, item)
+	return nil
+}
+
+// isIdle the group is forgotten once it stayed empty for its group interval
+func (g *notifyGroup) isIdle(now time.Time) bool {
+	return len(g.Items) == 0 && now.Sub(g.LastFlush) >= time.Duration(g.Channel.GroupInterval)*time.Second
+}
+
+func (g *notifyGroup) isDue(now time.Time) bool {
+	return len(g.Items) > 0 && !now.Before(g.FlushAt)
+}
+
+// take empties the group into a batch
+func (g *notifyGroup) take(now time.Time) notifyBatch {
+	res := notifyBatch{channel: g.Channel, items: g.Items}
+	g.Items = nil
+	g.LastFlush = now
+	return res
+}
+
+// notifyRateLimit drops the pushes older than a minute, and returns when the next message may be sent
+// if the channel reached its limit
+func notifyRateLimit(pushes []time.Time, limit int, now time.Time) ([]time.Time, time.Time, bool) {
+	for len(pushes) > 0 && now.Sub(pushes[0]) >= time.Minute {
+		pushes = pushes[1:]
+	}
+	if limit <= 0 || len(pushes) < limit {
+		return pushes, now, false
+	}
+	return pushes, pushes[len(pushes)-limit].Add(time.Minute), true
+}
+
+// notifyHistoryIds histories of the items, the deliverer updates them once a queued message is delivered
+func notifyHistoryIds(items []*notifyQueued) []int {
+	res := make([]int, 0, len(items))
+	for _, item := range items {
+		if item.HistoryId != 0 {
+			res = append(res, item.HistoryId)
+		}
+	}
+	return res
+}
+
+// notifyGroupKey values of the group by labels, sorted by label name
+func notifyGroupKey(groupBy []string, labels map[string]string) string {
+	keys := make([]string, 0, len(groupBy))
+	keys = append(keys, groupBy...)
+	if len(keys) == 0 {
+		keys = append(keys, notifierDefaultGroupBy)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+labels[k])
+	}
+	return strings.Join(parts, ",")
+}
+
+// notifyMessages incident channels receive each alert with its dedup key, the others one message for the group
+func notifyMessages(channel *db.AlarmChannel, items []*notifyQueued) []*db.PushMsg {
+	if channel.Typ != db.ChannelPagerDuty {
+		return []*db.PushMsg{notifyMerge(items)}
+	}
+	res := make([]*db.PushMsg, 0, len(items))
+	for _, item := range items {
+		res = append(res, item.Msg)
+	}
+	return res
+}
+
+// notifyMerge one message for the items of a group
+func notifyMerge(items []*notifyQueued) *db.PushMsg {
+	if len(items) == 1 {
+		return items[0].Msg
+	}
+	res := &db.PushMsg{}
+	texts := make([]string, 0, len(items))
+	mobiles := make(map[string]struct{})
+	for _, item := range items {
+		msg := item.Msg
+		texts = append(texts, msg.Text)
+		// one image per message, the chart of the first alert having one
+		if len(res.Image) == 0 && res.ImageURL == "" {
+			res.Image, res.ImageURL = msg.Image, msg.ImageURL
+		}
+		for _, m := range msg.Mobiles {
+			if _, ok := mobiles[m]; ok {
+				continue
+			}
+			mobiles[m] = struct{}{}
+			res.Mobiles = append(res.Mobiles, m)
+		}
+	}
+	res.Title = pusher.GroupedTitle(items[0].Msg.Title, len(items))
+	res.Text = strings.Join(texts, "\n\n---\n\n")
+	return res
+}
+
+// notifyMsg the built-in message, or the one rendered with the template of the alarm or the channel
+func notifyMsg(channel *db.AlarmChannel, item *notifyItem) *db.PushMsg {
+	msg := item.msg
+	if channel.Typ == db.ChannelDingDing && item.msgWithAt != nil {
+		msg = item.msgWithAt
+	}
+	tpl := item.template
+	if tpl == "" {
+		tpl = channel.Template
+	}
+	if tpl == "" || item.data == nil {
+		return msg
+	}
+	res, err := pusher.RenderTemplate(tpl, item.data(), msg.Title)
+	if err != nil {
+		elog.Error("notifyMsg", elog.FieldErr(err), elog.Int("channelId", channel.ID))
+		return msg
+	}
+	res.Mobiles, res.DedupKey, res.Status = msg.Mobiles, msg.DedupKey, msg.Status
+	res.Image, res.ImageURL = msg.Image, msg.ImageURL
+	res.AlarmId, res.LogsURL = msg.AlarmId, msg.LogsURL
+	return res
+}
+
+// notifyLabels labels of the notification used for grouping, the alarm tags with the alarm and table ids
+func notifyLabels(alarm *db.Alarm, filter *db.AlarmFilter, table *db.BaseTable) map[string]string {
+	labels := make(map[string]string, len(alarm.Tags)+6)
+	for k, v := range alarm.Tags {
+		labels[k] = v
+	}
+	labels["alarmId"] = strconv.Itoa(alarm.ID)
+	labels["alarmName"] = alarm.Name
+	labels["filterId"] = strconv.Itoa(filter.ID)
+	labels["tid"] = strconv.Itoa(table.ID)
+	labels["table"] = table.Name
+	labels["iid"] = strconv.Itoa(table.Database.Iid)
+	return labels
+}
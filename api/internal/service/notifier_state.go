@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ego-component/eredis"
+	"github.com/pkg/errors"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+const (
+	redisNotifyLock   = "clickvisual:notifier:lock"
+	redisNotifyGroups = "clickvisual:notifier:groups"
+	// notifierLockTTL the lock is only held to read and write the state, never while sending
+	notifierLockTTL = 10 * time.Second
+)
+
+// notifyState groups, sent alerts and pushes of the channels, in redis when the copies share them
+type notifyState interface {
+	// enqueue adds the item to its group, false when the same status of the alert was pushed within the repeat interval.
+	// The queued item of the same alert it replaced is returned.
+	enqueue(channel *db.AlarmChannel, groupKey string, item *notifyQueued, now time.Time) (bool, *notifyQueued, error)
+	// due takes the items of the due groups and counts their push against the rate limit of the channel
+	due(now time.Time) ([]notifyBatch, error)
+	// markSent drops the repeats of the pushed items until the repeat interval of the channel expires
+	markSent(channel *db.AlarmChannel, items []*notifyQueued, isPushed []int, now time.Time) error
+	// drain takes the items the copy can not send anymore once it stops
+	drain() []*notifyQueued
+}
+
+// notifySent last status pushed for an alert, repeats are dropped until expire
+type notifySent struct {
+	status int
+	expire time.Time
+}
+
+type memoryNotifyState struct {
+	mu     sync.Mutex
+	groups map[string]*notifyGroup
+	sent   map[string]notifySent
+	pushes map[int][]time.Time
+}
+
+func newMemoryNotifyState() *memoryNotifyState {
+	return &memoryNotifyState{
+		groups: make(map[string]*notifyGroup),
+		sent:   make(map[string]notifySent),
+		pushes: make(map[int][]time.Time),
+	}
+}
+
+func (s *memoryNotifyState) enqueue(channel *db.AlarmChannel, groupKey string, item *notifyQueued, now time.Time) (bool, *notifyQueued, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sent, ok := s.sent[notifySentKey(channel, item.AlertKey)]; ok && sent.status == item.Status && now.Before(sent.expire) {
+		return false, nil, nil
+	}
+	g, ok := s.groups[groupKey]
+	if !ok {
+		g = newNotifyGroup(channel, now)
+		s.groups[groupKey] = g
+	}
+	return true, g.add(channel, item, now), nil
+}
+
+func (s *memoryNotifyState) due(now time.Time) ([]notifyBatch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, sent := range s.sent {
+		if !now.Before(sent.expire) {
+			delete(s.sent, key)
+		}
+	}
+	res := make([]notifyBatch, 0)
+	for key, g := range s.groups {
+		if g.isIdle(now) {
+			delete(s.groups, key)
+			continue
+		}
+		if !g.isDue(now) {
+			continue
+		}
+		pushes, next, limited := notifyRateLimit(s.pushes[g.Channel.ID], g.Channel.RateLimit, now)
+		s.pushes[g.Channel.ID] = pushes
+		if limited {
+			g.FlushAt = next
+			continue
+		}
+		s.pushes[g.Channel.ID] = append(pushes, now)
+		res = append(res, g.take(now))
+	}
+	return res, nil
+}
+
+func (s *memoryNotifyState) markSent(channel *db.AlarmChannel, items []*notifyQueued, isPushed []int, now time.Time) error {
+	if channel.RepeatInterval <= 0 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, item := range items {
+		if isPushed[i] == db.PushedStatusFail {
+			continue
+		}
+		s.sent[notifySentKey(channel, item.AlertKey)] = notifySent{
+			status: item.Status,
+			expire: now.Add(time.Duration(channel.RepeatInterval) * time.Second),
+		}
+	}
+	return nil
+}
+
+func (s *memoryNotifyState) drain() []*notifyQueued {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]*notifyQueued, 0)
+	for _, g := range s.groups {
+		res = append(res, g.Items...)
+		g.Items = nil
+	}
+	return res
+}
+
+// redisNotifyState the groups are a redis hash and the pushes of each channel a key expiring after a minute,
+// they are read and written under a lock shared by the copies
+type redisNotifyState struct {
+	redis *eredis.Component
+}
+
+func redisNotifySentKey(sentKey string) string {
+	return "clickvisual:notifier:sent:" + sentKey
+}
+
+func redisNotifyPushesKey(channelId int) string {
+	return "clickvisual:notifier:pushes:" + strconv.Itoa(channelId)
+}
+
+func (s *redisNotifyState) lock(ctx context.Context) (func(), error) {
+	l, err := s.redis.LockClient().Obtain(ctx, redisNotifyLock, notifierLockTTL,
+		eredis.WithLockOptionRetryStrategy(eredis.LinearBackoffRetry(50*time.Millisecond)))
+	if err != nil {
+		return nil, errors.Wrap(err, "notifier lock")
+	}
+	return func() {
+		core.LoggerError("notifier", "unlock", l.Release(ctx))
+	}, nil
+}
+
+func (s *redisNotifyState) enqueue(channel *db.AlarmChannel, groupKey string, item *notifyQueued, now time.Time) (bool, *notifyQueued, error) {
+	ctx := context.Background()
+	unlock, err := s.lock(ctx)
+	if err != nil {
+		return false, nil, err
+	}
+	defer unlock()
+	status, err := s.redis.Get(ctx, redisNotifySentKey(notifySentKey(channel, item.AlertKey)))
+	if err == nil && status == strconv.Itoa(item.Status) {
+		return false, nil, nil
+	}
+	if err != nil && !errors.Is(err, eredis.Nil) {
+		return false, nil, err
+	}
+	g := newNotifyGroup(channel, now)
+	raw, err := s.redis.HGet(ctx, redisNotifyGroups, groupKey)
+	if err != nil && !errors.Is(err, eredis.Nil) {
+		return false, nil, err
+	}
+	if err == nil {
+		if err = unmarshalNotifyGroup(raw, g); err != nil {
+			return false, nil, err
+		}
+	}
+	replaced := g.add(channel, item, now)
+	return true, replaced, s.saveGroup(ctx, groupKey, g)
+}
+
+func (s *redisNotifyState) due(now time.Time) ([]notifyBatch, error) {
+	ctx := context.Background()
+	unlock, err := s.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	groups, err := s.redis.HGetAll(ctx, redisNotifyGroups)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]notifyBatch, 0)
+	for key, raw := range groups {
+		g := &notifyGroup{}
+		if err = unmarshalNotifyGroup(raw, g); err != nil {
+			return res, err
+		}
+		if g.isIdle(now) {
+			if err = s.redis.HDel(ctx, redisNotifyGroups, key); err != nil {
+				return res, err
+			}
+			continue
+		}
+		if !g.isDue(now) {
+			continue
+		}
+		pushes, next, limited, err := s.rateLimit(ctx, &g.Channel, now)
+		if err != nil {
+			return res, err
+		}
+		if limited {
+			g.FlushAt = next
+		} else {
+			batch := g.take(now)
+			// the secrets of the channel are not stored with the group
+			if channel, errInfo := db.AlarmChannelInfo(invoker.Db, g.Channel.ID); errInfo == nil {
+				batch.channel = channel
+			}
+			res = append(res, batch)
+			if err = s.savePushes(ctx, g.Channel.ID, append(pushes, now)); err != nil {
+				return res, err
+			}
+		}
+		if err = s.saveGroup(ctx, key, g); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+func (s *redisNotifyState) rateLimit(ctx context.Context, channel *db.AlarmChannel, now time.Time) ([]time.Time, time.Time, bool, error) {
+	pushes := make([]time.Time, 0)
+	raw, err := s.redis.GetBytes(ctx, redisNotifyPushesKey(channel.ID))
+	if err != nil && !errors.Is(err, eredis.Nil) {
+		return nil, now, false, err
+	}
+	if err == nil {
+		if err = json.Unmarshal(raw, &pushes); err != nil {
+			return nil, now, false, errors.Wrap(err, "notifier pushes")
+		}
+	}
+	pushes, next, limited := notifyRateLimit(pushes, channel.RateLimit, now)
+	return pushes, next, limited, nil
+}
+
+func (s *redisNotifyState) savePushes(ctx context.Context, channelId int, pushes []time.Time) error {
+	raw, err := json.Marshal(pushes)
+	if err != nil {
+		return err
+	}
+	return s.redis.Set(ctx, redisNotifyPushesKey(channelId), raw, time.Minute)
+}
+
+func (s *redisNotifyState) markSent(channel *db.AlarmChannel, items []*notifyQueued, isPushed []int, now time.Time) error {
+	if channel.RepeatInterval <= 0 {
+		return nil
+	}
+	ctx := context.Background()
+	for i, item := range items {
+		if isPushed[i] == db.PushedStatusFail {
+			continue
+		}
+		// the key expires with the repeat interval, the status is compared by the next enqueue of the alert
+		if err := s.redis.Set(ctx, redisNotifySentKey(notifySentKey(channel, item.AlertKey)), strconv.Itoa(item.Status),
+			time.Duration(channel.RepeatInterval)*time.Second); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// drain nothing, the groups are sent by the other copies or after the restart
+func (s *redisNotifyState) drain() []*notifyQueued {
+	return nil
+}
+
+func (s *redisNotifyState) saveGroup(ctx context.Context, groupKey string, g *notifyGroup) error {
+	raw, err := marshalNotifyGroup(g)
+	if err != nil {
+		return err
+	}
+	return s.redis.HSet(ctx, redisNotifyGroups, groupKey, raw)
+}
+
+// marshalNotifyGroup json of the group with the images of its messages
+func marshalNotifyGroup(g *notifyGroup) ([]byte, error) {
+	for _, item := range g.Items {
+		item.Image = item.Msg.Image
+	}
+	return json.Marshal(g)
+}
+
+func unmarshalNotifyGroup(raw string, g *notifyGroup) error {
+	if err := json.Unmarshal([]byte(raw), g); err != nil {
+		return errors.Wrap(err, "notifier group")
+	}
+	for _, item := range g.Items {
+		item.Msg.Image = item.Image
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+func testEnqueue(t *testing.T, n *notifier, channel *db.AlarmChannel, labels map[string]string, item *notifyItem, now time.Time) bool {
+	ok, err := n.enqueue(channel, labels, item, now)
+	assert.NoError(t, err)
+	return ok
+}
+
+func newTestNotifier() (*notifier, *[]*db.PushMsg, map[int]int) {
+	n := NewNotifier()
+	sent := make([]*db.PushMsg, 0)
+	histories := make(map[int]int)
+	n.send = func(channel *db.AlarmChannel, msg *db.PushMsg, historyIds []int) (bool, error) {
+		sent = append(sent, msg)
+		return false, nil
+	}
+	n.updateHistory = func(historyId int, isPushed int) {
+		histories[historyId] = isPushed
+	}
+	return n, &sent, histories
+}
+
+func testNotifyItem(historyId int, alertKey string, status int) *notifyItem {
+	return &notifyItem{
+		alertKey:  alertKey,
+		status:    status,
+		historyId: historyId,
+		msg:       &db.PushMsg{Title: alertKey, Text: alertKey, Mobiles: []string{"1"}},
+	}
+}
+
+func Test_notifyGroupKey(t *testing.T) {
+	labels := map[string]string{"alarmId": "1", "env": "prod", "app": "svc"}
+	assert.Equal(t, "alarmId=1", notifyGroupKey(nil, labels))
+	assert.Equal(t, "app=svc,env=prod", notifyGroupKey([]string{"env", "app"}, labels))
+	assert.Equal(t, "zone=", notifyGroupKey([]string{"zone"}, labels))
+}
+
+func Test_notifierGroup(t *testing.T) {
+	n, sent, histories := newTestNotifier()
+	channel := &db.AlarmChannel{GroupBy: []string{"env"}, GroupWait: 30, GroupInterval: 60}
+	channel.ID = 1
+	labels := map[string]string{"env": "prod"}
+	now := time.Unix(1000, 0)
+
+	assert.True(t, testEnqueue(t, n, channel, labels, testNotifyItem(1, "a", db.AlarmStatusFiring), now))
+	assert.True(t, testEnqueue(t, n, channel, labels, testNotifyItem(2, "b", db.AlarmStatusFiring), now.Add(time.Second)))
+	// the latest status of a replaces the first one
+	assert.True(t, testEnqueue(t, n, channel, labels, testNotifyItem(3, "a", db.AlarmStatusNormal), now.Add(2*time.Second)))
+	assert.Equal(t, db.PushedStatusRepeat, histories[1])
+	assert.Equal(t, db.PushedStatusGrouped, histories[3])
+
+	assert.NoError(t, n.flush(now.Add(29*time.Second)))
+	assert.Len(t, *sent, 0)
+	assert.NoError(t, n.flush(now.Add(30*time.Second)))
+	assert.Len(t, *sent, 1)
+	assert.Equal(t, "a 等2条告警", (*sent)[0].Title)
+	assert.Equal(t, []string{"1"}, (*sent)[0].Mobiles)
+	assert.Equal(t, db.PushedStatusSuccess, histories[2])
+	assert.Equal(t, db.PushedStatusSuccess, histories[3])
+
+	// group interval after the first message
+	assert.True(t, testEnqueue(t, n, channel, labels, testNotifyItem(4, "c", db.AlarmStatusFiring), now.Add(40*time.Second)))
+	assert.NoError(t, n.flush(now.Add(80*time.Second)))
+	assert.Len(t, *sent, 1)
+	assert.NoError(t, n.flush(now.Add(90*time.Second)))
+	assert.Len(t, *sent, 2)
+	assert.Equal(t, "c", (*sent)[1].Title)
+}
+
+func Test_notifierRepeat(t *testing.T) {
+	n, sent, _ := newTestNotifier()
+	channel := &db.AlarmChannel{RepeatInterval: 600}
+	channel.ID = 1
+	labels := map[string]string{"alarmId": "1"}
+	now := time.Unix(1000, 0)
+
+	assert.True(t, testEnqueue(t, n, channel, labels, testNotifyItem(1, "a", db.AlarmStatusFiring), now))
+	assert.NoError(t, n.flush(now))
+	assert.Len(t, *sent, 1)
+	assert.False(t, testEnqueue(t, n, channel, labels, testNotifyItem(2, "a", db.AlarmStatusFiring), now.Add(time.Minute)))
+	assert.True(t, testEnqueue(t, n, channel, labels, testNotifyItem(3, "a", db.AlarmStatusNormal), now.Add(time.Minute)))
+	assert.NoError(t, n.flush(now.Add(time.Minute)))
+	assert.Len(t, *sent, 2)
+	assert.True(t, testEnqueue(t, n, channel, labels, testNotifyItem(4, "a", db.AlarmStatusNormal), now.Add(12*time.Minute)))
+}
+
+func Test_notifierRateLimit(t *testing.T) {
+	n, sent, histories := newTestNotifier()
+	channel := &db.AlarmChannel{GroupBy: []string{"filterId"}, RateLimit: 2}
+	channel.ID = 1
+	now := time.Unix(1000, 0)
+
+	for k, filterId := range []string{"1", "2", "3"} {
+		testEnqueue(t, n, channel, map[string]string{"filterId": filterId}, testNotifyItem(k+1, filterId, db.AlarmStatusFiring), now)
+	}
+	assert.NoError(t, n.flush(now))
+	assert.Len(t, *sent, 2)
+	assert.NoError(t, n.flush(now.Add(59*time.Second)))
+	assert.Len(t, *sent, 2)
+	assert.NoError(t, n.flush(now.Add(time.Minute)))
+	assert.Len(t, *sent, 3)
+	for historyId := 1; historyId <= 3; historyId++ {
+		assert.Equal(t, db.PushedStatusSuccess, histories[historyId])
+	}
+}
+
+func Test_notifierQueued(t *testing.T) {
+	n, _, histories := newTestNotifier()
+	queuedIds := make([][]int, 0)
+	// the first alert is delivered, the push of the second one fails and is queued
+	n.send = func(channel *db.AlarmChannel, msg *db.PushMsg, historyIds []int) (bool, error) {
+		if msg.Title == "b" {
+			queuedIds = append(queuedIds, historyIds)
+			return true, nil
+		}
+		return false, nil
+	}
+	channel := &db.AlarmChannel{Typ: db.ChannelPagerDuty, GroupBy: []string{"env"}}
+	channel.ID = 1
+	labels := map[string]string{"env": "prod"}
+	now := time.Unix(1000, 0)
+
+	testEnqueue(t, n, channel, labels, testNotifyItem(1, "a", db.AlarmStatusFiring), now)
+	testEnqueue(t, n, channel, labels, testNotifyItem(2, "b", db.AlarmStatusFiring), now)
+	assert.NoError(t, n.flush(now))
+	assert.Equal(t, [][]int{{2}}, queuedIds)
+	assert.Equal(t, db.PushedStatusSuccess, histories[1])
+	assert.Equal(t, db.PushedStatusPending, histories[2])
+}
+
+func Test_notifierFlushUnlocked(t *testing.T) {
+	n, _, histories := newTestNotifier()
+	channel := &db.AlarmChannel{GroupBy: []string{"env"}}
+	channel.ID = 1
+	labels := map[string]string{"env": "prod"}
+	now := time.Unix(1000, 0)
+	// alerts arriving while a slow push is in flight are queued instead of waiting for it
+	n.send = func(channel *db.AlarmChannel, msg *db.PushMsg, historyIds []int) (bool, error) {
+		if msg.Title == "a" {
+			assert.True(t, testEnqueue(t, n, channel, labels, testNotifyItem(2, "b", db.AlarmStatusFiring), now))
+		}
+		return false, nil
+	}
+	testEnqueue(t, n, channel, labels, testNotifyItem(1, "a", db.AlarmStatusFiring), now)
+	assert.NoError(t, n.flush(now))
+	assert.Equal(t, db.PushedStatusSuccess, histories[1])
+	assert.Equal(t, db.PushedStatusGrouped, histories[2])
+}
+
+func Test_notifierStop(t *testing.T) {
+	n, sent, histories := newTestNotifier()
+	channel := &db.AlarmChannel{GroupWait: 30}
+	channel.ID = 1
+	assert.True(t, testEnqueue(t, n, channel, map[string]string{"alarmId": "1"}, testNotifyItem(1, "a", db.AlarmStatusFiring), time.Unix(1000, 0)))
+	n.stop()
+	assert.Equal(t, db.PushedStatusFail, histories[1])
+	assert.NoError(t, n.flush(time.Unix(2000, 0)))
+	assert.Len(t, *sent, 0)
+}
+
+func Test_notifyRateLimit(t *testing.T) {
+	now := time.Unix(1000, 0)
+	pushes := []time.Time{now.Add(-time.Minute), now.Add(-30 * time.Second), now.Add(-10 * time.Second)}
+	kept, _, limited := notifyRateLimit(pushes, 3, now)
+	assert.False(t, limited)
+	assert.Len(t, kept, 2)
+	kept, next, limited := notifyRateLimit(pushes, 2, now)
+	assert.True(t, limited)
+	assert.Equal(t, now.Add(30*time.Second), next)
+	assert.Len(t, kept, 2)
+	_, _, limited = notifyRateLimit(kept, 0, now)
+	assert.False(t, limited)
+}
+
+func Test_marshalNotifyGroup(t *testing.T) {
+	channel := &db.AlarmChannel{GroupInterval: 60}
+	channel.ID = 1
+	g := newNotifyGroup(channel, time.Unix(1000, 0))
+	g.add(channel, &notifyQueued{AlertKey: "a", HistoryId: 1, Msg: &db.PushMsg{Title: "a", Image: []byte("png")}}, time.Unix(1000, 0))
+	raw, err := marshalNotifyGroup(g)
+	assert.NoError(t, err)
+	res := &notifyGroup{}
+	assert.NoError(t, unmarshalNotifyGroup(string(raw), res))
+	assert.Equal(t, g.FlushAt.Unix(), res.FlushAt.Unix())
+	assert.Equal(t, 60, res.Channel.GroupInterval)
+	assert.Equal(t, []byte("png"), res.Items[0].Msg.Image)
+	assert.Equal(t, "a", res.Items[0].AlertKey)
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+)
+
+// OncallValidate checks the rotation and the overrides of an on-call schedule
+func OncallValidate(req view.ReqAlarmOncallCreate) error {
+	if req.Rotation != db.OncallRotationDaily && req.Rotation != db.OncallRotationWeekly {
+		return errors.New("rotation must be daily or weekly")
+	}
+	if len(req.Users) == 0 {
+		return errors.New("users are required")
+	}
+	for _, o := range req.Overrides {
+		if o.Uid == 0 || o.EndTime <= o.StartTime {
+			return errors.New("override requires a user and an end time after its start time")
+		}
+	}
+	return nil
+}
+
+// EscalationValidate steps are notified in order, so their delays must increase
+func EscalationValidate(req view.ReqAlarmEscalationCreate) error {
+	if len(req.Steps) == 0 {
+		return errors.New("steps are required")
+	}
+	for k, step := range req.Steps {
+		if step.OncallId == 0 && len(step.Uids) == 0 && len(step.ChannelIds) == 0 {
+			return errors.Errorf("step %d notifies nobody", k+1)
+		}
+		if step.Delay < 0 || (k > 0 && step.Delay <= req.Steps[k-1].Delay) {
+			return errors.Errorf("delay of step %d must be greater than the previous one", k+1)
+		}
+		if step.OncallId != 0 {
+			if _, err := db.AlarmOncallInfo(invoker.Db, step.OncallId); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
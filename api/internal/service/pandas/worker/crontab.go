@@ -16,7 +16,7 @@ import (
 	"github.com/clickvisual/clickvisual/api/internal/invoker"
 	db2 "github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
 	"github.com/clickvisual/clickvisual/api/internal/pkg/preempt"
-	"github.com/clickvisual/clickvisual/api/internal/service/alarm/pusher"
+	"github.com/clickvisual/clickvisual/api/internal/service"
 	"github.com/clickvisual/clickvisual/api/internal/service/pandas/node"
 )
 
@@ -187,5 +187,8 @@ func pushExec(channelIds []int, text string, iid int) {
 			text, strings.TrimRight(econf.GetString("app.rootURL"), "/"), iid,
 		),
 	}
-	_ = pusher.Execute(channelIds, &msg, &msg)
+	_, err := service.Deliverer.Execute(channelIds, &msg, &msg, nil)
+	if err != nil {
+		elog.Error("crontabRules", elog.String("step", "pushExec"), elog.Any("channelIds", channelIds), elog.FieldErr(err))
+	}
 }
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/service/alarm/alertcomponent"
+)
+
+const (
+	// ruleHealthPrefix prefix of the alert name of the prometheus rules of clickvisual
+	ruleHealthPrefix = "ClickVisual-"
+	// ruleHealthTTL the rules of an instance are pulled once in so long, a prometheus that is down is not waited for on each alarm info
+	ruleHealthTTL = 30 * time.Second
+)
+
+var ruleHealths = &ruleHealthCache{entries: make(map[int]ruleHealthEntry)}
+
+// RuleHealth evaluation status of the prometheus rules of the filters by filter id, prometheus is asked once per instance.
+// The instances are keyed by table id, the filters evaluated by the native evaluator have none.
+func (i *alert) RuleHealth(alarm *db.Alarm, filters []*db.AlarmFilter, instances map[int]db.BaseInstance) map[int]*db.AlarmRuleHealth {
+	return ruleHealth(alarm, filters, instances, func(instance db.BaseInstance) (map[string]db.AlarmRuleHealth, error) {
+		return ruleHealths.get(instance, time.Now(), pullRuleHealth)
+	})
+}
+
+func pullRuleHealth(instance db.BaseInstance) (map[string]db.AlarmRuleHealth, error) {
+	target := instance.PrometheusTarget
+	if !strings.HasPrefix(target, "http") {
+		target = "http://" + target
+	}
+	p, err := alertcomponent.NewPrometheus(target, instance.RuleStoreType)
+	if err != nil {
+		return nil, err
+	}
+	return p.RuleHealth(instance.RulerTenant)
+}
+
+// ruleHealthCache rules pulled by instance for ruleHealthTTL, the errors too
+type ruleHealthCache struct {
+	mu      sync.Mutex
+	entries map[int]ruleHealthEntry
+}
+
+type ruleHealthEntry struct {
+	target string
+	rules  map[string]db.AlarmRuleHealth
+	err    error
+	expire time.Time
+}
+
+func (c *ruleHealthCache) get(instance db.BaseInstance, now time.Time,
+	pull func(instance db.BaseInstance) (map[string]db.AlarmRuleHealth, error)) (map[string]db.AlarmRuleHealth, error) {
+	// a change of the prometheus of the instance is seen at once
+	target := instance.PrometheusTarget + "|" + instance.RulerTenant
+	c.mu.Lock()
+	entry, ok := c.entries[instance.ID]
+	c.mu.Unlock()
+	if ok && entry.target == target && now.Before(entry.expire) {
+		return entry.rules, entry.err
+	}
+	entry = ruleHealthEntry{target: target, expire: now.Add(ruleHealthTTL)}
+	entry.rules, entry.err = pull(instance)
+	c.mu.Lock()
+	c.entries[instance.ID] = entry
+	c.mu.Unlock()
+	return entry.rules, entry.err
+}
+
+func ruleHealth(alarm *db.Alarm, filters []*db.AlarmFilter, instances map[int]db.BaseInstance,
+	pull func(instance db.BaseInstance) (map[string]db.AlarmRuleHealth, error)) map[int]*db.AlarmRuleHealth {
+	type pulled struct {
+		rules map[string]db.AlarmRuleHealth
+		err   error
+	}
+	cache := make(map[int]pulled)
+	res := make(map[int]*db.AlarmRuleHealth)
+	for _, filter := range filters {
+		instance, ok := instances[filter.Tid]
+		if !ok || instance.AlertEvaluator == db.AlertEvaluatorNative || instance.PrometheusTarget == "" {
+			continue
+		}
+		cur, ok := cache[instance.ID]
+		if !ok {
+			cur.rules, cur.err = pull(instance)
+			cache[instance.ID] = cur
+		}
+		name := ruleHealthPrefix + alarm.UniqueName(filter.ID)
+		h := cur.rules[name]
+		h.Rule = name
+		if cur.err != nil {
+			h.Error = cur.err.Error()
+		}
+		res[filter.ID] = &h
+	}
+	return res
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+func Test_ruleHealth(t *testing.T) {
+	alarm := &db.Alarm{Uuid: "a-b"}
+	filters := []*db.AlarmFilter{{Tid: 1}, {Tid: 2}, {Tid: 3}, {Tid: 4}}
+	for k, filter := range filters {
+		filter.ID = k + 1
+	}
+	prometheus := db.BaseInstance{PrometheusTarget: "http://prometheus:9090"}
+	prometheus.ID = 1
+	down := db.BaseInstance{PrometheusTarget: "http://down:9090"}
+	down.ID = 2
+	native := db.BaseInstance{AlertEvaluator: db.AlertEvaluatorNative}
+	native.ID = 3
+	instances := map[int]db.BaseInstance{1: prometheus, 2: prometheus, 3: down, 4: native}
+
+	pulls := 0
+	res := ruleHealth(alarm, filters, instances, func(instance db.BaseInstance) (map[string]db.AlarmRuleHealth, error) {
+		pulls++
+		if instance.ID == down.ID {
+			return nil, errors.New("connection refused")
+		}
+		return map[string]db.AlarmRuleHealth{
+			"ClickVisual-a_b_1": {Rule: "ClickVisual-a_b_1", Loaded: true, State: "firing", Health: "ok"},
+		}, nil
+	})
+	assert.Equal(t, 2, pulls)
+	assert.Len(t, res, 3)
+	assert.Equal(t, "firing", res[1].State)
+	// not loaded by prometheus
+	assert.False(t, res[2].Loaded)
+	assert.Equal(t, "ClickVisual-a_b_2", res[2].Rule)
+	assert.Equal(t, "connection refused", res[3].Error)
+	assert.Nil(t, res[4])
+}
+
+func Test_ruleHealthCache(t *testing.T) {
+	c := &ruleHealthCache{entries: make(map[int]ruleHealthEntry)}
+	instance := db.BaseInstance{PrometheusTarget: "http://down:9090"}
+	instance.ID = 1
+	now := time.Unix(1700000000, 0)
+	pulls := 0
+	pull := func(instance db.BaseInstance) (map[string]db.AlarmRuleHealth, error) {
+		pulls++
+		return nil, errors.New("connection refused")
+	}
+
+	_, err := c.get(instance, now, pull)
+	assert.Error(t, err)
+	// the error is cached too
+	_, err = c.get(instance, now.Add(ruleHealthTTL-time.Second), pull)
+	assert.Error(t, err)
+	assert.Equal(t, 1, pulls)
+
+	_, _ = c.get(instance, now.Add(ruleHealthTTL), pull)
+	assert.Equal(t, 2, pulls)
+	instance.PrometheusTarget = "http://prometheus:9090"
+	_, _ = c.get(instance, now.Add(ruleHealthTTL), pull)
+	assert.Equal(t, 3, pulls)
+}
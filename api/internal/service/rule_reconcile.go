@@ -0,0 +1,376 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ego-component/egorm"
+	"github.com/ego-component/eredis"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/pkg/errors"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service/alarm/rule"
+	"github.com/clickvisual/clickvisual/api/internal/service/event"
+)
+
+// ruleReconcilerUser author of the events of the automatic repairs
+var ruleReconcilerUser = &core.User{Username: "clickvisual", Nickname: "clickvisual"}
+
+// ruleReconciler compares the prometheus rules of the open alarms with those of the rule stores
+type ruleReconciler struct {
+	mu     sync.RWMutex
+	drifts ruleDriftStore
+	ticker workerTicker
+}
+
+// desiredRule rule of an alarm, the rules of the group for the prometheus operator
+type desiredRule struct {
+	alarm   *db.Alarm
+	content string
+	rules   []db.ClusterRuleItem
+}
+
+func NewRuleReconciler() *ruleReconciler {
+	r := &ruleReconciler{}
+	// only the leader copy checks periodically, the others read its drifts
+	if econf.GetBool("app.isMultiCopy") {
+		r.drifts = &redisDriftStore{redis: invoker.Redis}
+	} else {
+		r.drifts = &memoryDriftStore{drifts: make(map[int]view.RuleDrift)}
+	}
+	return r
+}
+
+// Drifts latest drifts, of the instance when iid is not 0
+func (r *ruleReconciler) Drifts(iid int) []view.RuleDrift {
+	drifts, err := r.drifts.load()
+	if err != nil {
+		core.LoggerError("ruleReconciler", "drifts", err)
+	}
+	res := make([]view.RuleDrift, 0, len(drifts))
+	for _, drift := range drifts {
+		if iid == 0 || drift.InstanceId == iid {
+			res = append(res, drift)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].InstanceId < res[j].InstanceId })
+	return res
+}
+
+func (r *ruleReconciler) tickerCheck() {
+	interval := econf.GetDuration("app.alertRuleReconcileInterval")
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	r.ticker.start("ruleReconciler", interval, nil, func() error {
+		_, err := r.Reconcile(0, econf.GetBool("app.alertRuleAutoRepair"), ruleReconcilerUser)
+		return err
+	})
+}
+
+func (r *ruleReconciler) stop() {
+	r.ticker.stop()
+}
+
+// Reconcile compares the rules of the instances evaluated by prometheus, iid 0 for all of them,
+// with repair missing and changed rules are written and orphaned ones deleted, each repair is an event of the user
+func (r *ruleReconciler) Reconcile(iid int, repair bool, user *core.User) (res []view.RuleDrift, err error) {
+	conds := egorm.Conds{}
+	conds["alert_evaluator"] = db.AlertEvaluatorPrometheus
+	conds["rule_store_type"] = egorm.Cond{Op: "!=", Val: 0}
+	if iid != 0 {
+		conds["id"] = iid
+	}
+	instances, err := db.InstanceList(conds)
+	if err != nil {
+		return nil, err
+	}
+	alarms, err := openAlarms()
+	if err != nil {
+		return nil, err
+	}
+	known := knownRules(alarms)
+	desired := make(map[int]map[string]*desiredRule)
+	for _, instance := range instances {
+		desired[instance.ID] = desiredRules(*instance, alarms)
+	}
+	reload := false
+	for _, instance := range instances {
+		drift := view.RuleDrift{InstanceId: instance.ID, InstanceName: instance.Name, RuleStoreType: instance.RuleStoreType, CheckTime: time.Now().Unix()}
+		rc, errRc := ruleComponent(*instance)
+		var actual map[string]string
+		if errRc == nil {
+			actual, errRc = rc.List()
+		}
+		if errRc != nil {
+			drift.Error = errRc.Error()
+		} else {
+			drift.Missing, drift.Changed, drift.Orphaned = ruleDiff(desired[instance.ID], actual, known)
+			if repair && len(drift.Orphaned) > 0 {
+				drift.Orphaned, err = confirmOrphans(drift.Orphaned)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if repair {
+				reload = r.repair(*instance, rc, desired[instance.ID], &drift, user) || reload
+			}
+		}
+		res = append(res, drift)
+	}
+	if reload {
+		go Alert.AddPrometheusReloadChan()
+	}
+	core.LoggerError("ruleReconciler", "saveDrifts", r.drifts.save(iid, res))
+	return res, nil
+}
+
+// openAlarms alarms whose rules are expected in the stores
+func openAlarms() ([]*db.Alarm, error) {
+	conds := egorm.Conds{}
+	conds["status"] = egorm.Cond{Op: "!=", Val: db.AlarmStatusClose}
+	return db.AlarmList(conds)
+}
+
+// knownRules rule and group names of the alarms, rules of other instances sharing the store are not orphaned
+func knownRules(alarms []*db.Alarm) map[string]struct{} {
+	known := make(map[string]struct{})
+	for _, alarm := range alarms {
+		for iidRuleName := range alarm.AlertRules {
+			arr := strings.Split(iidRuleName, "|")
+			known[arr[len(arr)-1]] = struct{}{}
+			if len(arr) == 2 {
+				ruleIid, _ := strconv.Atoi(arr[0])
+				known[alarm.GetGroupName(ruleIid)] = struct{}{}
+			}
+		}
+		known[alarm.RuleName(0)] = struct{}{}
+	}
+	return known
+}
+
+// confirmOrphans orphaned rules once the alarms are read again before they are deleted.
+// Rules written after the first read are those of alarms created or updated meanwhile, which store
+// their rules after writing them, so a rule named after the uuid of an alarm is kept.
+func confirmOrphans(orphaned []view.RuleDriftItem) ([]view.RuleDriftItem, error) {
+	alarms, err := openAlarms()
+	if err != nil {
+		return nil, err
+	}
+	known := knownRules(alarms)
+	res := make([]view.RuleDriftItem, 0, len(orphaned))
+	for _, item := range orphaned {
+		if _, ok := known[item.Name]; ok || isAlarmRule(item.Name, alarms) {
+			continue
+		}
+		res = append(res, item)
+	}
+	return res, nil
+}
+
+// isAlarmRule rules are cv-<uuid>[-<filter id>].yaml, operator groups cv-<iid>-<uuid>
+func isAlarmRule(name string, alarms []*db.Alarm) bool {
+	for _, alarm := range alarms {
+		if alarm.Uuid != "" && strings.Contains(name, alarm.Uuid) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *ruleReconciler) repair(instance db.BaseInstance, rc rule.Component, desired map[string]*desiredRule, drift *view.RuleDrift, user *core.User) (repaired bool) {
+	operator := instance.RuleStoreType == db.RuleStoreTypeK8sOperator
+	set := func(items []view.RuleDriftItem, action string) {
+		for k := range items {
+			item := &items[k]
+			var err error
+			switch {
+			case action == "delete" && operator:
+				err = rc.BatchRemove(item.Name)
+			case action == "delete":
+				err = rc.Delete("", item.Name)
+			case operator:
+				err = rc.BatchSet(item.Name, desired[item.Name].rules)
+			default:
+				err = rc.CreateOrUpdate(desired[item.Name].alarm.GetGroupName(instance.ID), item.Name, desired[item.Name].content)
+			}
+			if err != nil {
+				item.RepairError = err.Error()
+				elog.Error("ruleReconciler", elog.String("step", "repair"), elog.String("rule", item.Name), elog.FieldErr(err))
+				continue
+			}
+			item.Repaired, repaired = true, true
+			event.Event.AlarmCMDB(user, db.OpnAlarmsRuleRepair, map[string]interface{}{
+				"instanceId": instance.ID,
+				"rule":       item.Name,
+				"alarmId":    item.AlarmId,
+				"action":     action,
+			})
+		}
+	}
+	set(drift.Missing, "create")
+	set(drift.Changed, "update")
+	set(drift.Orphaned, "delete")
+	return repaired
+}
+
+func ruleComponent(instance db.BaseInstance) (rule.Component, error) {
+	return rule.GetComponent(instance.RuleStoreType, &rule.Params{
+		InstanceID:         instance.ID,
+		RulePath:           instance.FilePath,
+		ClusterId:          instance.K8sClusterId,
+		Namespace:          instance.K8sNamespace,
+		Configmap:          instance.K8sConfigmap,
+		PrometheusOperator: instance.ConfigPrometheusOperator,
+		RulerUrl:           instance.RulerUrl,
+		RulerNamespace:     instance.RulerNamespace,
+		RulerTenant:        instance.RulerTenant,
+	})
+}
+
+// desiredRules rules of the alarms on the instance by rule name, by group name for the prometheus operator
+func desiredRules(instance db.BaseInstance, alarms []*db.Alarm) map[string]*desiredRule {
+	res := make(map[string]*desiredRule)
+	for _, alarm := range alarms {
+		names := make([]string, 0, len(alarm.AlertRules))
+		for iidRuleName := range alarm.AlertRules {
+			names = append(names, iidRuleName)
+		}
+		sort.Strings(names)
+		for _, iidRuleName := range names {
+			arr := strings.Split(iidRuleName, "|")
+			if len(arr) != 2 || arr[0] != strconv.Itoa(instance.ID) {
+				continue
+			}
+			content := alarm.AlertRules[iidRuleName]
+			if instance.RuleStoreType != db.RuleStoreTypeK8sOperator {
+				res[arr[1]] = &desiredRule{alarm: alarm, content: content}
+				continue
+			}
+			groupName := alarm.GetGroupName(instance.ID)
+			if res[groupName] == nil {
+				res[groupName] = &desiredRule{alarm: alarm}
+			}
+			res[groupName].rules = append(res[groupName].rules, db.ClusterRuleItem{RuleName: arr[1], Content: content})
+		}
+	}
+	if instance.RuleStoreType == db.RuleStoreTypeK8sOperator {
+		for groupName, d := range res {
+			contents := make([]string, 0, len(d.rules))
+			for _, item := range d.rules {
+				contents = append(contents, item.Content)
+			}
+			d.content, _ = rule.MergeRules(groupName, contents)
+		}
+	}
+	return res
+}
+
+// ruleDiff rules missing from the store, different from the alarm, and rules of the store unknown to all the alarms
+func ruleDiff(desired map[string]*desiredRule, actual map[string]string, known map[string]struct{}) (missing, changed, orphaned []view.RuleDriftItem) {
+	missing, changed, orphaned = make([]view.RuleDriftItem, 0), make([]view.RuleDriftItem, 0), make([]view.RuleDriftItem, 0)
+	for name, d := range desired {
+		item := view.RuleDriftItem{Name: name, AlarmId: d.alarm.ID, AlarmName: d.alarm.Name}
+		content, ok := actual[name]
+		switch {
+		case !ok:
+			missing = append(missing, item)
+		case !rule.RulesEqual(content, d.content):
+			changed = append(changed, item)
+		}
+	}
+	for name := range actual {
+		if _, ok := desired[name]; ok {
+			continue
+		}
+		if _, ok := known[name]; ok {
+			continue
+		}
+		orphaned = append(orphaned, view.RuleDriftItem{Name: name})
+	}
+	for _, items := range [][]view.RuleDriftItem{missing, changed, orphaned} {
+		sort.Slice(items, func(i, j int) bool { return items[i].Name < items[j].Name })
+	}
+	return
+}
+
+// ruleDriftStore latest drifts by instance, all of them are replaced when iid is 0
+type ruleDriftStore interface {
+	save(iid int, drifts []view.RuleDrift) error
+	load() (map[int]view.RuleDrift, error)
+}
+
+type memoryDriftStore struct {
+	mu     sync.RWMutex
+	drifts map[int]view.RuleDrift
+}
+
+func (s *memoryDriftStore) save(iid int, drifts []view.RuleDrift) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if iid == 0 {
+		s.drifts = make(map[int]view.RuleDrift)
+	}
+	for _, drift := range drifts {
+		s.drifts[drift.InstanceId] = drift
+	}
+	return nil
+}
+
+func (s *memoryDriftStore) load() (map[int]view.RuleDrift, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	res := make(map[int]view.RuleDrift, len(s.drifts))
+	for iid, drift := range s.drifts {
+		res[iid] = drift
+	}
+	return res, nil
+}
+
+// redisDriftStore drifts are json in redis, seen by every copy
+type redisDriftStore struct {
+	redis *eredis.Component
+}
+
+const redisDriftKey = "clickvisual:rule:drifts"
+
+func (s *redisDriftStore) save(iid int, drifts []view.RuleDrift) error {
+	res := make(map[int]view.RuleDrift)
+	if iid != 0 {
+		cur, err := s.load()
+		if err != nil {
+			return err
+		}
+		res = cur
+	}
+	for _, drift := range drifts {
+		res[drift.InstanceId] = drift
+	}
+	raw, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	return s.redis.Set(context.Background(), redisDriftKey, raw, 24*time.Hour)
+}
+
+func (s *redisDriftStore) load() (map[int]view.RuleDrift, error) {
+	res := make(map[int]view.RuleDrift)
+	raw, err := s.redis.GetBytes(context.Background(), redisDriftKey)
+	if errors.Is(err, eredis.Nil) {
+		return res, nil
+	}
+	if err != nil {
+		return res, err
+	}
+	return res, json.Unmarshal(raw, &res)
+}
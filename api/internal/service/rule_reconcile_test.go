@@ -0,0 +1,87 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+)
+
+const ruleReconcileTestContent = `groups:
+- name: default
+  rules:
+  - alert: ClickVisual-%s
+    expr: up > 0`
+
+func Test_ruleDiff(t *testing.T) {
+	alarm := &db.Alarm{Name: "errors", Uuid: "uuid", AlertRules: db.String2String{
+		"1|cv-uuid-1.yaml": ruleReconcileTestContent,
+		"1|cv-uuid-2.yaml": ruleReconcileTestContent,
+		"1|cv-uuid-3.yaml": ruleReconcileTestContent,
+		"2|cv-uuid-4.yaml": ruleReconcileTestContent,
+	}}
+	alarm.ID = 7
+	desired := desiredRules(db.BaseInstance{BaseModel: db.BaseModel{ID: 1}, RuleStoreType: db.RuleStoreTypeFile}, []*db.Alarm{alarm})
+	assert.Len(t, desired, 3)
+
+	actual := map[string]string{
+		"cv-uuid-1.yaml":  ruleReconcileTestContent,
+		"cv-uuid-2.yaml":  "groups:\n- name: cv-uuid-2\n  rules:\n  - alert: ClickVisual-%s\n    expr: up > 1",
+		"cv-uuid-4.yaml":  ruleReconcileTestContent, // rule of the instance 2 sharing the path
+		"cv-other-1.yaml": ruleReconcileTestContent,
+	}
+	known := map[string]struct{}{"cv-uuid-4.yaml": {}}
+	missing, changed, orphaned := ruleDiff(desired, actual, known)
+	assert.Equal(t, []view.RuleDriftItem{{Name: "cv-uuid-3.yaml", AlarmId: 7, AlarmName: "errors"}}, missing)
+	assert.Equal(t, []view.RuleDriftItem{{Name: "cv-uuid-2.yaml", AlarmId: 7, AlarmName: "errors"}}, changed)
+	assert.Equal(t, []view.RuleDriftItem{{Name: "cv-other-1.yaml"}}, orphaned)
+
+	// the group of the ruler is named after the rule
+	actual["cv-uuid-2.yaml"] = "groups:\n- name: cv-uuid-2\n  rules:\n  - alert: ClickVisual-%s\n    expr: up > 0"
+	actual["cv-uuid-3.yaml"] = ruleReconcileTestContent
+	missing, changed, _ = ruleDiff(desired, actual, known)
+	assert.Empty(t, missing)
+	assert.Empty(t, changed)
+}
+
+func Test_desiredRules_operator(t *testing.T) {
+	alarm := &db.Alarm{Uuid: "uuid", AlertRules: db.String2String{
+		"3|cv-uuid-1.yaml": ruleReconcileTestContent,
+		"3|cv-uuid-2.yaml": ruleReconcileTestContent,
+	}}
+	desired := desiredRules(db.BaseInstance{BaseModel: db.BaseModel{ID: 3}, RuleStoreType: db.RuleStoreTypeK8sOperator}, []*db.Alarm{alarm})
+	assert.Len(t, desired, 1)
+	group := desired[alarm.GetGroupName(3)]
+	assert.Len(t, group.rules, 2)
+	assert.Contains(t, group.content, "name: cv-3-uuid")
+}
+
+func Test_knownRules(t *testing.T) {
+	alarm := &db.Alarm{Uuid: "uuid", AlertRules: db.String2String{"3|cv-uuid-1.yaml": ruleReconcileTestContent}}
+	known := knownRules([]*db.Alarm{alarm})
+	assert.Contains(t, known, "cv-uuid-1.yaml")
+	assert.Contains(t, known, alarm.GetGroupName(3))
+	assert.Contains(t, known, "cv-uuid.yaml")
+
+	// the rules of an alarm are written before they are stored in the alarm
+	created := &db.Alarm{Uuid: "created"}
+	assert.True(t, isAlarmRule("cv-created-2.yaml", []*db.Alarm{alarm, created}))
+	assert.True(t, isAlarmRule(created.GetGroupName(3), []*db.Alarm{created}))
+	assert.False(t, isAlarmRule("cv-other-1.yaml", []*db.Alarm{alarm, created, {}}))
+}
+
+func Test_memoryDriftStore(t *testing.T) {
+	s := &memoryDriftStore{drifts: make(map[int]view.RuleDrift)}
+	assert.NoError(t, s.save(0, []view.RuleDrift{{InstanceId: 1}, {InstanceId: 2}}))
+	assert.NoError(t, s.save(2, []view.RuleDrift{{InstanceId: 2, Error: "timeout"}}))
+	drifts, err := s.load()
+	assert.NoError(t, err)
+	assert.Len(t, drifts, 2)
+	assert.Equal(t, "timeout", drifts[2].Error)
+	assert.NoError(t, s.save(0, []view.RuleDrift{{InstanceId: 3}}))
+	drifts, err = s.load()
+	assert.NoError(t, err)
+	assert.Len(t, drifts, 1)
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+)
+
+const (
+	defaultSnoozeDuration = time.Hour
+	maxSnoozeDuration     = 7 * 24 * time.Hour
+	snoozeReason          = "snooze"
+)
+
+// SilenceValidate checks the scope and the time range of a silence
+func SilenceValidate(req view.ReqAlarmSilenceCreate) error {
+	if req.AlarmId == 0 && req.Tid == 0 && req.Iid == 0 && len(req.Matchers) == 0 {
+		return errors.New("silence must match an alarm, table, instance or tags")
+	}
+	if req.EndTime != 0 && req.EndTime <= req.StartTime {
+		return errors.New("end time must be after start time")
+	}
+	if req.Cron == "" {
+		if req.EndTime == 0 {
+			return errors.New("end time is required")
+		}
+		return nil
+	}
+	if _, err := cron.ParseStandard(req.Cron); err != nil {
+		return errors.Wrapf(err, "invalid cron %s", req.Cron)
+	}
+	if req.Duration <= 0 {
+		return errors.New("duration of the maintenance window is required")
+	}
+	return nil
+}
+
+// Silenced the first active silence matching the alarm, nil if the alarm is not silenced
+func (i *alert) Silenced(alarm *db.Alarm, table *db.BaseTable, now time.Time) (*db.AlarmSilence, error) {
+	silences, err := db.AlarmSilenceListActive(invoker.Db, now.Unix())
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range silences {
+		if silenceMatch(s, alarm, table) && SilenceActive(s, now) {
+			return s, nil
+		}
+	}
+	return nil, nil
+}
+
+// Snooze silences the alarm from now on for the duration
+func (i *alert) Snooze(uid, alarmId int, req view.ReqAlarmSnooze) (*db.AlarmSilence, error) {
+	duration := defaultSnoozeDuration
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid duration %s", req.Duration)
+		}
+		duration = d
+	}
+	if duration <= 0 || duration > maxSnoozeDuration {
+		return nil, errors.Errorf("duration must be between 0 and %s", maxSnoozeDuration)
+	}
+	reason := req.Reason
+	if reason == "" {
+		reason = snoozeReason
+	}
+	now := time.Now()
+	obj := &db.AlarmSilence{
+		AlarmId:   alarmId,
+		StartTime: now.Unix(),
+		EndTime:   now.Add(duration).Unix(),
+		Uid:       uid,
+		Reason:    reason,
+	}
+	if err := db.AlarmSilenceCreate(invoker.Db, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// silenceMatch every non-empty scope of the silence must match
+func silenceMatch(s *db.AlarmSilence, alarm *db.Alarm, table *db.BaseTable) bool {
+	if s.AlarmId != 0 && s.AlarmId != alarm.ID {
+		return false
+	}
+	if s.Tid != 0 && s.Tid != table.ID {
+		return false
+	}
+	if s.Iid != 0 && s.Iid != table.Database.Iid {
+		return false
+	}
+	for k, v := range s.Matchers {
+		if tag, ok := alarm.Tags[k]; !ok || tag != v {
+			return false
+		}
+	}
+	return true
+}
+
+// SilenceActive now is in the time range, and in one of the maintenance windows when cron is set
+func SilenceActive(s *db.AlarmSilence, now time.Time) bool {
+	if now.Unix() < s.StartTime || (s.EndTime != 0 && now.Unix() >= s.EndTime) {
+		return false
+	}
+	if s.Cron == "" {
+		return true
+	}
+	schedule, err := cron.ParseStandard(s.Cron)
+	if err != nil {
+		return false
+	}
+	// the latest window starting within duration before now
+	return !schedule.Next(now.Add(-time.Duration(s.Duration) * time.Second)).After(now)
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+)
+
+func TestSilenceActive(t *testing.T) {
+	now := time.Date(2023, 4, 15, 2, 30, 0, 0, time.Local) // Saturday
+	tests := []struct {
+		name    string
+		silence db.AlarmSilence
+		want    bool
+	}{
+		{
+			name:    "in range",
+			silence: db.AlarmSilence{StartTime: now.Add(-time.Hour).Unix(), EndTime: now.Add(time.Hour).Unix()},
+			want:    true,
+		},
+		{
+			name:    "expired",
+			silence: db.AlarmSilence{StartTime: now.Add(-time.Hour).Unix(), EndTime: now.Unix()},
+			want:    false,
+		},
+		{
+			name:    "not started",
+			silence: db.AlarmSilence{StartTime: now.Add(time.Minute).Unix(), EndTime: now.Add(time.Hour).Unix()},
+			want:    false,
+		},
+		{
+			name:    "in maintenance window",
+			silence: db.AlarmSilence{Cron: "0 2 * * 6", Duration: 3600},
+			want:    true,
+		},
+		{
+			name:    "after maintenance window",
+			silence: db.AlarmSilence{Cron: "0 2 * * 6", Duration: 1800},
+			want:    false,
+		},
+		{
+			name:    "other weekday",
+			silence: db.AlarmSilence{Cron: "0 2 * * 0", Duration: 3600},
+			want:    false,
+		},
+		{
+			name:    "maintenance window not started",
+			silence: db.AlarmSilence{StartTime: now.Add(time.Hour).Unix(), Cron: "0 2 * * 6", Duration: 3600},
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SilenceActive(&tt.silence, now); got != tt.want {
+				t.Errorf("SilenceActive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_silenceMatch(t *testing.T) {
+	alarm := &db.Alarm{Tags: db.String2String{"team": "infra", "env": "prod"}}
+	alarm.ID = 1
+	table := &db.BaseTable{Database: &db.BaseDatabase{Iid: 3}}
+	table.ID = 2
+	tests := []struct {
+		name    string
+		silence db.AlarmSilence
+		want    bool
+	}{
+		{name: "alarm", silence: db.AlarmSilence{AlarmId: 1}, want: true},
+		{name: "other alarm", silence: db.AlarmSilence{AlarmId: 4}, want: false},
+		{name: "table", silence: db.AlarmSilence{Tid: 2}, want: true},
+		{name: "instance", silence: db.AlarmSilence{Iid: 3}, want: true},
+		{name: "instance and other table", silence: db.AlarmSilence{Iid: 3, Tid: 5}, want: false},
+		{name: "tags", silence: db.AlarmSilence{Matchers: db.String2String{"team": "infra"}}, want: true},
+		{name: "other tags", silence: db.AlarmSilence{Matchers: db.String2String{"team": "infra", "env": "test"}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := silenceMatch(&tt.silence, alarm, table); got != tt.want {
+				t.Errorf("silenceMatch() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSilenceValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     view.ReqAlarmSilenceCreate
+		wantErr bool
+	}{
+		{name: "no scope", req: view.ReqAlarmSilenceCreate{StartTime: 1, EndTime: 2}, wantErr: true},
+		{name: "no end", req: view.ReqAlarmSilenceCreate{AlarmId: 1, StartTime: 1}, wantErr: true},
+		{name: "end before start", req: view.ReqAlarmSilenceCreate{AlarmId: 1, StartTime: 2, EndTime: 1}, wantErr: true},
+		{name: "ok", req: view.ReqAlarmSilenceCreate{AlarmId: 1, StartTime: 1, EndTime: 2}},
+		{name: "invalid cron", req: view.ReqAlarmSilenceCreate{Tid: 1, Cron: "0 2 *", Duration: 60}, wantErr: true},
+		{name: "window without duration", req: view.ReqAlarmSilenceCreate{Tid: 1, Cron: "0 2 * * 6"}, wantErr: true},
+		{name: "window", req: view.ReqAlarmSilenceCreate{Matchers: map[string]string{"team": "infra"}, Cron: "0 2 * * 6", Duration: 60}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SilenceValidate(tt.req); (err != nil) != tt.wantErr {
+				t.Errorf("SilenceValidate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
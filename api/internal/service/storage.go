@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/ego-component/egorm"
+	"github.com/pkg/errors"
 	"go.uber.org/multierr"
 
 	"github.com/clickvisual/clickvisual/api/internal/invoker"
@@ -11,7 +12,10 @@ import (
 	"github.com/clickvisual/clickvisual/api/internal/pkg/constx"
 	db2 "github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
 	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/utils/mapping"
+	"github.com/clickvisual/clickvisual/api/internal/service/inquiry/factory"
 	"github.com/clickvisual/clickvisual/api/internal/service/storage"
+	"github.com/clickvisual/clickvisual/api/internal/service/storage/storagetemplate"
 	"github.com/clickvisual/clickvisual/api/internal/service/storage/storageworker"
 )
 
@@ -81,7 +85,7 @@ func (s *srvStorage) on(row *db2.BaseTable) error {
 		return err
 	}
 	worker := storageworker.NewTrace(storageworker.WorkerParams{
-		Spec:   "*/10 * * * *",
+		Spec:   storageworker.TraceSpec,
 		Source: source,
 		Target: target,
 		DB:     op.Conn(),
@@ -232,6 +236,112 @@ func (s *srvStorage) CreateByEgoTemplate(uid int, databaseInfo db2.BaseDatabase,
 	return
 }
 
+// CreateByTemplate creates the storage and its analysis fields from a collector template
+func (s *srvStorage) CreateByTemplate(uid int, databaseInfo db2.BaseDatabase, name string, param view.ReqCreateStorageByTemplate) (err error) {
+	tpl, err := storagetemplate.Get(name)
+	if err != nil {
+		return err
+	}
+	conds := egorm.Conds{}
+	conds["did"] = databaseInfo.ID
+	conds["name"] = param.Name
+	tableInfo, _ := db2.TableInfoX(invoker.Db, conds)
+	if tableInfo.ID != 0 {
+		return errors.New("table is repeat")
+	}
+	table, err := StorageCreate(uid, databaseInfo, tpl.StorageCreate(param))
+	if err != nil {
+		return err
+	}
+	return AnalysisFieldsUpdate(table.ID, tpl.AnalysisFields)
+}
+
+// Infer proposes the storage create params from pasted samples or messages read from kafka
+func (s *srvStorage) Infer(databaseInfo db2.BaseDatabase, req view.ReqStorageInfer) (res view.RespStorageInfer, err error) {
+	samples := req.Samples
+	if len(samples) == 0 {
+		if req.Brokers == "" || req.Topic == "" {
+			return res, errors.New("samples or kafka brokers and topic are required")
+		}
+		if req.Limit <= 0 || req.Limit > 1000 {
+			req.Limit = 100
+		}
+		op, errLoad := InstanceManager.Load(databaseInfo.Iid)
+		if errLoad != nil {
+			return res, errLoad
+		}
+		if samples, err = op.KafkaSample(databaseInfo, req.Brokers, req.Topic, req.Limit); err != nil {
+			return res, errors.Wrap(err, "read kafka samples")
+		}
+	}
+	inference, err := mapping.Infer(samples)
+	if err != nil {
+		return
+	}
+	res.Inference = inference
+	res.Storage = view.ReqStorageCreate{
+		CreateType:              constx.TableCreateTypeJSONAsString,
+		TableName:               req.TableName,
+		Days:                    req.Days,
+		Brokers:                 req.Brokers,
+		Topics:                  req.Topic,
+		Consumers:               1,
+		KafkaSkipBrokenMessages: 1000,
+		Source:                  inference.Source,
+		DatabaseId:              databaseInfo.ID,
+		RawLogField:             inference.RawLogField,
+		RawLogFieldParent:       inference.RawLogFieldParent,
+	}
+	for _, t := range inference.TimeFields {
+		if typ := inferTimeTyp(t); typ != 0 {
+			res.Storage.Typ = typ
+			res.Storage.TimeField = t.Key
+			res.Storage.TimeFieldParent = t.Parent
+			break
+		}
+	}
+	res.AnalysisFields = make([]view.IndexItem, 0)
+	for _, f := range inference.AnalysisFields {
+		switch {
+		case f.Typ == "String":
+			res.AnalysisFields = append(res.AnalysisFields, view.IndexItem{Field: f.Key, Typ: 0})
+		case f.Typ == "Float64" && f.Integer:
+			res.AnalysisFields = append(res.AnalysisFields, view.IndexItem{Field: f.Key, Typ: 1})
+		case f.Typ == "Float64":
+			res.AnalysisFields = append(res.AnalysisFields, view.IndexItem{Field: f.Key, Typ: 2})
+		}
+	}
+	return
+}
+
+// inferTimeTyp time field type able to parse the inferred format, 0 means not supported
+func inferTimeTyp(t mapping.InferTime) int {
+	switch t.Format {
+	case mapping.TimeFormatEpochSecond:
+		if t.Quoted {
+			// parseDateTimeBestEffort accepts unix timestamp strings
+			return factory.TableTypeString
+		}
+		return factory.TableTypeFloat
+	case mapping.TimeFormatEpochMilli:
+		if !t.Quoted {
+			return factory.TableTypeUnixMilli
+		}
+	case mapping.TimeFormatEpochNano:
+		if t.Quoted {
+			return factory.TableTypeUnixNano
+		}
+	case mapping.TimeFormatCLF:
+		return factory.TableTypeCLF
+	case time.ANSIC:
+		return factory.TableTypeANSIC
+	case mapping.TimeFormatRFC3339, time.RFC1123Z, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006/01/02 15:04:05":
+		return factory.TableTypeString
+	}
+	// epoch micro, and RFC1123 whose zone abbreviations parseDateTimeBestEffort does not know
+	return 0
+}
+
 func (s *srvStorage) createByIlogtailTemplateItem(uid int, databaseInfo db2.BaseDatabase, param view.ReqStorageCreate) (err error) {
 	// Detection is whether it has been created
 	conds := egorm.Conds{}
@@ -0,0 +1,173 @@
+package storagetemplate
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/constx"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service/inquiry/factory"
+)
+
+const (
+	NameFluentBit = "fluentbit"
+	NameVector    = "vector"
+	NameOTLP      = "otlp"
+)
+
+// Template describes how the messages of a log collector are stored
+type Template struct {
+	Name              string
+	Typ               int // time field type, see factory.TableTypeString
+	TimeField         string
+	TimeFieldParent   string
+	RawLogField       string
+	RawLogFieldParent string
+	// Format envelope of the messages, see constx.SourceFormatOTLP
+	Format string
+	// Source sample message, columns are generated from it
+	Source string
+	// FieldAlias parent.key or key -> base field name
+	FieldAlias map[string]string
+	// AnalysisFields fields parsed from the raw log
+	AnalysisFields []view.IndexItem
+}
+
+var templates = map[string]Template{
+	// fluent bit kafka output with kubernetes filter, see data/all-in-one/fluent-bit
+	NameFluentBit: {
+		Name:        NameFluentBit,
+		Typ:         factory.TableTypeFloat,
+		TimeField:   "_time_",
+		RawLogField: "_log_",
+		Source: `{
+    "_time_": 1681704438.624075,
+    "_log_": "{\"level\":\"info\",\"ts\":1681704437,\"msg\":\"presigned get object URL\"}",
+    "stream": "stdout",
+    "kubernetes": {
+        "pod_name": "xx-xx-xx-xx",
+        "namespace_name": "default",
+        "pod_id": "xx-xx-xx-xx-xx",
+        "host": "xx-xx-xx",
+        "container_name": "xx-xx",
+        "container_image": "xxx"
+    }
+}`,
+		FieldAlias: map[string]string{
+			"stream":                    "_source_",
+			"kubernetes.pod_name":       "_pod_name_",
+			"kubernetes.namespace_name": "_namespace_",
+			"kubernetes.host":           "_node_name_",
+			"kubernetes.container_name": "_container_name_",
+		},
+		AnalysisFields: []view.IndexItem{
+			{Field: "level", Typ: 0},
+			{Field: "msg", Typ: 0},
+			{Field: "ts", Typ: 2},
+		},
+	},
+	// vector kafka sink with json encoding and kubernetes_logs source
+	NameVector: {
+		Name:        NameVector,
+		Typ:         factory.TableTypeString,
+		TimeField:   "timestamp",
+		RawLogField: "message",
+		Source: `{
+    "timestamp": "2023-04-17T04:07:17.624075074Z",
+    "message": "{\"level\":\"info\",\"ts\":1681704437,\"msg\":\"presigned get object URL\"}",
+    "source_type": "kubernetes_logs",
+    "stream": "stdout",
+    "file": "xx-xx-xx",
+    "kubernetes": {
+        "pod_name": "xx-xx-xx-xx",
+        "pod_namespace": "default",
+        "pod_uid": "xx-xx-xx-xx-xx",
+        "pod_ip": "127.0.0.1",
+        "pod_node_name": "xx-xx-xx",
+        "container_name": "xx-xx",
+        "container_image": "xxx"
+    }
+}`,
+		FieldAlias: map[string]string{
+			"stream":                    "_source_",
+			"source_type":               "_log_agent_",
+			"kubernetes.pod_name":       "_pod_name_",
+			"kubernetes.pod_namespace":  "_namespace_",
+			"kubernetes.pod_node_name":  "_node_name_",
+			"kubernetes.container_name": "_container_name_",
+		},
+		AnalysisFields: []view.IndexItem{
+			{Field: "level", Typ: 0},
+			{Field: "msg", Typ: 0},
+			{Field: "ts", Typ: 2},
+		},
+	},
+	// otel collector kafka exporter with otlp_json encoding, the resourceLogs/scopeLogs/logRecords
+	// of a message are unnested by the view, Source is one unnested record with its resource attributes
+	NameOTLP: {
+		Name:              NameOTLP,
+		Typ:               factory.TableTypeUnixNano,
+		TimeField:         "timeUnixNano",
+		RawLogField:       "stringValue",
+		RawLogFieldParent: "body",
+		Format:            constx.SourceFormatOTLP,
+		Source: `{
+    "timeUnixNano": "1681704437624075074",
+    "severityText": "INFO",
+    "severityNumber": 9,
+    "traceId": "5b8efff798038103d269b633813fc60c",
+    "spanId": "eee19b7ec3c1b174",
+    "body": {
+        "stringValue": "presigned get object URL"
+    },
+    "resource": {
+        "service.name": "xx-xx",
+        "k8s.pod.name": "xx-xx-xx-xx",
+        "k8s.namespace.name": "default",
+        "k8s.node.name": "xx-xx-xx",
+        "k8s.container.name": "xx-xx"
+    }
+}`,
+		FieldAlias: map[string]string{
+			"resource.k8s.pod.name":       "_pod_name_",
+			"resource.k8s.namespace.name": "_namespace_",
+			"resource.k8s.node.name":      "_node_name_",
+			"resource.k8s.container.name": "_container_name_",
+		},
+		AnalysisFields: []view.IndexItem{
+			{Field: "level", Typ: 0},
+			{Field: "msg", Typ: 0},
+		},
+	},
+}
+
+// Get returns the template by name
+func Get(name string) (Template, error) {
+	tpl, ok := templates[name]
+	if !ok {
+		return Template{}, errors.Errorf("template %s not found", name)
+	}
+	return tpl, nil
+}
+
+// StorageCreate storage create params of the template
+func (t Template) StorageCreate(param view.ReqCreateStorageByTemplate) view.ReqStorageCreate {
+	return view.ReqStorageCreate{
+		CreateType:              constx.TableCreateTypeJSONAsString,
+		TableName:               param.Name,
+		Typ:                     t.Typ,
+		Days:                    param.Days,
+		Brokers:                 param.Brokers,
+		Topics:                  param.Topic,
+		Consumers:               1,
+		KafkaSkipBrokenMessages: 1000,
+		Source:                  t.Source,
+		DatabaseId:              param.DatabaseId,
+		TimeField:               t.TimeField,
+		TimeFieldParent:         t.TimeFieldParent,
+		RawLogField:             t.RawLogField,
+		RawLogFieldParent:       t.RawLogFieldParent,
+		FieldAlias:              t.FieldAlias,
+		DeadLetter:              param.DeadLetter,
+		Format:                  t.Format,
+	}
+}
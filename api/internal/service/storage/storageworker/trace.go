@@ -13,6 +13,13 @@ import (
 
 var _ iWorker = (*Trace)(nil)
 
+const (
+	// TraceSpec the dependencies are computed every ten minutes
+	TraceSpec = "*/10 * * * *"
+	// TraceInterval time between two runs of TraceSpec
+	TraceInterval = 10 * time.Minute
+)
+
 // Trace Used to otel jaeger json data analysis
 type Trace struct {
 	// default
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/utils/mapping"
+	"github.com/clickvisual/clickvisual/api/internal/service/inquiry/factory"
+)
+
+func Test_inferTimeTyp(t *testing.T) {
+	tests := []struct {
+		time mapping.InferTime
+		want int
+	}{
+		{mapping.InferTime{Format: mapping.TimeFormatEpochSecond}, factory.TableTypeFloat},
+		{mapping.InferTime{Format: mapping.TimeFormatEpochSecond, Quoted: true}, factory.TableTypeString},
+		{mapping.InferTime{Format: mapping.TimeFormatEpochMilli}, factory.TableTypeUnixMilli},
+		{mapping.InferTime{Format: mapping.TimeFormatEpochNano, Quoted: true}, factory.TableTypeUnixNano},
+		{mapping.InferTime{Format: mapping.TimeFormatEpochMicro}, 0},
+		{mapping.InferTime{Format: mapping.TimeFormatRFC3339, Quoted: true}, factory.TableTypeString},
+		{mapping.InferTime{Format: "2006-01-02 15:04:05", Quoted: true}, factory.TableTypeString},
+		{mapping.InferTime{Format: mapping.TimeFormatCLF, Quoted: true}, factory.TableTypeCLF},
+		{mapping.InferTime{Format: time.ANSIC, Quoted: true}, factory.TableTypeANSIC},
+		{mapping.InferTime{Format: time.RFC1123Z, Quoted: true}, factory.TableTypeString},
+		{mapping.InferTime{Format: time.RFC1123, Quoted: true}, 0},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, inferTimeTyp(tt.time), tt.time.Format)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
 	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
 	"github.com/clickvisual/clickvisual/api/internal/pkg/utils/mapping"
+	"github.com/clickvisual/clickvisual/api/internal/service/inquiry/factory"
 	"github.com/clickvisual/clickvisual/api/internal/service/permission"
 	"github.com/clickvisual/clickvisual/api/internal/service/permission/pmsplugin"
 )
@@ -58,6 +59,22 @@ func IsCheckInner(createType int) bool {
 }
 
 func StorageCreate(uid int, databaseInfo db.BaseDatabase, param view.ReqStorageCreate) (tableInfo db.BaseTable, err error) {
+	if param.DeadLetter == 1 && param.CreateType != constx.TableCreateTypeJSONAsString {
+		err = errors.New("dead letter is only supported by JSONAsString storage")
+		return
+	}
+	if param.Format != "" && (param.Format != constx.SourceFormatOTLP || param.CreateType != constx.TableCreateTypeJSONAsString) {
+		err = errors.New("format is otlp and only supported by JSONAsString storage")
+		return
+	}
+	if param.Typ > factory.TableTypeFloat && param.CreateType != constx.TableCreateTypeJSONAsString {
+		err = errors.New("time field type is only supported by JSONAsString storage")
+		return
+	}
+	if param.RawLogIndex < db.RawLogIndexDefault || param.RawLogIndex > db.RawLogIndexNone {
+		err = errors.New("invalid raw log index")
+		return
+	}
 	param.SourceMapping, err = mapping.Handle(param.Source, IsCheckInner(param.CreateType))
 	if err != nil {
 		return
@@ -65,6 +82,7 @@ func StorageCreate(uid int, databaseInfo db.BaseDatabase, param view.ReqStorageC
 	if err = json.Unmarshal([]byte(param.Source), &param.SourceMapping); err != nil {
 		return
 	}
+	param.SourceMapping.SetAlias(param.FieldAlias)
 	op, err := InstanceManager.Load(databaseInfo.Iid)
 	if err != nil {
 		return
@@ -104,6 +122,18 @@ func StorageCreate(uid int, databaseInfo db.BaseDatabase, param view.ReqStorageC
 		SelectFields:            param.SelectFields(),
 		AnyJSON:                 param.JSON(),
 		KafkaSkipBrokenMessages: param.KafkaSkipBrokenMessages,
+		RawLogIndex:             param.RawLogIndex,
+		DeadLetter:              param.DeadLetter,
+	}
+	// tables created by clickvisual come with the tokenbf_v1 index
+	created := db.BaseTable{CreateType: tableInfo.CreateType}
+	if tableInfo.GetRawLogIndex() != created.GetRawLogIndex() {
+		indexTable := tableInfo
+		indexTable.Database = &databaseInfo
+		if err = op.UpdateRawLogIndex(&indexTable, tableInfo.GetRawLogIndex()); err != nil {
+			err = errors.Wrap(err, "raw log index create failed")
+			return
+		}
 	}
 	tx := invoker.Db.Begin()
 	err = db.TableCreate(tx, &tableInfo)
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gotomicro/cetus/pkg/xgo"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/component/core"
+)
+
+// workerTicker runs the check of a background worker at its interval until it is stopped.
+// The stop channel is created before the goroutine starts, so a stop right after the start always ends it.
+type workerTicker struct {
+	mu    sync.Mutex
+	stopC chan struct{}
+}
+
+// start runs check every interval and on each send to trigger, which may be nil. A previous run is stopped.
+func (t *workerTicker) start(component string, interval time.Duration, trigger <-chan struct{}, check func() error) {
+	stopC := make(chan struct{})
+	t.mu.Lock()
+	if t.stopC != nil {
+		close(t.stopC)
+	}
+	t.stopC = stopC
+	t.mu.Unlock()
+	xgo.Go(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				core.LoggerError(component, "tickerCheck", check())
+			case <-trigger:
+				core.LoggerError(component, "trigger", check())
+			case <-stopC:
+				return
+			}
+		}
+	})
+}
+
+func (t *workerTicker) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stopC != nil {
+		close(t.stopC)
+		t.stopC = nil
+	}
+}
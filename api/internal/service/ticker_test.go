@@ -0,0 +1,43 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_workerTicker(t *testing.T) {
+	w := &workerTicker{}
+	triggerC := make(chan struct{})
+	checked := make(chan struct{}, 1)
+	w.start("test", time.Hour, triggerC, func() error {
+		checked <- struct{}{}
+		return nil
+	})
+	triggerC <- struct{}{}
+	select {
+	case <-checked:
+	case <-time.After(time.Second):
+		t.Fatal("the trigger did not run the check")
+	}
+
+	// a restart stops the previous run, a stop right after the start closes the channel of the new one
+	w.mu.Lock()
+	first := w.stopC
+	w.mu.Unlock()
+	w.start("test", time.Hour, nil, func() error { return nil })
+	w.mu.Lock()
+	second := w.stopC
+	w.mu.Unlock()
+	w.stop()
+	for _, stopC := range []chan struct{}{first, second} {
+		select {
+		case <-stopC:
+		default:
+			t.Fatal("the stop channel is not closed")
+		}
+	}
+	w.stop()
+	assert.Nil(t, w.stopC)
+}
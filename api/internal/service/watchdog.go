@@ -0,0 +1,468 @@
+package service
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ego-component/egorm"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/gotomicro/ego/core/elog"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"gorm.io/gorm"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/view"
+	"github.com/clickvisual/clickvisual/api/internal/service/alarm/pusher"
+	"github.com/clickvisual/clickvisual/api/internal/service/inquiry/factory"
+)
+
+const (
+	// defaultWatchdogLearnWindows windows of an hour of the day learned before its anomalies are notified
+	defaultWatchdogLearnWindows = 24
+	// watchdogDelay windows are checked after the late logs are ingested
+	watchdogDelay = time.Minute
+	// watchdogAlpha weight of a window in the learned statistics once the hour has enough samples
+	watchdogAlpha = 0.05
+	// watchdogZeroMean no logs is only an anomaly for the hours usually with more logs per window
+	watchdogZeroMean = 5
+	// watchdogSpikeMin a spike has at least so many logs and twice the usual volume
+	watchdogSpikeMin = 100
+	// watchdogErrorMin an error ratio spike has at least so many error logs and a ratio 5% over the usual one
+	watchdogErrorMin   = 10
+	watchdogErrorDelta = 0.05
+	// watchdogMaxPatterns oldest known error patterns are forgotten beyond it
+	watchdogMaxPatterns = 1000
+	// watchdogWindowPatterns most frequent error patterns read of a window
+	watchdogWindowPatterns = 100
+)
+
+var (
+	watchdogLevelFields   = []string{"level", "lv", "severity", "loglevel", "log_level"}
+	watchdogMessageFields = []string{"msg", "message", "error", "err", "errmsg", "error_msg"}
+)
+
+// watchdog learns the usual volume and error ratio of the storages with it enabled for each hour of the day,
+// their channels are notified when the volume drops to zero or spikes, the error ratio spikes or a new error pattern appears
+type watchdog struct {
+	ticker workerTicker
+}
+
+// watchdogSample logs of a checked window
+type watchdogSample struct {
+	count    uint64
+	errors   uint64
+	patterns map[string]watchdogPattern // by fingerprint
+}
+
+type watchdogPattern struct {
+	pattern string
+	example string
+}
+
+func NewWatchdog() *watchdog {
+	return &watchdog{}
+}
+
+func (w *watchdog) tickerCheck() {
+	w.ticker.start("watchdog", watchdogInterval(), nil, func() error { return w.check(time.Now()) })
+}
+
+func (w *watchdog) stop() {
+	w.ticker.stop()
+}
+
+// watchdogInterval length of the checked windows, app.watchdogCheckInterval
+func watchdogInterval() time.Duration {
+	interval := econf.GetDuration("app.watchdogCheckInterval")
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	return interval
+}
+
+func (w *watchdog) check(now time.Time) (err error) {
+	dogs, err := db.WatchdogList(invoker.Db, egorm.Conds{"enabled": 1})
+	if err != nil {
+		return err
+	}
+	interval := watchdogInterval()
+	et := now.Add(-watchdogDelay).Truncate(interval)
+	st := et.Add(-interval)
+	for _, dog := range dogs {
+		err = multierr.Append(err, w.inspect(dog, st, et))
+	}
+	return err
+}
+
+// inspect checks the window of the storage, learns it and notifies the changes of the anomalies
+func (w *watchdog) inspect(dog *db.BaseWatchdog, st, et time.Time) error {
+	if dog.CheckedAt >= et.Unix() {
+		return nil
+	}
+	table, err := db.TableInfo(invoker.Db, dog.Tid)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// the storage was deleted before its watchdog went with it
+		return db.WatchdogDeleteByTid(invoker.Db, dog.Tid)
+	}
+	if err != nil {
+		return err
+	}
+	op, err := InstanceManager.Load(table.Database.Iid)
+	if err != nil {
+		return err
+	}
+	indexes, err := db.IndexList(egorm.Conds{"tid": table.ID})
+	if err != nil {
+		return err
+	}
+	levelField, messageField := watchdogFields(indexes)
+	sample, err := watchdogQuery(op, &table, levelField, messageField, st, et)
+	if err != nil {
+		return err
+	}
+	minSamples := econf.GetInt("app.watchdogLearnWindows")
+	if minSamples <= 0 {
+		minSamples = defaultWatchdogLearnWindows
+	}
+	hour := st.In(pusher.MsgLocation()).Hour()
+	stats := dog.Baseline[hour]
+	kinds := watchdogAnomalies(stats, sample, minSamples, econf.GetFloat64("app.watchdogSensitivity"))
+	newPatterns, patterns := watchdogLearnPatterns(dog.Patterns, sample.patterns)
+	if stats.Samples < minSamples {
+		// the new patterns of the learning windows are known errors
+		newPatterns = nil
+	}
+	dog.Baseline[hour] = watchdogLearn(stats, sample, kinds)
+	// a new error pattern fires for its window, it is resolved with the next one
+	anomalies := append(db.Strings{}, kinds...)
+	for _, fingerprint := range newPatterns {
+		anomalies = append(anomalies, db.WatchdogAnomaly(db.WatchdogNewError, fingerprint))
+	}
+
+	ups := make(map[string]interface{})
+	ups["baseline"] = dog.Baseline
+	ups["patterns"] = patterns
+	ups["anomalies"] = anomalies
+	ups["checked_at"] = et.Unix()
+	if err = db.WatchdogUpdate(invoker.Db, dog.ID, ups); err != nil {
+		return err
+	}
+	anomaly := pusher.WatchdogAnomaly{
+		ST:         st.Unix(),
+		ET:         et.Unix(),
+		Count:      sample.count,
+		Usual:      stats.Mean,
+		ErrorRatio: watchdogRatio(sample),
+		UsualRatio: stats.ErrorMean,
+	}
+	firing, resolved := watchdogChanges(dog.Anomalies, anomalies)
+	return w.notifyChanges(dog, &table, anomaly, firing, resolved, sample.patterns)
+}
+
+// notifyChanges notifies the channels of the watchdog of the anomalies starting and ending with the window
+func (w *watchdog) notifyChanges(dog *db.BaseWatchdog, table *db.BaseTable, anomaly pusher.WatchdogAnomaly,
+	firing, resolved []string, patterns map[string]watchdogPattern) (err error) {
+	if len(firing)+len(resolved) == 0 {
+		return nil
+	}
+	channelIds, err := watchdogChannels(dog)
+	if err != nil {
+		return err
+	}
+	if len(channelIds) == 0 {
+		elog.Warn("watchdog", elog.String("step", "noChannels"), elog.Int("tid", table.ID), elog.Any("firing", firing))
+		return nil
+	}
+	for _, kind := range firing {
+		anomaly.Kind, anomaly.Fingerprint = db.WatchdogAnomalyKind(kind)
+		anomaly.Pattern, anomaly.Example = patterns[anomaly.Fingerprint].pattern, patterns[anomaly.Fingerprint].example
+		err = multierr.Append(err, w.notify(dog, channelIds, table, anomaly, db.AlarmStatusFiring))
+	}
+	for _, kind := range resolved {
+		anomaly.Kind, anomaly.Fingerprint = db.WatchdogAnomalyKind(kind)
+		anomaly.Pattern, anomaly.Example = "", ""
+		err = multierr.Append(err, w.notify(dog, channelIds, table, anomaly, db.AlarmStatusNormal))
+	}
+	return err
+}
+
+func (w *watchdog) notify(dog *db.BaseWatchdog, channelIds []int, table *db.BaseTable, anomaly pusher.WatchdogAnomaly, status int) error {
+	elog.Info("watchdog", elog.String("step", "notify"), elog.Int("tid", table.ID), elog.Any("anomaly", anomaly), elog.Int("status", status))
+	history := db.AlarmHistory{WatchdogId: dog.ID, FilterStatus: status, IsPushed: db.PushedStatusRepeat}
+	if err := db.AlarmHistoryCreate(invoker.Db, &history); err != nil {
+		return err
+	}
+	msg, msgWithAt := pusher.BuildWatchdogMsg(table, anomaly, status)
+	alertKey := fmt.Sprintf("watchdog|%d|%s", table.ID, anomaly.Kind)
+	if anomaly.Fingerprint != "" {
+		alertKey += "|" + anomaly.Fingerprint
+	}
+	item := &notifyItem{
+		alertKey:  alertKey,
+		status:    status,
+		historyId: history.ID,
+		msg:       msg,
+		msgWithAt: msgWithAt,
+	}
+	// grouped apart from the alarms by default
+	labels := map[string]string{
+		"alarmId":   "watchdog-" + strconv.Itoa(table.ID),
+		"alarmName": "watchdog",
+		"tid":       strconv.Itoa(table.ID),
+		"table":     table.Name,
+		"anomaly":   anomaly.Kind,
+	}
+	return Notifier.Notify(channelIds, labels, item)
+}
+
+// watchdogChannels channels of the watchdog, the ones of the alarms of the storage when it has none
+func watchdogChannels(dog *db.BaseWatchdog) ([]int, error) {
+	if len(dog.ChannelIds) > 0 {
+		return dog.ChannelIds, nil
+	}
+	alarms, err := db.AlarmListByTidArr(egorm.Conds{}, []int{dog.Tid})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]int, 0)
+	exists := make(map[int]struct{})
+	for _, alarm := range alarms {
+		for _, channelId := range alarm.ChannelIds {
+			if _, ok := exists[channelId]; ok {
+				continue
+			}
+			exists[channelId] = struct{}{}
+			res = append(res, channelId)
+		}
+	}
+	return res, nil
+}
+
+// watchdogQuery counts the logs and the error logs of the window, the error patterns are the most frequent ones of the error logs
+func watchdogQuery(op factory.Operator, table *db.BaseTable, levelField, messageField string, st, et time.Time) (res watchdogSample, err error) {
+	param, err := op.Prepare(view.ReqQuery{
+		Tid:           table.ID,
+		Database:      table.Database.Name,
+		Table:         table.Name,
+		TimeField:     table.TimeField,
+		TimeFieldType: table.TimeFieldType,
+		ST:            st.Unix(),
+		ET:            et.Unix(),
+	}, table, false)
+	if err != nil {
+		return res, err
+	}
+	if res.count, err = op.Count(param); err != nil {
+		return res, err
+	}
+	res.patterns = make(map[string]watchdogPattern)
+	if levelField == "" || res.count == 0 {
+		return res, nil
+	}
+	param.Query = fmt.Sprintf("lower(toString(`%s`)) IN ('error', 'fatal', 'panic', 'critical')", levelField)
+	if res.errors, err = op.Count(param); err != nil {
+		return res, err
+	}
+	if messageField == "" || res.errors == 0 {
+		return res, nil
+	}
+	param.Field = messageField
+	patterns, err := op.ErrorPatterns(param, watchdogWindowPatterns)
+	if err != nil {
+		return res, err
+	}
+	for _, p := range patterns {
+		res.patterns[watchdogFingerprint(p.Pattern)] = watchdogPattern{pattern: p.Pattern, example: p.Example}
+	}
+	return res, nil
+}
+
+// watchdogFields level and message fields of the storage found by their usual names, empty when missing
+func watchdogFields(indexes []*db.BaseIndex) (levelField, messageField string) {
+	fields := make(map[string]string, len(indexes))
+	for _, index := range indexes {
+		if index.RootName != "" {
+			continue
+		}
+		fields[strings.ToLower(index.Field)] = index.Field
+	}
+	for _, name := range watchdogLevelFields {
+		if field, ok := fields[name]; ok {
+			levelField = field
+			break
+		}
+	}
+	for _, name := range watchdogMessageFields {
+		if field, ok := fields[name]; ok {
+			messageField = field
+			break
+		}
+	}
+	return
+}
+
+func watchdogFingerprint(pattern string) string {
+	sum := sha1.Sum([]byte(pattern))
+	return hex.EncodeToString(sum[:8])
+}
+
+// watchdogAnomalies anomalies of the window against the learned statistics of its hour, none while the hour is learned
+func watchdogAnomalies(stats db.WatchdogHour, sample watchdogSample, minSamples int, sensitivity float64) []string {
+	res := make([]string, 0)
+	if stats.Samples < minSamples {
+		return res
+	}
+	if sensitivity <= 0 {
+		sensitivity = 4
+	}
+	count := float64(sample.count)
+	switch {
+	case sample.count == 0 && stats.Mean >= watchdogZeroMean:
+		res = append(res, db.WatchdogVolumeZero)
+	case count >= watchdogSpikeMin && count > 2*stats.Mean && count > stats.Mean+sensitivity*math.Sqrt(stats.Var):
+		res = append(res, db.WatchdogVolumeSpike)
+	}
+	ratio := watchdogRatio(sample)
+	if sample.errors >= watchdogErrorMin && ratio >= stats.ErrorMean+watchdogErrorDelta &&
+		ratio > stats.ErrorMean+sensitivity*math.Sqrt(stats.ErrorVar) {
+		res = append(res, db.WatchdogErrorRatio)
+	}
+	return res
+}
+
+// watchdogLearn statistics of the hour with the window, anomalies are left out.
+// The mean of the first windows is a plain average, an exponentially weighted one after it.
+func watchdogLearn(stats db.WatchdogHour, sample watchdogSample, anomalies []string) db.WatchdogHour {
+	volumeAnomaly, errorAnomaly := false, false
+	for _, kind := range anomalies {
+		switch kind {
+		case db.WatchdogVolumeZero, db.WatchdogVolumeSpike:
+			volumeAnomaly = true
+		case db.WatchdogErrorRatio:
+			errorAnomaly = true
+		}
+	}
+	if volumeAnomaly {
+		return stats
+	}
+	alpha := math.Max(1/float64(stats.Samples+1), watchdogAlpha)
+	stats.Mean, stats.Var = watchdogEWM(stats.Mean, stats.Var, float64(sample.count), alpha)
+	if sample.count > 0 && !errorAnomaly {
+		stats.ErrorMean, stats.ErrorVar = watchdogEWM(stats.ErrorMean, stats.ErrorVar, watchdogRatio(sample), alpha)
+	}
+	stats.Samples++
+	return stats
+}
+
+// watchdogEWM exponentially weighted mean and variance with the value
+func watchdogEWM(mean, variance, value, alpha float64) (float64, float64) {
+	diff := value - mean
+	mean += alpha * diff
+	variance = (1 - alpha) * (variance + alpha*diff*diff)
+	return mean, variance
+}
+
+func watchdogRatio(sample watchdogSample) float64 {
+	if sample.count == 0 {
+		return 0
+	}
+	return float64(sample.errors) / float64(sample.count)
+}
+
+// watchdogLearnPatterns fingerprints of the window not known yet, sorted, and the known ones with them
+func watchdogLearnPatterns(known db.Strings, patterns map[string]watchdogPattern) (newPatterns []string, res db.Strings) {
+	exists := make(map[string]struct{}, len(known))
+	for _, fingerprint := range known {
+		exists[fingerprint] = struct{}{}
+	}
+	for fingerprint := range patterns {
+		if _, ok := exists[fingerprint]; !ok {
+			newPatterns = append(newPatterns, fingerprint)
+		}
+	}
+	sort.Strings(newPatterns)
+	res = append(append(db.Strings{}, known...), newPatterns...)
+	if len(res) > watchdogMaxPatterns {
+		res = res[len(res)-watchdogMaxPatterns:]
+	}
+	return newPatterns, res
+}
+
+// watchdogChanges anomalies starting and ending with the window
+func watchdogChanges(prev, cur []string) (firing, resolved []string) {
+	for _, kind := range cur {
+		if !stringsContain(prev, kind) {
+			firing = append(firing, kind)
+		}
+	}
+	for _, kind := range prev {
+		if !stringsContain(cur, kind) {
+			resolved = append(resolved, kind)
+		}
+	}
+	return
+}
+
+func stringsContain(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateWatchdog switches the watchdog of the storage, its learned statistics are kept
+func (w *watchdog) UpdateWatchdog(tid int, req view.ReqStorageUpdateWatchdog) error {
+	dog, err := db.WatchdogInfoX(invoker.Db, egorm.Conds{"tid": tid})
+	if err != nil {
+		return err
+	}
+	if dog.ID == 0 {
+		return db.WatchdogCreate(invoker.Db, &db.BaseWatchdog{
+			Tid:        tid,
+			Enabled:    req.Enabled,
+			ChannelIds: req.ChannelIds,
+			Patterns:   db.Strings{},
+			Anomalies:  db.Strings{},
+		})
+	}
+	ups := make(map[string]interface{})
+	ups["enabled"] = req.Enabled
+	ups["channel_ids"] = db.Ints(req.ChannelIds)
+	if req.Enabled == 0 {
+		// nothing is firing while it is off
+		ups["anomalies"] = db.Strings{}
+	}
+	if err = db.WatchdogUpdate(invoker.Db, dog.ID, ups); err != nil {
+		return err
+	}
+	if req.Enabled != 0 || len(dog.Anomalies) == 0 {
+		return nil
+	}
+	// the anomalies are resolved in the channels they fired in
+	table, err := db.TableInfo(invoker.Db, tid)
+	if err != nil {
+		return err
+	}
+	anomaly := pusher.WatchdogAnomaly{ST: dog.CheckedAt - int64(watchdogInterval().Seconds()), ET: dog.CheckedAt}
+	return w.notifyChanges(&dog, &table, anomaly, nil, dog.Anomalies, nil)
+}
+
+// Fields level and message fields of the storage used by the watchdog
+func (w *watchdog) Fields(tid int) (levelField, messageField string, err error) {
+	indexes, err := db.IndexList(egorm.Conds{"tid": tid})
+	if err != nil {
+		return "", "", err
+	}
+	levelField, messageField = watchdogFields(indexes)
+	return
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+func Test_watchdogFields(t *testing.T) {
+	indexes := []*db.BaseIndex{
+		{Field: "Level"},
+		{Field: "msg", RootName: "body"},
+		{Field: "message"},
+		{Field: "err"},
+	}
+	levelField, messageField := watchdogFields(indexes)
+	assert.Equal(t, "Level", levelField)
+	assert.Equal(t, "message", messageField)
+
+	levelField, messageField = watchdogFields([]*db.BaseIndex{{Field: "code"}})
+	assert.Equal(t, "", levelField)
+	assert.Equal(t, "", messageField)
+}
+
+func Test_watchdogFingerprint(t *testing.T) {
+	assert.Equal(t, watchdogFingerprint("user ? not found"), watchdogFingerprint("user ? not found"))
+	assert.NotEqual(t, watchdogFingerprint("user ? not found"), watchdogFingerprint("order ? not found"))
+	assert.Len(t, watchdogFingerprint("x"), 16)
+}
+
+func Test_watchdogLearn(t *testing.T) {
+	stats := db.WatchdogHour{}
+	for k := 0; k < 24; k++ {
+		count := uint64(1000)
+		if k%2 == 1 {
+			count = 1100
+		}
+		stats = watchdogLearn(stats, watchdogSample{count: count, errors: count / 100}, nil)
+	}
+	assert.Equal(t, 24, stats.Samples)
+	assert.InDelta(t, 1050, stats.Mean, 10)
+	assert.Greater(t, stats.Var, 0.0)
+	assert.InDelta(t, 0.01, stats.ErrorMean, 0.001)
+
+	// anomalies are not learned
+	assert.Equal(t, stats, watchdogLearn(stats, watchdogSample{}, []string{db.WatchdogVolumeZero}))
+	learned := watchdogLearn(stats, watchdogSample{count: 1000, errors: 500}, []string{db.WatchdogErrorRatio})
+	assert.Equal(t, stats.ErrorMean, learned.ErrorMean)
+	assert.Equal(t, 25, learned.Samples)
+}
+
+func Test_watchdogAnomalies(t *testing.T) {
+	stats := db.WatchdogHour{Samples: 24, Mean: 1000, Var: 2500, ErrorMean: 0.01, ErrorVar: 0.0001}
+
+	assert.Empty(t, watchdogAnomalies(stats, watchdogSample{count: 1050, errors: 10}, 24, 4))
+	assert.Equal(t, []string{db.WatchdogVolumeZero}, watchdogAnomalies(stats, watchdogSample{}, 24, 4))
+	assert.Equal(t, []string{db.WatchdogVolumeSpike}, watchdogAnomalies(stats, watchdogSample{count: 5000, errors: 50}, 24, 4))
+	assert.Equal(t, []string{db.WatchdogErrorRatio}, watchdogAnomalies(stats, watchdogSample{count: 1000, errors: 200}, 24, 4))
+	// still learning
+	assert.Empty(t, watchdogAnomalies(stats, watchdogSample{}, 48, 4))
+	// quiet hours do not fire on no logs
+	assert.Empty(t, watchdogAnomalies(db.WatchdogHour{Samples: 24, Mean: 1}, watchdogSample{}, 24, 4))
+}
+
+func Test_watchdogLearnPatterns(t *testing.T) {
+	patterns := map[string]watchdogPattern{"b": {}, "a": {}, "c": {}}
+	newPatterns, known := watchdogLearnPatterns(db.Strings{"c"}, patterns)
+	assert.Equal(t, []string{"a", "b"}, newPatterns)
+	assert.Equal(t, db.Strings{"c", "a", "b"}, known)
+
+	full := make(db.Strings, watchdogMaxPatterns)
+	for k := range full {
+		full[k] = watchdogFingerprint(string(rune(k)))
+	}
+	_, known = watchdogLearnPatterns(full, map[string]watchdogPattern{"z": {}})
+	assert.Len(t, known, watchdogMaxPatterns)
+	assert.Equal(t, "z", known[len(known)-1])
+}
+
+func Test_watchdogChanges(t *testing.T) {
+	firing, resolved := watchdogChanges([]string{db.WatchdogVolumeZero}, []string{db.WatchdogErrorRatio})
+	assert.Equal(t, []string{db.WatchdogErrorRatio}, firing)
+	assert.Equal(t, []string{db.WatchdogVolumeZero}, resolved)
+	firing, resolved = watchdogChanges(nil, nil)
+	assert.Empty(t, firing)
+	assert.Empty(t, resolved)
+
+	// a new error pattern is resolved with the next window
+	newError := db.WatchdogAnomaly(db.WatchdogNewError, "0123456789abcdef")
+	firing, resolved = watchdogChanges(nil, []string{newError})
+	assert.Equal(t, []string{newError}, firing)
+	assert.Empty(t, resolved)
+	firing, resolved = watchdogChanges([]string{newError}, nil)
+	assert.Empty(t, firing)
+	assert.Equal(t, []string{newError}, resolved)
+	kind, fingerprint := db.WatchdogAnomalyKind(newError)
+	assert.Equal(t, db.WatchdogNewError, kind)
+	assert.Equal(t, "0123456789abcdef", fingerprint)
+	kind, fingerprint = db.WatchdogAnomalyKind(db.WatchdogVolumeZero)
+	assert.Equal(t, db.WatchdogVolumeZero, kind)
+	assert.Empty(t, fingerprint)
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ego-component/egorm"
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/pkg/errors"
+
+	"github.com/clickvisual/clickvisual/api/internal/invoker"
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+const (
+	webhookSignatureHeader = "X-Clickvisual-Signature"
+	webhookSignaturePrefix = "sha256="
+	webhookSecretMinLen    = 16
+)
+
+// WebhookInstance instance of the alarm whose uuid labels the notification of the alertmanager
+func (i *alert) WebhookInstance(alarmUUID string) (*db.BaseInstance, error) {
+	alarm, err := db.AlarmInfoX(invoker.Db, egorm.Conds{"uuid": strings.ReplaceAll(alarmUUID, "\u0000", "")})
+	if err != nil {
+		return nil, err
+	}
+	if alarm.ID == 0 {
+		return nil, errors.Errorf("alarm %s not found", alarmUUID)
+	}
+	_, relatedList, err := db.GetAlarmTableInstanceInfo(alarm.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(relatedList) == 0 {
+		return nil, errors.Errorf("alarm %s has no table", alarmUUID)
+	}
+	return &relatedList[0].Instance, nil
+}
+
+// WebhookVerify checks the call of the alertmanager webhook against the authentication of the instance,
+// instances without authentication are rejected when app.alertWebhookRequireAuth is set
+func WebhookVerify(instance *db.BaseInstance, header http.Header, body []byte, clientIP string) error {
+	if len(instance.WebhookAllowIPs) > 0 && !webhookIPAllowed(instance.WebhookAllowIPs, clientIP) {
+		return errors.Errorf("ip %s is not allowed", clientIP)
+	}
+	switch instance.WebhookAuth {
+	case db.WebhookAuthToken:
+		token := strings.TrimSpace(strings.TrimPrefix(header.Get("Authorization"), "Bearer "))
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(instance.WebhookSecret)) != 1 {
+			return errors.New("invalid bearer token")
+		}
+	case db.WebhookAuthHMAC:
+		signature := header.Get(webhookSignatureHeader)
+		if !strings.HasPrefix(signature, webhookSignaturePrefix) {
+			return errors.Errorf("missing %s header", webhookSignatureHeader)
+		}
+		got, err := hex.DecodeString(strings.TrimPrefix(signature, webhookSignaturePrefix))
+		if err != nil || !hmac.Equal(got, WebhookSign(instance.WebhookSecret, body)) {
+			return errors.New("invalid signature")
+		}
+	default:
+		if len(instance.WebhookAllowIPs) == 0 && econf.GetBool("app.alertWebhookRequireAuth") {
+			return errors.New("the webhook of the instance has no authentication")
+		}
+	}
+	return nil
+}
+
+// WebhookSign hmac sha256 of the body keyed by the secret
+func WebhookSign(secret string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// WebhookSettingValidate checks the authentication of the webhook set for an instance
+func WebhookSettingValidate(auth int, secret string, allowIPs []string) error {
+	switch auth {
+	case db.WebhookAuthNone:
+	case db.WebhookAuthToken, db.WebhookAuthHMAC:
+		if len(secret) < webhookSecretMinLen {
+			return errors.Errorf("the webhook secret needs %d characters at least", webhookSecretMinLen)
+		}
+	default:
+		return errors.Errorf("invalid webhook authentication %d", auth)
+	}
+	for _, allowed := range allowIPs {
+		if net.ParseIP(allowed) != nil {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(allowed); err != nil {
+			return errors.Errorf("invalid ip or cidr %s", allowed)
+		}
+	}
+	return nil
+}
+
+// WebhookClientIP ip of the caller of the webhook, X-Forwarded-For is only read when the peer is one of
+// app.alertWebhookTrustedProxies, then its last address which is not a trusted proxy is the caller
+func WebhookClientIP(remoteAddr string, header http.Header) string {
+	ip, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		ip = remoteAddr
+	}
+	proxies := econf.GetStringSlice("app.alertWebhookTrustedProxies")
+	if len(proxies) == 0 || !webhookIPAllowed(proxies, ip) {
+		return ip
+	}
+	forwarded := strings.Split(strings.Join(header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		if hop == "" {
+			continue
+		}
+		if !webhookIPAllowed(proxies, hop) {
+			return hop
+		}
+		ip = hop
+	}
+	return ip
+}
+
+func webhookIPAllowed(allowIPs []string, clientIP string) bool {
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	for _, allowed := range allowIPs {
+		if allowedIP := net.ParseIP(allowed); allowedIP != nil {
+			if allowedIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		if _, ipNet, err := net.ParseCIDR(allowed); err == nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"encoding/hex"
+	"net/http"
+	"testing"
+
+	"github.com/gotomicro/ego/core/econf"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/clickvisual/clickvisual/api/internal/pkg/model/db"
+)
+
+func TestWebhookVerify(t *testing.T) {
+	secret := "0123456789abcdef"
+	body := []byte(`{"status":"firing"}`)
+	signature := "sha256=" + hex.EncodeToString(WebhookSign(secret, body))
+	tests := []struct {
+		name     string
+		instance db.BaseInstance
+		header   http.Header
+		ip       string
+		wantErr  bool
+	}{
+		{name: "none", instance: db.BaseInstance{}, header: http.Header{}, ip: "10.0.0.1"},
+		{name: "token", instance: db.BaseInstance{WebhookAuth: db.WebhookAuthToken, WebhookSecret: secret},
+			header: http.Header{"Authorization": {"Bearer " + secret}}, ip: "10.0.0.1"},
+		{name: "wrong token", instance: db.BaseInstance{WebhookAuth: db.WebhookAuthToken, WebhookSecret: secret},
+			header: http.Header{"Authorization": {"Bearer guess"}}, ip: "10.0.0.1", wantErr: true},
+		{name: "missing token", instance: db.BaseInstance{WebhookAuth: db.WebhookAuthToken, WebhookSecret: secret},
+			header: http.Header{}, ip: "10.0.0.1", wantErr: true},
+		{name: "hmac", instance: db.BaseInstance{WebhookAuth: db.WebhookAuthHMAC, WebhookSecret: secret},
+			header: http.Header{"X-Clickvisual-Signature": {signature}}, ip: "10.0.0.1"},
+		{name: "wrong hmac", instance: db.BaseInstance{WebhookAuth: db.WebhookAuthHMAC, WebhookSecret: "fedcba9876543210"},
+			header: http.Header{"X-Clickvisual-Signature": {signature}}, ip: "10.0.0.1", wantErr: true},
+		{name: "missing hmac", instance: db.BaseInstance{WebhookAuth: db.WebhookAuthHMAC, WebhookSecret: secret},
+			header: http.Header{}, ip: "10.0.0.1", wantErr: true},
+		{name: "allowed cidr", instance: db.BaseInstance{WebhookAllowIPs: db.Strings{"192.168.1.1", "10.0.0.0/8"}},
+			header: http.Header{}, ip: "10.1.2.3"},
+		{name: "allowed ip", instance: db.BaseInstance{WebhookAllowIPs: db.Strings{"192.168.1.1"}},
+			header: http.Header{}, ip: "192.168.1.1"},
+		{name: "denied ip", instance: db.BaseInstance{WebhookAllowIPs: db.Strings{"10.0.0.0/8"}},
+			header: http.Header{}, ip: "192.168.1.1", wantErr: true},
+		{name: "denied ip with token", instance: db.BaseInstance{WebhookAuth: db.WebhookAuthToken, WebhookSecret: secret, WebhookAllowIPs: db.Strings{"10.0.0.0/8"}},
+			header: http.Header{"Authorization": {"Bearer " + secret}}, ip: "192.168.1.1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := WebhookVerify(&tt.instance, tt.header, body, tt.ip)
+			assert.Equal(t, tt.wantErr, err != nil, err)
+		})
+	}
+}
+
+func TestWebhookClientIP(t *testing.T) {
+	spoofed := http.Header{"X-Forwarded-For": {"10.0.0.1"}}
+	assert.Equal(t, "192.168.1.1", WebhookClientIP("192.168.1.1:52000", spoofed))
+	assert.Equal(t, "192.168.1.1", WebhookClientIP("192.168.1.1", http.Header{}))
+
+	econf.Set("app.alertWebhookTrustedProxies", []string{"172.16.0.0/12"})
+	defer econf.Set("app.alertWebhookTrustedProxies", []string{})
+	// only the proxies are trusted, a spoofed first address is not the caller
+	assert.Equal(t, "192.168.1.1", WebhookClientIP("172.16.0.2:443", http.Header{"X-Forwarded-For": {"10.0.0.1, 192.168.1.1"}}))
+	assert.Equal(t, "192.168.1.1", WebhookClientIP("172.16.0.2:443", http.Header{"X-Forwarded-For": {"10.0.0.1", "192.168.1.1, 172.16.0.3"}}))
+	assert.Equal(t, "172.16.0.2", WebhookClientIP("172.16.0.2:443", http.Header{}))
+	assert.Equal(t, "192.168.1.1", WebhookClientIP("192.168.1.1:52000", spoofed))
+}
+
+func TestWebhookSettingValidate(t *testing.T) {
+	assert.NoError(t, WebhookSettingValidate(db.WebhookAuthNone, "", nil))
+	assert.NoError(t, WebhookSettingValidate(db.WebhookAuthToken, "0123456789abcdef", []string{"10.0.0.1", "fd00::/8"}))
+	assert.Error(t, WebhookSettingValidate(db.WebhookAuthHMAC, "short", nil))
+	assert.Error(t, WebhookSettingValidate(3, "0123456789abcdef", nil))
+	assert.Error(t, WebhookSettingValidate(db.WebhookAuthNone, "", []string{"10.0.0"}))
+}